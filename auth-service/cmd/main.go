@@ -8,9 +8,12 @@ import (
 	"trovagiocatoriAuth/internal/database"
 	"trovagiocatoriAuth/internal/database/repositories"
 	"trovagiocatoriAuth/internal/handlers"
+	"trovagiocatoriAuth/internal/invitelink"
 	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/postcatalog"
 	"trovagiocatoriAuth/internal/services"
 	"trovagiocatoriAuth/internal/sessions"
+	"trovagiocatoriAuth/internal/storage"
 )
 
 func main() {
@@ -31,26 +34,71 @@ func main() {
 	eventRepo := repositories.NewEventRepository(db.Conn)
 	notificationRepo := repositories.NewNotificationRepository(db.Conn)
 	banRepo := repositories.NewBanRepository(db.Conn)
+	inviteCodeRepo := repositories.NewInviteCodeRepository(db.Conn)
+	calendarRepo := repositories.NewCalendarRepository(db.Conn)
+	jobRunRepo := repositories.NewJobRunRepository(db.Conn)
+	reminderRepo := repositories.NewReminderRepository(db.Conn)
+	seriesRepo := repositories.NewSeriesRepository(db.Conn)
+	ratingRepo := repositories.NewRatingRepository(db.Conn)
+	resultRepo := repositories.NewMatchResultRepository(db.Conn)
+	recommendationRepo := repositories.NewRecommendationRepository(db.Conn)
+
+	// Inizializza lo storage dei file media (filesystem locale o S3)
+	blobStore, err := storage.NewBlobStore(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Error initializing media storage: %v", err)
+	}
 
 	// Inizializza il SessionManager
 	sm := sessions.NewSessionManager()
 
-	// Inizializza i servizi
+	// Inizializza i servizi e i job schedulati
 	cleanupService := services.NewNotificationCleanupService(notificationRepo)
-	cleanupService.Start()
-	defer cleanupService.Stop()
+	reminderService := services.NewMatchReminderService(reminderRepo, notificationRepo)
+	seriesService := services.NewSeriesService(seriesRepo, notificationRepo)
+	inviteService := services.NewEventInviteService(eventRepo, notificationRepo)
+	fieldAlertService := services.NewFieldAlertService(eventRepo, notificationRepo, cfg.FieldAlerts)
+	recommendationService := services.NewRecommendationService(recommendationRepo, cfg.Recommend)
+
+	// Dati dei post (titoli, date, luoghi) gestiti dal backend Python
+	postCatalog, err := postcatalog.New(cfg.PostCatalog, db.Conn)
+	if err != nil {
+		log.Fatalf("Error initializing post catalog: %v", err)
+	}
+
+	// Firma dei link di invito condivisibili alle partite
+	linkSigner, err := invitelink.NewSigner(cfg.EventLinks.SigningKey)
+	if err != nil {
+		log.Fatalf("Error initializing event link signer: %v", err)
+	}
+	linkService := services.NewEventLinkService(eventRepo, userRepo, notificationRepo, postCatalog, linkSigner)
+
+	scheduler := services.NewScheduler(jobRunRepo)
+	if err := registerJobs(scheduler, cleanupService, reminderService, seriesService, inviteService, fieldAlertService); err != nil {
+		log.Fatalf("Error registering scheduled jobs: %v", err)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
 
 
 	// Inizializza gli handlers
-	authHandler := handlers.NewAuthHandler(userRepo, banRepo, sm)
+	authHandler := handlers.NewAuthHandler(userRepo, banRepo, inviteCodeRepo, ratingRepo, linkService, blobStore, cfg.Registration, sm)
 	friendHandler := handlers.NewFriendHandler(friendRepo, userRepo, notificationRepo, sm)
-	eventHandler := handlers.NewEventHandler(eventRepo, userRepo, sm)
-	notificationHandler := handlers.NewNotificationHandler(notificationRepo, sm)
-	adminHandler := handlers.NewAdminHandler(adminRepo, userRepo, banRepo, sm)
+	eventHandler := handlers.NewEventHandler(eventRepo, userRepo, notificationRepo, cfg.Schedule, cfg.Privacy, postCatalog, sm)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo, postCatalog, sm)
+	adminHandler := handlers.NewAdminHandler(adminRepo, userRepo, banRepo, eventRepo, sm)
 	banHandler := handlers.NewBanHandler(banRepo, userRepo, sm)
+	inviteCodeHandler := handlers.NewInviteCodeHandler(inviteCodeRepo, sm)
+	calendarHandler := handlers.NewCalendarHandler(calendarRepo, cfg.Calendar, sm)
+	internalHandler := handlers.NewInternalHandler(eventRepo, userRepo, cfg.Internal)
+	seriesHandler := handlers.NewSeriesHandler(seriesRepo, eventRepo, notificationRepo, postCatalog, sm)
+	ratingHandler := handlers.NewRatingHandler(ratingRepo, sm)
+	resultHandler := handlers.NewResultHandler(resultRepo, eventRepo, notificationRepo, postCatalog, sm)
+	recommendationHandler := handlers.NewRecommendationHandler(recommendationService, sm)
+	eventLinkHandler := handlers.NewEventLinkHandler(eventRepo, linkService, cfg.EventLinks, sm)
 
 	// Setup routes
-	setupRoutes(authHandler, friendHandler, eventHandler, notificationHandler, adminHandler, banHandler, userRepo, sm)
+	setupRoutes(authHandler, friendHandler, eventHandler, notificationHandler, adminHandler, banHandler, inviteCodeHandler, calendarHandler, internalHandler, seriesHandler, ratingHandler, resultHandler, recommendationHandler, eventLinkHandler, userRepo, sm)
 
 
 
@@ -60,6 +108,28 @@ func main() {
 	}
 }
 
+func registerJobs(scheduler *services.Scheduler, cleanupService *services.NotificationCleanupService, reminderService *services.MatchReminderService, seriesService *services.SeriesService, inviteService *services.EventInviteService, fieldAlertService *services.FieldAlertService) error {
+	jobs := []struct {
+		name string
+		spec string
+		run  services.JobFunc
+	}{
+		{"notification_cleanup", "@hourly", cleanupService.Run},
+		{"match_reminders", "*/5 * * * *", reminderService.Run},
+		{"series_occurrences", "*/15 * * * *", seriesService.Run},
+		{"event_invites", "*/15 * * * *", inviteService.Run},
+		{"field_alerts", "*/10 * * * *", fieldAlertService.Run},
+		{"job_runs_cleanup", "30 3 * * *", scheduler.PruneHistory},
+	}
+
+	for _, job := range jobs {
+		if err := scheduler.Register(job.name, job.spec, job.run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func setupRoutes(
 	authHandler *handlers.AuthHandler,
 	friendHandler *handlers.FriendHandler,
@@ -67,6 +137,14 @@ func setupRoutes(
 	notificationHandler *handlers.NotificationHandler,
 	adminHandler *handlers.AdminHandler,
 	banHandler *handlers.BanHandler,
+	inviteCodeHandler *handlers.InviteCodeHandler,
+	calendarHandler *handlers.CalendarHandler,
+	internalHandler *handlers.InternalHandler,
+	seriesHandler *handlers.SeriesHandler,
+	ratingHandler *handlers.RatingHandler,
+	resultHandler *handlers.ResultHandler,
+	recommendationHandler *handlers.RecommendationHandler,
+	eventLinkHandler *handlers.EventLinkHandler,
 	userRepo *repositories.UserRepository,
 	sm *sessions.SessionManager,
 ) {
@@ -78,6 +156,9 @@ func setupRoutes(
 	// ========== ENDPOINT PROFILO UTENTE ==========
 	http.HandleFunc("/profile", authHandler.ProfileBySessionHandler())
 	http.HandleFunc("/images/", authHandler.ServeProfilePicture())
+	http.HandleFunc("/profile/picture", authHandler.ProfilePictureHandler())
+	http.HandleFunc("/profile/picture/url", authHandler.ProfilePictureURLHandler())
+	http.HandleFunc(storage.SignedMediaPath, authHandler.ServeSignedMediaHandler())
 	http.HandleFunc("/api/user", authHandler.UserHandler())
 	http.HandleFunc("/api/user/by-email", authHandler.GetUserByEmailHandler())
 	http.HandleFunc("/update-password", authHandler.UpdatePasswordHandler())
@@ -87,18 +168,44 @@ func setupRoutes(
 	http.HandleFunc("/favorites/remove", eventHandler.RemoveFavoriteHandler())
 	http.HandleFunc("/favorites/check/", eventHandler.CheckFavoriteHandler())
 	http.HandleFunc("/favorites", eventHandler.GetUserFavoritesHandler())
+	http.HandleFunc("/favorites/collections", eventHandler.FavoriteCollectionsHandler())
+	http.HandleFunc("/favorites/collections/", eventHandler.FavoriteCollectionRoutesHandler())
+	http.HandleFunc("/favorites/shared/", eventHandler.SharedFavoriteCollectionHandler())
+	http.HandleFunc("/favorites/fields", eventHandler.FavoriteFieldsHandler())
+	http.HandleFunc("/favorites/fields/", eventHandler.FavoriteFieldRoutesHandler())
 
 
 
 	// ========== ENDPOINT PARTECIPAZIONE EVENTI ==========
 	http.HandleFunc("/events/join", eventHandler.JoinEventHandler())
 	http.HandleFunc("/events/leave", eventHandler.LeaveEventHandler())
+	http.HandleFunc("/events/rsvp", eventHandler.RSVPHandler())
+	http.HandleFunc("/events/attendance", eventHandler.MarkAttendanceHandler())
 	http.HandleFunc("/events/check/", eventHandler.CheckParticipationHandler())
-	http.HandleFunc("/events/", eventHandler.GetEventParticipantsHandler())
+	http.HandleFunc("/events/recommended", recommendationHandler.RecommendedEventsHandler())
+	http.HandleFunc("/events/", eventHandler.EventRoutesHandler())
 
 	// ========== ENDPOINT PARTECIPAZIONI UTENTE ==========
 	http.HandleFunc("/user/participations", eventHandler.GetUserParticipationsHandler())
+	http.HandleFunc("/user/schedule", eventHandler.UserScheduleHandler())
 	http.HandleFunc("/user/email", authHandler.GetUserEmailHandler())
+	http.HandleFunc("/user/privacy", authHandler.PrivacySettingsHandler())
+
+	// ========== ENDPOINT CALENDARIO ==========
+	http.HandleFunc("/calendar/subscription", calendarHandler.SubscriptionHandler())
+	http.HandleFunc("/calendar/events/", calendarHandler.EventICSHandler())
+	http.HandleFunc("/calendar/", calendarHandler.FeedHandler())
+
+	// ========== ENDPOINT SERIE RICORRENTI ==========
+	http.HandleFunc("/series", seriesHandler.SeriesListHandler())
+	http.HandleFunc("/series/", seriesHandler.SeriesRoutesHandler())
+
+	// ========== ENDPOINT VALUTAZIONI GIOCATORI ==========
+	http.HandleFunc("/ratings/events/", ratingHandler.EventRatingsHandler())
+	http.HandleFunc("/ratings/users/", ratingHandler.UserReputationHandler())
+
+	// ========== ENDPOINT RISULTATI E CLASSIFICHE ==========
+	http.HandleFunc("/results/", resultHandler.ResultRoutesHandler())
 
 	// ========== ENDPOINT AMICI ==========
 	http.HandleFunc("/friends/request", friendHandler.SendFriendRequestHandler())
@@ -111,17 +218,28 @@ func setupRoutes(
 	http.HandleFunc("/friends/search", friendHandler.SearchUsersHandler())
 	http.HandleFunc("/friends/sent-requests", friendHandler.GetSentFriendRequestsHandler())
 	http.HandleFunc("/friends/cancel", friendHandler.CancelFriendRequestHandler())
+	http.HandleFunc("/friends/groups", friendHandler.FriendGroupsHandler())
+	http.HandleFunc("/friends/groups/", friendHandler.FriendGroupRoutesHandler())
 
 	// ========== ENDPOINT INVITI EVENTI ==========
 	http.HandleFunc("/events/invite", eventHandler.SendEventInviteHandler())
 	http.HandleFunc("/events/invites", eventHandler.GetEventInvitesHandler())
+	http.HandleFunc("/events/invites/sent", eventHandler.GetSentEventInvitesHandler())
 	http.HandleFunc("/events/invite/accept", eventHandler.AcceptEventInviteHandler())
 	http.HandleFunc("/events/invite/reject", eventHandler.RejectEventInviteHandler())
+	http.HandleFunc("/events/invite/revoke", eventHandler.RevokeEventInviteHandler())
+	http.HandleFunc("/events/invite/bulk", eventHandler.BulkEventInviteHandler())
 	http.HandleFunc("/friends/available-for-invite", eventHandler.GetAvailableFriendsForInviteHandler())
 
+	// ========== ENDPOINT LINK DI INVITO ==========
+	http.HandleFunc("/event-links", eventLinkHandler.EventLinksHandler())
+	http.HandleFunc("/event-links/", eventLinkHandler.EventLinkRoutesHandler())
+	http.HandleFunc("/join/", eventLinkHandler.JoinEventLinkHandler())
+
 	// ========== ENDPOINT NOTIFICHE ==========
 	http.HandleFunc("/notifications", notificationHandler.GetNotificationsHandler())
 	http.HandleFunc("/notifications/summary", notificationHandler.GetNotificationsSummaryHandler())
+	http.HandleFunc("/notifications/preferences", notificationHandler.PreferencesHandler())
 	http.HandleFunc("/notifications/read", notificationHandler.MarkNotificationAsReadHandler())
 	http.HandleFunc("/notifications/read-all", notificationHandler.MarkAllNotificationsAsReadHandler())
 	http.HandleFunc("/notifications/delete", notificationHandler.DeleteNotificationHandler())
@@ -132,6 +250,7 @@ func setupRoutes(
 	http.HandleFunc("/admin/users", middleware.RequireAdmin(userRepo, sm)(adminHandler.AdminGetUsersHandler()))
 	http.HandleFunc("/admin/users/", middleware.RequireAdmin(userRepo, sm)(adminHandler.AdminToggleUserStatusHandler()))
 	http.HandleFunc("/admin/stats", middleware.RequireAdmin(userRepo, sm)(adminHandler.AdminStatsHandler()))
+	http.HandleFunc("/admin/ratings/recalculate", middleware.RequireAdmin(userRepo, sm)(resultHandler.RecalculateRatingsHandler()))
 
 	// ========== ENDPOINT BAN UTENTI ==========
 	http.HandleFunc("/admin/bans", middleware.RequireAdmin(userRepo, sm)(banHandler.GetActiveBansHandler()))
@@ -140,4 +259,12 @@ func setupRoutes(
 	http.HandleFunc("/admin/ban/info/", middleware.RequireAdmin(userRepo, sm)(banHandler.GetUserBanHandler()))
 	http.HandleFunc("/admin/ban/history/", middleware.RequireAdmin(userRepo, sm)(banHandler.GetUserBanHistoryHandler()))
 	http.HandleFunc("/admin/ban/stats", middleware.RequireAdmin(userRepo, sm)(banHandler.GetBanStatsHandler()))
+
+	// ========== ENDPOINT CODICI INVITO ==========
+	http.HandleFunc("/admin/invite-codes", middleware.RequireAdmin(userRepo, sm)(inviteCodeHandler.InviteCodesHandler()))
+	http.HandleFunc("/admin/invite-codes/", middleware.RequireAdmin(userRepo, sm)(inviteCodeHandler.InviteCodeDetailHandler()))
+
+	// ========== ENDPOINT INTERNI (backend Python) ==========
+	http.HandleFunc("/internal/events/cancel", internalHandler.CancelEventHandler())
+	http.HandleFunc("/internal/events/participants/count", internalHandler.EventParticipantCountHandler())
 }
\ No newline at end of file
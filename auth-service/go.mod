@@ -4,4 +4,6 @@ go 1.20
 
 require github.com/lib/pq v1.10.9 //Permette alle applicazioni Go di connettersi e interagire con database PostgreSQL
 
-require golang.org/x/crypto v0.32.0 // indirect
+require golang.org/x/crypto v0.32.0
+
+require golang.org/x/image v0.15.0
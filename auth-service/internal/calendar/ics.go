@@ -0,0 +1,157 @@
+package calendar
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType è il MIME type dei file iCalendar (RFC 5545)
+const ContentType = "text/calendar; charset=utf-8"
+
+// Valori della proprietà STATUS di un VEVENT
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+const (
+	productID = "-//Trovagiocatori//Calendario partite//IT"
+	// RFC 5545 §3.1: le righe non devono superare i 75 ottetti (CRLF escluso)
+	maxLineOctets = 75
+	utcLayout     = "20060102T150405Z"
+)
+
+// Event è una partita esportata come VEVENT
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Latitude     float64
+	Longitude    float64
+	HasGeo       bool
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time // usato anche come DTSTAMP, così il feed è deterministico
+	Status       string
+}
+
+// Calendar è un VCALENDAR con un nome visualizzato dai client
+type Calendar struct {
+	Name string
+	// RefreshInterval suggerisce ai client ogni quanto ricaricare il feed (0 = non indicato)
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// Encode serializza il calendario in formato iCalendar. A parità di input
+// l'output è identico byte per byte, quindi può essere usato per calcolare l'ETag.
+func (c *Calendar) Encode() []byte {
+	var buf bytes.Buffer
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:"+productID)
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	writeLine(&buf, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(&buf, "X-WR-CALNAME:"+escapeText(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		interval := formatDuration(c.RefreshInterval)
+		writeLine(&buf, "REFRESH-INTERVAL;VALUE=DURATION:"+interval)
+		writeLine(&buf, "X-PUBLISHED-TTL:"+interval)
+	}
+
+	for _, event := range c.Events {
+		writeEvent(&buf, event)
+	}
+
+	writeLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+func writeEvent(buf *bytes.Buffer, e Event) {
+	writeLine(buf, "BEGIN:VEVENT")
+	writeLine(buf, "UID:"+e.UID)
+	writeLine(buf, "DTSTAMP:"+formatUTC(e.LastModified))
+	writeLine(buf, "LAST-MODIFIED:"+formatUTC(e.LastModified))
+	writeLine(buf, "DTSTART:"+formatUTC(e.Start))
+	writeLine(buf, "DTEND:"+formatUTC(e.End))
+	writeLine(buf, "SUMMARY:"+escapeText(e.Summary))
+	if e.Description != "" {
+		writeLine(buf, "DESCRIPTION:"+escapeText(e.Description))
+	}
+	if e.Location != "" {
+		writeLine(buf, "LOCATION:"+escapeText(e.Location))
+	}
+	if e.HasGeo {
+		writeLine(buf, "GEO:"+formatCoordinate(e.Latitude)+";"+formatCoordinate(e.Longitude))
+	}
+	if e.Status != "" {
+		writeLine(buf, "STATUS:"+e.Status)
+	}
+	writeLine(buf, "TRANSP:OPAQUE")
+	writeLine(buf, "END:VEVENT")
+}
+
+// writeLine scrive una content line ripiegandola ogni 75 ottetti senza
+// spezzare i caratteri UTF-8 multibyte
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Le righe di continuazione iniziano con uno spazio che conta nel limite
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText applica l'escaping dei valori TEXT (RFC 5545 §3.3.11)
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+// formatDuration converte una durata in formato iCalendar (es. PT1H30M)
+func formatDuration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	out := "PT"
+	if hours := minutes / 60; hours > 0 {
+		out += strconv.Itoa(hours) + "H"
+	}
+	if rest := minutes % 60; rest > 0 {
+		out += strconv.Itoa(rest) + "M"
+	}
+	return out
+}
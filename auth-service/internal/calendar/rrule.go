@@ -0,0 +1,214 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequenze supportate dalle regole di ricorrenza
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// Numero massimo di periodi esaminati durante l'espansione di una regola
+const maxRecurrencePeriods = 5000
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule è un sottoinsieme della RRULE di RFC 5545: FREQ (DAILY, WEEKLY,
+// MONTHLY), INTERVAL, BYDAY (solo con WEEKLY), BYMONTHDAY (solo con MONTHLY),
+// COUNT e UNTIL. Gli orari sono "floating", come data_partita + ora_partita.
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      time.Time
+}
+
+// ParseRRule interpreta una regola come "FREQ=WEEKLY;BYDAY=TH" (il prefisso
+// "RRULE:" è facoltativo)
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("regola di ricorrenza vuota")
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("parte non valida nella regola: %q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		switch key {
+		case "FREQ":
+			if val != FreqDaily && val != FreqWeekly && val != FreqMonthly {
+				return nil, fmt.Errorf("frequenza non supportata: %s", val)
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > 52 {
+				return nil, fmt.Errorf("INTERVAL non valido: %s", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("COUNT non valido: %s", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return nil, fmt.Errorf("giorno non valido in BYDAY: %s", code)
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, err := strconv.Atoi(item)
+				if err != nil || day < 1 || day > 31 {
+					return nil, fmt.Errorf("giorno non valido in BYMONTHDAY: %s", item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, day)
+			}
+		case "WKST":
+			if val != "MO" {
+				return nil, fmt.Errorf("WKST diverso da MO non supportato")
+			}
+		default:
+			return nil, fmt.Errorf("parametro non supportato: %s", key)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, fmt.Errorf("FREQ è obbligatorio")
+	case rule.Count > 0 && !rule.Until.IsZero():
+		return nil, fmt.Errorf("COUNT e UNTIL non possono essere usati insieme")
+	case len(rule.ByDay) > 0 && rule.Freq != FreqWeekly:
+		return nil, fmt.Errorf("BYDAY è supportato solo con FREQ=WEEKLY")
+	case len(rule.ByMonthDay) > 0 && rule.Freq != FreqMonthly:
+		return nil, fmt.Errorf("BYMONTHDAY è supportato solo con FREQ=MONTHLY")
+	}
+
+	sort.Slice(rule.ByDay, func(i, j int) bool { return weekdayIndex(rule.ByDay[i]) < weekdayIndex(rule.ByDay[j]) })
+	sort.Ints(rule.ByMonthDay)
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// Una data senza orario include l'intera giornata
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("UNTIL non valido: %s", value)
+}
+
+// Between restituisce le occorrenze della regola successive ad after e non
+// oltre before; le date precedenti a dtstart non vengono mai generate
+func (r *RRule) Between(dtstart, after, before time.Time) []time.Time {
+	var occurrences []time.Time
+	emitted := 0
+
+	for period := 0; period < maxRecurrencePeriods; period++ {
+		for _, candidate := range r.periodCandidates(dtstart, period) {
+			if candidate.Before(dtstart) {
+				continue
+			}
+			if (!r.Until.IsZero() && candidate.After(r.Until)) || candidate.After(before) {
+				return occurrences
+			}
+			emitted++
+			if r.Count > 0 && emitted > r.Count {
+				return occurrences
+			}
+			if candidate.After(after) {
+				occurrences = append(occurrences, candidate)
+			}
+		}
+	}
+	return occurrences
+}
+
+// periodCandidates restituisce in ordine le date candidate del periodo n-esimo
+// (giorno, settimana o mese) con l'orario di dtstart
+func (r *RRule) periodCandidates(dtstart time.Time, n int) []time.Time {
+	hour, min, sec := dtstart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, dtstart.Location())
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+		return []time.Time{dtstart.AddDate(0, 0, n*r.Interval)}
+
+	case FreqWeekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{dtstart.Weekday()}
+		}
+		monday := dtstart.AddDate(0, 0, -weekdayIndex(dtstart.Weekday())+7*n*r.Interval)
+		candidates := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			d := monday.AddDate(0, 0, weekdayIndex(day))
+			candidates = append(candidates, at(d.Year(), d.Month(), d.Day()))
+		}
+		return candidates
+
+	case FreqMonthly:
+		days := r.ByMonthDay
+		if len(days) == 0 {
+			days = []int{dtstart.Day()}
+		}
+		first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(n*r.Interval), 1, 0, 0, 0, 0, dtstart.Location())
+		var candidates []time.Time
+		for _, day := range days {
+			// I giorni inesistenti nel mese (es. 31 febbraio) vengono saltati
+			if day > daysInMonth(first) {
+				continue
+			}
+			candidates = append(candidates, at(first.Year(), first.Month(), day))
+		}
+		return candidates
+	}
+	return nil
+}
+
+// weekdayIndex numera i giorni a partire dal lunedì (0) fino alla domenica (6)
+func weekdayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func daysInMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
@@ -0,0 +1,227 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  RRule
+	}{
+		{
+			name:  "prefisso e BYDAY ordinato dal lunedì",
+			value: "RRULE:FREQ=WEEKLY;BYDAY=SU,TH,MO",
+			want:  RRule{Freq: FreqWeekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Thursday, time.Sunday}},
+		},
+		{
+			name:  "minuscole e BYMONTHDAY ordinato",
+			value: "freq=monthly;interval=2;bymonthday=31,1",
+			want:  RRule{Freq: FreqMonthly, Interval: 2, ByMonthDay: []int{1, 31}},
+		},
+		{
+			name:  "UNTIL come data include l'intera giornata",
+			value: "FREQ=DAILY;UNTIL=20240131",
+			want:  RRule{Freq: FreqDaily, Interval: 1, Until: time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)},
+		},
+		{
+			name:  "UNTIL con orario UTC",
+			value: "FREQ=DAILY;UNTIL=20240131T180000Z",
+			want:  RRule{Freq: FreqDaily, Interval: 1, Until: date(2024, 1, 31, 18)},
+		},
+		{
+			name:  "UNTIL con orario floating",
+			value: "FREQ=DAILY;UNTIL=20240131T180000",
+			want:  RRule{Freq: FreqDaily, Interval: 1, Until: date(2024, 1, 31, 18)},
+		},
+		{
+			name:  "COUNT e WKST=MO",
+			value: "FREQ=WEEKLY;COUNT=10;WKST=MO",
+			want:  RRule{Freq: FreqWeekly, Interval: 1, Count: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.value)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.value, err)
+			}
+			if rule.Freq != tt.want.Freq || rule.Interval != tt.want.Interval || rule.Count != tt.want.Count {
+				t.Errorf("ParseRRule(%q) = %+v, atteso %+v", tt.value, rule, tt.want)
+			}
+			if !rule.Until.Equal(tt.want.Until) {
+				t.Errorf("Until = %v, atteso %v", rule.Until, tt.want.Until)
+			}
+			if len(rule.ByDay) != len(tt.want.ByDay) {
+				t.Fatalf("ByDay = %v, atteso %v", rule.ByDay, tt.want.ByDay)
+			}
+			for i := range rule.ByDay {
+				if rule.ByDay[i] != tt.want.ByDay[i] {
+					t.Errorf("ByDay = %v, atteso %v", rule.ByDay, tt.want.ByDay)
+					break
+				}
+			}
+			if len(rule.ByMonthDay) != len(tt.want.ByMonthDay) {
+				t.Fatalf("ByMonthDay = %v, atteso %v", rule.ByMonthDay, tt.want.ByMonthDay)
+			}
+			for i := range rule.ByMonthDay {
+				if rule.ByMonthDay[i] != tt.want.ByMonthDay[i] {
+					t.Errorf("ByMonthDay = %v, atteso %v", rule.ByMonthDay, tt.want.ByMonthDay)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"RRULE:",
+		"FREQ",
+		"FREQ=",
+		"FREQ=YEARLY",
+		"BYDAY=MO",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;INTERVAL=53",
+		"FREQ=WEEKLY;COUNT=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;UNTIL=2024-01-01",
+		"FREQ=WEEKLY;WKST=SU",
+		"FREQ=MONTHLY;BYSETPOS=1",
+	} {
+		if rule, err := ParseRRule(value); err == nil {
+			t.Errorf("ParseRRule(%q) = %+v, atteso errore", value, rule)
+		}
+	}
+}
+
+func TestRRuleBetween(t *testing.T) {
+	farFuture := date(2030, 1, 1, 0)
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		after   time.Time // zero: un secondo prima di dtstart
+		before  time.Time // zero: farFuture
+		want    []time.Time
+	}{
+		{
+			name:    "BYMONTHDAY=31 salta febbraio e i mesi da 30 giorni",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=4",
+			dtstart: date(2024, 1, 31, 20),
+			want:    []time.Time{date(2024, 1, 31, 20), date(2024, 3, 31, 20), date(2024, 5, 31, 20), date(2024, 7, 31, 20)},
+		},
+		{
+			name:    "mensile dal 31 senza BYMONTHDAY",
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: date(2024, 1, 31, 20),
+			want:    []time.Time{date(2024, 1, 31, 20), date(2024, 3, 31, 20), date(2024, 5, 31, 20)},
+		},
+		{
+			name:    "BYMONTHDAY=29 in un anno bisestile",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=29;COUNT=3",
+			dtstart: date(2024, 1, 29, 18),
+			want:    []time.Time{date(2024, 1, 29, 18), date(2024, 2, 29, 18), date(2024, 3, 29, 18)},
+		},
+		{
+			name:    "BYMONTHDAY=29 in un anno non bisestile",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=29;COUNT=3",
+			dtstart: date(2023, 1, 29, 18),
+			want:    []time.Time{date(2023, 1, 29, 18), date(2023, 3, 29, 18), date(2023, 4, 29, 18)},
+		},
+		{
+			// 3 gennaio 2024 è un mercoledì: il lunedì 1 precede dtstart e non conta
+			name:    "COUNT con BYDAY e dtstart a metà settimana",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3",
+			dtstart: date(2024, 1, 3, 19),
+			want:    []time.Time{date(2024, 1, 5, 19), date(2024, 1, 8, 19), date(2024, 1, 12, 19)},
+		},
+		{
+			name:    "COUNT con BYDAY che include il giorno di dtstart",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3",
+			dtstart: date(2024, 1, 3, 19),
+			want:    []time.Time{date(2024, 1, 3, 19), date(2024, 1, 8, 19), date(2024, 1, 10, 19)},
+		},
+		{
+			name:    "INTERVAL=2 con BYDAY e dtstart a metà settimana",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=3",
+			dtstart: date(2024, 1, 3, 19),
+			want:    []time.Time{date(2024, 1, 4, 19), date(2024, 1, 16, 19), date(2024, 1, 18, 19)},
+		},
+		{
+			name:    "COUNT conta anche le occorrenze precedenti alla finestra",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtstart: date(2024, 1, 4, 20),
+			after:   date(2024, 1, 10, 0),
+			want:    []time.Time{date(2024, 1, 11, 20), date(2024, 1, 18, 20)},
+		},
+		{
+			name:    "UNTIL come data include l'ultimo giorno",
+			rule:    "FREQ=WEEKLY;UNTIL=20240118",
+			dtstart: date(2024, 1, 4, 20),
+			want:    []time.Time{date(2024, 1, 4, 20), date(2024, 1, 11, 20), date(2024, 1, 18, 20)},
+		},
+		{
+			name:    "UNTIL con orario precedente all'ultima occorrenza",
+			rule:    "FREQ=WEEKLY;UNTIL=20240118T190000Z",
+			dtstart: date(2024, 1, 4, 20),
+			want:    []time.Time{date(2024, 1, 4, 20), date(2024, 1, 11, 20)},
+		},
+		{
+			name:    "UNTIL coincidente con l'ultima occorrenza",
+			rule:    "FREQ=WEEKLY;UNTIL=20240118T200000Z",
+			dtstart: date(2024, 1, 4, 20),
+			want:    []time.Time{date(2024, 1, 4, 20), date(2024, 1, 11, 20), date(2024, 1, 18, 20)},
+		},
+		{
+			name:    "before limita una regola senza fine",
+			rule:    "FREQ=DAILY;INTERVAL=2",
+			dtstart: date(2024, 1, 1, 10),
+			before:  date(2024, 1, 7, 10),
+			want:    []time.Time{date(2024, 1, 1, 10), date(2024, 1, 3, 10), date(2024, 1, 5, 10), date(2024, 1, 7, 10)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			after, before := tt.after, tt.before
+			if after.IsZero() {
+				after = tt.dtstart.Add(-time.Second)
+			}
+			if before.IsZero() {
+				before = farFuture
+			}
+
+			got := rule.Between(tt.dtstart, after, before)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Between = %v, atteso %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("Between = %v, atteso %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
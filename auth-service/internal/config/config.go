@@ -3,11 +3,24 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
+	Database     DatabaseConfig
+	Server       ServerConfig
+	Registration RegistrationConfig
+	Storage      StorageConfig
+	Calendar     CalendarConfig
+	Internal     InternalConfig
+	Schedule     ScheduleConfig
+	Recommend    RecommendationConfig
+	EventLinks   EventLinkConfig
+	Privacy      PrivacyConfig
+	PostCatalog  PostCatalogConfig
+	FieldAlerts  FieldAlertConfig
 }
 
 type DatabaseConfig struct {
@@ -21,6 +34,106 @@ type ServerConfig struct {
 	Port string
 }
 
+// RegistrationConfig controlla le modalità di registrazione dei nuovi utenti
+type RegistrationConfig struct {
+	InviteOnly bool // se true la registrazione richiede un codice invito valido
+}
+
+// StorageConfig configura dove vengono salvati i file media degli utenti
+type StorageConfig struct {
+	Backend       string // "local" oppure "s3"
+	LocalDir      string
+	PublicBaseURL string // prefisso degli URL firmati dello storage locale
+	SigningKey    string // chiave condivisa tra le repliche per firmare gli URL
+	S3Endpoint    string
+	S3Bucket      string
+	S3Region      string
+	S3AccessKey   string
+	S3SecretKey   string
+}
+
+// CalendarConfig configura il feed iCalendar delle partite
+type CalendarConfig struct {
+	PublicBaseURL        string // prefisso degli URL di iscrizione (vuoto = host della richiesta)
+	TimeZone             string // fuso orario di data_partita/ora_partita
+	EventDurationMinutes int    // durata presunta di una partita, i post non hanno un orario di fine
+}
+
+// InternalConfig protegge gli endpoint chiamati dagli altri servizi (backend Python)
+type InternalConfig struct {
+	APIToken string // segreto condiviso inviato nell'header X-Internal-Token; vuoto = endpoint disabilitati
+}
+
+// ScheduleConfig configura la durata presunta delle partite usata per
+// individuare gli impegni sovrapposti
+type ScheduleConfig struct {
+	DefaultDurationMinutes int            // durata degli sport non elencati
+	SportDurationMinutes   map[string]int // durata per sport, es. "Calcio=90,Tennis=120"
+}
+
+// MatchDuration restituisce la durata presunta di una partita dello sport indicato
+func (c ScheduleConfig) MatchDuration(sport string) time.Duration {
+	for name, minutes := range c.SportDurationMinutes {
+		if strings.EqualFold(name, strings.TrimSpace(sport)) {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+	return time.Duration(c.DefaultDurationMinutes) * time.Minute
+}
+
+// MaxMatchDuration restituisce la durata più lunga tra quelle configurate
+func (c ScheduleConfig) MaxMatchDuration() time.Duration {
+	longest := c.DefaultDurationMinutes
+	for _, minutes := range c.SportDurationMinutes {
+		if minutes > longest {
+			longest = minutes
+		}
+	}
+	return time.Duration(longest) * time.Minute
+}
+
+// RecommendationConfig configura i pesi dei segnali usati per consigliare le
+// partite e la cache dei risultati
+type RecommendationConfig struct {
+	SportWeight     float64 // sport già giocati
+	LevelWeight     float64 // livello delle partite giocate
+	FavoriteWeight  float64 // post preferiti e sport dei preferiti
+	FriendsWeight   float64 // amici già iscritti
+	DistanceWeight  float64 // vicinanza ai campi abituali
+	CapacityWeight  float64 // posti ancora liberi
+	MaxDistanceKm   float64 // oltre questa distanza la vicinanza non conta
+	HorizonDays     int     // quanti giorni avanti cercare le partite
+	CacheTTLSeconds int
+}
+
+// EventLinkConfig configura i link di invito alle partite condivisibili fuori dall'app
+type EventLinkConfig struct {
+	SigningKey    string // chiave condivisa tra le repliche per firmare i link
+	PublicBaseURL string // prefisso degli URL dei link (vuoto = host della richiesta)
+}
+
+// PrivacyConfig controlla l'accesso alle liste dei partecipanti
+type PrivacyConfig struct {
+	PublicParticipantLists bool // se true anche chi non è loggato vede la lista, come uno sconosciuto
+}
+
+// PostCatalogConfig configura da dove vengono letti i dati dei post (titolo,
+// data, luogo) gestiti dal backend Python
+type PostCatalogConfig struct {
+	Backend         string // "db" (tabelle condivise posts/sport_fields) oppure "http" (API del backend Python)
+	BaseURL         string // URL del backend Python per il backend "http"
+	TimeoutMs       int    // timeout di ogni chiamata HTTP
+	CacheSize       int    // numero massimo di post in cache
+	CacheTTLSeconds int
+}
+
+// FieldAlertConfig configura gli avvisi di nuove partite nei campi preferiti
+type FieldAlertConfig struct {
+	MinIntervalMinutes int // intervallo minimo tra due riepiloghi allo stesso utente
+	LookbackHours      int // i post creati prima di questa finestra non generano avvisi
+	MaxPostsPerDigest  int // partite elencate nel messaggio del riepilogo
+}
+
 func LoadConfig() *Config {
 	config := &Config{
 		Database: DatabaseConfig{
@@ -32,6 +145,62 @@ func LoadConfig() *Config {
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8080"),
 		},
+		Registration: RegistrationConfig{
+			InviteOnly: getEnvBool("REGISTRATION_INVITE_ONLY", false),
+		},
+		Storage: StorageConfig{
+			Backend:       getEnv("STORAGE_BACKEND", "local"),
+			LocalDir:      getEnv("STORAGE_LOCAL_DIR", "uploads"),
+			PublicBaseURL: getEnv("MEDIA_PUBLIC_BASE_URL", ""),
+			SigningKey:    getEnv("MEDIA_SIGNING_KEY", ""),
+			S3Endpoint:    getEnv("S3_ENDPOINT", ""),
+			S3Bucket:      getEnv("S3_BUCKET", ""),
+			S3Region:      getEnv("S3_REGION", "us-east-1"),
+			S3AccessKey:   getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:   getEnv("S3_SECRET_KEY", ""),
+		},
+		Calendar: CalendarConfig{
+			PublicBaseURL:        getEnv("CALENDAR_PUBLIC_BASE_URL", ""),
+			TimeZone:             getEnv("CALENDAR_TIMEZONE", "Europe/Rome"),
+			EventDurationMinutes: getEnvInt("CALENDAR_EVENT_DURATION_MINUTES", 90),
+		},
+		Internal: InternalConfig{
+			APIToken: getEnv("INTERNAL_API_TOKEN", ""),
+		},
+		Schedule: ScheduleConfig{
+			DefaultDurationMinutes: getEnvInt("SCHEDULE_DEFAULT_MATCH_MINUTES", 90),
+			SportDurationMinutes:   getEnvDurations("SCHEDULE_SPORT_MATCH_MINUTES", "Calcio=90,Calcetto=60,Basket=90,Pallavolo=120,Tennis=120,Padel=90"),
+		},
+		Recommend: RecommendationConfig{
+			SportWeight:     getEnvFloat("RECOMMEND_WEIGHT_SPORT", 3),
+			LevelWeight:     getEnvFloat("RECOMMEND_WEIGHT_LEVEL", 1),
+			FavoriteWeight:  getEnvFloat("RECOMMEND_WEIGHT_FAVORITE", 2),
+			FriendsWeight:   getEnvFloat("RECOMMEND_WEIGHT_FRIENDS", 3),
+			DistanceWeight:  getEnvFloat("RECOMMEND_WEIGHT_DISTANCE", 2),
+			CapacityWeight:  getEnvFloat("RECOMMEND_WEIGHT_CAPACITY", 1),
+			MaxDistanceKm:   getEnvFloat("RECOMMEND_MAX_DISTANCE_KM", 30),
+			HorizonDays:     getEnvInt("RECOMMEND_HORIZON_DAYS", 30),
+			CacheTTLSeconds: getEnvInt("RECOMMEND_CACHE_SECONDS", 600),
+		},
+		EventLinks: EventLinkConfig{
+			SigningKey:    getEnv("EVENT_LINK_SIGNING_KEY", ""),
+			PublicBaseURL: getEnv("EVENT_LINK_PUBLIC_BASE_URL", ""),
+		},
+		Privacy: PrivacyConfig{
+			PublicParticipantLists: getEnvBool("PARTICIPANTS_PUBLIC_LISTS", false),
+		},
+		PostCatalog: PostCatalogConfig{
+			Backend:         getEnv("POST_CATALOG_BACKEND", "db"),
+			BaseURL:         getEnv("POST_CATALOG_BASE_URL", "http://backend_python:8000"),
+			TimeoutMs:       getEnvInt("POST_CATALOG_TIMEOUT_MS", 2000),
+			CacheSize:       getEnvInt("POST_CATALOG_CACHE_SIZE", 5000),
+			CacheTTLSeconds: getEnvInt("POST_CATALOG_CACHE_SECONDS", 300),
+		},
+		FieldAlerts: FieldAlertConfig{
+			MinIntervalMinutes: getEnvInt("FIELD_ALERTS_MIN_INTERVAL_MINUTES", 360),
+			LookbackHours:      getEnvInt("FIELD_ALERTS_LOOKBACK_HOURS", 24),
+			MaxPostsPerDigest:  getEnvInt("FIELD_ALERTS_MAX_POSTS_PER_DIGEST", 3),
+		},
 	}
 
 	// Verifica che la password sia presente
@@ -49,6 +218,63 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean value for %s: %q, using default %t", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("Invalid integer value for %s: %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		log.Printf("Invalid number value for %s: %q, using default %g", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvDurations legge un elenco "sport=minuti" separato da virgole
+func getEnvDurations(key, fallback string) map[string]int {
+	durations := make(map[string]int)
+	for _, entry := range strings.Split(getEnv(key, fallback), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		sport, value, found := strings.Cut(entry, "=")
+		minutes, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || strings.TrimSpace(sport) == "" || err != nil || minutes <= 0 {
+			log.Printf("Invalid match duration in %s: %q, ignoring it", key, entry)
+			continue
+		}
+		durations[strings.TrimSpace(sport)] = minutes
+	}
+	return durations
+}
+
 func (c *Config) GetDSN() string {
 	return "host=" + c.Database.Host + 
 		   " user=" + c.Database.User + 
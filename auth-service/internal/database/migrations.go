@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
 )
 
 func (db *Database) runMigrations() error {
@@ -16,6 +17,25 @@ func (db *Database) runMigrations() error {
 		db.createNotificationsTableIfNotExists,
 		db.createBanTablesIfNotExists,
 		db.updateUsersTableWithAdminFields,
+		db.createInviteCodesTablesIfNotExists,
+		db.updateNotificationTypes,
+		db.updateEventParticipantsForWaitlist,
+		db.updateEventParticipantsStatusLifecycle,
+		db.createEventSettingsTableIfNotExists,
+		db.createCalendarTokensTableIfNotExists,
+		db.createJobRunsTableIfNotExists,
+		db.createMatchRemindersTablesIfNotExists,
+		db.createCancelledEventsTableIfNotExists,
+		db.createEventTeamsTablesIfNotExists,
+		db.createEventSeriesTablesIfNotExists,
+		db.createPlayerRatingsTableIfNotExists,
+		db.createMatchResultsTablesIfNotExists,
+		db.createFriendGroupsTablesIfNotExists,
+		db.updateEventInvitesLifecycle,
+		db.createEventLinksTablesIfNotExists,
+		db.createUserPrivacySettingsTableIfNotExists,
+		db.createFavoriteCollectionsTablesIfNotExists,
+		db.createFavoriteFieldsTablesIfNotExists,
 	}
 
 	for i, migration := range migrations {
@@ -308,4 +328,722 @@ func (db *Database) updateUsersTableWithAdminFields() error {
 
 	log.Println("Users table updated with admin fields")
 	return nil
-}
\ No newline at end of file
+}
+
+func (db *Database) createInviteCodesTablesIfNotExists() error {
+	// Tabella dei codici invito generati dagli amministratori
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS invite_codes (
+		id SERIAL PRIMARY KEY,
+		code VARCHAR(32) NOT NULL UNIQUE,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		max_uses INTEGER NOT NULL DEFAULT 1,
+		uses INTEGER NOT NULL DEFAULT 0,
+		role VARCHAR(20),
+		expires_at TIMESTAMP,
+		is_active BOOLEAN DEFAULT TRUE,
+		note TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CHECK(max_uses > 0),
+		CHECK(uses >= 0 AND uses <= max_uses),
+		CHECK(role IS NULL OR role IN ('user', 'admin'))
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella invite_codes: %v", err)
+	}
+
+	// Tabella delle registrazioni effettuate con un codice invito
+	_, err = db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS invite_code_redemptions (
+		id SERIAL PRIMARY KEY,
+		code_id INTEGER NOT NULL REFERENCES invite_codes(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella invite_code_redemptions: %v", err)
+	}
+
+	// Ogni nuovo utente è collegato a chi ha creato il codice usato
+	_, err = db.Conn.Exec("ALTER TABLE users ADD COLUMN IF NOT EXISTS invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL")
+	if err != nil {
+		return fmt.Errorf("errore nell'aggiornamento tabella users: %v", err)
+	}
+
+	indexQueries := []string{
+		"CREATE INDEX IF NOT EXISTS idx_invite_codes_created_by ON invite_codes(created_by)",
+		"CREATE INDEX IF NOT EXISTS idx_invite_code_redemptions_code ON invite_code_redemptions(code_id)",
+		"CREATE INDEX IF NOT EXISTS idx_users_invited_by ON users(invited_by)",
+	}
+
+	for _, query := range indexQueries {
+		_, err = db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione degli indici invite_codes: %v", err)
+		}
+	}
+
+	log.Println("Invite codes tables created successfully")
+	return nil
+}
+
+// Tipi di notifica ammessi dal vincolo CHECK della tabella notifications
+var notificationTypes = []string{
+	"friend_request",
+	"event_invite",
+	"post_comment",
+	"general",
+	"event_update",
+	"match_reminder",
+	"series_occurrence",
+	"field_alert",
+}
+
+// updateNotificationTypes ricrea il vincolo sui tipi di notifica, così che
+// i nuovi tipi possano essere aggiunti alla lista senza modificare la tabella
+func (db *Database) updateNotificationTypes() error {
+	quoted := make([]string, len(notificationTypes))
+	for i, t := range notificationTypes {
+		quoted[i] = "'" + t + "'"
+	}
+
+	_, err := db.Conn.Exec(fmt.Sprintf(`
+	ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_type_check;
+	ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK(type IN (%s));
+	`, strings.Join(quoted, ", ")))
+	if err != nil {
+		return fmt.Errorf("errore nell'aggiornamento dei tipi di notifica: %v", err)
+	}
+
+	log.Println("Notification types constraint updated successfully")
+	return nil
+}
+
+func (db *Database) updateEventParticipantsForWaitlist() error {
+	// La lista d'attesa è ordinata per data di iscrizione
+	_, err := db.Conn.Exec("CREATE INDEX IF NOT EXISTS idx_event_participants_post_status ON event_participants(post_id, status, registered_at)")
+	if err != nil {
+		return fmt.Errorf("errore nella creazione degli indici event_participants: %v", err)
+	}
+
+	log.Println("Event participants table updated for waitlist")
+	return nil
+}
+
+// Stati ammessi dal vincolo CHECK della tabella event_participants
+var participationStatuses = []string{
+	"confirmed",
+	"waitlisted",
+	"tentative",
+	"declined",
+	"cancelled",
+	"no_show",
+	"pending",
+	"rejected",
+	"removed",
+}
+
+func (db *Database) updateEventParticipantsStatusLifecycle() error {
+	// Timestamp dell'ultimo passaggio a ciascuno stato
+	alterQueries := []string{
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS waitlisted_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS tentative_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS declined_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS no_show_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS pending_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP",
+		"ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}
+
+	for _, query := range alterQueries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nell'aggiornamento tabella event_participants: %v", err)
+		}
+	}
+
+	// Le iscrizioni esistenti sono state confermate al momento della registrazione
+	_, err := db.Conn.Exec(`
+	UPDATE event_participants 
+	SET confirmed_at = registered_at, status_updated_at = registered_at
+	WHERE status = 'confirmed' AND confirmed_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nell'aggiornamento delle partecipazioni esistenti: %v", err)
+	}
+
+	quoted := make([]string, len(participationStatuses))
+	for i, status := range participationStatuses {
+		quoted[i] = "'" + status + "'"
+	}
+
+	_, err = db.Conn.Exec(fmt.Sprintf(`
+	ALTER TABLE event_participants DROP CONSTRAINT IF EXISTS event_participants_status_check;
+	ALTER TABLE event_participants ADD CONSTRAINT event_participants_status_check 
+		CHECK(status IN (%s));
+	`, strings.Join(quoted, ", ")))
+	if err != nil {
+		return fmt.Errorf("errore nella creazione del vincolo sullo stato delle partecipazioni: %v", err)
+	}
+
+	log.Println("Event participants table updated with status lifecycle")
+	return nil
+}
+
+func (db *Database) createEventSettingsTableIfNotExists() error {
+	// Impostazioni degli eventi gestite dall'organizzatore (i post sono del backend Python)
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS event_settings (
+		post_id INTEGER PRIMARY KEY,
+		join_policy VARCHAR(20) NOT NULL DEFAULT 'open',
+		updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CHECK(join_policy IN ('open', 'approval', 'friends_only', 'invite_only'))
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella event_settings: %v", err)
+	}
+
+	log.Println("Event settings table created successfully")
+	return nil
+}
+
+func (db *Database) createCalendarTokensTableIfNotExists() error {
+	// Un solo token attivo per utente: rigenerarlo invalida il vecchio URL del feed
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS calendar_tokens (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		token VARCHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMP
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella calendar_tokens: %v", err)
+	}
+
+	log.Println("Calendar tokens table created successfully")
+	return nil
+}
+
+func (db *Database) createJobRunsTableIfNotExists() error {
+	// Storico delle esecuzioni dei job schedulati: il vincolo UNIQUE impedisce
+	// che due repliche eseguano lo stesso job per lo stesso orario
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id SERIAL PRIMARY KEY,
+		job_name VARCHAR(100) NOT NULL,
+		scheduled_for TIMESTAMP NOT NULL,
+		instance VARCHAR(255),
+		status VARCHAR(20) NOT NULL DEFAULT 'running',
+		error TEXT,
+		started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP,
+		UNIQUE(job_name, scheduled_for),
+		CHECK(status IN ('running', 'succeeded', 'failed'))
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella job_runs: %v", err)
+	}
+
+	_, err = db.Conn.Exec("CREATE INDEX IF NOT EXISTS idx_job_runs_started_at ON job_runs(started_at)")
+	if err != nil {
+		return fmt.Errorf("errore nella creazione degli indici job_runs: %v", err)
+	}
+
+	log.Println("Job runs table created successfully")
+	return nil
+}
+
+func (db *Database) createMatchRemindersTablesIfNotExists() error {
+	queries := []string{
+		// Promemoria già inviati, uno per tipo (24h, 2h) per utente e partita
+		`CREATE TABLE IF NOT EXISTS match_reminders_sent (
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			post_id INTEGER NOT NULL,
+			kind VARCHAR(10) NOT NULL,
+			sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, post_id, kind)
+		)`,
+		// Preferenze di notifica: in assenza di una riga valgono i default
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			match_reminders BOOLEAN NOT NULL DEFAULT TRUE,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle dei promemoria: %v", err)
+		}
+	}
+
+	log.Println("Match reminders tables created successfully")
+	return nil
+}
+
+func (db *Database) createCancelledEventsTableIfNotExists() error {
+	// Archivio degli eventi annullati per eliminazione del post: le righe
+	// collegate (partecipanti, inviti, preferiti) vengono rimosse e qui restano i conteggi
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS cancelled_events (
+		post_id INTEGER PRIMARY KEY,
+		titolo TEXT NOT NULL,
+		starts_at TIMESTAMP,
+		reason TEXT,
+		source VARCHAR(20) NOT NULL,
+		cancelled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		cancelled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		participants_count INTEGER NOT NULL DEFAULT 0,
+		invites_count INTEGER NOT NULL DEFAULT 0,
+		favorites_count INTEGER NOT NULL DEFAULT 0,
+		notified_count INTEGER NOT NULL DEFAULT 0,
+		CHECK(source IN ('admin', 'author'))
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella cancelled_events: %v", err)
+	}
+
+	log.Println("Cancelled events table created successfully")
+	return nil
+}
+
+func (db *Database) createEventTeamsTablesIfNotExists() error {
+	queries := []string{
+		// Abilità dei giocatori impostata dall'organizzatore per un evento (scala 1-5)
+		`CREATE TABLE IF NOT EXISTS event_player_skills (
+			post_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			skill NUMERIC(3,1) NOT NULL,
+			updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (post_id, user_id),
+			CHECK(skill >= 1 AND skill <= 5)
+		)`,
+		// Squadre bloccate dall'organizzatore
+		`CREATE TABLE IF NOT EXISTS event_teams (
+			post_id INTEGER PRIMARY KEY,
+			team_count INTEGER NOT NULL,
+			seed BIGINT NOT NULL,
+			pairs JSONB NOT NULL DEFAULT '[]',
+			teams JSONB NOT NULL,
+			locked_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			locked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle delle squadre: %v", err)
+		}
+	}
+
+	log.Println("Event teams tables created successfully")
+	return nil
+}
+
+func (db *Database) createEventSeriesTablesIfNotExists() error {
+	queries := []string{
+		// Serie di partite ricorrenti: ogni occorrenza è un nuovo post copiato
+		// dall'ultimo post esistente della serie (inizialmente il post modello)
+		`CREATE TABLE IF NOT EXISTS event_series (
+			id SERIAL PRIMARY KEY,
+			template_post_id INTEGER NOT NULL UNIQUE,
+			organizer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			rrule TEXT NOT NULL,
+			dtstart TIMESTAMP NOT NULL,
+			horizon_days INTEGER NOT NULL DEFAULT 7,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			last_occurrence_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CHECK(horizon_days >= 1 AND horizon_days <= 60)
+		)`,
+		`CREATE TABLE IF NOT EXISTS event_series_occurrences (
+			series_id INTEGER NOT NULL REFERENCES event_series(id) ON DELETE CASCADE,
+			starts_at TIMESTAMP NOT NULL,
+			post_id INTEGER NOT NULL UNIQUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (series_id, starts_at)
+		)`,
+		// Giocatori iscritti automaticamente a ogni nuova occorrenza
+		`CREATE TABLE IF NOT EXISTS event_series_roster (
+			series_id INTEGER NOT NULL REFERENCES event_series(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (series_id, user_id)
+		)`,
+		// Occorrenze a cui un giocatore del roster ha scelto di non partecipare
+		`CREATE TABLE IF NOT EXISTS event_series_optouts (
+			series_id INTEGER NOT NULL REFERENCES event_series(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			occurrence_date DATE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (series_id, user_id, occurrence_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_event_series_roster_user ON event_series_roster(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_event_series_active ON event_series(active) WHERE active`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle delle serie: %v", err)
+		}
+	}
+
+	log.Println("Event series tables created successfully")
+	return nil
+}
+
+func (db *Database) createPlayerRatingsTableIfNotExists() error {
+	// Valutazioni tra partecipanti dopo la partita: una per coppia e per evento.
+	// Chi segnala un no-show non esprime voti sulle altre voci.
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS player_ratings (
+		post_id INTEGER NOT NULL,
+		rater_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		rated_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		sportsmanship SMALLINT,
+		skill SMALLINT,
+		punctuality SMALLINT,
+		no_show BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (post_id, rater_id, rated_id),
+		CHECK(rater_id <> rated_id),
+		CHECK(sportsmanship BETWEEN 1 AND 5 AND skill BETWEEN 1 AND 5 AND punctuality BETWEEN 1 AND 5),
+		CHECK(no_show = (sportsmanship IS NULL AND skill IS NULL AND punctuality IS NULL)),
+		CHECK(no_show OR (sportsmanship IS NOT NULL AND skill IS NOT NULL AND punctuality IS NOT NULL))
+	);
+	CREATE INDEX IF NOT EXISTS idx_player_ratings_rated ON player_ratings(rated_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella player_ratings: %v", err)
+	}
+
+	log.Println("Player ratings table created successfully")
+	return nil
+}
+
+func (db *Database) createMatchResultsTablesIfNotExists() error {
+	queries := []string{
+		// Risultato registrato dall'organizzatore. Sport, provincia e orario
+		// vengono copiati dal post, così lo storico resta ricalcolabile anche se
+		// il post viene eliminato.
+		`CREATE TABLE IF NOT EXISTS match_results (
+			post_id INTEGER PRIMARY KEY,
+			sport TEXT NOT NULL,
+			provincia TEXT NOT NULL,
+			played_at TIMESTAMP NOT NULL,
+			score_a INTEGER NOT NULL,
+			score_b INTEGER NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			confirmed_at TIMESTAMP,
+			CHECK(score_a >= 0 AND score_b >= 0),
+			CHECK(status IN ('pending', 'confirmed', 'disputed'))
+		)`,
+		`CREATE TABLE IF NOT EXISTS match_result_players (
+			post_id INTEGER NOT NULL REFERENCES match_results(post_id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			team CHAR(1) NOT NULL,
+			PRIMARY KEY (post_id, user_id),
+			CHECK(team IN ('A', 'B'))
+		)`,
+		// Conferme (agree = TRUE) e contestazioni dei giocatori
+		`CREATE TABLE IF NOT EXISTS match_result_votes (
+			post_id INTEGER NOT NULL REFERENCES match_results(post_id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			agree BOOLEAN NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (post_id, user_id)
+		)`,
+		// Punteggio Elo attuale per sport
+		`CREATE TABLE IF NOT EXISTS player_elo (
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			sport TEXT NOT NULL,
+			rating DOUBLE PRECISION NOT NULL,
+			games INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, sport)
+		)`,
+		// Variazioni di punteggio per partita, rigenerate a ogni ricalcolo
+		`CREATE TABLE IF NOT EXISTS elo_history (
+			id SERIAL PRIMARY KEY,
+			post_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			sport TEXT NOT NULL,
+			rating_before DOUBLE PRECISION NOT NULL,
+			rating_after DOUBLE PRECISION NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(post_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_match_result_players_user ON match_result_players(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_match_results_sport ON match_results(sport, played_at) WHERE status = 'confirmed'`,
+		`CREATE INDEX IF NOT EXISTS idx_player_elo_sport ON player_elo(sport, rating DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_elo_history_user ON elo_history(user_id, sport)`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle dei risultati: %v", err)
+		}
+	}
+
+	log.Println("Match results tables created successfully")
+	return nil
+}
+
+func (db *Database) createFriendGroupsTablesIfNotExists() error {
+	queries := []string{
+		// Gruppi di amici salvati dall'utente per invitarli insieme agli eventi
+		`CREATE TABLE IF NOT EXISTS friend_groups (
+			id SERIAL PRIMARY KEY,
+			owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(60) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(owner_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS friend_group_members (
+			group_id INTEGER NOT NULL REFERENCES friend_groups(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle dei gruppi di amici: %v", err)
+		}
+	}
+
+	log.Println("Friend groups tables created successfully")
+	return nil
+}
+
+func (db *Database) updateEventInvitesLifecycle() error {
+	// Gli inviti scadono all'inizio della partita, possono essere revocati dal
+	// mittente e ricevono al più un promemoria. Lo storico resta nella tabella:
+	// l'unicità vale solo per gli inviti ancora in attesa, così dopo un rifiuto
+	// l'amico può essere invitato di nuovo.
+	queries := []string{
+		"ALTER TABLE event_invites ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP",
+		"ALTER TABLE event_invites ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP",
+		"ALTER TABLE event_invites ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMP",
+		"ALTER TABLE event_invites DROP CONSTRAINT IF EXISTS event_invites_status_check",
+		`ALTER TABLE event_invites ADD CONSTRAINT event_invites_status_check
+			CHECK(status IN ('pending', 'accepted', 'rejected', 'expired', 'revoked'))`,
+		"ALTER TABLE event_invites DROP CONSTRAINT IF EXISTS event_invites_receiver_id_post_id_key",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_event_invites_pending ON event_invites(receiver_id, post_id) WHERE status = 'pending'",
+		"CREATE INDEX IF NOT EXISTS idx_event_invites_receiver_post ON event_invites(receiver_id, post_id)",
+		"CREATE INDEX IF NOT EXISTS idx_event_invites_pending_expiry ON event_invites(expires_at) WHERE status = 'pending'",
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nell'aggiornamento della tabella event_invites: %v", err)
+		}
+	}
+
+	// La tabella posts è del backend Python e potrebbe non esistere ancora
+	var postsExist bool
+	if err := db.Conn.QueryRow("SELECT to_regclass('posts') IS NOT NULL").Scan(&postsExist); err != nil {
+		return fmt.Errorf("errore nella verifica della tabella posts: %v", err)
+	}
+	if postsExist {
+		_, err := db.Conn.Exec(`
+		UPDATE event_invites ei
+		SET expires_at = p.data_partita + p.ora_partita
+		FROM posts p
+		WHERE p.id = ei.post_id AND ei.expires_at IS NULL
+		`)
+		if err != nil {
+			return fmt.Errorf("errore nell'aggiornamento della scadenza degli inviti: %v", err)
+		}
+	}
+
+	log.Println("Event invites table updated with lifecycle")
+	return nil
+}
+
+func (db *Database) createEventLinksTablesIfNotExists() error {
+	// Link di invito generati dall'organizzatore per chi non è ancora amico o
+	// non ha un account; ogni utilizzo viene registrato per le statistiche
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS event_links (
+			id SERIAL PRIMARY KEY,
+			post_id INTEGER NOT NULL,
+			created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			max_uses INTEGER,
+			uses INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CHECK(max_uses IS NULL OR max_uses > 0)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_event_links_post ON event_links(post_id)",
+		`CREATE TABLE IF NOT EXISTS event_link_redemptions (
+			id SERIAL PRIMARY KEY,
+			link_id INTEGER NOT NULL REFERENCES event_links(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			registered BOOLEAN NOT NULL DEFAULT FALSE,
+			redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(link_id, user_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_event_link_redemptions_user ON event_link_redemptions(user_id)",
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle dei link di invito: %v", err)
+		}
+	}
+
+	log.Println("Event links tables created successfully")
+	return nil
+}
+
+func (db *Database) createUserPrivacySettingsTableIfNotExists() error {
+	// Cosa vedono dell'utente, nelle liste dei partecipanti, sconosciuti, amici e
+	// co-partecipanti; chi non ha una riga usa le impostazioni predefinite
+	_, err := db.Conn.Exec(`
+	CREATE TABLE IF NOT EXISTS user_privacy_settings (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		strangers VARCHAR(10) NOT NULL DEFAULT 'basic' CHECK(strangers IN ('full', 'basic', 'hidden')),
+		friends VARCHAR(10) NOT NULL DEFAULT 'full' CHECK(friends IN ('full', 'basic', 'hidden')),
+		co_participants VARCHAR(10) NOT NULL DEFAULT 'full' CHECK(co_participants IN ('full', 'basic', 'hidden')),
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("errore nella creazione della tabella user_privacy_settings: %v", err)
+	}
+
+	log.Println("User privacy settings table created successfully")
+	return nil
+}
+
+func (db *Database) createFavoriteCollectionsTablesIfNotExists() error {
+	// Raccolte di preferiti con nome e ordinamento; user_favorites resta l'insieme
+	// di tutti i post salvati, cioè l'unione delle raccolte dell'utente
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS favorite_collections (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(60) NOT NULL,
+			visibility VARCHAR(10) NOT NULL DEFAULT 'private' CHECK(visibility IN ('private', 'friends', 'link')),
+			share_token VARCHAR(64) UNIQUE,
+			is_default BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, name)
+		)`,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_favorite_collections_default ON favorite_collections(user_id) WHERE is_default",
+		`CREATE TABLE IF NOT EXISTS favorite_collection_items (
+			collection_id INTEGER NOT NULL REFERENCES favorite_collections(id) ON DELETE CASCADE,
+			post_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (collection_id, post_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS favorite_collection_follows (
+			collection_id INTEGER NOT NULL REFERENCES favorite_collections(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (collection_id, user_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_favorite_collection_follows_user ON favorite_collection_follows(user_id)",
+		// I preferiti che non sono in nessuna raccolta finiscono in quella
+		// predefinita, nell'ordine in cui sono stati salvati
+		`INSERT INTO favorite_collections (user_id, name, is_default)
+		SELECT DISTINCT uf.user_id, 'Preferiti', TRUE
+		FROM user_favorites uf
+		ON CONFLICT (user_id) WHERE is_default DO NOTHING`,
+		`INSERT INTO favorite_collection_items (collection_id, post_id, position, added_at)
+		SELECT c.id, uf.post_id,
+			COALESCE((SELECT MAX(i.position) FROM favorite_collection_items i WHERE i.collection_id = c.id), 0)
+				+ ROW_NUMBER() OVER (PARTITION BY c.id ORDER BY uf.created_at, uf.post_id),
+			COALESCE(uf.created_at, CURRENT_TIMESTAMP)
+		FROM user_favorites uf
+		JOIN favorite_collections c ON c.user_id = uf.user_id AND c.is_default
+		WHERE NOT EXISTS (
+			SELECT 1 FROM favorite_collection_items i
+			JOIN favorite_collections o ON o.id = i.collection_id
+			WHERE o.user_id = uf.user_id AND i.post_id = uf.post_id
+		)
+		ON CONFLICT (collection_id, post_id) DO NOTHING`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle delle raccolte di preferiti: %v", err)
+		}
+	}
+
+	log.Println("Favorite collections tables created successfully")
+	return nil
+}
+
+func (db *Database) createFavoriteFieldsTablesIfNotExists() error {
+	// Campi preferiti e coda degli avvisi di nuove partite, inviati in un
+	// riepilogo al massimo ogni FIELD_ALERTS_MIN_INTERVAL_MINUTES per utente.
+	// watch_from è in UTC come posts.created_at: si avvisa solo dei post creati
+	// dopo aver salvato il campo
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS user_favorite_fields (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			field_id INTEGER NOT NULL,
+			alerts BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			watch_from TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+			UNIQUE(user_id, field_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_user_favorite_fields_field ON user_favorite_fields(field_id) WHERE alerts",
+		`CREATE TABLE IF NOT EXISTS field_alert_queue (
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			field_id INTEGER NOT NULL,
+			post_id INTEGER NOT NULL,
+			queued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			sent_at TIMESTAMP,
+			PRIMARY KEY (user_id, post_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_field_alert_queue_pending ON field_alert_queue(user_id) WHERE sent_at IS NULL",
+		`CREATE TABLE IF NOT EXISTS field_alert_digests (
+			user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			last_sent_at TIMESTAMP NOT NULL
+		)`,
+	}
+
+	for _, query := range queries {
+		_, err := db.Conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("errore nella creazione delle tabelle dei campi preferiti: %v", err)
+		}
+	}
+
+	log.Println("Favorite fields tables created successfully")
+	return nil
+}
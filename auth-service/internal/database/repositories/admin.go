@@ -142,7 +142,7 @@ func (r *AdminRepository) GetUserStats(userID int64) (map[string]interface{}, er
 	// Conta partecipazioni eventi
 	var eventCount int
 	err = r.db.QueryRow(
-		"SELECT COUNT(*) FROM event_participants WHERE user_id = $1",
+		"SELECT COUNT(*) FROM event_participants WHERE user_id = $1 AND status = 'confirmed'",
 		userID,
 	).Scan(&eventCount)
 	if err == nil {
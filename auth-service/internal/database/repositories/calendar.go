@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/utils"
+)
+
+// ErrCalendarTokenNotFound indica un token del feed inesistente o revocato
+var ErrCalendarTokenNotFound = errors.New("calendario non trovato")
+
+const (
+	calendarTokenBytes = 20
+	// Le partite già giocate restano nel feed per questo numero di giorni
+	calendarHistoryDays = 30
+)
+
+type CalendarRepository struct {
+	db *sql.DB
+}
+
+func NewCalendarRepository(db *sql.DB) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+// GetOrCreateToken restituisce il token del feed dell'utente, creandolo se non esiste
+func (r *CalendarRepository) GetOrCreateToken(userID int64) (string, time.Time, error) {
+	var token string
+	var createdAt time.Time
+	err := r.db.QueryRow(`
+		SELECT token, created_at FROM calendar_tokens WHERE user_id = $1`,
+		userID).Scan(&token, &createdAt)
+	if err == nil {
+		return token, createdAt, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", time.Time{}, err
+	}
+	return r.RotateToken(userID)
+}
+
+// RotateToken genera un nuovo token: il vecchio URL smette immediatamente di funzionare
+func (r *CalendarRepository) RotateToken(userID int64) (string, time.Time, error) {
+	token, err := utils.GenerateToken(calendarTokenBytes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var createdAt time.Time
+	err = r.db.QueryRow(`
+		INSERT INTO calendar_tokens (user_id, token, created_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			token = $2,
+			created_at = CURRENT_TIMESTAMP,
+			last_used_at = NULL
+		RETURNING created_at`,
+		userID, token).Scan(&createdAt)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("errore nella creazione del token calendario: %v", err)
+	}
+	return token, createdAt, nil
+}
+
+// RevokeToken elimina il token del feed dell'utente
+func (r *CalendarRepository) RevokeToken(userID int64) error {
+	_, err := r.db.Exec("DELETE FROM calendar_tokens WHERE user_id = $1", userID)
+	return err
+}
+
+// GetUserIDByToken risolve il token del feed nell'utente proprietario
+func (r *CalendarRepository) GetUserIDByToken(token string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`
+		UPDATE calendar_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE token = $1
+		RETURNING user_id`,
+		token).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrCalendarTokenNotFound
+	}
+	return userID, err
+}
+
+// calendarEntryColumns seleziona i dati del post e del campo collegato;
+// le query che la usano devono fare LEFT JOIN di sport_fields come sf
+const calendarEntryColumns = `
+	p.id, p.titolo, p.sport, p.livello, COALESCE(p.commento, ''), p.citta, p.provincia,
+	p.data_partita + p.ora_partita,
+	COALESCE(sf.nome, ''), COALESCE(sf.indirizzo, ''), sf.lat, sf.lng`
+
+// GetCalendarEntries restituisce le partite dell'utente (stessi stati di
+// ListUserParticipations) con i dati del post e del campo, in ordine cronologico
+func (r *CalendarRepository) GetCalendarEntries(userID int64, statuses []string) ([]models.CalendarEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT `+calendarEntryColumns+`, ep.status,
+			GREATEST(COALESCE(p.created_at, ep.registered_at), COALESCE(ep.status_updated_at, ep.registered_at))
+		FROM event_participants ep
+		JOIN posts p ON p.id = ep.post_id
+		LEFT JOIN sport_fields sf ON sf.id = p.campo_id
+		WHERE ep.user_id = $1 AND ep.status = ANY($2)
+		AND p.data_partita >= CURRENT_DATE - $3::INTEGER
+		ORDER BY p.data_partita, p.ora_partita, p.id`,
+		userID, pq.Array(statuses), calendarHistoryDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.CalendarEntry
+	for rows.Next() {
+		var entry models.CalendarEntry
+		dest := append(calendarEntryDest(&entry), &entry.Status, &entry.LastModified)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetCalendarEntry restituisce una singola partita per il download del file .ics
+func (r *CalendarRepository) GetCalendarEntry(postID int) (*models.CalendarEntry, error) {
+	var entry models.CalendarEntry
+	dest := append(calendarEntryDest(&entry), &entry.LastModified)
+	err := r.db.QueryRow(`
+		SELECT `+calendarEntryColumns+`,
+			COALESCE(p.created_at, p.data_partita + p.ora_partita)
+		FROM posts p
+		LEFT JOIN sport_fields sf ON sf.id = p.campo_id
+		WHERE p.id = $1`,
+		postID).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func calendarEntryDest(entry *models.CalendarEntry) []interface{} {
+	return []interface{}{
+		&entry.PostID, &entry.Titolo, &entry.Sport, &entry.Livello, &entry.Commento, &entry.Citta, &entry.Provincia,
+		&entry.StartsAt,
+		&entry.FieldName, &entry.FieldAddress, &entry.Latitude, &entry.Longitude,
+	}
+}
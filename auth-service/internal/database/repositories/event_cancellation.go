@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Stati di partecipazione i cui utenti vanno avvisati dell'annullamento
+var cancellationNotifiedStatuses = map[string]bool{
+	models.ParticipantStatusConfirmed:  true,
+	models.ParticipantStatusWaitlisted: true,
+	models.ParticipantStatusTentative:  true,
+	models.ParticipantStatusPending:    true,
+}
+
+// PrepareEventCancellation legge dal post i dati da conservare nell'archivio;
+// va chiamato prima di eliminare il post
+func (r *EventRepository) PrepareEventCancellation(postID int) (*models.EventCancellation, error) {
+	cancellation := &models.EventCancellation{PostID: postID}
+	if err := loadCancellationPost(r.db, cancellation); err != nil {
+		return nil, err
+	}
+	return cancellation, nil
+}
+
+func loadCancellationPost(q queryRower, cancellation *models.EventCancellation) error {
+	var startsAt time.Time
+	err := q.QueryRow(`
+		SELECT titolo, data_partita + ora_partita FROM posts WHERE id = $1`,
+		cancellation.PostID).Scan(&cancellation.Titolo, &startsAt)
+	if err == sql.ErrNoRows {
+		return ErrEventNotFound
+	}
+	if err != nil {
+		return err
+	}
+	cancellation.StartsAt = &startsAt
+	return nil
+}
+
+// CancelEvent annulla l'evento di un post eliminato in un'unica transazione:
+// archivia l'evento, rimuove partecipanti, inviti, preferiti e impostazioni e
+// notifica partecipanti, invitati e utenti che lo avevano tra i preferiti.
+// È idempotente: una seconda chiamata restituisce l'annullamento già registrato.
+func (r *EventRepository) CancelEvent(cancellation *models.EventCancellation) (*models.EventCancellation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Il lock sul post (se esiste ancora) attende le iscrizioni in corso: quelle
+	// successive trovano l'evento annullato in lockEvent
+	if _, err := tx.Exec("SELECT 1 FROM posts WHERE id = $1 FOR UPDATE", cancellation.PostID); err != nil {
+		return nil, err
+	}
+
+	// Se il post non è ancora stato eliminato i suoi dati hanno la precedenza
+	if err := loadCancellationPost(tx, cancellation); err != nil && err != ErrEventNotFound {
+		return nil, err
+	}
+	if cancellation.Titolo == "" {
+		cancellation.Titolo = fmt.Sprintf("Evento #%d", cancellation.PostID)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO cancelled_events (post_id, titolo, starts_at, reason, source, cancelled_by)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT (post_id) DO NOTHING
+		RETURNING cancelled_at`,
+		cancellation.PostID, cancellation.Titolo, cancellation.StartsAt, cancellation.Reason,
+		cancellation.Source, cancellation.CancelledBy).Scan(&cancellation.CancelledAt)
+	if err == sql.ErrNoRows {
+		return r.getCancelledEvent(cancellation.PostID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("errore nell'archiviazione dell'evento: %v", err)
+	}
+
+	recipients := make(map[int64]bool)
+
+	cancellation.Participants, err = deleteEventRows(tx, recipients,
+		"DELETE FROM event_participants WHERE post_id = $1 RETURNING user_id, status",
+		cancellation.PostID, func(status string) bool { return cancellationNotifiedStatuses[status] })
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation.Invites, err = deleteEventRows(tx, recipients,
+		"DELETE FROM event_invites WHERE post_id = $1 RETURNING receiver_id, status",
+		cancellation.PostID, func(status string) bool { return status == "pending" })
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation.Favorites, err = deleteEventRows(tx, recipients,
+		"DELETE FROM user_favorites WHERE post_id = $1 RETURNING user_id, ''",
+		cancellation.PostID, func(string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	cleanupQueries := []string{
+		// Le raccolte seguono i preferiti appena rimossi
+		"DELETE FROM favorite_collection_items WHERE post_id = $1",
+		"DELETE FROM event_settings WHERE post_id = $1",
+		"DELETE FROM match_reminders_sent WHERE post_id = $1",
+		"DELETE FROM event_player_skills WHERE post_id = $1",
+		"DELETE FROM event_teams WHERE post_id = $1",
+		// I risultati confermati restano: fanno parte dello storico dei punteggi
+		"DELETE FROM match_results WHERE post_id = $1 AND status <> 'confirmed'",
+		// I link di invito restano per le statistiche ma non sono più utilizzabili
+		"UPDATE event_links SET revoked_at = CURRENT_TIMESTAMP WHERE post_id = $1 AND revoked_at IS NULL",
+		// Gli inviti non più accettabili spariscono anche dalle notifiche
+		"DELETE FROM notifications WHERE type = 'event_invite' AND related_id = $1",
+	}
+	for _, query := range cleanupQueries {
+		if _, err := tx.Exec(query, cancellation.PostID); err != nil {
+			return nil, fmt.Errorf("errore nella pulizia dei dati dell'evento: %v", err)
+		}
+	}
+
+	// Chi ha annullato l'evento non riceve la propria notifica
+	if cancellation.CancelledBy != nil {
+		delete(recipients, *cancellation.CancelledBy)
+	}
+	userIDs := make([]int64, 0, len(recipients))
+	for userID := range recipients {
+		userIDs = append(userIDs, userID)
+	}
+
+	if len(userIDs) > 0 {
+		_, err = tx.Exec(`
+			INSERT INTO notifications (user_id, type, title, message, status, related_id, sender_id)
+			SELECT unnest($1::INTEGER[]), $2, $3, $4, 'unread', $5, $6`,
+			pq.Array(userIDs), models.NotificationTypeEventUpdate, "Partita annullata",
+			cancellationMessage(cancellation), cancellation.PostID, cancellation.CancelledBy)
+		if err != nil {
+			return nil, fmt.Errorf("errore nella creazione delle notifiche di annullamento: %v", err)
+		}
+	}
+	cancellation.NotifiedUsers = len(userIDs)
+
+	_, err = tx.Exec(`
+		UPDATE cancelled_events
+		SET participants_count = $2, invites_count = $3, favorites_count = $4, notified_count = $5
+		WHERE post_id = $1`,
+		cancellation.PostID, cancellation.Participants, cancellation.Invites,
+		cancellation.Favorites, cancellation.NotifiedUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return cancellation, nil
+}
+
+// deleteEventRows esegue una DELETE ... RETURNING (user_id, status), aggiunge ai
+// destinatari gli utenti il cui stato va notificato e restituisce le righe eliminate
+func deleteEventRows(tx *sql.Tx, recipients map[int64]bool, query string, postID int, notify func(status string) bool) (int, error) {
+	rows, err := tx.Query(query, postID)
+	if err != nil {
+		return 0, fmt.Errorf("errore nell'eliminazione dei dati dell'evento: %v", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var userID int64
+		var status sql.NullString
+		if err := rows.Scan(&userID, &status); err != nil {
+			return 0, err
+		}
+		count++
+		if notify(status.String) {
+			recipients[userID] = true
+		}
+	}
+	return count, rows.Err()
+}
+
+func cancellationMessage(cancellation *models.EventCancellation) string {
+	message := fmt.Sprintf("La partita %s è stata annullata", cancellation.Titolo)
+	if cancellation.StartsAt != nil {
+		message = fmt.Sprintf("La partita %s del %s è stata annullata",
+			cancellation.Titolo, cancellation.StartsAt.Format("02/01/2006 15:04"))
+	}
+	if cancellation.Reason != "" {
+		message += ": " + cancellation.Reason
+	}
+	return message
+}
+
+func (r *EventRepository) getCancelledEvent(postID int) (*models.EventCancellation, error) {
+	cancellation := &models.EventCancellation{PostID: postID, AlreadyCancelled: true}
+	var reason sql.NullString
+	var cancelledBy sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT titolo, starts_at, reason, source, cancelled_by, cancelled_at,
+			participants_count, invites_count, favorites_count, notified_count
+		FROM cancelled_events WHERE post_id = $1`,
+		postID).Scan(&cancellation.Titolo, &cancellation.StartsAt, &reason, &cancellation.Source,
+		&cancelledBy, &cancellation.CancelledAt, &cancellation.Participants, &cancellation.Invites,
+		&cancellation.Favorites, &cancellation.NotifiedUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation.Reason = reason.String
+	if cancelledBy.Valid {
+		cancellation.CancelledBy = &cancelledBy.Int64
+	}
+	return cancellation, nil
+}
@@ -0,0 +1,365 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori degli inviti agli eventi
+var (
+	ErrTooManyInviteRecipients  = errors.New("troppi destinatari in un solo invio")
+	ErrNoInviteRecipients       = errors.New("nessun destinatario indicato")
+	ErrInviteNotFound           = errors.New("invito non trovato o non più in attesa")
+	ErrInviteExpired            = errors.New("l'invito è scaduto: la partita è già iniziata")
+	ErrInviteCooldown           = errors.New("l'utente è stato invitato di recente")
+	ErrInviteSelf               = errors.New("non puoi invitare te stesso")
+	ErrInviteNotFriend          = errors.New("puoi invitare solo i tuoi amici")
+	ErrInviteOrganizer          = errors.New("l'utente è l'organizzatore dell'evento")
+	ErrInviteAlreadyParticipant = errors.New("l'utente partecipa già all'evento")
+	ErrInviteExcluded           = errors.New("l'utente è stato escluso dall'organizzatore")
+)
+
+const (
+	// Numero massimo di destinatari di un invio multiplo
+	MaxBulkInviteRecipients = 50
+	// Dopo un rifiuto o una revoca lo stesso utente può essere reinvitato
+	// alla stessa partita solo trascorso questo tempo
+	InviteCooldown = 72 * time.Hour
+)
+
+// BulkInvite descrive un invio multiplo di inviti a un evento
+type BulkInvite struct {
+	SenderID       int64
+	PostID         int
+	RecipientIDs   []int64
+	GroupID        int64 // gruppo di amici salvato da aggiungere ai destinatari (0 = nessuno)
+	Message        string
+	SenderUsername string
+	EventTitle     string
+}
+
+// SendBulkEventInvites invita più utenti a un evento in un'unica transazione.
+// Ogni destinatario viene valutato singolarmente: chi non è amico del mittente,
+// è già iscritto, ha già un invito in attesa o ne ha rifiutato uno di recente
+// viene saltato con il motivo.
+// Inviti e notifiche vengono salvati insieme, oppure nessuno.
+func (r *EventRepository) SendBulkEventInvites(invite *BulkInvite) ([]models.BulkInviteResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, invite.PostID)
+	if err != nil {
+		return nil, err
+	}
+	if event.started {
+		return nil, ErrEventAlreadyStarted
+	}
+
+	recipients := invite.RecipientIDs
+	if invite.GroupID != 0 {
+		members, err := friendGroupMemberIDs(tx, invite.SenderID, invite.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(append([]int64{}, recipients...), members...)
+	}
+
+	seen := make(map[int64]bool, len(recipients))
+	unique := make([]int64, 0, len(recipients))
+	for _, userID := range recipients {
+		if !seen[userID] {
+			seen[userID] = true
+			unique = append(unique, userID)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, ErrNoInviteRecipients
+	}
+	if len(unique) > MaxBulkInviteRecipients {
+		return nil, ErrTooManyInviteRecipients
+	}
+
+	rows, err := tx.Query(`
+		SELECT rcp.id, COALESCE(u.username, ''), u.id IS NOT NULL AND COALESCE(u.is_active, TRUE),
+			f.user1_id IS NOT NULL, COALESCE(ep.status, ''),
+			EXISTS(
+				SELECT 1 FROM event_invites ei
+				WHERE ei.post_id = $3 AND ei.receiver_id = rcp.id AND ei.status = 'pending'
+			),
+			EXISTS(
+				SELECT 1 FROM event_invites ei
+				WHERE ei.post_id = $3 AND ei.receiver_id = rcp.id AND ei.status IN ('rejected', 'revoked')
+				AND ei.responded_at > LOCALTIMESTAMP - make_interval(secs => $4)
+			)
+		FROM unnest($2::INTEGER[]) WITH ORDINALITY AS rcp(id, ord)
+		LEFT JOIN users u ON u.id = rcp.id
+		LEFT JOIN friendships f ON f.user1_id = LEAST(rcp.id, $1::INTEGER) AND f.user2_id = GREATEST(rcp.id, $1::INTEGER)
+		LEFT JOIN event_participants ep ON ep.post_id = $3 AND ep.user_id = rcp.id
+		ORDER BY rcp.ord`,
+		invite.SenderID, pq.Array(unique), invite.PostID, InviteCooldown.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.BulkInviteResult, 0, len(unique))
+	for rows.Next() {
+		var result models.BulkInviteResult
+		var exists, friends, invited, cooldown bool
+		var participation string
+		if err := rows.Scan(&result.UserID, &result.Username, &exists, &friends, &participation, &invited, &cooldown); err != nil {
+			return nil, err
+		}
+
+		result.Status = models.BulkInviteStatusSkipped
+		switch {
+		case result.UserID == invite.SenderID:
+			result.Reason = models.BulkInviteReasonSelf
+		case !exists:
+			result.Reason = models.BulkInviteReasonUserNotFound
+			result.Username = ""
+		case !friends:
+			result.Reason = models.BulkInviteReasonNotFriend
+		case result.UserID == event.organizerID:
+			result.Reason = models.BulkInviteReasonOrganizer
+		case participation == models.ParticipantStatusConfirmed, participation == models.ParticipantStatusTentative,
+			participation == models.ParticipantStatusPending, participation == models.ParticipantStatusWaitlisted:
+			result.Reason = models.BulkInviteReasonAlreadyParticipant
+		case participation == models.ParticipantStatusRejected, participation == models.ParticipantStatusRemoved:
+			// Un invito non deve aggirare la decisione dell'organizzatore
+			result.Reason = models.BulkInviteReasonExcluded
+		case invited:
+			result.Reason = models.BulkInviteReasonAlreadyInvited
+		case cooldown:
+			result.Reason = models.BulkInviteReasonCooldown
+		default:
+			result.Status = models.BulkInviteStatusInvited
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, result := range results {
+		if result.Status != models.BulkInviteStatusInvited {
+			continue
+		}
+
+		if err := upsertEventInvite(tx, invite.SenderID, result.UserID, invite.PostID, invite.Message); err != nil {
+			return nil, err
+		}
+
+		notification := eventInviteNotification(result.UserID, invite.SenderID, int64(invite.PostID),
+			invite.SenderUsername, invite.EventTitle)
+		if err := insertNotification(tx, notification); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// friendGroupMemberIDs restituisce i membri di un gruppo del proprietario indicato
+func friendGroupMemberIDs(tx *sql.Tx, ownerID, groupID int64) ([]int64, error) {
+	var memberIDs []int64
+	err := tx.QueryRow(`
+		SELECT ARRAY(SELECT m.user_id FROM friend_group_members m WHERE m.group_id = g.id ORDER BY m.added_at, m.user_id)
+		FROM friend_groups g
+		WHERE g.id = $1 AND g.owner_id = $2`,
+		groupID, ownerID).Scan(pq.Array(&memberIDs))
+	if err == sql.ErrNoRows {
+		return nil, ErrFriendGroupNotFound
+	}
+	return memberIDs, err
+}
+
+// organizerIDSQL seleziona l'ID dell'organizzatore del post dell'invito in conflitto
+const organizerIDSQL = `
+	SELECT u.id FROM posts p JOIN users u ON u.email = p.autore_email
+	WHERE p.id = event_invites.post_id`
+
+// upsertEventInvite salva un invito in attesa che scade all'inizio della partita.
+// Se ce n'è già uno in attesa ne aggiorna mittente e messaggio, tranne quando
+// il mittente è l'organizzatore: il suo invito resta il riferimento. In ogni
+// caso la scadenza viene ricalcolata e il promemoria può essere inviato di nuovo.
+func upsertEventInvite(tx *sql.Tx, senderID, receiverID int64, postID int, message string) error {
+	_, err := tx.Exec(`
+		INSERT INTO event_invites (sender_id, receiver_id, post_id, message, status, expires_at)
+		VALUES ($1, $2, $3, $4, 'pending', (SELECT data_partita + ora_partita FROM posts WHERE id = $3))
+		ON CONFLICT (receiver_id, post_id) WHERE status = 'pending'
+		DO UPDATE SET
+			sender_id = CASE WHEN event_invites.sender_id IN (`+organizerIDSQL+`)
+				THEN event_invites.sender_id ELSE EXCLUDED.sender_id END,
+			message = CASE WHEN event_invites.sender_id IN (`+organizerIDSQL+`)
+				THEN event_invites.message ELSE EXCLUDED.message END,
+			expires_at = EXCLUDED.expires_at,
+			reminded_at = NULL,
+			updated_at = CURRENT_TIMESTAMP`,
+		senderID, receiverID, postID, message)
+	return err
+}
+
+// inviteCooldownActive indica se l'utente ha rifiutato (o il mittente ha revocato)
+// un invito alla partita da meno di InviteCooldown
+func inviteCooldownActive(q queryRower, receiverID int64, postID int) (bool, error) {
+	var active bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM event_invites
+			WHERE receiver_id = $1 AND post_id = $2 AND status IN ('rejected', 'revoked')
+			AND responded_at > LOCALTIMESTAMP - make_interval(secs => $3)
+		)`,
+		receiverID, postID, InviteCooldown.Seconds()).Scan(&active)
+	return active, err
+}
+
+// GetSentEventInvites restituisce gli inviti inviati dall'utente, i più recenti
+// per primi; postID e status (0 e "" = tutti) filtrano il risultato. Gli inviti
+// in attesa di una partita già iniziata risultano scaduti anche prima che il job
+// di pulizia li aggiorni.
+func (r *EventRepository) GetSentEventInvites(senderID int64, postID int, status string, limit, offset int) ([]models.SentEventInvite, error) {
+	rows, err := r.db.Query(`
+		SELECT id, post_id, titolo, receiver_id, username, profile_picture, message,
+			status, created_at, responded_at, expires_at, reminded_at
+		FROM (
+			SELECT ei.id, ei.post_id, COALESCE(p.titolo, '') AS titolo, ei.receiver_id, u.username,
+				u.profile_picture, COALESCE(ei.message, '') AS message,
+				CASE WHEN ei.status = 'pending' AND ei.expires_at <= LOCALTIMESTAMP THEN 'expired' ELSE ei.status END AS status,
+				ei.created_at, ei.responded_at, ei.expires_at, ei.reminded_at
+			FROM event_invites ei
+			JOIN users u ON u.id = ei.receiver_id
+			LEFT JOIN posts p ON p.id = ei.post_id
+			WHERE ei.sender_id = $1 AND ($2 = 0 OR ei.post_id = $2)
+		) sent
+		WHERE $3 = '' OR status = $3
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5`,
+		senderID, postID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.SentEventInvite{}
+	for rows.Next() {
+		var invite models.SentEventInvite
+		var profilePic sql.NullString
+		var respondedAt, expiresAt, remindedAt sql.NullTime
+		if err := rows.Scan(&invite.InviteID, &invite.PostID, &invite.Titolo, &invite.ReceiverID,
+			&invite.ReceiverUsername, &profilePic, &invite.Message, &invite.Status, &invite.CreatedAt,
+			&respondedAt, &expiresAt, &remindedAt); err != nil {
+			return nil, err
+		}
+		invite.ReceiverProfilePicture = profilePictureOrAvatar(invite.ReceiverID, profilePic)
+		if respondedAt.Valid {
+			invite.RespondedAt = &respondedAt.Time
+		}
+		if expiresAt.Valid {
+			invite.ExpiresAt = &expiresAt.Time
+		}
+		if remindedAt.Valid {
+			invite.RemindedAt = &remindedAt.Time
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeEventInvite ritira un invito ancora in attesa inviato dall'utente e
+// restituisce destinatario e post per rimuoverne la notifica
+func (r *EventRepository) RevokeEventInvite(inviteID, senderID int64) (int64, int, error) {
+	var receiverID int64
+	var postID int
+	err := r.db.QueryRow(`
+		UPDATE event_invites
+		SET status = 'revoked', responded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND sender_id = $2 AND status = 'pending'
+		AND (expires_at IS NULL OR expires_at > LOCALTIMESTAMP)
+		RETURNING receiver_id, post_id`,
+		inviteID, senderID).Scan(&receiverID, &postID)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrInviteNotFound
+	}
+	return receiverID, postID, err
+}
+
+// ExpireEventInvites chiude come scaduti gli inviti in attesa di partite già
+// iniziate, rimuovendone le notifiche; restituisce il numero di inviti scaduti
+func (r *EventRepository) ExpireEventInvites() (int, error) {
+	var expired int
+	err := r.db.QueryRow(`
+		WITH expired AS (
+			UPDATE event_invites
+			SET status = 'expired', updated_at = CURRENT_TIMESTAMP
+			WHERE status = 'pending' AND expires_at <= LOCALTIMESTAMP
+			RETURNING receiver_id, post_id
+		), removed AS (
+			DELETE FROM notifications n
+			USING expired e
+			WHERE n.user_id = e.receiver_id AND n.type = 'event_invite' AND n.related_id = e.post_id
+		)
+		SELECT COUNT(*) FROM expired`).Scan(&expired)
+	return expired, err
+}
+
+// GetDueInviteReminders restituisce gli inviti in attesa da più di after, mai
+// ricordati e la cui partita non è ancora iniziata
+func (r *EventRepository) GetDueInviteReminders(after time.Duration, limit int) ([]models.EventInviteReminder, error) {
+	rows, err := r.db.Query(`
+		SELECT ei.id, ei.receiver_id, ei.sender_id, u.username, ei.post_id, p.titolo, p.data_partita + p.ora_partita
+		FROM event_invites ei
+		JOIN users u ON u.id = ei.sender_id
+		JOIN posts p ON p.id = ei.post_id
+		WHERE ei.status = 'pending' AND ei.reminded_at IS NULL
+		AND ei.created_at <= LOCALTIMESTAMP - make_interval(secs => $1)
+		AND p.data_partita + p.ora_partita > LOCALTIMESTAMP
+		ORDER BY ei.created_at, ei.id
+		LIMIT $2`,
+		after.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []models.EventInviteReminder
+	for rows.Next() {
+		var reminder models.EventInviteReminder
+		if err := rows.Scan(&reminder.InviteID, &reminder.ReceiverID, &reminder.SenderID, &reminder.SenderUsername,
+			&reminder.PostID, &reminder.Titolo, &reminder.StartsAt); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// MarkInviteReminded registra il promemoria di un invito; restituisce false se
+// era già stato inviato o l'invito non è più in attesa
+func (r *EventRepository) MarkInviteReminded(inviteID int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE event_invites SET reminded_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending' AND reminded_at IS NULL`,
+		inviteID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,329 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori dei link di invito alle partite
+var (
+	ErrEventLinkNotFound  = errors.New("link di invito non trovato")
+	ErrEventLinkRevoked   = errors.New("link di invito disattivato")
+	ErrEventLinkExpired   = errors.New("link di invito scaduto")
+	ErrEventLinkExhausted = errors.New("link di invito esaurito")
+	ErrTooManyEventLinks  = errors.New("hai raggiunto il numero massimo di link attivi per questa partita")
+)
+
+// Numero massimo di link attivi per partita
+const maxActiveEventLinks = 20
+
+// eventLinkColumns sono le colonne lette da scanEventLink
+const eventLinkColumns = `id, post_id, max_uses, uses, expires_at, revoked_at, created_at,
+	revoked_at IS NULL AND expires_at > LOCALTIMESTAMP AND (max_uses IS NULL OR uses < max_uses)`
+
+// CreateEventLink genera un link di invito per la partita; il link scade dopo
+// expiresIn (0 = all'inizio della partita) e comunque non oltre l'inizio
+func (r *EventRepository) CreateEventLink(organizerID int64, postID int, maxUses int, expiresIn time.Duration) (*models.EventLink, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if event.organizerID == 0 || event.organizerID != organizerID {
+		return nil, ErrNotEventOrganizer
+	}
+	if event.started {
+		return nil, ErrEventAlreadyStarted
+	}
+
+	var active int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM event_links
+		WHERE post_id = $1 AND revoked_at IS NULL AND expires_at > LOCALTIMESTAMP`,
+		postID).Scan(&active)
+	if err != nil {
+		return nil, err
+	}
+	if active >= maxActiveEventLinks {
+		return nil, ErrTooManyEventLinks
+	}
+
+	var usesLimit sql.NullInt64
+	if maxUses > 0 {
+		usesLimit = sql.NullInt64{Int64: int64(maxUses), Valid: true}
+	}
+
+	row := tx.QueryRow(`
+		INSERT INTO event_links (post_id, created_by, max_uses, expires_at)
+		SELECT p.id, $2, $3, CASE
+			WHEN $4::FLOAT8 > 0 THEN LEAST(p.data_partita + p.ora_partita, LOCALTIMESTAMP + make_interval(secs => $4::FLOAT8))
+			ELSE p.data_partita + p.ora_partita
+		END
+		FROM posts p WHERE p.id = $1
+		RETURNING `+eventLinkColumns,
+		postID, organizerID, usesLimit, expiresIn.Seconds())
+	link, err := scanEventLink(row)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// GetEventLinks restituisce i link della partita, dal più recente
+func (r *EventRepository) GetEventLinks(organizerID int64, postID int) ([]models.EventLink, error) {
+	isOrganizer, err := r.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganizer {
+		return nil, ErrNotEventOrganizer
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+eventLinkColumns+`
+		FROM event_links
+		WHERE post_id = $1
+		ORDER BY created_at DESC, id DESC`,
+		postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.EventLink{}
+	for rows.Next() {
+		link, err := scanEventLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *link)
+	}
+	return links, rows.Err()
+}
+
+// GetEventLink restituisce un link creato dall'utente con i suoi utilizzi
+func (r *EventRepository) GetEventLink(organizerID, linkID int64) (*models.EventLink, error) {
+	row := r.db.QueryRow(`
+		SELECT `+eventLinkColumns+`
+		FROM event_links
+		WHERE id = $1 AND created_by = $2`,
+		linkID, organizerID)
+	link, err := scanEventLink(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT u.id, u.username, u.profile_picture, elr.status, elr.registered, elr.redeemed_at
+		FROM event_link_redemptions elr
+		JOIN users u ON u.id = elr.user_id
+		WHERE elr.link_id = $1
+		ORDER BY elr.redeemed_at DESC`,
+		linkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	link.Redemptions = []models.EventLinkRedemption{}
+	for rows.Next() {
+		var redemption models.EventLinkRedemption
+		var profilePic sql.NullString
+		if err := rows.Scan(&redemption.UserID, &redemption.Username, &profilePic, &redemption.Status,
+			&redemption.Registered, &redemption.RedeemedAt); err != nil {
+			return nil, err
+		}
+		redemption.ProfilePic = profilePictureOrAvatar(redemption.UserID, profilePic)
+		link.Redemptions = append(link.Redemptions, redemption)
+	}
+	return link, rows.Err()
+}
+
+// RevokeEventLink disattiva un link creato dall'utente
+func (r *EventRepository) RevokeEventLink(organizerID, linkID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE event_links SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND created_by = $2 AND revoked_at IS NULL`,
+		linkID, organizerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEventLinkNotFound
+	}
+	return nil
+}
+
+// GetEventLinkPreview restituisce l'anteprima della partita di un link ancora valido
+func (r *EventRepository) GetEventLinkPreview(linkID int64) (*models.EventLinkPreview, error) {
+	var preview models.EventLinkPreview
+	var maxUses sql.NullInt64
+	var uses, capacity, confirmed int
+	var revoked, expired bool
+	err := r.db.QueryRow(`
+		SELECT p.id, p.titolo, p.sport, p.livello, p.citta, p.provincia, p.data_partita + p.ora_partita,
+			COALESCE(u.username, ''), p.numero_giocatori,
+			(SELECT COUNT(*) FROM event_participants ep WHERE ep.post_id = p.id AND ep.status = 'confirmed'),
+			COALESCE(es.join_policy, 'open'), l.expires_at, l.max_uses, l.uses,
+			l.revoked_at IS NOT NULL, l.expires_at <= LOCALTIMESTAMP
+		FROM event_links l
+		JOIN posts p ON p.id = l.post_id
+		LEFT JOIN users u ON u.email = p.autore_email
+		LEFT JOIN event_settings es ON es.post_id = p.id
+		WHERE l.id = $1`,
+		linkID).Scan(&preview.PostID, &preview.Titolo, &preview.Sport, &preview.Livello, &preview.Citta,
+		&preview.Provincia, &preview.StartsAt, &preview.Organizer, &capacity, &confirmed,
+		&preview.JoinPolicy, &preview.ExpiresAt, &maxUses, &uses, &revoked, &expired)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkEventLink(revoked, expired, maxUses, uses); err != nil {
+		return nil, err
+	}
+
+	preview.SpotsLeft = capacity - confirmed
+	if preview.SpotsLeft < 0 {
+		preview.SpotsLeft = 0
+	}
+	return &preview, nil
+}
+
+// RedeemEventLink iscrive l'utente alla partita del link secondo la politica
+// dell'evento e registra l'utilizzo; registered indica un utente appena
+// registrato tramite il link. Restituisce l'ID del post e il cambio di stato.
+func (r *EventRepository) RedeemEventLink(linkID, userID int64, registered bool) (int, *models.ParticipationChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var postID, uses int
+	var maxUses sql.NullInt64
+	var revoked, expired, redeemed bool
+	err = tx.QueryRow(`
+		SELECT post_id, max_uses, uses, revoked_at IS NOT NULL, expires_at <= LOCALTIMESTAMP,
+			EXISTS(SELECT 1 FROM event_link_redemptions WHERE link_id = l.id AND user_id = $2)
+		FROM event_links l
+		WHERE id = $1
+		FOR UPDATE`,
+		linkID, userID).Scan(&postID, &maxUses, &uses, &revoked, &expired, &redeemed)
+	if err == sql.ErrNoRows {
+		return 0, nil, ErrEventLinkNotFound
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Chi ha già usato il link non consuma un altro utilizzo
+	if redeemed {
+		maxUses = sql.NullInt64{}
+	}
+	if err := checkEventLink(revoked, expired, maxUses, uses); err != nil {
+		return 0, nil, err
+	}
+
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if event.started {
+		return 0, nil, ErrEventLinkExpired
+	}
+
+	// Il link vale come invito dell'organizzatore (supera i vincoli "solo amici"
+	// e "solo su invito") ma non come approvazione: con la politica "approval"
+	// resta una richiesta da approvare
+	inviteSenderID := event.organizerID
+	if event.joinPolicy == models.JoinPolicyApproval {
+		inviteSenderID = 0
+	}
+
+	change, err := changeParticipationStatusTx(tx, userID, postID, models.ParticipantStatusConfirmed, inviteSenderID, nil)
+	switch {
+	case errors.Is(err, ErrAlreadyParticipant):
+		change = &models.ParticipationChange{PreviousStatus: models.ParticipantStatusConfirmed, Status: models.ParticipantStatusConfirmed}
+	case errors.Is(err, ErrAlreadyWaitlisted):
+		change = &models.ParticipationChange{PreviousStatus: models.ParticipantStatusWaitlisted, Status: models.ParticipantStatusWaitlisted}
+		change.WaitlistPosition, err = waitlistPosition(tx, userID, postID)
+		if err != nil {
+			return 0, nil, err
+		}
+	case err != nil:
+		return 0, nil, err
+	}
+
+	if !redeemed {
+		_, err = tx.Exec(`
+			INSERT INTO event_link_redemptions (link_id, user_id, status, registered)
+			VALUES ($1, $2, $3, $4)`,
+			linkID, userID, change.Status, registered)
+		if err != nil {
+			return 0, nil, err
+		}
+		if _, err := tx.Exec("UPDATE event_links SET uses = uses + 1 WHERE id = $1", linkID); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return postID, change, nil
+}
+
+// checkEventLink verifica che un link sia ancora utilizzabile
+func checkEventLink(revoked, expired bool, maxUses sql.NullInt64, uses int) error {
+	switch {
+	case revoked:
+		return ErrEventLinkRevoked
+	case expired:
+		return ErrEventLinkExpired
+	case maxUses.Valid && int64(uses) >= maxUses.Int64:
+		return ErrEventLinkExhausted
+	}
+	return nil
+}
+
+// scanEventLink legge un link dalle colonne eventLinkColumns
+func scanEventLink(row interface{ Scan(...interface{}) error }) (*models.EventLink, error) {
+	var link models.EventLink
+	var maxUses sql.NullInt64
+	var revokedAt sql.NullTime
+	if err := row.Scan(&link.ID, &link.PostID, &maxUses, &link.Uses, &link.ExpiresAt, &revokedAt,
+		&link.CreatedAt, &link.Active); err != nil {
+		return nil, err
+	}
+	if maxUses.Valid {
+		limit := int(maxUses.Int64)
+		link.MaxUses = &limit
+	}
+	if revokedAt.Valid {
+		link.RevokedAt = &revokedAt.Time
+	}
+	return &link, nil
+}
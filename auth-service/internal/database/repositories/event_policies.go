@@ -0,0 +1,333 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/validation"
+)
+
+// Errori legati alla politica di iscrizione e alle decisioni dell'organizzatore
+var (
+	ErrJoinNotAllowed      = errors.New("non puoi iscriverti a questo evento")
+	ErrRemovedFromEvent    = errors.New("sei stato rimosso da questo evento dall'organizzatore")
+	ErrJoinRequestNotFound = errors.New("richiesta di partecipazione non trovata")
+	ErrParticipantNotFound = errors.New("partecipante non trovato")
+	ErrCannotRemoveSelf    = errors.New("l'organizzatore non può rimuovere se stesso")
+)
+
+// admissionStatus applica la politica di iscrizione dell'evento a una richiesta
+// dell'utente e restituisce lo stato effettivo da applicare (es. pending invece
+// di confirmed negli eventi con approvazione)
+func admissionStatus(tx *sql.Tx, event *eventState, userID int64, postID int, current, target string, inviteSenderID int64) (string, error) {
+	// Un no-show può essere corretto solo dall'organizzatore (MarkAttendance)
+	if current == models.ParticipantStatusNoShow {
+		return "", fmt.Errorf("%w: la presenza è gestita dall'organizzatore", validation.ErrInvalidTransition)
+	}
+	if target != models.ParticipantStatusConfirmed && target != models.ParticipantStatusTentative {
+		return target, nil
+	}
+
+	switch current {
+	case models.ParticipantStatusConfirmed, models.ParticipantStatusWaitlisted, models.ParticipantStatusTentative:
+		// Già ammesso all'evento
+		return target, nil
+	case models.ParticipantStatusPending:
+		return current, nil
+	case models.ParticipantStatusRemoved:
+		return "", ErrRemovedFromEvent
+	case models.ParticipantStatusRejected:
+		return "", ErrJoinNotAllowed
+	}
+
+	if event.organizerID != 0 && userID == event.organizerID {
+		return target, nil
+	}
+
+	switch event.joinPolicy {
+	case models.JoinPolicyApproval:
+		// Solo un invito dell'organizzatore vale come approvazione
+		if inviteSenderID != 0 && inviteSenderID == event.organizerID {
+			return target, nil
+		}
+		return models.ParticipantStatusPending, nil
+
+	case models.JoinPolicyFriendsOnly:
+		inviter, err := isEventInviter(tx, event, inviteSenderID, userID, postID)
+		if err != nil || inviter {
+			return target, err
+		}
+		friends, err := areFriends(tx, userID, event.organizerID)
+		if err != nil {
+			return "", err
+		}
+		if !friends {
+			return "", ErrJoinNotAllowed
+		}
+		return target, nil
+
+	case models.JoinPolicyInviteOnly:
+		inviter, err := isEventInviter(tx, event, inviteSenderID, userID, postID)
+		if err != nil || inviter {
+			return target, err
+		}
+		invited, err := hasEventInvite(tx, event, userID, postID)
+		if err != nil {
+			return "", err
+		}
+		if !invited {
+			return "", ErrJoinNotAllowed
+		}
+		return target, nil
+	}
+
+	return target, nil
+}
+
+func areFriends(q queryRower, userID, otherID int64) (bool, error) {
+	if otherID == 0 {
+		return false, nil
+	}
+
+	var friends bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM friendships
+			WHERE user1_id = LEAST($1::INTEGER, $2::INTEGER) AND user2_id = GREATEST($1::INTEGER, $2::INTEGER)
+		)`,
+		userID, otherID).Scan(&friends)
+	return friends, err
+}
+
+// isEventInviter indica se un invito di senderID ammette userID agli eventi
+// "solo amici" e "solo su invito": vale solo se arriva dall'organizzatore o
+// da un partecipante confermato
+func isEventInviter(q queryRower, event *eventState, senderID, userID int64, postID int) (bool, error) {
+	if senderID == 0 || senderID == userID {
+		return false, nil
+	}
+	if event.organizerID != 0 && senderID == event.organizerID {
+		return true, nil
+	}
+
+	var confirmed bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM event_participants
+			WHERE post_id = $1 AND user_id = $2 AND status = 'confirmed'
+		)`,
+		postID, senderID).Scan(&confirmed)
+	return confirmed, err
+}
+
+// hasEventInvite indica se l'utente ha un invito valido all'evento, con le
+// stesse regole sul mittente di isEventInviter
+func hasEventInvite(q queryRower, event *eventState, userID int64, postID int) (bool, error) {
+	var invited bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM event_invites ei
+			WHERE ei.receiver_id = $1 AND ei.post_id = $2 AND ei.status IN ('pending', 'accepted')
+			AND ei.sender_id <> ei.receiver_id
+			AND (ei.sender_id = $3 OR EXISTS(
+				SELECT 1 FROM event_participants ep
+				WHERE ep.post_id = ei.post_id AND ep.user_id = ei.sender_id AND ep.status = 'confirmed'
+			))
+		)`,
+		userID, postID, event.organizerID).Scan(&invited)
+	return invited, err
+}
+
+// GetEventSettings restituisce le impostazioni dell'evento (politica "open" se mai impostate)
+func (r *EventRepository) GetEventSettings(postID int) (*models.EventSettings, error) {
+	settings := &models.EventSettings{PostID: postID, JoinPolicy: models.JoinPolicyOpen}
+
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1)", postID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrEventNotFound
+	}
+
+	err := r.db.QueryRow(`
+		SELECT join_policy, updated_at FROM event_settings WHERE post_id = $1`,
+		postID).Scan(&settings.JoinPolicy, &settings.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SetJoinPolicy imposta la politica di iscrizione; solo l'organizzatore può modificarla
+func (r *EventRepository) SetJoinPolicy(organizerID int64, postID int, policy string) (*models.EventSettings, error) {
+	isOrganizer, err := r.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganizer {
+		return nil, ErrNotEventOrganizer
+	}
+
+	settings := &models.EventSettings{PostID: postID, JoinPolicy: policy}
+	err = r.db.QueryRow(`
+		INSERT INTO event_settings (post_id, join_policy, updated_by, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (post_id)
+		DO UPDATE SET
+			join_policy = $2,
+			updated_by = $3,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`,
+		postID, policy, organizerID).Scan(&settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// GetJoinRequests restituisce le richieste di partecipazione in attesa, in ordine di arrivo
+func (r *EventRepository) GetJoinRequests(organizerID int64, postID int) ([]models.EventJoinRequest, error) {
+	isOrganizer, err := r.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganizer {
+		return nil, ErrNotEventOrganizer
+	}
+
+	rows, err := r.db.Query(`
+		SELECT u.id, u.username, u.nome, u.cognome, u.profile_picture,
+			COALESCE(ep.pending_at, ep.registered_at)
+		FROM event_participants ep
+		JOIN users u ON ep.user_id = u.id
+		WHERE ep.post_id = $1 AND ep.status = 'pending'
+		ORDER BY COALESCE(ep.pending_at, ep.registered_at) ASC`,
+		postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.EventJoinRequest
+	for rows.Next() {
+		var request models.EventJoinRequest
+		var profilePic sql.NullString
+		var requestedAt time.Time
+
+		err := rows.Scan(&request.UserID, &request.Username, &request.Nome, &request.Cognome, &profilePic, &requestedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		request.ProfilePic = profilePictureOrAvatar(request.UserID, profilePic)
+		request.RequestedAt = requestedAt
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+// DecideJoinRequest approva (confirmed, o waitlisted se l'evento è al completo)
+// oppure rifiuta la richiesta di partecipazione di un utente
+func (r *EventRepository) DecideJoinRequest(organizerID, userID int64, postID int, approve bool) (*models.ParticipationChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if event.organizerID == 0 || event.organizerID != organizerID {
+		return nil, ErrNotEventOrganizer
+	}
+
+	current, err := currentParticipationStatus(tx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	target := models.ParticipantStatusRejected
+	if approve {
+		target = models.ParticipantStatusConfirmed
+	}
+
+	// Una richiesta rifiutata può ancora essere approvata, non rifiutata di nuovo
+	if current != models.ParticipantStatusPending && !(approve && current == models.ParticipantStatusRejected) {
+		return nil, ErrJoinRequestNotFound
+	}
+
+	change, err := applyParticipationStatus(tx, event, userID, postID, current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// RemoveParticipant permette all'organizzatore di rimuovere un partecipante;
+// il posto liberato viene assegnato alla lista d'attesa
+func (r *EventRepository) RemoveParticipant(organizerID, userID int64, postID int) (*models.ParticipationChange, error) {
+	if organizerID == userID {
+		return nil, ErrCannotRemoveSelf
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if event.organizerID == 0 || event.organizerID != organizerID {
+		return nil, ErrNotEventOrganizer
+	}
+
+	current, err := currentParticipationStatus(tx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch current {
+	case models.ParticipantStatusConfirmed, models.ParticipantStatusWaitlisted,
+		models.ParticipantStatusTentative, models.ParticipantStatusPending:
+	default:
+		return nil, ErrParticipantNotFound
+	}
+
+	change, err := applyParticipationStatus(tx, event, userID, postID, current, models.ParticipantStatusRemoved)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// GetEventOrganizerID restituisce l'ID utente dell'organizzatore (autore del post)
+func (r *EventRepository) GetEventOrganizerID(postID int) (int64, error) {
+	var organizerID int64
+	err := r.db.QueryRow(`
+		SELECT u.id FROM posts p
+		JOIN users u ON u.email = p.autore_email
+		WHERE p.id = $1`,
+		postID).Scan(&organizerID)
+	if err == sql.ErrNoRows {
+		return 0, ErrEventNotFound
+	}
+	return organizerID, err
+}
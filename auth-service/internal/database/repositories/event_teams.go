@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori legati alla generazione delle squadre
+var (
+	ErrTeamsLocked    = errors.New("le squadre sono bloccate: sbloccale prima di rigenerarle")
+	ErrTeamsNotFound  = errors.New("nessuna squadra bloccata per questo evento")
+	ErrPairNotFriends = errors.New("i giocatori da tenere insieme devono essere amici")
+)
+
+// Abilità di default (scala 1-5) di un giocatore senza storico né valutazione
+const defaultPlayerSkill = 3.0
+
+// levelSkillSQL converte il livello di un post nella scala di abilità 1-5
+const levelSkillSQL = `CASE p.livello WHEN 'Principiante' THEN 2 WHEN 'Intermedio' THEN 3 WHEN 'Avanzato' THEN 4 END`
+
+// GetParticipantSkills restituisce l'abilità dei partecipanti confermati:
+// quella impostata dall'organizzatore per l'evento oppure la media dei livelli
+// delle partite già giocate
+func (r *EventRepository) GetParticipantSkills(postID int) (map[int64]models.TeamPlayer, error) {
+	rows, err := r.db.Query(`
+		SELECT ep.user_id, eps.skill,
+			(SELECT AVG(`+levelSkillSQL+`)
+			 FROM event_participants h
+			 JOIN posts p ON p.id = h.post_id
+			 WHERE h.user_id = ep.user_id AND h.post_id <> ep.post_id
+			 AND h.status = 'confirmed'
+			 AND p.data_partita + p.ora_partita <= LOCALTIMESTAMP)
+		FROM event_participants ep
+		LEFT JOIN event_player_skills eps ON eps.post_id = ep.post_id AND eps.user_id = ep.user_id
+		WHERE ep.post_id = $1 AND ep.status = 'confirmed'`,
+		postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := make(map[int64]models.TeamPlayer)
+	for rows.Next() {
+		var userID int64
+		var organizerSkill, historySkill sql.NullFloat64
+		if err := rows.Scan(&userID, &organizerSkill, &historySkill); err != nil {
+			return nil, err
+		}
+
+		player := models.TeamPlayer{UserID: userID, Skill: defaultPlayerSkill, SkillSource: models.SkillSourceDefault}
+		switch {
+		case organizerSkill.Valid:
+			player.Skill, player.SkillSource = organizerSkill.Float64, models.SkillSourceOrganizer
+		case historySkill.Valid:
+			player.Skill, player.SkillSource = historySkill.Float64, models.SkillSourceHistory
+		}
+		skills[userID] = player
+	}
+
+	return skills, rows.Err()
+}
+
+// SetParticipantSkill imposta l'abilità (1-5) di un partecipante; solo l'organizzatore
+func (r *EventRepository) SetParticipantSkill(organizerID, userID int64, postID int, skill float64) error {
+	isOrganizer, err := r.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return err
+	}
+	if !isOrganizer {
+		return ErrNotEventOrganizer
+	}
+
+	status, err := currentParticipationStatus(r.db, userID, postID)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case models.ParticipantStatusConfirmed, models.ParticipantStatusWaitlisted, models.ParticipantStatusTentative:
+	default:
+		return ErrParticipantNotFound
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO event_player_skills (post_id, user_id, skill, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (post_id, user_id)
+		DO UPDATE SET
+			skill = $3,
+			updated_by = $4,
+			updated_at = CURRENT_TIMESTAMP`,
+		postID, userID, skill, organizerID)
+	return err
+}
+
+// ValidateTeamPairs verifica che le coppie da tenere insieme siano composte da amici
+func (r *EventRepository) ValidateTeamPairs(pairs [][2]int64) error {
+	for _, pair := range pairs {
+		friends, err := areFriends(r.db, pair[0], pair[1])
+		if err != nil {
+			return err
+		}
+		if !friends {
+			return ErrPairNotFriends
+		}
+	}
+	return nil
+}
+
+// GetLockedTeams restituisce le squadre bloccate dell'evento (ErrTeamsNotFound se assenti)
+func (r *EventRepository) GetLockedTeams(postID int) (*models.EventTeams, error) {
+	teams := &models.EventTeams{PostID: postID, Locked: true}
+	var pairsJSON, teamsJSON []byte
+	var lockedAt time.Time
+
+	err := r.db.QueryRow(`
+		SELECT team_count, seed, pairs, teams, locked_at
+		FROM event_teams WHERE post_id = $1`,
+		postID).Scan(&teams.TeamCount, &teams.Seed, &pairsJSON, &teamsJSON, &lockedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTeamsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(pairsJSON, &teams.Pairs); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(teamsJSON, &teams.Teams); err != nil {
+		return nil, err
+	}
+	teams.LockedAt = &lockedAt
+	return teams, nil
+}
+
+// LockTeams salva le squadre generate; solo l'organizzatore, e solo se non già bloccate
+func (r *EventRepository) LockTeams(organizerID int64, teams *models.EventTeams) error {
+	isOrganizer, err := r.IsEventOrganizer(organizerID, teams.PostID)
+	if err != nil {
+		return err
+	}
+	if !isOrganizer {
+		return ErrNotEventOrganizer
+	}
+
+	pairs := teams.Pairs
+	if pairs == nil {
+		pairs = [][2]int64{}
+	}
+	pairsJSON, err := json.Marshal(pairs)
+	if err != nil {
+		return err
+	}
+	teamsJSON, err := json.Marshal(teams.Teams)
+	if err != nil {
+		return err
+	}
+
+	var lockedAt time.Time
+	err = r.db.QueryRow(`
+		INSERT INTO event_teams (post_id, team_count, seed, pairs, teams, locked_by, locked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		ON CONFLICT (post_id) DO NOTHING
+		RETURNING locked_at`,
+		teams.PostID, teams.TeamCount, teams.Seed, pairsJSON, teamsJSON, organizerID).Scan(&lockedAt)
+	if err == sql.ErrNoRows {
+		return ErrTeamsLocked
+	}
+	if err != nil {
+		return err
+	}
+
+	teams.Locked = true
+	teams.LockedAt = &lockedAt
+	return nil
+}
+
+// UnlockTeams elimina le squadre bloccate, permettendo di rigenerarle
+func (r *EventRepository) UnlockTeams(organizerID int64, postID int) error {
+	isOrganizer, err := r.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return err
+	}
+	if !isOrganizer {
+		return ErrNotEventOrganizer
+	}
+
+	result, err := r.db.Exec("DELETE FROM event_teams WHERE post_id = $1", postID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTeamsNotFound
+	}
+	return nil
+}
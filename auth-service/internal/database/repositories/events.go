@@ -2,10 +2,26 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 
+	"github.com/lib/pq"
 	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/validation"
+)
+
+// Errori restituiti dall'iscrizione agli eventi
+var (
+	ErrEventNotFound      = errors.New("evento non trovato")
+	ErrAlreadyParticipant = errors.New("utente già iscritto a questo evento")
+	ErrAlreadyWaitlisted  = errors.New("utente già in lista d'attesa per questo evento")
+	ErrNotEventOrganizer  = errors.New("solo l'organizzatore può gestire questo evento")
+	ErrEventNotStarted    = errors.New("l'evento non è ancora iniziato")
+	// Dopo l'inizio ci si può solo disiscrivere: le presenze le gestisce l'organizzatore
+	ErrEventAlreadyStarted = errors.New("l'evento è già iniziato")
+	// Un evento annullato è anche un evento non trovato per chi non distingue i due casi
+	ErrEventCancelled = fmt.Errorf("l'evento è stato annullato: %w", ErrEventNotFound)
 )
 
 type EventRepository struct {
@@ -16,23 +32,58 @@ func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
-// AddFavorite aggiunge un post ai preferiti dell'utente
+// AddFavorite aggiunge un post ai preferiti dell'utente, in fondo alla raccolta predefinita
 func (r *EventRepository) AddFavorite(userID int64, postID int) error {
-	_, err := r.db.Exec(`
-		INSERT INTO user_favorites (user_id, post_id) 
-		VALUES ($1, $2) 
-		ON CONFLICT (user_id, post_id) DO NOTHING`,
-		userID, postID)
-	return err
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Un post già salvato in un'altra raccolta non viene aggiunto alla predefinita
+	var saved bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_favorites WHERE user_id = $1 AND post_id = $2)`,
+		userID, postID).Scan(&saved)
+	if err != nil || saved {
+		return err
+	}
+
+	collectionID, err := ensureDefaultCollection(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := addFavoriteToCollection(tx, userID, collectionID, postID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// RemoveFavorite rimuove un post dai preferiti dell'utente
+// RemoveFavorite rimuove un post dai preferiti dell'utente e da tutte le sue raccolte
 func (r *EventRepository) RemoveFavorite(userID int64, postID int) error {
-	_, err := r.db.Exec(`
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		DELETE FROM user_favorites 
 		WHERE user_id = $1 AND post_id = $2`,
 		userID, postID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM favorite_collection_items i
+		USING favorite_collections c
+		WHERE c.id = i.collection_id AND c.user_id = $1 AND i.post_id = $2`,
+		userID, postID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // IsFavorite controlla se un post è nei preferiti dell'utente
@@ -50,57 +101,454 @@ func (r *EventRepository) IsFavorite(userID int64, postID int) (bool, error) {
 	return count > 0, nil
 }
 
-// GetUserFavorites restituisce tutti i post ID preferiti di un utente
-func (r *EventRepository) GetUserFavorites(userID int64) ([]int, error) {
-	rows, err := r.db.Query(`
-		SELECT post_id FROM user_favorites 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC`,
-		userID)
+// Colonne con il timestamp dell'ultimo passaggio a ciascuno stato
+var participationStatusColumns = map[string]string{
+	models.ParticipantStatusConfirmed:  "confirmed_at",
+	models.ParticipantStatusWaitlisted: "waitlisted_at",
+	models.ParticipantStatusTentative:  "tentative_at",
+	models.ParticipantStatusDeclined:   "declined_at",
+	models.ParticipantStatusCancelled:  "cancelled_at",
+	models.ParticipantStatusNoShow:     "no_show_at",
+	models.ParticipantStatusPending:    "pending_at",
+	models.ParticipantStatusRejected:   "rejected_at",
+	models.ParticipantStatusRemoved:    "removed_at",
+}
 
+// eventState contiene i dati del post letti sotto lock
+type eventState struct {
+	capacity    int
+	started     bool
+	joinPolicy  string
+	organizerID int64 // 0 se l'autore del post non ha un account
+}
+
+// JoinEvent - Iscrive un utente a un evento: se i posti (numero_giocatori)
+// sono esauriti l'utente finisce in lista d'attesa. Con check non nil le
+// sovrapposizioni con gli altri impegni vengono verificate sotto lock.
+func (r *EventRepository) JoinEvent(userID int64, postID int, check *ScheduleCheck) (*models.ParticipationChange, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var favorites []int
-	for rows.Next() {
-		var postID int
-		if err := rows.Scan(&postID); err != nil {
+	change, err := changeParticipationStatusTx(tx, userID, postID, models.ParticipantStatusConfirmed, 0, check)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// LeaveEvent - Disiscrive un utente da un evento. La partecipazione non viene
+// cancellata ma passa a "cancelled"; se si libera un posto vengono promossi
+// i primi utenti in lista d'attesa. Dopo l'inizio dell'evento restituisce
+// ErrEventAlreadyStarted.
+func (r *EventRepository) LeaveEvent(userID int64, postID int) (*models.ParticipationChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Se il post non esiste più o l'evento è annullato la disiscrizione avviene
+	// comunque, senza promozioni
+	event, err := lockEvent(tx, postID)
+	deleted := err == ErrEventNotFound || err == ErrEventCancelled
+	if deleted {
+		event = &eventState{started: true}
+	} else if err != nil {
+		return nil, err
+	}
+
+	current, err := currentParticipationStatus(tx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Disiscriversi senza essere iscritti non è un errore
+	switch current {
+	case "", models.ParticipantStatusCancelled, models.ParticipantStatusDeclined,
+		models.ParticipantStatusRejected, models.ParticipantStatusRemoved:
+		return &models.ParticipationChange{PreviousStatus: current, Status: current}, nil
+	}
+
+	var change *models.ParticipationChange
+	if deleted {
+		if err := validation.ValidateParticipationTransition(current, models.ParticipantStatusCancelled); err != nil {
 			return nil, err
 		}
-		favorites = append(favorites, postID)
+		change, err = writeParticipationChange(tx, event, userID, postID, current, models.ParticipantStatusCancelled)
+	} else {
+		change, err = applyParticipationStatus(tx, event, userID, postID, current, models.ParticipantStatusCancelled)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return favorites, rows.Err()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
 }
 
-// JoinEvent - Iscrive un utente a un evento
-func (r *EventRepository) JoinEvent(userID int64, postID int) error {
-	// Controlla se l'utente è già iscritto
-	isAlreadyParticipant, err := r.IsEventParticipant(userID, postID)
+// SetParticipationStatus porta la partecipazione dell'utente allo stato richiesto,
+// validando la transizione e rispettando la capienza dell'evento
+func (r *EventRepository) SetParticipationStatus(userID int64, postID int, status string) (*models.ParticipationChange, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	
-	if isAlreadyParticipant {
-		return fmt.Errorf("utente già iscritto a questo evento")
+	defer tx.Rollback()
+
+	change, err := changeParticipationStatusTx(tx, userID, postID, status, 0, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = r.db.Exec(`
-		INSERT INTO event_participants (user_id, post_id, status) 
-		VALUES ($1, $2, 'confirmed')`,
-		userID, postID)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// MarkAttendance permette all'organizzatore di segnare un partecipante come
+// no-show (o di annullare la segnalazione) dopo l'inizio dell'evento
+func (r *EventRepository) MarkAttendance(organizerID, userID int64, postID int, status string) (*models.ParticipationChange, error) {
+	if status != models.ParticipantStatusNoShow && status != models.ParticipantStatusConfirmed {
+		return nil, fmt.Errorf("%w: stato presenza non valido %s", validation.ErrInvalidTransition, status)
+	}
+
+	isOrganizer, err := r.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganizer {
+		return nil, ErrNotEventOrganizer
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !event.started {
+		return nil, ErrEventNotStarted
+	}
+
+	current, err := currentParticipationStatus(tx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+	// Si può segnare come no-show solo un confermato e correggere solo una
+	// segnalazione di no-show, non iscrivere altri utenti
+	switch {
+	case current == status:
+		return &models.ParticipationChange{PreviousStatus: current, Status: current}, nil
+	case status == models.ParticipantStatusNoShow && current != models.ParticipantStatusConfirmed,
+		status == models.ParticipantStatusConfirmed && current != models.ParticipantStatusNoShow:
+		return nil, fmt.Errorf("%w: da %s a %s", validation.ErrInvalidTransition, current, status)
+	}
+
+	// Non passa da applyParticipationStatus: dopo l'inizio solo l'organizzatore
+	// può riportare un no-show tra i confermati, senza limiti di capienza
+	change, err := writeParticipationChange(tx, event, userID, postID, current, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// IsEventOrganizer verifica se l'utente è l'autore del post (posts.autore_email)
+func (r *EventRepository) IsEventOrganizer(userID int64, postID int) (bool, error) {
+	var isOrganizer bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM posts p
+			JOIN users u ON u.email = p.autore_email
+			WHERE p.id = $1 AND u.id = $2
+		)`,
+		postID, userID).Scan(&isOrganizer)
+	return isOrganizer, err
+}
+
+// changeParticipationStatusTx esegue un cambio di stato richiesto dall'utente
+// all'interno di una transazione, applicando la politica di iscrizione dell'evento.
+// inviteSenderID è il mittente dell'invito accettato (0 se l'utente si iscrive da sé).
+func changeParticipationStatusTx(tx *sql.Tx, userID int64, postID int, status string, inviteSenderID int64, check *ScheduleCheck) (*models.ParticipationChange, error) {
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := currentParticipationStatus(tx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := admissionStatus(tx, event, userID, postID, current, status, inviteSenderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chi occupa già un posto nel calendario per questo evento non ha nuove sovrapposizioni
+	var conflicts []models.Commitment
+	if check != nil && !event.started && !commitmentStatuses[current] && commitmentStatuses[target] {
+		conflicts, err = scheduleConflicts(tx, check.Schedule, userID, postID)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 && !check.Force {
+			return nil, &ScheduleConflictError{Conflicts: conflicts}
+		}
+	}
+
+	change, err := applyParticipationStatus(tx, event, userID, postID, current, target)
+	if err != nil {
+		return nil, err
+	}
+	change.Conflicts = conflicts
+	return change, nil
+}
+
+// Stati che l'utente raggiunge da sé (iscrizione, RSVP, disiscrizione): dopo
+// l'inizio dell'evento la partecipazione la gestisce solo l'organizzatore
+var selfServiceStatuses = map[string]bool{
+	models.ParticipantStatusConfirmed:  true,
+	models.ParticipantStatusTentative:  true,
+	models.ParticipantStatusPending:    true,
+	models.ParticipantStatusWaitlisted: true,
+	models.ParticipantStatusDeclined:   true,
+	models.ParticipantStatusCancelled:  true,
+}
+
+// applyParticipationStatus valida la transizione current -> target e la applica.
+// Dopo l'inizio dell'evento non si può più entrare né uscire: un confermato
+// resta tale finché l'organizzatore non ne segna la presenza.
+func applyParticipationStatus(tx *sql.Tx, event *eventState, userID int64, postID int, current, target string) (*models.ParticipationChange, error) {
+	switch {
+	case current == target && current == models.ParticipantStatusConfirmed:
+		return nil, ErrAlreadyParticipant
+	case current == target:
+		return &models.ParticipationChange{PreviousStatus: current, Status: current}, nil
+	}
+
+	if event.started && selfServiceStatuses[target] {
+		return nil, ErrEventAlreadyStarted
+	}
+
+	if err := validation.ValidateParticipationTransition(current, target); err != nil {
+		return nil, err
+	}
+
+	return writeParticipationChange(tx, event, userID, postID, current, target)
+}
+
+// writeParticipationChange applica una transizione già validata. Una conferma
+// su un evento al completo diventa "waitlisted"; se un confermato lascia il
+// posto prima dell'inizio, la lista d'attesa viene fatta scorrere.
+func writeParticipationChange(tx *sql.Tx, event *eventState, userID int64, postID int, current, target string) (*models.ParticipationChange, error) {
+	newStatus := target
+	if target == models.ParticipantStatusConfirmed && !event.started {
+		confirmed, err := countConfirmedParticipants(tx, postID)
+		if err != nil {
+			return nil, err
+		}
+		if confirmed >= event.capacity {
+			if current == models.ParticipantStatusWaitlisted {
+				return nil, ErrAlreadyWaitlisted
+			}
+			newStatus = models.ParticipantStatusWaitlisted
+		}
+	}
+
+	if err := writeParticipationStatus(tx, userID, postID, current, newStatus); err != nil {
+		return nil, err
+	}
+
+	change := &models.ParticipationChange{PreviousStatus: current, Status: newStatus}
+	if newStatus == models.ParticipantStatusWaitlisted {
+		position, err := waitlistPosition(tx, userID, postID)
+		if err != nil {
+			return nil, err
+		}
+		change.WaitlistPosition = position
+	}
+
+	if current == models.ParticipantStatusConfirmed && !event.started {
+		promoted, err := promoteFromWaitlist(tx, postID, event.capacity)
+		if err != nil {
+			return nil, err
+		}
+		change.PromotedUserIDs = promoted
+	}
+	return change, nil
+}
+
+// writeParticipationStatus inserisce o aggiorna la riga registrando il timestamp
+// della transizione. Chi si (re)iscrive torna in fondo alla lista d'attesa.
+func writeParticipationStatus(tx *sql.Tx, userID int64, postID int, current, status string) error {
+	column := participationStatusColumns[status]
+
+	if current == "" {
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO event_participants (user_id, post_id, status, %s, status_updated_at) 
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`, column),
+			userID, postID, status)
+		return err
+	}
+
+	occupiesSpot := func(s string) bool {
+		return s == models.ParticipantStatusConfirmed || s == models.ParticipantStatusWaitlisted
+	}
+	resetRegistration := occupiesSpot(status) && !occupiesSpot(current)
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		UPDATE event_participants 
+		SET status = $3, %s = CURRENT_TIMESTAMP, status_updated_at = CURRENT_TIMESTAMP,
+			registered_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP ELSE registered_at END
+		WHERE user_id = $1 AND post_id = $2`, column),
+		userID, postID, status, resetRegistration)
 	return err
 }
 
-// LeaveEvent - Disiscrive un utente da un evento
-func (r *EventRepository) LeaveEvent(userID int64, postID int) error {
-	_, err := r.db.Exec(`
-		DELETE FROM event_participants 
+// lockEvent blocca la riga del post e ne restituisce capienza, stato e politica di iscrizione:
+// le iscrizioni concorrenti allo stesso evento vengono così serializzate. Un evento
+// annullato il cui post non è ancora stato eliminato restituisce ErrEventCancelled.
+func lockEvent(tx *sql.Tx, postID int) (*eventState, error) {
+	var event eventState
+	var cancelled bool
+	err := tx.QueryRow(`
+		SELECT p.numero_giocatori, (p.data_partita + p.ora_partita) <= LOCALTIMESTAMP,
+			COALESCE(es.join_policy, 'open'), COALESCE(u.id, 0),
+			EXISTS (SELECT 1 FROM cancelled_events ce WHERE ce.post_id = p.id)
+		FROM posts p
+		LEFT JOIN event_settings es ON es.post_id = p.id
+		LEFT JOIN users u ON u.email = p.autore_email
+		WHERE p.id = $1 
+		FOR UPDATE OF p`,
+		postID).Scan(&event.capacity, &event.started, &event.joinPolicy, &event.organizerID, &cancelled)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cancelled {
+		return nil, ErrEventCancelled
+	}
+	return &event, nil
+}
+
+func currentParticipationStatus(q queryRower, userID int64, postID int) (string, error) {
+	var status string
+	err := q.QueryRow(`
+		SELECT status FROM event_participants 
 		WHERE user_id = $1 AND post_id = $2`,
-		userID, postID)
-	return err
+		userID, postID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+func countConfirmedParticipants(q queryRower, postID int) (int, error) {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM event_participants 
+		WHERE post_id = $1 AND status = 'confirmed'`,
+		postID).Scan(&count)
+	return count, err
+}
+
+// waitlistPosition calcola la posizione (da 1) dell'utente in lista d'attesa,
+// in ordine di iscrizione
+func waitlistPosition(q queryRower, userID int64, postID int) (int, error) {
+	var position int
+	err := q.QueryRow(`
+		SELECT COUNT(*) 
+		FROM event_participants w
+		JOIN event_participants me ON me.post_id = w.post_id
+		WHERE me.user_id = $1 AND me.post_id = $2
+		AND w.status = 'waitlisted'
+		AND (w.registered_at, w.id) <= (me.registered_at, me.id)`,
+		userID, postID).Scan(&position)
+	return position, err
+}
+
+// promoteFromWaitlist conferma i primi utenti in lista d'attesa fino a esaurire
+// i posti liberi; va chiamata con la riga del post già bloccata
+func promoteFromWaitlist(tx *sql.Tx, postID int, capacity int) ([]int64, error) {
+	confirmed, err := countConfirmedParticipants(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	freeSpots := capacity - confirmed
+	if freeSpots <= 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+		UPDATE event_participants 
+		SET status = 'confirmed', confirmed_at = CURRENT_TIMESTAMP, status_updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM event_participants 
+			WHERE post_id = $1 AND status = 'waitlisted'
+			ORDER BY registered_at ASC, id ASC
+			LIMIT $2
+		)
+		RETURNING user_id`,
+		postID, freeSpots)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promoted []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, userID)
+	}
+	return promoted, rows.Err()
+}
+
+// GetParticipationStatus restituisce lo stato dell'iscrizione dell'utente
+// (stringa vuota se non iscritto) e l'eventuale posizione in lista d'attesa
+func (r *EventRepository) GetParticipationStatus(userID int64, postID int) (string, int, error) {
+	status, err := currentParticipationStatus(r.db, userID, postID)
+	if err != nil || status != models.ParticipantStatusWaitlisted {
+		return status, 0, err
+	}
+
+	position, err := waitlistPosition(r.db, userID, postID)
+	return status, position, err
+}
+
+// GetEventWaitlistCount - Ottiene il numero di utenti in lista d'attesa per un evento
+func (r *EventRepository) GetEventWaitlistCount(postID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM event_participants 
+		WHERE post_id = $1 AND status = 'waitlisted'`,
+		postID).Scan(&count)
+
+	return count, err
 }
 
 // IsEventParticipant - Controlla se un utente è iscritto a un evento
@@ -118,48 +566,72 @@ func (r *EventRepository) IsEventParticipant(userID int64, postID int) (bool, er
 	return count > 0, nil
 }
 
-// GetEventParticipants - Ottiene tutti i partecipanti di un evento con le loro informazioni
-func (r *EventRepository) GetEventParticipants(postID int) ([]map[string]interface{}, error) {
+// GetEventParticipants - Ottiene i partecipanti di un evento con le loro informazioni
+// e impostazioni di privacy, filtrati per stato della partecipazione
+func (r *EventRepository) GetEventParticipants(postID int, statuses []string) ([]models.EventParticipant, error) {
+	defaults := models.DefaultPrivacySettings()
 	rows, err := r.db.Query(`
 		SELECT 
 			u.id, u.username, u.nome, u.cognome, u.email, u.profile_picture,
-			ep.registered_at
+			ep.registered_at, ep.status, COALESCE(ep.status_updated_at, ep.registered_at),
+			COALESCE(ps.strangers, $3), COALESCE(ps.friends, $4), COALESCE(ps.co_participants, $5)
 		FROM event_participants ep
 		JOIN users u ON ep.user_id = u.id
-		WHERE ep.post_id = $1 AND ep.status = 'confirmed'
+		LEFT JOIN user_privacy_settings ps ON ps.user_id = u.id
+		WHERE ep.post_id = $1 AND ep.status = ANY($2)
 		ORDER BY ep.registered_at ASC`,
-		postID)
+		postID, pq.Array(statuses), defaults.Strangers, defaults.Friends, defaults.CoParticipants)
 
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var participants []map[string]interface{}
+	participants := []models.EventParticipant{}
 	for rows.Next() {
-		var userID int64
-		var username, nome, cognome, email, profilePic, registeredAt string
-		
-		err := rows.Scan(&userID, &username, &nome, &cognome, &email, &profilePic, &registeredAt)
+		var participant models.EventParticipant
+		var profilePic sql.NullString
+
+		err := rows.Scan(&participant.UserID, &participant.Username, &participant.Nome, &participant.Cognome,
+			&participant.Email, &profilePic, &participant.RegisteredAt, &participant.Status, &participant.StatusUpdatedAt,
+			&participant.Privacy.Strangers, &participant.Privacy.Friends, &participant.Privacy.CoParticipants)
 		if err != nil {
 			return nil, err
 		}
 
-		participant := map[string]interface{}{
-			"user_id":         userID,
-			"username":        username,
-			"nome":            nome,
-			"cognome":         cognome,
-			"email":           email,
-			"profile_picture": profilePic,
-			"registered_at":   registeredAt,
-		}
+		participant.ProfilePic = profilePictureOrAvatar(participant.UserID, profilePic)
 		participants = append(participants, participant)
 	}
 
 	return participants, rows.Err()
 }
 
+// GetParticipantFriendIDs restituisce gli amici dell'utente tra gli iscritti
+// all'evento, in qualsiasi stato
+func (r *EventRepository) GetParticipantFriendIDs(userID int64, postID int) (map[int64]bool, error) {
+	rows, err := r.db.Query(`
+		SELECT ep.user_id
+		FROM event_participants ep
+		JOIN friendships f ON (f.user1_id = $1 AND f.user2_id = ep.user_id)
+			OR (f.user2_id = $1 AND f.user1_id = ep.user_id)
+		WHERE ep.post_id = $2`,
+		userID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	friendIDs := make(map[int64]bool)
+	for rows.Next() {
+		var friendID int64
+		if err := rows.Scan(&friendID); err != nil {
+			return nil, err
+		}
+		friendIDs[friendID] = true
+	}
+	return friendIDs, rows.Err()
+}
+
 // GetEventParticipantCount - Ottiene il numero di partecipanti iscritti a un evento
 func (r *EventRepository) GetEventParticipantCount(postID int) (int, error) {
 	var count int
@@ -171,43 +643,62 @@ func (r *EventRepository) GetEventParticipantCount(postID int) (int, error) {
 	return count, err
 }
 
-// GetUserParticipations - Ottiene tutti gli eventi a cui un utente è iscritto
-func (r *EventRepository) GetUserParticipations(userID int64) ([]int, error) {
-	rows, err := r.db.Query(`
-		SELECT post_id FROM event_participants 
-		WHERE user_id = $1 AND status = 'confirmed'
-		ORDER BY registered_at DESC`,
-		userID)
+// SendEventInvite - Invia un invito per un evento; l'invito scade all'inizio della partita.
+// Valgono gli stessi controlli sul destinatario di SendBulkEventInvites.
+func (r *EventRepository) SendEventInvite(senderID, receiverID int64, postID int, message string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	event, err := lockEvent(tx, postID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if event.started {
+		return ErrEventAlreadyStarted
 	}
-	defer rows.Close()
 
-	var participations []int
-	for rows.Next() {
-		var postID int
-		if err := rows.Scan(&postID); err != nil {
-			return nil, err
-		}
-		participations = append(participations, postID)
+	if receiverID == senderID {
+		return ErrInviteSelf
+	}
+	friends, err := areFriends(tx, senderID, receiverID)
+	if err != nil {
+		return err
+	}
+	if !friends {
+		return ErrInviteNotFriend
+	}
+	if receiverID == event.organizerID {
+		return ErrInviteOrganizer
 	}
 
-	return participations, rows.Err()
-}
+	participation, err := currentParticipationStatus(tx, receiverID, postID)
+	if err != nil {
+		return err
+	}
+	switch participation {
+	case models.ParticipantStatusConfirmed, models.ParticipantStatusTentative,
+		models.ParticipantStatusPending, models.ParticipantStatusWaitlisted:
+		return ErrInviteAlreadyParticipant
+	case models.ParticipantStatusRejected, models.ParticipantStatusRemoved:
+		// Un invito non deve aggirare la decisione dell'organizzatore
+		return ErrInviteExcluded
+	}
 
-// SendEventInvite - Invia un invito per un evento
-func (r *EventRepository) SendEventInvite(senderID, receiverID int64, postID int, message string) error {
-	_, err := r.db.Exec(`
-		INSERT INTO event_invites (sender_id, receiver_id, post_id, message, status) 
-		VALUES ($1, $2, $3, $4, 'pending')
-		ON CONFLICT (receiver_id, post_id) 
-		DO UPDATE SET 
-			status = 'pending', 
-			message = $4,
-			updated_at = CURRENT_TIMESTAMP`,
-		senderID, receiverID, postID, message)
-	return err
+	cooldown, err := inviteCooldownActive(tx, receiverID, postID)
+	if err != nil {
+		return err
+	}
+	if cooldown {
+		return ErrInviteCooldown
+	}
+
+	if err := upsertEventInvite(tx, senderID, receiverID, postID, message); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // CheckPendingEventInvite - Controlla se esiste un invito pendente per un evento
@@ -215,7 +706,8 @@ func (r *EventRepository) CheckPendingEventInvite(receiverID int64, postID int)
 	var count int
 	err := r.db.QueryRow(`
 		SELECT COUNT(*) FROM event_invites 
-		WHERE receiver_id = $1 AND post_id = $2 AND status = 'pending'`,
+		WHERE receiver_id = $1 AND post_id = $2 AND status = 'pending'
+		AND (expires_at IS NULL OR expires_at > LOCALTIMESTAMP)`,
 		receiverID, postID).Scan(&count)
 
 	if err != nil {
@@ -225,11 +717,12 @@ func (r *EventRepository) CheckPendingEventInvite(receiverID int64, postID int)
 	return count > 0, nil
 }
 
-// AcceptEventInvite - Accetta un invito per un evento e iscrive automaticamente l'utente
-func (r *EventRepository) AcceptEventInvite(inviteID, receiverID int64) error {
+// AcceptEventInvite - Accetta un invito per un evento e iscrive automaticamente l'utente,
+// in lista d'attesa se l'evento è al completo
+func (r *EventRepository) AcceptEventInvite(inviteID, receiverID int64, check *ScheduleCheck) (*models.ParticipationChange, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
@@ -237,45 +730,69 @@ func (r *EventRepository) AcceptEventInvite(inviteID, receiverID int64) error {
 	var senderID, actualReceiverID int64
 	var postID int
 	var status string
+	var expired bool
 	err = tx.QueryRow(`
-		SELECT sender_id, receiver_id, post_id, status 
+		SELECT sender_id, receiver_id, post_id, status, COALESCE(expires_at <= LOCALTIMESTAMP, FALSE)
 		FROM event_invites 
-		WHERE id = $1`, inviteID).Scan(&senderID, &actualReceiverID, &postID, &status)
+		WHERE id = $1
+		FOR UPDATE`, inviteID).Scan(&senderID, &actualReceiverID, &postID, &status, &expired)
 
 	if err != nil {
-		return fmt.Errorf("invito non trovato: %v", err)
+		return nil, fmt.Errorf("invito non trovato: %v", err)
 	}
 
 	// Verifica che l'utente sia il destinatario dell'invito
 	if actualReceiverID != receiverID {
-		return fmt.Errorf("non autorizzato ad accettare questo invito")
+		return nil, fmt.Errorf("non autorizzato ad accettare questo invito")
 	}
 
 	// Verifica che l'invito sia ancora pendente
-	if status != "pending" {
-		return fmt.Errorf("l'invito non è più pendente")
+	if status != models.EventInviteStatusPending {
+		return nil, fmt.Errorf("l'invito non è più pendente")
+	}
+
+	// La partita è già iniziata: l'invito viene chiuso come scaduto
+	if expired {
+		if _, err := tx.Exec(`
+			UPDATE event_invites 
+			SET status = 'expired', updated_at = CURRENT_TIMESTAMP 
+			WHERE id = $1`, inviteID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrInviteExpired
 	}
 
 	// Aggiorna lo status dell'invito
 	_, err = tx.Exec(`
 		UPDATE event_invites 
-		SET status = 'accepted', updated_at = CURRENT_TIMESTAMP 
+		SET status = 'accepted', responded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = $1`, inviteID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Iscrive automaticamente l'utente all'evento (se non è già iscritto)
-	_, err = tx.Exec(`
-		INSERT INTO event_participants (user_id, post_id, status) 
-		VALUES ($1, $2, 'confirmed')
-		ON CONFLICT (user_id, post_id) DO NOTHING`,
-		receiverID, postID)
-	if err != nil {
-		return err
+	result, err := changeParticipationStatusTx(tx, receiverID, postID, models.ParticipantStatusConfirmed, senderID, check)
+	switch {
+	case errors.Is(err, ErrAlreadyParticipant):
+		result = &models.ParticipationChange{PreviousStatus: models.ParticipantStatusConfirmed, Status: models.ParticipantStatusConfirmed}
+	case errors.Is(err, ErrAlreadyWaitlisted):
+		result = &models.ParticipationChange{PreviousStatus: models.ParticipantStatusWaitlisted, Status: models.ParticipantStatusWaitlisted}
+		result.WaitlistPosition, err = waitlistPosition(tx, receiverID, postID)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // RejectEventInvite - Rifiuta un invito per un evento
@@ -297,8 +814,8 @@ func (r *EventRepository) RejectEventInvite(inviteID, receiverID int64) error {
 	// Aggiorna lo status dell'invito
 	_, err = r.db.Exec(`
 		UPDATE event_invites 
-		SET status = 'rejected', updated_at = CURRENT_TIMESTAMP 
-		WHERE id = $1`, inviteID)
+		SET status = 'rejected', responded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = $1 AND status = 'pending'`, inviteID)
 
 	return err
 }
@@ -312,14 +829,17 @@ func (r *EventRepository) GetEventInvites(userID int64) ([]models.EventInviteInf
 			ei.message,
 			ei.created_at,
 			ei.status,
+			u.id as sender_id,
 			u.username as sender_username,
 			u.nome as sender_nome,
 			u.cognome as sender_cognome,
 			u.email as sender_email,
-			u.profile_picture as sender_profile_picture
+			u.profile_picture as sender_profile_picture,
+			ei.expires_at
 		FROM event_invites ei
 		JOIN users u ON ei.sender_id = u.id
 		WHERE ei.receiver_id = $1 AND ei.status = 'pending'
+		AND (ei.expires_at IS NULL OR ei.expires_at > LOCALTIMESTAMP)
 		ORDER BY ei.created_at DESC`,
 		userID)
 
@@ -331,7 +851,10 @@ func (r *EventRepository) GetEventInvites(userID int64) ([]models.EventInviteInf
 	var invites []models.EventInviteInfo
 	for rows.Next() {
 		var invite models.EventInviteInfo
+		var senderID int64
+		var senderProfilePic sql.NullString
 		var createdAt string
+		var expiresAt sql.NullTime
 
 		err := rows.Scan(
 			&invite.InviteID,
@@ -339,17 +862,23 @@ func (r *EventRepository) GetEventInvites(userID int64) ([]models.EventInviteInf
 			&invite.Message,
 			&createdAt,
 			&invite.Status,
+			&senderID,
 			&invite.SenderUsername,
 			&invite.SenderNome,
 			&invite.SenderCognome,
 			&invite.SenderEmail,
-			&invite.SenderProfilePicture,
+			&senderProfilePic,
+			&expiresAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		invite.SenderProfilePicture = profilePictureOrAvatar(senderID, senderProfilePic)
 		invite.CreatedAt = createdAt
+		if expiresAt.Valid {
+			invite.ExpiresAt = &expiresAt.Time
+		}
 		invites = append(invites, invite)
 	}
 
@@ -397,6 +926,7 @@ func (r *EventRepository) GetAvailableFriendsForInvite(userID int64, postID int)
 			SELECT ei.receiver_id 
 			FROM event_invites ei 
 			WHERE ei.post_id = $2 AND ei.status = 'pending'
+			AND (ei.expires_at IS NULL OR ei.expires_at > LOCALTIMESTAMP)
 		)
 		AND CASE 
 			WHEN f.user1_id = $1 THEN u2.id
@@ -418,6 +948,7 @@ func (r *EventRepository) GetAvailableFriendsForInvite(userID int64, postID int)
 	var friends []models.FriendInfo
 	for rows.Next() {
 		var friend models.FriendInfo
+		var profilePic sql.NullString
 		var createdAt string
 
 		err := rows.Scan(
@@ -426,13 +957,14 @@ func (r *EventRepository) GetAvailableFriendsForInvite(userID int64, postID int)
 			&friend.Nome,
 			&friend.Cognome,
 			&friend.Email,
-			&friend.ProfilePic,
+			&profilePic,
 			&createdAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		friend.ProfilePic = profilePictureOrAvatar(friend.UserID, profilePic)
 		friend.FriendsSince = createdAt
 		friends = append(friends, friend)
 	}
@@ -452,13 +984,6 @@ func (r *EventRepository) GetEventInvitePostID(inviteID int64) (int64, error) {
 	return postID, nil
 }
 
-// GetPostTitleByID ottiene il titolo di un post tramite il suo ID
-func (r *EventRepository) GetPostTitleByID(postID int) (string, error) {
-	// Nota: Questo metodo richiede accesso al database Python
-	// Per ora restituiamo un placeholder
-	return fmt.Sprintf("Evento #%d", postID), nil
-}
-
 // GetInvitedUserEmailsForPost ottiene le email degli utenti già invitati a un evento
 func (r *EventRepository) GetInvitedUserEmailsForPost(postID int) ([]string, error) {
 	rows, err := r.db.Query(`
@@ -0,0 +1,494 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/utils"
+)
+
+// Errori legati alle raccolte di preferiti
+var (
+	ErrCollectionNotFound      = errors.New("raccolta non trovata")
+	ErrCollectionExists        = errors.New("esiste già una raccolta con questo nome")
+	ErrTooManyCollections      = errors.New("hai raggiunto il numero massimo di raccolte")
+	ErrDefaultCollection       = errors.New("la raccolta predefinita non può essere eliminata")
+	ErrCollectionItemNotFound  = errors.New("il post non è in questa raccolta")
+	ErrCollectionOrderMismatch = errors.New("l'ordine deve contenere tutti e soli i post della raccolta")
+	ErrCannotFollowCollection  = errors.New("puoi seguire solo le raccolte condivise dai tuoi amici")
+)
+
+const (
+	maxFavoriteCollections = 50
+	// Nome della raccolta creata per i post salvati con "/favorites/add"
+	DefaultCollectionName = "Preferiti"
+	collectionTokenBytes  = 20
+)
+
+// favoriteCollectionColumns sono le colonne lette da scanFavoriteCollection; il
+// primo parametro della query deve essere l'utente che guarda la raccolta
+const favoriteCollectionColumns = `
+	c.id, c.user_id, u.username, c.name, c.visibility, c.is_default, COALESCE(c.share_token, ''),
+	(SELECT COUNT(*) FROM favorite_collection_items i WHERE i.collection_id = c.id),
+	(SELECT COUNT(*) FROM favorite_collection_follows f WHERE f.collection_id = c.id),
+	EXISTS(SELECT 1 FROM favorite_collection_follows f WHERE f.collection_id = c.id AND f.user_id = $1),
+	c.created_at, c.updated_at`
+
+// collectionVisibleToFriend è vera se l'utente $1 è amico del proprietario e la
+// raccolta non è privata
+const collectionVisibleToFriend = `
+	c.visibility <> 'private' AND EXISTS(
+		SELECT 1 FROM friendships fr
+		WHERE fr.user1_id = LEAST(c.user_id, $1::INTEGER) AND fr.user2_id = GREATEST(c.user_id, $1::INTEGER)
+	)`
+
+// GetFavoriteCollections restituisce le raccolte di ownerID visibili a viewerID:
+// tutte se è il proprietario (creando quella predefinita se manca), altrimenti
+// quelle condivise con gli amici
+func (r *EventRepository) GetFavoriteCollections(viewerID, ownerID int64) ([]models.FavoriteCollection, error) {
+	condition := "c.user_id = $2"
+	if viewerID == ownerID {
+		if _, err := ensureDefaultCollection(r.db, ownerID); err != nil {
+			return nil, err
+		}
+	} else {
+		condition += " AND" + collectionVisibleToFriend
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+favoriteCollectionColumns+`
+		FROM favorite_collections c
+		JOIN users u ON u.id = c.user_id
+		WHERE `+condition+`
+		ORDER BY c.is_default DESC, c.name`,
+		viewerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFavoriteCollections(rows, viewerID)
+}
+
+// GetFollowedFavoriteCollections restituisce le raccolte seguite dall'utente che
+// sono ancora condivise con lui
+func (r *EventRepository) GetFollowedFavoriteCollections(userID int64) ([]models.FavoriteCollection, error) {
+	rows, err := r.db.Query(`
+		SELECT `+favoriteCollectionColumns+`
+		FROM favorite_collection_follows fw
+		JOIN favorite_collections c ON c.id = fw.collection_id
+		JOIN users u ON u.id = c.user_id
+		WHERE fw.user_id = $1 AND`+collectionVisibleToFriend+`
+		ORDER BY fw.created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFavoriteCollections(rows, userID)
+}
+
+// GetFavoriteCollection restituisce una raccolta con i suoi post, se visibile all'utente
+func (r *EventRepository) GetFavoriteCollection(viewerID, collectionID int64) (*models.FavoriteCollection, error) {
+	collection, err := scanFavoriteCollection(r.db.QueryRow(`
+		SELECT `+favoriteCollectionColumns+`
+		FROM favorite_collections c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $2 AND (c.user_id = $1 OR`+collectionVisibleToFriend+`)`,
+		viewerID, collectionID), viewerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadCollectionItems(collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// GetSharedFavoriteCollection restituisce la raccolta condivisa con il link;
+// viewerID è 0 per chi non ha effettuato l'accesso
+func (r *EventRepository) GetSharedFavoriteCollection(viewerID int64, token string) (*models.FavoriteCollection, error) {
+	collection, err := scanFavoriteCollection(r.db.QueryRow(`
+		SELECT `+favoriteCollectionColumns+`
+		FROM favorite_collections c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.share_token = $2 AND c.visibility = 'link'`,
+		viewerID, token), viewerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadCollectionItems(collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// CreateFavoriteCollection crea una raccolta vuota dell'utente
+func (r *EventRepository) CreateFavoriteCollection(userID int64, name, visibility string) (*models.FavoriteCollection, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// La predefinita esiste prima delle altre, così il suo nome resta libero
+	if _, err := ensureDefaultCollection(tx, userID); err != nil {
+		return nil, err
+	}
+
+	var collections int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM favorite_collections WHERE user_id = $1", userID).Scan(&collections); err != nil {
+		return nil, err
+	}
+	if collections >= maxFavoriteCollections {
+		return nil, ErrTooManyCollections
+	}
+
+	token, err := collectionShareToken(visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	var collectionID int64
+	err = tx.QueryRow(`
+		INSERT INTO favorite_collections (user_id, name, visibility, share_token) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, name) DO NOTHING
+		RETURNING id`,
+		userID, name, visibility, token).Scan(&collectionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetFavoriteCollection(userID, collectionID)
+}
+
+// UpdateFavoriteCollection rinomina la raccolta e ne cambia la visibilità: il
+// link di condivisione viene creato al passaggio a "link" e invalidato all'uscita
+func (r *EventRepository) UpdateFavoriteCollection(userID, collectionID int64, name, visibility string) (*models.FavoriteCollection, error) {
+	token, err := collectionShareToken(models.CollectionVisibilityLink)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE favorite_collections
+		SET name = $3, visibility = $4,
+			share_token = CASE WHEN $4 = 'link' THEN COALESCE(share_token, $5) ELSE NULL END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2`,
+		collectionID, userID, name, visibility, token)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrCollectionExists
+		}
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrCollectionNotFound
+	}
+
+	return r.GetFavoriteCollection(userID, collectionID)
+}
+
+// DeleteFavoriteCollection elimina una raccolta dell'utente; i post che non sono
+// in nessun'altra raccolta passano in quella predefinita e restano tra i preferiti
+func (r *EventRepository) DeleteFavoriteCollection(userID, collectionID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCollection(tx, userID, collectionID, true); err != nil {
+		return err
+	}
+	defaultID, err := ensureDefaultCollection(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO favorite_collection_items (collection_id, post_id, position, added_at)
+		SELECT $2, i.post_id,
+			COALESCE((SELECT MAX(d.position) FROM favorite_collection_items d WHERE d.collection_id = $2), 0)
+				+ ROW_NUMBER() OVER (ORDER BY i.position, i.post_id),
+			i.added_at
+		FROM favorite_collection_items i
+		WHERE i.collection_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM favorite_collection_items o
+			JOIN favorite_collections oc ON oc.id = o.collection_id
+			WHERE oc.user_id = $3 AND o.collection_id <> $1 AND o.post_id = i.post_id
+		)
+		ON CONFLICT (collection_id, post_id) DO NOTHING`,
+		collectionID, defaultID, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM favorite_collections WHERE id = $1", collectionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddFavoriteCollectionItem aggiunge un post in fondo alla raccolta e ai preferiti
+func (r *EventRepository) AddFavoriteCollectionItem(userID, collectionID int64, postID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCollection(tx, userID, collectionID, false); err != nil {
+		return err
+	}
+	if err := addFavoriteToCollection(tx, userID, collectionID, postID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveFavoriteCollectionItem toglie un post dalla raccolta; se non è in
+// nessun'altra raccolta dell'utente viene tolto anche dai preferiti
+func (r *EventRepository) RemoveFavoriteCollectionItem(userID, collectionID int64, postID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCollection(tx, userID, collectionID, false); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM favorite_collection_items WHERE collection_id = $1 AND post_id = $2", collectionID, postID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrCollectionItemNotFound
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM user_favorites uf
+		WHERE uf.user_id = $1 AND uf.post_id = $2 AND NOT EXISTS (
+			SELECT 1 FROM favorite_collection_items i
+			JOIN favorite_collections c ON c.id = i.collection_id
+			WHERE c.user_id = $1 AND i.post_id = $2
+		)`,
+		userID, postID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReorderFavoriteCollection assegna ai post della raccolta l'ordine indicato
+func (r *EventRepository) ReorderFavoriteCollection(userID, collectionID int64, postIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCollection(tx, userID, collectionID, false); err != nil {
+		return err
+	}
+
+	var items, matched int
+	err = tx.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM favorite_collection_items WHERE collection_id = $1),
+			(SELECT COUNT(*) FROM favorite_collection_items WHERE collection_id = $1 AND post_id = ANY($2::INTEGER[]))`,
+		collectionID, pq.Array(postIDs)).Scan(&items, &matched)
+	if err != nil {
+		return err
+	}
+	if items != len(postIDs) || matched != len(postIDs) {
+		return ErrCollectionOrderMismatch
+	}
+
+	_, err = tx.Exec(`
+		UPDATE favorite_collection_items i SET position = o.position
+		FROM unnest($2::INTEGER[]) WITH ORDINALITY AS o(post_id, position)
+		WHERE i.collection_id = $1 AND i.post_id = o.post_id`,
+		collectionID, pq.Array(postIDs))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE favorite_collections SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", collectionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FollowFavoriteCollection segue la raccolta condivisa da un amico
+func (r *EventRepository) FollowFavoriteCollection(userID, collectionID int64) error {
+	var ownerID int64
+	var visible bool
+	err := r.db.QueryRow(`
+		SELECT c.user_id, `+collectionVisibleToFriend+`
+		FROM favorite_collections c WHERE c.id = $2`,
+		userID, collectionID).Scan(&ownerID, &visible)
+	if err == sql.ErrNoRows {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ownerID == userID || !visible {
+		return ErrCannotFollowCollection
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO favorite_collection_follows (collection_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (collection_id, user_id) DO NOTHING`,
+		collectionID, userID)
+	return err
+}
+
+// UnfollowFavoriteCollection smette di seguire la raccolta
+func (r *EventRepository) UnfollowFavoriteCollection(userID, collectionID int64) error {
+	_, err := r.db.Exec("DELETE FROM favorite_collection_follows WHERE collection_id = $1 AND user_id = $2", collectionID, userID)
+	return err
+}
+
+// loadCollectionItems legge i post della raccolta nell'ordine scelto dal proprietario
+func (r *EventRepository) loadCollectionItems(collection *models.FavoriteCollection) error {
+	rows, err := r.db.Query(`
+		SELECT post_id, position, COALESCE(added_at, CURRENT_TIMESTAMP)
+		FROM favorite_collection_items
+		WHERE collection_id = $1
+		ORDER BY position, post_id`,
+		collection.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	collection.Items = []models.FavoriteCollectionItem{}
+	for rows.Next() {
+		var item models.FavoriteCollectionItem
+		if err := rows.Scan(&item.PostID, &item.Position, &item.AddedAt); err != nil {
+			return err
+		}
+		collection.Items = append(collection.Items, item)
+	}
+	return rows.Err()
+}
+
+// ensureDefaultCollection restituisce l'ID della raccolta predefinita dell'utente, creandola se manca
+func ensureDefaultCollection(q queryRower, userID int64) (int64, error) {
+	var collectionID int64
+	err := q.QueryRow(`
+		INSERT INTO favorite_collections (user_id, name, is_default) VALUES ($1, $2, TRUE)
+		ON CONFLICT DO NOTHING
+		RETURNING id`,
+		userID, DefaultCollectionName).Scan(&collectionID)
+	if err == sql.ErrNoRows {
+		err = q.QueryRow("SELECT id FROM favorite_collections WHERE user_id = $1 AND is_default", userID).Scan(&collectionID)
+	}
+	return collectionID, err
+}
+
+// lockCollection blocca la raccolta dell'utente per la durata della transazione;
+// con notDefault rifiuta la raccolta predefinita
+func lockCollection(tx *sql.Tx, userID, collectionID int64, notDefault bool) error {
+	var isDefault bool
+	err := tx.QueryRow(`
+		SELECT is_default FROM favorite_collections
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`,
+		collectionID, userID).Scan(&isDefault)
+	if err == sql.ErrNoRows {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if notDefault && isDefault {
+		return ErrDefaultCollection
+	}
+	return nil
+}
+
+// addFavoriteToCollection salva il post tra i preferiti e lo mette in fondo alla raccolta
+func addFavoriteToCollection(tx *sql.Tx, userID, collectionID int64, postID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO user_favorites (user_id, post_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING`,
+		userID, postID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO favorite_collection_items (collection_id, post_id, position)
+		SELECT $1, $2, COALESCE(MAX(position), 0) + 1
+		FROM favorite_collection_items WHERE collection_id = $1
+		ON CONFLICT (collection_id, post_id) DO NOTHING`,
+		collectionID, postID)
+	return err
+}
+
+// collectionShareToken genera il token di condivisione per le raccolte visibili con il link
+func collectionShareToken(visibility string) (sql.NullString, error) {
+	if visibility != models.CollectionVisibilityLink {
+		return sql.NullString{}, nil
+	}
+	token, err := utils.GenerateToken(collectionTokenBytes)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: token, Valid: true}, nil
+}
+
+// scanFavoriteCollection legge una raccolta; il token di condivisione è visibile solo al proprietario
+func scanFavoriteCollection(row interface{ Scan(...interface{}) error }, viewerID int64) (*models.FavoriteCollection, error) {
+	var collection models.FavoriteCollection
+	err := row.Scan(&collection.ID, &collection.OwnerID, &collection.OwnerUsername, &collection.Name,
+		&collection.Visibility, &collection.IsDefault, &collection.ShareToken,
+		&collection.ItemCount, &collection.FollowerCount, &collection.IsFollowing,
+		&collection.CreatedAt, &collection.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if collection.OwnerID != viewerID {
+		collection.ShareToken = ""
+	}
+	return &collection, nil
+}
+
+func scanFavoriteCollections(rows *sql.Rows, viewerID int64) ([]models.FavoriteCollection, error) {
+	collections := []models.FavoriteCollection{}
+	for rows.Next() {
+		collection, err := scanFavoriteCollection(rows, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, *collection)
+	}
+	return collections, rows.Err()
+}
@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori legati ai campi preferiti
+var (
+	ErrFieldNotFound         = errors.New("campo non trovato")
+	ErrFavoriteFieldNotFound = errors.New("il campo non è tra i preferiti")
+)
+
+// Dopo questo numero di giorni gli avvisi già inviati vengono eliminati dalla coda
+const fieldAlertRetentionDays = 30
+
+// favoriteFieldColumns sono le colonne lette da scanFavoriteField; le query che
+// la usano devono fare LEFT JOIN di sport_fields come sf
+const favoriteFieldColumns = `
+	ff.field_id, ff.alerts, COALESCE(ff.created_at, CURRENT_TIMESTAMP),
+	sf.id, sf.nome, sf.indirizzo, sf.citta, sf.provincia, sf.lat, sf.lng`
+
+// AddFavoriteField salva un campo tra i preferiti, o ne aggiorna gli avvisi se c'è già
+func (r *EventRepository) AddFavoriteField(userID int64, fieldID int, alerts bool) (*models.FavoriteField, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO user_favorite_fields (user_id, field_id, alerts)
+		SELECT $1, sf.id, $3 FROM sport_fields sf WHERE sf.id = $2
+		ON CONFLICT (user_id, field_id) DO UPDATE SET alerts = EXCLUDED.alerts`,
+		userID, fieldID, alerts)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrFieldNotFound
+	}
+
+	return r.GetFavoriteField(userID, fieldID)
+}
+
+// RemoveFavoriteField rimuove un campo dai preferiti e gli avvisi ancora da inviare
+func (r *EventRepository) RemoveFavoriteField(userID int64, fieldID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM user_favorite_fields WHERE user_id = $1 AND field_id = $2", userID, fieldID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrFavoriteFieldNotFound
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM field_alert_queue
+		WHERE user_id = $1 AND field_id = $2 AND sent_at IS NULL`,
+		userID, fieldID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetFavoriteFieldAlerts attiva o disattiva gli avvisi di nuove partite per un
+// campo preferito; disattivandoli si scartano quelli ancora da inviare
+func (r *EventRepository) SetFavoriteFieldAlerts(userID int64, fieldID int, alerts bool) (*models.FavoriteField, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE user_favorite_fields SET alerts = $3
+		WHERE user_id = $1 AND field_id = $2`,
+		userID, fieldID, alerts)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrFavoriteFieldNotFound
+	}
+
+	if !alerts {
+		_, err = tx.Exec(`
+			DELETE FROM field_alert_queue
+			WHERE user_id = $1 AND field_id = $2 AND sent_at IS NULL`,
+			userID, fieldID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetFavoriteField(userID, fieldID)
+}
+
+// GetFavoriteField restituisce un campo preferito dell'utente
+func (r *EventRepository) GetFavoriteField(userID int64, fieldID int) (*models.FavoriteField, error) {
+	field, err := scanFavoriteField(r.db.QueryRow(`
+		SELECT `+favoriteFieldColumns+`
+		FROM user_favorite_fields ff
+		LEFT JOIN sport_fields sf ON sf.id = ff.field_id
+		WHERE ff.user_id = $1 AND ff.field_id = $2`,
+		userID, fieldID))
+	if err == sql.ErrNoRows {
+		return nil, ErrFavoriteFieldNotFound
+	}
+	return field, err
+}
+
+// GetFavoriteFields restituisce i campi preferiti dell'utente, dal più recente
+func (r *EventRepository) GetFavoriteFields(userID int64) ([]models.FavoriteField, error) {
+	rows, err := r.db.Query(`
+		SELECT `+favoriteFieldColumns+`
+		FROM user_favorite_fields ff
+		LEFT JOIN sport_fields sf ON sf.id = ff.field_id
+		WHERE ff.user_id = $1
+		ORDER BY ff.created_at DESC, ff.field_id`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []models.FavoriteField{}
+	for rows.Next() {
+		field, err := scanFavoriteField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, *field)
+	}
+	return fields, rows.Err()
+}
+
+// QueueFieldAlerts mette in coda, per chi segue il campo con gli avvisi attivi,
+// le partite future create nelle ultime lookback ore; l'autore del post e chi è
+// già iscritto non vengono avvisati. Restituisce il numero di avvisi aggiunti.
+func (r *EventRepository) QueueFieldAlerts(lookback time.Duration) (int64, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO field_alert_queue (user_id, field_id, post_id)
+		SELECT ff.user_id, p.campo_id, p.id
+		FROM posts p
+		JOIN user_favorite_fields ff ON ff.field_id = p.campo_id AND ff.alerts
+		LEFT JOIN users author ON author.email = p.autore_email
+		WHERE p.created_at >= (NOW() AT TIME ZONE 'UTC') - make_interval(secs => $1::FLOAT8)
+		AND p.created_at >= ff.watch_from
+		AND p.data_partita + p.ora_partita > LOCALTIMESTAMP
+		AND (author.id IS NULL OR author.id <> ff.user_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM event_participants ep
+			WHERE ep.post_id = p.id AND ep.user_id = ff.user_id
+		)
+		ON CONFLICT (user_id, post_id) DO NOTHING`,
+		lookback.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetDueFieldAlerts restituisce gli avvisi in coda di al massimo limit utenti che
+// non hanno ricevuto un riepilogo negli ultimi minInterval, ordinati per utente e
+// data della partita. I campi non più seguiti e le partite iniziate sono esclusi.
+func (r *EventRepository) GetDueFieldAlerts(minInterval time.Duration, limit int) ([]models.FieldAlert, error) {
+	rows, err := r.db.Query(`
+		WITH due AS (
+			SELECT DISTINCT q.user_id
+			FROM field_alert_queue q
+			JOIN user_favorite_fields ff ON ff.user_id = q.user_id AND ff.field_id = q.field_id AND ff.alerts
+			LEFT JOIN field_alert_digests d ON d.user_id = q.user_id
+			WHERE q.sent_at IS NULL
+			AND (d.last_sent_at IS NULL OR d.last_sent_at <= CURRENT_TIMESTAMP - make_interval(secs => $1::FLOAT8))
+			ORDER BY q.user_id
+			LIMIT $2
+		)
+		SELECT q.user_id, q.post_id, q.field_id, COALESCE(sf.nome, ''), p.titolo, p.data_partita + p.ora_partita
+		FROM field_alert_queue q
+		JOIN due ON due.user_id = q.user_id
+		JOIN posts p ON p.id = q.post_id
+		JOIN user_favorite_fields ff ON ff.user_id = q.user_id AND ff.field_id = q.field_id AND ff.alerts
+		LEFT JOIN sport_fields sf ON sf.id = q.field_id
+		WHERE q.sent_at IS NULL
+		AND p.data_partita + p.ora_partita > LOCALTIMESTAMP
+		ORDER BY q.user_id, p.data_partita, p.ora_partita, q.post_id`,
+		minInterval.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.FieldAlert
+	for rows.Next() {
+		var alert models.FieldAlert
+		if err := rows.Scan(&alert.UserID, &alert.PostID, &alert.FieldID, &alert.FieldName, &alert.Titolo, &alert.StartsAt); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// MarkFieldAlertsSent registra il riepilogo inviato all'utente con le partite
+// indicate; restituisce false se nel frattempo un'altra replica ne ha già
+// inviato uno entro minInterval
+func (r *EventRepository) MarkFieldAlertsSent(userID int64, postIDs []int, minInterval time.Duration) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO field_alert_digests (user_id, last_sent_at) VALUES ($1, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET last_sent_at = CURRENT_TIMESTAMP
+		WHERE field_alert_digests.last_sent_at <= CURRENT_TIMESTAMP - make_interval(secs => $2::FLOAT8)`,
+		userID, minInterval.Seconds())
+	if err != nil {
+		return false, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE field_alert_queue SET sent_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND post_id = ANY($2::INTEGER[]) AND sent_at IS NULL`,
+		userID, pq.Array(postIDs))
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneFieldAlerts elimina dalla coda gli avvisi non più utili: partite iniziate
+// o eliminate mai notificate e avvisi inviati da più di fieldAlertRetentionDays
+func (r *EventRepository) PruneFieldAlerts() (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM field_alert_queue q
+		WHERE (q.sent_at IS NOT NULL AND q.sent_at < CURRENT_TIMESTAMP - make_interval(days => $1))
+		OR (q.sent_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM posts p
+			WHERE p.id = q.post_id AND p.data_partita + p.ora_partita > LOCALTIMESTAMP
+		))`,
+		fieldAlertRetentionDays)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanFavoriteField legge un campo preferito; Field è nil se il campo non esiste più
+func scanFavoriteField(row interface{ Scan(...interface{}) error }) (*models.FavoriteField, error) {
+	var field models.FavoriteField
+	var id sql.NullInt64
+	var nome, indirizzo, citta, provincia sql.NullString
+	var lat, lng sql.NullFloat64
+	err := row.Scan(&field.FieldID, &field.Alerts, &field.SavedAt,
+		&id, &nome, &indirizzo, &citta, &provincia, &lat, &lng)
+	if err != nil {
+		return nil, err
+	}
+
+	if id.Valid {
+		field.Field = &models.FieldSummary{
+			ID:        int(id.Int64),
+			Nome:      nome.String,
+			Indirizzo: indirizzo.String,
+			Citta:     citta.String,
+			Provincia: provincia.String,
+			Lat:       lat.Float64,
+			Lng:       lng.Float64,
+		}
+	}
+	return &field, nil
+}
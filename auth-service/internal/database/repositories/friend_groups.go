@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori legati ai gruppi di amici
+var (
+	ErrFriendGroupNotFound  = errors.New("gruppo di amici non trovato")
+	ErrFriendGroupExists    = errors.New("esiste già un gruppo con questo nome")
+	ErrGroupMemberNotFriend = errors.New("un gruppo può contenere solo i tuoi amici")
+	ErrTooManyFriendGroups  = errors.New("hai raggiunto il numero massimo di gruppi")
+)
+
+// Numero massimo di gruppi per utente e di membri per gruppo
+const (
+	maxFriendGroups       = 50
+	MaxFriendGroupMembers = 50
+)
+
+// GetFriendGroups restituisce i gruppi dell'utente con i loro membri
+func (r *FriendRepository) GetFriendGroups(ownerID int64) ([]models.FriendGroup, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, created_at, updated_at
+		FROM friend_groups WHERE owner_id = $1
+		ORDER BY name`,
+		ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.FriendGroup{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		group := models.FriendGroup{Members: []models.FriendGroupMember{}}
+		if err := rows.Scan(&group.ID, &group.Name, &group.CreatedAt, &group.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[group.ID] = len(groups)
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	memberRows, err := r.db.Query(`
+		SELECT m.group_id, u.id, u.username, u.profile_picture
+		FROM friend_group_members m
+		JOIN friend_groups g ON g.id = m.group_id
+		JOIN users u ON u.id = m.user_id
+		WHERE g.owner_id = $1
+		ORDER BY u.username`,
+		ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer memberRows.Close()
+
+	for memberRows.Next() {
+		var groupID int64
+		var member models.FriendGroupMember
+		var profilePic sql.NullString
+		if err := memberRows.Scan(&groupID, &member.UserID, &member.Username, &profilePic); err != nil {
+			return nil, err
+		}
+		member.ProfilePic = profilePictureOrAvatar(member.UserID, profilePic)
+		if i, ok := index[groupID]; ok {
+			groups[i].Members = append(groups[i].Members, member)
+		}
+	}
+	return groups, memberRows.Err()
+}
+
+// GetFriendGroup restituisce un gruppo dell'utente
+func (r *FriendRepository) GetFriendGroup(ownerID, groupID int64) (*models.FriendGroup, error) {
+	groups, err := r.GetFriendGroups(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if groups[i].ID == groupID {
+			return &groups[i], nil
+		}
+	}
+	return nil, ErrFriendGroupNotFound
+}
+
+// CreateFriendGroup crea un gruppo con i membri indicati, che devono essere amici dell'utente
+func (r *FriendRepository) CreateFriendGroup(ownerID int64, name string, memberIDs []int64) (*models.FriendGroup, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var groups int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM friend_groups WHERE owner_id = $1", ownerID).Scan(&groups); err != nil {
+		return nil, err
+	}
+	if groups >= maxFriendGroups {
+		return nil, ErrTooManyFriendGroups
+	}
+
+	var groupID int64
+	err = tx.QueryRow(`
+		INSERT INTO friend_groups (owner_id, name) VALUES ($1, $2)
+		ON CONFLICT (owner_id, name) DO NOTHING
+		RETURNING id`,
+		ownerID, name).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return nil, ErrFriendGroupExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setFriendGroupMembers(tx, ownerID, groupID, memberIDs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetFriendGroup(ownerID, groupID)
+}
+
+// UpdateFriendGroup rinomina il gruppo e ne sostituisce i membri
+func (r *FriendRepository) UpdateFriendGroup(ownerID, groupID int64, name string, memberIDs []int64) (*models.FriendGroup, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE friend_groups SET name = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND owner_id = $2`,
+		groupID, ownerID, name)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrFriendGroupExists
+		}
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrFriendGroupNotFound
+	}
+
+	if _, err := tx.Exec("DELETE FROM friend_group_members WHERE group_id = $1", groupID); err != nil {
+		return nil, err
+	}
+	if err := setFriendGroupMembers(tx, ownerID, groupID, memberIDs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetFriendGroup(ownerID, groupID)
+}
+
+// DeleteFriendGroup elimina un gruppo dell'utente
+func (r *FriendRepository) DeleteFriendGroup(ownerID, groupID int64) error {
+	result, err := r.db.Exec("DELETE FROM friend_groups WHERE id = $1 AND owner_id = $2", groupID, ownerID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFriendGroupNotFound
+	}
+	return nil
+}
+
+// setFriendGroupMembers inserisce i membri del gruppo verificando che siano tutti amici del proprietario
+func setFriendGroupMembers(tx *sql.Tx, ownerID, groupID int64, memberIDs []int64) error {
+	if len(memberIDs) == 0 {
+		return nil
+	}
+
+	var friends int
+	err := tx.QueryRow(`
+		SELECT COUNT(DISTINCT m.id)
+		FROM unnest($2::INTEGER[]) AS m(id)
+		JOIN friendships f ON f.user1_id = LEAST(m.id, $1::INTEGER) AND f.user2_id = GREATEST(m.id, $1::INTEGER)`,
+		ownerID, pq.Array(memberIDs)).Scan(&friends)
+	if err != nil {
+		return err
+	}
+	if friends != len(memberIDs) {
+		return ErrGroupMemberNotFriend
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO friend_group_members (group_id, user_id)
+		SELECT $1, unnest($2::INTEGER[])`,
+		groupID, pq.Array(memberIDs))
+	return err
+}
@@ -152,12 +152,32 @@ func (r *FriendRepository) RemoveFriendship(userID1, userID2 int64) error {
 		userID1, userID2 = userID2, userID1
 	}
 
-	_, err := r.db.Exec(`
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		DELETE FROM friendships 
 		WHERE user1_id = $1 AND user2_id = $2`,
 		userID1, userID2)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// Un ex amico non può restare nei gruppi salvati dell'altro
+	_, err = tx.Exec(`
+		DELETE FROM friend_group_members m
+		USING friend_groups g
+		WHERE g.id = m.group_id
+		AND ((g.owner_id = $1 AND m.user_id = $2) OR (g.owner_id = $2 AND m.user_id = $1))`,
+		userID1, userID2)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetFriendsList - Ottiene la lista degli amici di un utente
@@ -204,6 +224,7 @@ func (r *FriendRepository) GetFriendsList(userID int64) ([]models.FriendInfo, er
 	var friends []models.FriendInfo
 	for rows.Next() {
 		var friend models.FriendInfo
+		var profilePic sql.NullString
 		var createdAt time.Time
 
 		err := rows.Scan(
@@ -212,13 +233,14 @@ func (r *FriendRepository) GetFriendsList(userID int64) ([]models.FriendInfo, er
 			&friend.Nome,
 			&friend.Cognome,
 			&friend.Email,
-			&friend.ProfilePic,
+			&profilePic,
 			&createdAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		friend.ProfilePic = profilePictureOrAvatar(friend.UserID, profilePic)
 		friend.FriendsSince = createdAt.Format("2006-01-02 15:04:05")
 		friends = append(friends, friend)
 	}
@@ -253,6 +275,7 @@ func (r *FriendRepository) GetFriendRequests(userID int64) ([]models.FriendReque
 	var requests []models.FriendRequestInfo
 	for rows.Next() {
 		var request models.FriendRequestInfo
+		var profilePic sql.NullString
 		var createdAt time.Time
 
 		err := rows.Scan(
@@ -262,7 +285,7 @@ func (r *FriendRepository) GetFriendRequests(userID int64) ([]models.FriendReque
 			&request.Nome,
 			&request.Cognome,
 			&request.Email,
-			&request.ProfilePic,
+			&profilePic,
 			&createdAt,
 			&request.Status,
 		)
@@ -270,6 +293,7 @@ func (r *FriendRepository) GetFriendRequests(userID int64) ([]models.FriendReque
 			return nil, err
 		}
 
+		request.ProfilePic = profilePictureOrAvatar(request.UserID, profilePic)
 		request.RequestDate = createdAt.Format("2006-01-02 15:04:05")
 		requests = append(requests, request)
 	}
@@ -304,6 +328,7 @@ func (r *FriendRepository) GetSentFriendRequests(userID int64) ([]models.FriendR
 	var requests []models.FriendRequestInfo
 	for rows.Next() {
 		var request models.FriendRequestInfo
+		var profilePic sql.NullString
 		var createdAt time.Time
 
 		err := rows.Scan(
@@ -313,7 +338,7 @@ func (r *FriendRepository) GetSentFriendRequests(userID int64) ([]models.FriendR
 			&request.Nome,
 			&request.Cognome,
 			&request.Email,
-			&request.ProfilePic,
+			&profilePic,
 			&createdAt,
 			&request.Status,
 		)
@@ -321,6 +346,7 @@ func (r *FriendRepository) GetSentFriendRequests(userID int64) ([]models.FriendR
 			return nil, err
 		}
 
+		request.ProfilePic = profilePictureOrAvatar(request.UserID, profilePic)
 		request.RequestDate = createdAt.Format("2006-01-02 15:04:05")
 		requests = append(requests, request)
 	}
@@ -397,6 +423,7 @@ func (r *FriendRepository) SearchUsers(searchTerm string, currentUserID int64) (
 	var users []models.UserSearchResult
 	for rows.Next() {
 		var user models.UserSearchResult
+		var profilePic sql.NullString
 
 		err := rows.Scan(
 			&user.UserID,
@@ -404,12 +431,13 @@ func (r *FriendRepository) SearchUsers(searchTerm string, currentUserID int64) (
 			&user.Nome,
 			&user.Cognome,
 			&user.Email,
-			&user.ProfilePic,
+			&profilePic,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		user.ProfilePic = profilePictureOrAvatar(user.UserID, profilePic)
 		users = append(users, user)
 	}
 
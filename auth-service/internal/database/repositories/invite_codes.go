@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/utils"
+)
+
+// Errori restituiti durante la validazione di un codice invito
+var (
+	ErrInviteCodeNotFound  = errors.New("codice invito non valido")
+	ErrInviteCodeInactive  = errors.New("codice invito disattivato")
+	ErrInviteCodeExpired   = errors.New("codice invito scaduto")
+	ErrInviteCodeExhausted = errors.New("codice invito esaurito")
+)
+
+const inviteCodeLength = 10
+
+type InviteCodeRepository struct {
+	db *sql.DB
+}
+
+func NewInviteCodeRepository(db *sql.DB) *InviteCodeRepository {
+	return &InviteCodeRepository{db: db}
+}
+
+// CreateInviteCode genera e salva un nuovo codice invito
+func (r *InviteCodeRepository) CreateInviteCode(req *models.CreateInviteCodeRequest, adminID int64) (*models.InviteCode, error) {
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	var role sql.NullString
+	if req.Role != "" {
+		role = sql.NullString{String: req.Role, Valid: true}
+	}
+
+	// In caso (improbabile) di collisione sul codice si riprova con un nuovo codice
+	for attempt := 0; attempt < 3; attempt++ {
+		code, err := utils.GenerateCode(inviteCodeLength)
+		if err != nil {
+			return nil, err
+		}
+
+		var codeID int64
+		err = r.db.QueryRow(`
+			INSERT INTO invite_codes (code, created_by, max_uses, role, expires_at, note)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (code) DO NOTHING
+			RETURNING id`,
+			code, adminID, req.MaxUses, role, expiresAt, req.Note,
+		).Scan(&codeID)
+
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("errore nella creazione del codice invito: %v", err)
+		}
+
+		return r.GetInviteCodeByID(codeID)
+	}
+
+	return nil, fmt.Errorf("impossibile generare un codice invito univoco")
+}
+
+// GetInviteCodeByID ottiene un codice invito per ID
+func (r *InviteCodeRepository) GetInviteCodeByID(codeID int64) (*models.InviteCode, error) {
+	row := r.db.QueryRow(`
+		SELECT ic.id, ic.code, ic.created_by, COALESCE(u.username, ''), ic.max_uses, ic.uses,
+			COALESCE(ic.role, ''), ic.expires_at, COALESCE(ic.is_active, true), COALESCE(ic.note, ''), ic.created_at
+		FROM invite_codes ic
+		LEFT JOIN users u ON ic.created_by = u.id
+		WHERE ic.id = $1`, codeID)
+
+	return scanInviteCode(row)
+}
+
+// GetAllInviteCodes restituisce tutti i codici invito, dal più recente
+func (r *InviteCodeRepository) GetAllInviteCodes() ([]models.InviteCode, error) {
+	rows, err := r.db.Query(`
+		SELECT ic.id, ic.code, ic.created_by, COALESCE(u.username, ''), ic.max_uses, ic.uses,
+			COALESCE(ic.role, ''), ic.expires_at, COALESCE(ic.is_active, true), COALESCE(ic.note, ''), ic.created_at
+		FROM invite_codes ic
+		LEFT JOIN users u ON ic.created_by = u.id
+		ORDER BY ic.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []models.InviteCode
+	for rows.Next() {
+		code, err := scanInviteCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, *code)
+	}
+
+	return codes, rows.Err()
+}
+
+// DeactivateInviteCode disattiva un codice invito impedendone ulteriori utilizzi
+func (r *InviteCodeRepository) DeactivateInviteCode(codeID int64) error {
+	result, err := r.db.Exec(`UPDATE invite_codes SET is_active = FALSE WHERE id = $1`, codeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrInviteCodeNotFound
+	}
+
+	return nil
+}
+
+// GetInviteCodeRedemptions restituisce gli utenti registrati con un codice invito
+func (r *InviteCodeRepository) GetInviteCodeRedemptions(codeID int64) ([]models.InviteCodeRedemption, error) {
+	rows, err := r.db.Query(`
+		SELECT icr.id, icr.code_id, icr.user_id, u.username, u.email, icr.redeemed_at
+		FROM invite_code_redemptions icr
+		JOIN users u ON icr.user_id = u.id
+		WHERE icr.code_id = $1
+		ORDER BY icr.redeemed_at DESC`, codeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var redemptions []models.InviteCodeRedemption
+	for rows.Next() {
+		var redemption models.InviteCodeRedemption
+		err := rows.Scan(
+			&redemption.ID, &redemption.CodeID, &redemption.UserID,
+			&redemption.Username, &redemption.Email, &redemption.RedeemedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+
+	return redemptions, rows.Err()
+}
+
+// RegisterWithInviteCode crea un nuovo utente consumando un codice invito.
+// Il codice viene bloccato (FOR UPDATE) per evitare che registrazioni concorrenti
+// superino il numero massimo di utilizzi.
+func (r *InviteCodeRepository) RegisterWithInviteCode(user models.User, code string) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var codeID int64
+	var createdBy sql.NullInt64
+	var maxUses, uses int
+	var role sql.NullString
+	var expiresAt sql.NullTime
+	var isActive bool
+	err = tx.QueryRow(`
+		SELECT id, created_by, max_uses, uses, role, expires_at, COALESCE(is_active, true)
+		FROM invite_codes
+		WHERE code = $1
+		FOR UPDATE`, strings.ToUpper(strings.TrimSpace(code)),
+	).Scan(&codeID, &createdBy, &maxUses, &uses, &role, &expiresAt, &isActive)
+
+	if err == sql.ErrNoRows {
+		return 0, ErrInviteCodeNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case !isActive:
+		return 0, ErrInviteCodeInactive
+	case expiresAt.Valid && expiresAt.Time.Before(time.Now()):
+		return 0, ErrInviteCodeExpired
+	case uses >= maxUses:
+		return 0, ErrInviteCodeExhausted
+	}
+
+	// Il nuovo utente viene collegato all'amministratore che ha creato il codice
+	if createdBy.Valid {
+		user.InvitedBy = &createdBy.Int64
+	}
+	user.IsAdmin = role.Valid && role.String == "admin"
+
+	userID, err := insertUser(tx, user)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO invite_code_redemptions (code_id, user_id)
+		VALUES ($1, $2)`, codeID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("errore nella registrazione dell'utilizzo del codice: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE invite_codes SET uses = uses + 1 WHERE id = $1`, codeID)
+	if err != nil {
+		return 0, fmt.Errorf("errore nell'aggiornamento del codice invito: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// scanInviteCode legge un codice invito da una riga di risultato
+func scanInviteCode(row interface{ Scan(...interface{}) error }) (*models.InviteCode, error) {
+	var code models.InviteCode
+	var createdBy sql.NullInt64
+
+	err := row.Scan(
+		&code.ID, &code.Code, &createdBy, &code.CreatedByName, &code.MaxUses, &code.Uses,
+		&code.Role, &code.ExpiresAt, &code.IsActive, &code.Note, &code.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if createdBy.Valid {
+		code.CreatedBy = &createdBy.Int64
+	}
+
+	return &code, nil
+}
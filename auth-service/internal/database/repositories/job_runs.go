@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Stati di un'esecuzione registrata in job_runs
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+type JobRunRepository struct {
+	db *sql.DB
+}
+
+func NewJobRunRepository(db *sql.DB) *JobRunRepository {
+	return &JobRunRepository{db: db}
+}
+
+// TryAdvisoryLock prova ad acquisire un advisory lock di sessione senza attendere.
+// Il lock appartiene alla connessione, quindi ne viene riservata una fino al rilascio:
+// la funzione restituita sblocca il lock e restituisce la connessione al pool.
+func (r *JobRunRepository) TryAdvisoryLock(ctx context.Context, key int64) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// Il contesto del job potrebbe essere già annullato: lo sblocco usa un contesto proprio
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+			// Chiudendo la connessione fisica Postgres rilascia comunque il lock
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// StartRun registra l'inizio di un'esecuzione (scheduled_for è salvato in UTC).
+// Se un'altra replica ha già eseguito il job per lo stesso orario restituisce started = false.
+func (r *JobRunRepository) StartRun(jobName string, scheduledFor time.Time, instance string) (int64, bool, error) {
+	var runID int64
+	err := r.db.QueryRow(`
+		INSERT INTO job_runs (job_name, scheduled_for, instance, status, started_at)
+		VALUES ($1, $2, $3, 'running', CURRENT_TIMESTAMP)
+		ON CONFLICT (job_name, scheduled_for) DO NOTHING
+		RETURNING id`,
+		jobName, scheduledFor.UTC(), instance).Scan(&runID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return runID, true, nil
+}
+
+// FinishRun registra l'esito di un'esecuzione
+func (r *JobRunRepository) FinishRun(runID int64, runErr error) error {
+	status := JobRunSucceeded
+	var errorText sql.NullString
+	if runErr != nil {
+		status = JobRunFailed
+		errorText = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE job_runs
+		SET status = $2, error = $3, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		runID, status, errorText)
+	return err
+}
+
+// GetRecentRuns restituisce le ultime esecuzioni, opzionalmente filtrate per job
+func (r *JobRunRepository) GetRecentRuns(jobName string, limit int) ([]models.JobRun, error) {
+	rows, err := r.db.Query(`
+		SELECT id, job_name, scheduled_for, COALESCE(instance, ''), status, COALESCE(error, ''), started_at, finished_at
+		FROM job_runs
+		WHERE $1 = '' OR job_name = $1
+		ORDER BY started_at DESC
+		LIMIT $2`,
+		jobName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		var run models.JobRun
+		err := rows.Scan(&run.ID, &run.JobName, &run.ScheduledFor, &run.Instance, &run.Status,
+			&run.Error, &run.StartedAt, &run.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// DeleteRunsOlderThan elimina lo storico più vecchio della durata indicata
+func (r *JobRunRepository) DeleteRunsOlderThan(age time.Duration) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM job_runs
+		WHERE started_at < CURRENT_TIMESTAMP - make_interval(secs => $1)`,
+		age.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,650 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/elo"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori legati ai risultati delle partite
+var (
+	ErrResultNotFound     = errors.New("nessun risultato registrato per questa partita")
+	ErrResultConfirmed    = errors.New("il risultato è già stato confermato e non può essere modificato")
+	ErrNotResultPlayer    = errors.New("solo i giocatori della partita possono confermare il risultato")
+	ErrInvalidResultTeams = errors.New("le squadre devono contenere solo partecipanti confermati, ciascuno una sola volta")
+)
+
+type MatchResultRepository struct {
+	db *sql.DB
+}
+
+func NewMatchResultRepository(db *sql.DB) *MatchResultRepository {
+	return &MatchResultRepository{db: db}
+}
+
+// resultQuorum è il numero di conferme necessarie: la maggioranza dei giocatori
+func resultQuorum(players int) int {
+	return players/2 + 1
+}
+
+// resultStatus decide lo stato del risultato dai voti dei giocatori: serve la
+// maggioranza delle conferme, con almeno una conferma da ciascuna squadra, così
+// una sola squadra non può convalidare da sé il proprio risultato
+func resultStatus(players, agreements, disputes, teamsAgreeing int) string {
+	quorum := resultQuorum(players)
+	switch {
+	case agreements >= quorum && teamsAgreeing == 2:
+		return models.MatchResultStatusConfirmed
+	case disputes > players-quorum:
+		return models.MatchResultStatusDisputed
+	default:
+		return models.MatchResultStatusPending
+	}
+}
+
+// RecordResult registra il risultato di una partita già iniziata, o lo corregge
+// finché non è confermato. Ogni correzione azzera le conferme dei giocatori;
+// se l'organizzatore ha giocato, la sua registrazione vale come conferma.
+func (r *MatchResultRepository) RecordResult(organizerID int64, postID, scoreA, scoreB int, teamA, teamB []int64) (*models.MatchResult, error) {
+	if len(teamA) == 0 || len(teamB) == 0 {
+		return nil, ErrInvalidResultTeams
+	}
+	players := append(append([]int64{}, teamA...), teamB...)
+	seen := make(map[int64]bool, len(players))
+	for _, userID := range players {
+		if seen[userID] {
+			return nil, ErrInvalidResultTeams
+		}
+		seen[userID] = true
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var sport, provincia string
+	var playedAt, now time.Time
+	var isOrganizer bool
+	err = tx.QueryRow(`
+		SELECT p.sport, p.provincia, p.data_partita + p.ora_partita, LOCALTIMESTAMP,
+			EXISTS(SELECT 1 FROM users u WHERE u.email = p.autore_email AND u.id = $2)
+		FROM posts p WHERE p.id = $1`,
+		postID, organizerID).Scan(&sport, &provincia, &playedAt, &now, &isOrganizer)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganizer {
+		return nil, ErrNotEventOrganizer
+	}
+	if playedAt.After(now) {
+		return nil, ErrEventNotStarted
+	}
+
+	var confirmedPlayers int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM event_participants
+		WHERE post_id = $1 AND status = 'confirmed' AND user_id = ANY($2::INTEGER[])`,
+		postID, pq.Array(players)).Scan(&confirmedPlayers)
+	if err != nil {
+		return nil, err
+	}
+	if confirmedPlayers != len(players) {
+		return nil, ErrInvalidResultTeams
+	}
+
+	// L'upsert blocca la riga fino al commit: le registrazioni concorrenti si serializzano
+	err = tx.QueryRow(`
+		INSERT INTO match_results (post_id, sport, provincia, played_at, score_a, score_b, status, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7)
+		ON CONFLICT (post_id) DO UPDATE SET
+			sport = EXCLUDED.sport, provincia = EXCLUDED.provincia, played_at = EXCLUDED.played_at,
+			score_a = EXCLUDED.score_a, score_b = EXCLUDED.score_b, status = 'pending',
+			recorded_by = EXCLUDED.recorded_by, updated_at = CURRENT_TIMESTAMP
+		WHERE match_results.status <> 'confirmed'
+		RETURNING post_id`,
+		postID, sport, provincia, playedAt, scoreA, scoreB, organizerID).Scan(&postID)
+	if err == sql.ErrNoRows {
+		return nil, ErrResultConfirmed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM match_result_votes WHERE post_id = $1",
+		"DELETE FROM match_result_players WHERE post_id = $1",
+	} {
+		if _, err := tx.Exec(query, postID); err != nil {
+			return nil, err
+		}
+	}
+
+	for team, members := range map[string][]int64{"A": teamA, "B": teamB} {
+		_, err = tx.Exec(`
+			INSERT INTO match_result_players (post_id, user_id, team)
+			SELECT $1, unnest($2::INTEGER[]), $3`,
+			postID, pq.Array(members), team)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if seen[organizerID] {
+		_, err = tx.Exec(`
+			INSERT INTO match_result_votes (post_id, user_id, agree) VALUES ($1, $2, TRUE)`,
+			postID, organizerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := settleResult(tx, postID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetResult(postID, organizerID)
+}
+
+// VoteResult registra la conferma (agree = true) o la contestazione di un
+// giocatore; l'utente può cambiare voto finché il risultato non è confermato
+func (r *MatchResultRepository) VoteResult(userID int64, postID int, agree bool) (*models.MatchResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM match_results WHERE post_id = $1 FOR UPDATE", postID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, ErrResultNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == models.MatchResultStatusConfirmed {
+		return nil, ErrResultConfirmed
+	}
+
+	var isPlayer bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM match_result_players WHERE post_id = $1 AND user_id = $2)`,
+		postID, userID).Scan(&isPlayer)
+	if err != nil {
+		return nil, err
+	}
+	if !isPlayer {
+		return nil, ErrNotResultPlayer
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO match_result_votes (post_id, user_id, agree) VALUES ($1, $2, $3)
+		ON CONFLICT (post_id, user_id) DO UPDATE SET agree = EXCLUDED.agree, created_at = CURRENT_TIMESTAMP`,
+		postID, userID, agree)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := settleResult(tx, postID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetResult(postID, userID)
+}
+
+// settleResult ricalcola lo stato del risultato dai voti e, alla conferma,
+// aggiorna i punteggi Elo dei giocatori
+func settleResult(tx *sql.Tx, postID int) error {
+	var players, agreements, disputes, teamsAgreeing int
+	err := tx.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE v.agree),
+			COUNT(*) FILTER (WHERE NOT v.agree),
+			COUNT(DISTINCT mp.team) FILTER (WHERE v.agree)
+		FROM match_result_players mp
+		LEFT JOIN match_result_votes v ON v.post_id = mp.post_id AND v.user_id = mp.user_id
+		WHERE mp.post_id = $1`,
+		postID).Scan(&players, &agreements, &disputes, &teamsAgreeing)
+	if err != nil {
+		return err
+	}
+
+	status := resultStatus(players, agreements, disputes, teamsAgreeing)
+	_, err = tx.Exec(`
+		UPDATE match_results
+		SET status = $2, confirmed_at = CASE WHEN $2::VARCHAR = 'confirmed' THEN CURRENT_TIMESTAMP END
+		WHERE post_id = $1`,
+		postID, status)
+	if err != nil {
+		return err
+	}
+
+	if status == models.MatchResultStatusConfirmed {
+		if err := applyResultRatings(tx, postID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockSportRatings serializza gli aggiornamenti dei punteggi di uno sport
+func lockSportRatings(tx *sql.Tx, sport string) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "elo:"+sport)
+	return err
+}
+
+// applyResultRatings applica un risultato appena confermato ai punteggi attuali.
+// I risultati vengono applicati nell'ordine di conferma: RecalculateRatings
+// riallinea lo storico all'ordine in cui le partite sono state giocate.
+func applyResultRatings(tx *sql.Tx, postID int) error {
+	var sport string
+	var scoreA, scoreB int
+	err := tx.QueryRow("SELECT sport, score_a, score_b FROM match_results WHERE post_id = $1", postID).
+		Scan(&sport, &scoreA, &scoreB)
+	if err != nil {
+		return err
+	}
+
+	if err := lockSportRatings(tx, sport); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT mp.user_id, mp.team, COALESCE(pe.rating, $3), COALESCE(pe.games, 0)
+		FROM match_result_players mp
+		LEFT JOIN player_elo pe ON pe.user_id = mp.user_id AND pe.sport = $2
+		WHERE mp.post_id = $1
+		ORDER BY mp.user_id`,
+		postID, sport, elo.InitialRating)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var teamA, teamB []elo.Player
+	for rows.Next() {
+		var player elo.Player
+		var team string
+		if err := rows.Scan(&player.UserID, &team, &player.Rating, &player.Games); err != nil {
+			return err
+		}
+		if team == "A" {
+			teamA = append(teamA, player)
+		} else {
+			teamB = append(teamB, player)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	ratings := make(map[int64]elo.Player)
+	if err := replayMatch(tx, postID, sport, teamA, teamB, scoreA, scoreB, ratings); err != nil {
+		return err
+	}
+	return saveRatings(tx, sport, ratings)
+}
+
+// replayMatch aggiorna i punteggi di una partita, salvandone la variazione nello
+// storico, e riporta i nuovi punteggi in ratings
+func replayMatch(tx *sql.Tx, postID int, sport string, teamA, teamB []elo.Player, scoreA, scoreB int, ratings map[int64]elo.Player) error {
+	before := make(map[int64]float64, len(teamA)+len(teamB))
+	for _, team := range [][]elo.Player{teamA, teamB} {
+		for _, player := range team {
+			before[player.UserID] = player.Rating
+		}
+	}
+
+	elo.Update(teamA, teamB, scoreA, scoreB)
+
+	for _, team := range [][]elo.Player{teamA, teamB} {
+		for _, player := range team {
+			_, err := tx.Exec(`
+				INSERT INTO elo_history (post_id, user_id, sport, rating_before, rating_after)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (post_id, user_id) DO UPDATE SET
+					sport = EXCLUDED.sport, rating_before = EXCLUDED.rating_before,
+					rating_after = EXCLUDED.rating_after, created_at = CURRENT_TIMESTAMP`,
+				postID, player.UserID, sport, before[player.UserID], player.Rating)
+			if err != nil {
+				return err
+			}
+			ratings[player.UserID] = player
+		}
+	}
+	return nil
+}
+
+func saveRatings(tx *sql.Tx, sport string, ratings map[int64]elo.Player) error {
+	for _, player := range ratings {
+		_, err := tx.Exec(`
+			INSERT INTO player_elo (user_id, sport, rating, games) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, sport) DO UPDATE SET
+				rating = EXCLUDED.rating, games = EXCLUDED.games, updated_at = CURRENT_TIMESTAMP`,
+			player.UserID, sport, player.Rating, player.Games)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecalculateRatings rigenera da zero punteggi e storico di uno sport
+// rigiocando i risultati confermati nell'ordine in cui le partite si sono
+// svolte. Restituisce il numero di partite considerate.
+func (r *MatchResultRepository) RecalculateRatings(sport string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := lockSportRatings(tx, sport); err != nil {
+		return 0, err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM elo_history WHERE sport = $1",
+		"DELETE FROM player_elo WHERE sport = $1",
+	} {
+		if _, err := tx.Exec(query, sport); err != nil {
+			return 0, err
+		}
+	}
+
+	type replayedMatch struct {
+		postID         int
+		scoreA, scoreB int
+		teamA, teamB   []int64
+	}
+
+	rows, err := tx.Query(`
+		SELECT mr.post_id, mr.score_a, mr.score_b, mp.user_id, mp.team
+		FROM match_results mr
+		JOIN match_result_players mp ON mp.post_id = mr.post_id
+		WHERE mr.sport = $1 AND mr.status = 'confirmed'
+		ORDER BY mr.played_at, mr.post_id, mp.user_id`,
+		sport)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var matches []*replayedMatch
+	for rows.Next() {
+		var postID, scoreA, scoreB int
+		var userID int64
+		var team string
+		if err := rows.Scan(&postID, &scoreA, &scoreB, &userID, &team); err != nil {
+			return 0, err
+		}
+		if len(matches) == 0 || matches[len(matches)-1].postID != postID {
+			matches = append(matches, &replayedMatch{postID: postID, scoreA: scoreA, scoreB: scoreB})
+		}
+		match := matches[len(matches)-1]
+		if team == "A" {
+			match.teamA = append(match.teamA, userID)
+		} else {
+			match.teamB = append(match.teamB, userID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	ratings := make(map[int64]elo.Player)
+	current := func(userIDs []int64) []elo.Player {
+		team := make([]elo.Player, 0, len(userIDs))
+		for _, userID := range userIDs {
+			player, ok := ratings[userID]
+			if !ok {
+				player = elo.Player{UserID: userID, Rating: elo.InitialRating}
+			}
+			team = append(team, player)
+		}
+		return team
+	}
+
+	for _, match := range matches {
+		err := replayMatch(tx, match.postID, sport, current(match.teamA), current(match.teamB), match.scoreA, match.scoreB, ratings)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := saveRatings(tx, sport, ratings); err != nil {
+		return 0, err
+	}
+	return len(matches), tx.Commit()
+}
+
+// GetRatedSports restituisce gli sport con punteggi o risultati confermati
+func (r *MatchResultRepository) GetRatedSports() ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT sport FROM match_results WHERE status = 'confirmed'
+		UNION
+		SELECT sport FROM player_elo
+		ORDER BY sport`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sports []string
+	for rows.Next() {
+		var sport string
+		if err := rows.Scan(&sport); err != nil {
+			return nil, err
+		}
+		sports = append(sports, sport)
+	}
+	return sports, rows.Err()
+}
+
+// GetResult restituisce il risultato di una partita con squadre, voti e
+// variazioni di punteggio; viewerID serve a indicare il voto dell'utente
+func (r *MatchResultRepository) GetResult(postID int, viewerID int64) (*models.MatchResult, error) {
+	result := &models.MatchResult{PostID: postID}
+	var recordedBy sql.NullInt64
+	var confirmedAt sql.NullTime
+
+	err := r.db.QueryRow(`
+		SELECT sport, provincia, played_at, score_a, score_b, status, recorded_by,
+			created_at, updated_at, confirmed_at
+		FROM match_results WHERE post_id = $1`,
+		postID).Scan(&result.Sport, &result.Provincia, &result.PlayedAt, &result.ScoreA, &result.ScoreB,
+		&result.Status, &recordedBy, &result.CreatedAt, &result.UpdatedAt, &confirmedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrResultNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recordedBy.Valid {
+		result.RecordedBy = &recordedBy.Int64
+	}
+	if confirmedAt.Valid {
+		result.ConfirmedAt = &confirmedAt.Time
+	}
+
+	rows, err := r.db.Query(`
+		SELECT mp.user_id, mp.team, u.username, u.profile_picture, v.agree,
+			h.rating_before, h.rating_after
+		FROM match_result_players mp
+		JOIN users u ON u.id = mp.user_id
+		LEFT JOIN match_result_votes v ON v.post_id = mp.post_id AND v.user_id = mp.user_id
+		LEFT JOIN elo_history h ON h.post_id = mp.post_id AND h.user_id = mp.user_id
+		WHERE mp.post_id = $1
+		ORDER BY u.username`,
+		postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.TeamA = []models.MatchResultPlayer{}
+	result.TeamB = []models.MatchResultPlayer{}
+	for rows.Next() {
+		var player models.MatchResultPlayer
+		var team string
+		var profilePic sql.NullString
+		var agree sql.NullBool
+		var ratingBefore, ratingAfter sql.NullFloat64
+		if err := rows.Scan(&player.UserID, &team, &player.Username, &profilePic, &agree,
+			&ratingBefore, &ratingAfter); err != nil {
+			return nil, err
+		}
+		player.ProfilePic = profilePictureOrAvatar(player.UserID, profilePic)
+		if ratingBefore.Valid && ratingAfter.Valid {
+			player.RatingBefore = &ratingBefore.Float64
+			player.RatingAfter = &ratingAfter.Float64
+		}
+
+		if agree.Valid {
+			if agree.Bool {
+				result.Agreements++
+			} else {
+				result.Disputes++
+			}
+			if player.UserID == viewerID {
+				vote := agree.Bool
+				result.MyVote = &vote
+			}
+		}
+
+		if team == "A" {
+			result.TeamA = append(result.TeamA, player)
+		} else {
+			result.TeamB = append(result.TeamB, player)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Required = resultQuorum(len(result.TeamA) + len(result.TeamB))
+	return result, nil
+}
+
+// GetLeaderboard restituisce la classifica di uno sport. Con provincia vengono
+// inclusi solo i giocatori con almeno una partita confermata in quella
+// provincia; con friendsOf diverso da 0 solo l'utente e i suoi amici.
+func (r *MatchResultRepository) GetLeaderboard(sport, provincia string, friendsOf int64, limit, offset int) ([]models.LeaderboardEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT pe.user_id, u.username, u.profile_picture, pe.rating, pe.games
+		FROM player_elo pe
+		JOIN users u ON u.id = pe.user_id
+		WHERE pe.sport = $1 AND COALESCE(u.is_active, TRUE)
+		AND ($2::TEXT = '' OR EXISTS(
+			SELECT 1 FROM match_result_players mp
+			JOIN match_results mr ON mr.post_id = mp.post_id
+			WHERE mp.user_id = pe.user_id AND mr.sport = pe.sport
+			AND mr.status = 'confirmed' AND mr.provincia = $2
+		))
+		AND ($3::INTEGER = 0 OR pe.user_id = $3 OR EXISTS(
+			SELECT 1 FROM friendships f
+			WHERE f.user1_id = LEAST(pe.user_id, $3::INTEGER) AND f.user2_id = GREATEST(pe.user_id, $3::INTEGER)
+		))
+		ORDER BY pe.rating DESC, pe.games DESC, pe.user_id
+		LIMIT $4 OFFSET $5`,
+		sport, provincia, friendsOf, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LeaderboardEntry
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		var profilePic sql.NullString
+		if err := rows.Scan(&entry.UserID, &entry.Username, &profilePic, &entry.Rating, &entry.Games); err != nil {
+			return nil, err
+		}
+		entry.Rank = offset + len(entries) + 1
+		entry.ProfilePic = profilePictureOrAvatar(entry.UserID, profilePic)
+		entry.Provisional = elo.Provisional(entry.Games)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetUserRatings restituisce i punteggi di un giocatore in ogni sport con le
+// ultime historyLimit variazioni
+func (r *MatchResultRepository) GetUserRatings(userID int64, historyLimit int) ([]models.SportRating, error) {
+	rows, err := r.db.Query(`
+		SELECT sport, rating, games FROM player_elo
+		WHERE user_id = $1
+		ORDER BY games DESC, sport`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ratings []models.SportRating
+	index := make(map[string]int)
+	for rows.Next() {
+		rating := models.SportRating{History: []models.RatingChange{}}
+		if err := rows.Scan(&rating.Sport, &rating.Rating, &rating.Games); err != nil {
+			return nil, err
+		}
+		rating.Provisional = elo.Provisional(rating.Games)
+		index[rating.Sport] = len(ratings)
+		ratings = append(ratings, rating)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(ratings) == 0 {
+		return ratings, nil
+	}
+
+	historyRows, err := r.db.Query(`
+		SELECT sport, post_id, played_at, rating_before, rating_after
+		FROM (
+			SELECT h.sport, h.post_id, mr.played_at, h.rating_before, h.rating_after,
+				ROW_NUMBER() OVER (PARTITION BY h.sport ORDER BY mr.played_at DESC, h.post_id DESC) AS n
+			FROM elo_history h
+			JOIN match_results mr ON mr.post_id = h.post_id
+			WHERE h.user_id = $1
+		) history
+		WHERE n <= $2
+		ORDER BY sport, played_at DESC, post_id DESC`,
+		userID, historyLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer historyRows.Close()
+
+	for historyRows.Next() {
+		var sport string
+		var change models.RatingChange
+		if err := historyRows.Scan(&sport, &change.PostID, &change.PlayedAt, &change.RatingBefore, &change.RatingAfter); err != nil {
+			return nil, err
+		}
+		if i, ok := index[sport]; ok {
+			ratings[i].History = append(ratings[i].History, change)
+		}
+	}
+	return ratings, historyRows.Err()
+}
@@ -17,12 +17,17 @@ func NewNotificationRepository(db *sql.DB) *NotificationRepository {
 
 // CreateNotification crea una nuova notifica
 func (r *NotificationRepository) CreateNotification(notification *models.Notification) error {
+	return insertNotification(r.db, notification)
+}
+
+// insertNotification salva la notifica con il connettore indicato, anche dentro una transazione
+func insertNotification(q queryRower, notification *models.Notification) error {
 	query := `
 		INSERT INTO notifications (user_id, type, title, message, status, related_id, sender_id, expires_at) 
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRow(
+	err := q.QueryRow(
 		query,
 		notification.UserID,
 		notification.Type,
@@ -61,12 +66,13 @@ func (r *NotificationRepository) GetUserNotifications(userID int64, limit int, o
 	for rows.Next() {
 		var n models.Notification
 		var senderInfo models.SenderInfo
+		var senderProfilePic sql.NullString
 
 		err := rows.Scan(
 			&n.ID, &n.UserID, &n.Type, &n.Title, &n.Message, &n.Status,
 			&n.RelatedID, &n.SenderID, &n.CreatedAt, &n.UpdatedAt, &n.ExpiresAt,
 			&senderInfo.Username, &senderInfo.Nome, &senderInfo.Cognome,
-			&senderInfo.Email, &senderInfo.ProfilePic,
+			&senderInfo.Email, &senderProfilePic,
 		)
 		if err != nil {
 			return nil, err
@@ -75,6 +81,7 @@ func (r *NotificationRepository) GetUserNotifications(userID int64, limit int, o
 		// Aggiungi le informazioni del mittente se presenti
 		if n.SenderID != nil {
 			senderInfo.UserID = *n.SenderID
+			senderInfo.ProfilePic = profilePictureOrAvatar(senderInfo.UserID, senderProfilePic)
 			senderInfo.DisplayName = getDisplayName(senderInfo.Username, senderInfo.Nome, senderInfo.Cognome)
 			n.SenderInfo = &senderInfo
 		}
@@ -212,7 +219,11 @@ func (r *NotificationRepository) CreateFriendRequestNotification(receiverID, sen
 
 // CreateEventInviteNotification crea una notifica per invito evento
 func (r *NotificationRepository) CreateEventInviteNotification(receiverID, senderID, postID int64, senderUsername, eventTitle string) error {
-	notification := &models.Notification{
+	return r.CreateNotification(eventInviteNotification(receiverID, senderID, postID, senderUsername, eventTitle))
+}
+
+func eventInviteNotification(receiverID, senderID, postID int64, senderUsername, eventTitle string) *models.Notification {
+	return &models.Notification{
 		UserID:    receiverID,
 		Type:      models.NotificationTypeEventInvite,
 		Title:     "Invito a evento",
@@ -221,10 +232,115 @@ func (r *NotificationRepository) CreateEventInviteNotification(receiverID, sende
 		RelatedID: &postID,
 		SenderID:  &senderID,
 	}
+}
+
+// CreateEventInviteReminderNotification ricorda al destinatario un invito ancora senza risposta
+func (r *NotificationRepository) CreateEventInviteReminderNotification(receiverID, senderID, postID int64, title, message string) error {
+	notification := &models.Notification{
+		UserID:    receiverID,
+		Type:      models.NotificationTypeEventInvite,
+		Title:     title,
+		Message:   message,
+		Status:    models.NotificationStatusUnread,
+		RelatedID: &postID,
+		SenderID:  &senderID,
+	}
 
 	return r.CreateNotification(notification)
 }
 
+// CreateWaitlistPromotionNotification avvisa l'utente promosso dalla lista d'attesa
+func (r *NotificationRepository) CreateWaitlistPromotionNotification(userID, postID int64, eventTitle string) error {
+	message := fmt.Sprintf("Si è liberato un posto: sei ora iscritto all'evento %s", eventTitle)
+	return r.CreateEventUpdateNotification(userID, postID, nil, "Posto confermato", message)
+}
+
+// CreateEventUpdateNotification crea una notifica relativa a un evento
+// (decisioni dell'organizzatore, cambi di stato della partecipazione, ...)
+func (r *NotificationRepository) CreateEventUpdateNotification(userID, postID int64, senderID *int64, title, message string) error {
+	notification := &models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationTypeEventUpdate,
+		Title:     title,
+		Message:   message,
+		Status:    models.NotificationStatusUnread,
+		RelatedID: &postID,
+		SenderID:  senderID,
+	}
+
+	return r.CreateNotification(notification)
+}
+
+// CreateMatchReminderNotification crea il promemoria di una partita imminente
+func (r *NotificationRepository) CreateMatchReminderNotification(userID, postID int64, title, message string) error {
+	notification := &models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationTypeMatchReminder,
+		Title:     title,
+		Message:   message,
+		Status:    models.NotificationStatusUnread,
+		RelatedID: &postID,
+	}
+
+	return r.CreateNotification(notification)
+}
+
+// CreateSeriesOccurrenceNotification avvisa un giocatore fisso della nuova partita di una serie
+func (r *NotificationRepository) CreateSeriesOccurrenceNotification(userID, postID, organizerID int64, title, message string) error {
+	notification := &models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationTypeSeriesOccurrence,
+		Title:     title,
+		Message:   message,
+		Status:    models.NotificationStatusUnread,
+		RelatedID: &postID,
+		SenderID:  &organizerID,
+	}
+
+	return r.CreateNotification(notification)
+}
+
+// CreateFieldAlertNotification avvisa l'utente delle nuove partite nei suoi campi
+// preferiti; postID è nil per i riepiloghi con più partite
+func (r *NotificationRepository) CreateFieldAlertNotification(userID int64, postID *int64, title, message string) error {
+	notification := &models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationTypeFieldAlert,
+		Title:     title,
+		Message:   message,
+		Status:    models.NotificationStatusUnread,
+		RelatedID: postID,
+	}
+
+	return r.CreateNotification(notification)
+}
+
+// GetPreferences restituisce le preferenze di notifica dell'utente (default se mai impostate)
+func (r *NotificationRepository) GetPreferences(userID int64) (*models.NotificationPreferences, error) {
+	preferences := &models.NotificationPreferences{MatchReminders: true}
+
+	err := r.db.QueryRow(`
+		SELECT match_reminders FROM notification_preferences WHERE user_id = $1`,
+		userID).Scan(&preferences.MatchReminders)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences salva le preferenze di notifica dell'utente
+func (r *NotificationRepository) UpdatePreferences(userID int64, preferences *models.NotificationPreferences) error {
+	_, err := r.db.Exec(`
+		INSERT INTO notification_preferences (user_id, match_reminders, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			match_reminders = $2,
+			updated_at = CURRENT_TIMESTAMP`,
+		userID, preferences.MatchReminders)
+	return err
+}
+
 // GetNotificationStats ottiene statistiche sulle notifiche per il cleanup service
 func (r *NotificationRepository) GetNotificationStats() (map[string]int, error) {
 	query := `
@@ -0,0 +1,235 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/reputation"
+)
+
+// Errori legati alle valutazioni tra giocatori
+var (
+	ErrRatingWindowClosed = errors.New("le valutazioni per questa partita sono chiuse")
+	ErrNotCoParticipant   = errors.New("puoi valutare solo i giocatori con cui hai giocato")
+	ErrAlreadyRated       = errors.New("hai già valutato questo giocatore per questa partita")
+	ErrCannotRateSelf     = errors.New("non puoi valutare te stesso")
+)
+
+// Giorni dopo la partita entro cui è possibile valutare i compagni
+const ratingWindowDays = 14
+
+// Una segnalazione di no-show conta solo se la fa almeno questo numero di
+// valutatori e comunque la maggioranza di chi ha valutato il giocatore
+const minNoShowFlags = 2
+
+type RatingRepository struct {
+	db *sql.DB
+}
+
+func NewRatingRepository(db *sql.DB) *RatingRepository {
+	return &RatingRepository{db: db}
+}
+
+// checkRatingWindow verifica che la partita sia terminata da non più di ratingWindowDays
+// e che il valutatore vi abbia partecipato
+func checkRatingWindow(q queryRower, raterID int64, postID int) error {
+	var startsAt, now time.Time
+	err := q.QueryRow(`
+		SELECT data_partita + ora_partita, LOCALTIMESTAMP FROM posts WHERE id = $1`,
+		postID).Scan(&startsAt, &now)
+	if err == sql.ErrNoRows {
+		return ErrEventNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if startsAt.After(now) {
+		return ErrEventNotStarted
+	}
+	if now.Sub(startsAt) > ratingWindowDays*24*time.Hour {
+		return ErrRatingWindowClosed
+	}
+
+	// Chi è stato segnato come no-show non ha giocato e non può valutare
+	status, err := currentParticipationStatus(q, raterID, postID)
+	if err != nil {
+		return err
+	}
+	if status != models.ParticipantStatusConfirmed {
+		return ErrNotCoParticipant
+	}
+	return nil
+}
+
+// GetRateableParticipants restituisce i compagni di partita che l'utente può
+// valutare, con le valutazioni già espresse
+func (r *RatingRepository) GetRateableParticipants(raterID int64, postID int) ([]models.RateableParticipant, error) {
+	if err := checkRatingWindow(r.db, raterID, postID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT u.id, u.username, u.profile_picture,
+			pr.sportsmanship, pr.skill, pr.punctuality, pr.no_show, pr.created_at
+		FROM event_participants ep
+		JOIN users u ON u.id = ep.user_id
+		LEFT JOIN player_ratings pr
+			ON pr.post_id = ep.post_id AND pr.rater_id = $2 AND pr.rated_id = ep.user_id
+		WHERE ep.post_id = $1 AND ep.user_id <> $2
+		AND ep.status IN ('confirmed', 'no_show')
+		ORDER BY u.username`,
+		postID, raterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []models.RateableParticipant
+	for rows.Next() {
+		var participant models.RateableParticipant
+		var profilePic sql.NullString
+		var sportsmanship, skill, punctuality sql.NullInt64
+		var noShow sql.NullBool
+		var createdAt sql.NullTime
+		if err := rows.Scan(&participant.UserID, &participant.Username, &profilePic,
+			&sportsmanship, &skill, &punctuality, &noShow, &createdAt); err != nil {
+			return nil, err
+		}
+		participant.ProfilePic = profilePictureOrAvatar(participant.UserID, profilePic)
+
+		if createdAt.Valid {
+			participant.MyRating = &models.PlayerRating{
+				PostID:        postID,
+				RaterID:       raterID,
+				RatedID:       participant.UserID,
+				Sportsmanship: nullIntPtr(sportsmanship),
+				Skill:         nullIntPtr(skill),
+				Punctuality:   nullIntPtr(punctuality),
+				NoShow:        noShow.Bool,
+				CreatedAt:     createdAt.Time,
+			}
+		}
+		participants = append(participants, participant)
+	}
+
+	return participants, rows.Err()
+}
+
+// CreateRating registra la valutazione di un compagno di partita: solo tra
+// partecipanti dello stesso evento e una sola volta per coppia
+func (r *RatingRepository) CreateRating(rating *models.PlayerRating) error {
+	if rating.RaterID == rating.RatedID {
+		return ErrCannotRateSelf
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkRatingWindow(tx, rating.RaterID, rating.PostID); err != nil {
+		return err
+	}
+
+	status, err := currentParticipationStatus(tx, rating.RatedID, rating.PostID)
+	if err != nil {
+		return err
+	}
+	if status != models.ParticipantStatusConfirmed && status != models.ParticipantStatusNoShow {
+		return ErrNotCoParticipant
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO player_ratings (post_id, rater_id, rated_id, sportsmanship, skill, punctuality, no_show)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (post_id, rater_id, rated_id) DO NOTHING
+		RETURNING created_at`,
+		rating.PostID, rating.RaterID, rating.RatedID, rating.Sportsmanship, rating.Skill,
+		rating.Punctuality, rating.NoShow).Scan(&rating.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrAlreadyRated
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetReputation calcola la reputazione del giocatore. Per limitare gli abusi
+// conta solo l'ultimo voto di ogni valutatore (un gruppo che gioca spesso
+// insieme non può votare ogni settimana), i voti di una stessa partita hanno
+// un peso complessivo limitato e i no-show contano solo se segnalati dalla
+// maggioranza dei valutatori o dall'organizzatore.
+func (r *RatingRepository) GetReputation(userID int64) (*models.Reputation, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT ON (pr.rater_id) pr.sportsmanship, pr.skill, pr.punctuality,
+			(SELECT COUNT(*) FROM player_ratings e
+			 WHERE e.post_id = pr.post_id AND e.rated_id = pr.rated_id AND NOT e.no_show)
+		FROM player_ratings pr
+		WHERE pr.rated_id = $1 AND NOT pr.no_show
+		ORDER BY pr.rater_id, pr.created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sportsmanship, skill, punctuality []reputation.Vote
+	for rows.Next() {
+		var s, sk, p float64
+		var eventRaters int
+		if err := rows.Scan(&s, &sk, &p, &eventRaters); err != nil {
+			return nil, err
+		}
+		sportsmanship = append(sportsmanship, reputation.Vote{Value: s, EventRaters: eventRaters})
+		skill = append(skill, reputation.Vote{Value: sk, EventRaters: eventRaters})
+		punctuality = append(punctuality, reputation.Vote{Value: p, EventRaters: eventRaters})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rep := &models.Reputation{
+		UserID:        userID,
+		Sportsmanship: reputation.Score(sportsmanship),
+		Skill:         reputation.Score(skill),
+		Punctuality:   reputation.Score(punctuality),
+		Raters:        len(sportsmanship),
+	}
+	rep.Overall = math.Round((rep.Sportsmanship+rep.Skill+rep.Punctuality)/3*100) / 100
+
+	err = r.db.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE ep.status = 'no_show' OR (f.flags >= $2 AND f.flags * 2 > f.raters))
+		FROM event_participants ep
+		JOIN posts p ON p.id = ep.post_id
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS raters, COUNT(*) FILTER (WHERE pr.no_show) AS flags
+			FROM player_ratings pr
+			WHERE pr.post_id = ep.post_id AND pr.rated_id = ep.user_id
+		) f
+		WHERE ep.user_id = $1 AND ep.status IN ('confirmed', 'no_show')
+		AND p.data_partita + p.ora_partita <= LOCALTIMESTAMP`,
+		userID, minNoShowFlags).Scan(&rep.EventsPlayed, &rep.NoShows)
+	if err != nil {
+		return nil, err
+	}
+
+	if rep.EventsPlayed > 0 {
+		rep.NoShowRate = math.Round(float64(rep.NoShows)/float64(rep.EventsPlayed)*100) / 100
+	}
+	return rep, nil
+}
+
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
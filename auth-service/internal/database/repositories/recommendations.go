@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/privacy"
+	"trovagiocatoriAuth/internal/recommend"
+)
+
+// Numero di partite giocate più recenti considerate per il profilo
+const recommendationHistoryLimit = 100
+
+type RecommendationRepository struct {
+	db *sql.DB
+}
+
+func NewRecommendationRepository(db *sql.DB) *RecommendationRepository {
+	return &RecommendationRepository{db: db}
+}
+
+// GetProfile ricostruisce le abitudini dell'utente: sport, livelli e campi
+// delle partite giocate e post preferiti
+func (r *RecommendationRepository) GetProfile(userID int64) (*recommend.Profile, error) {
+	profile := recommend.NewProfile()
+
+	rows, err := r.db.Query(`
+		SELECT p.sport, p.livello, sf.lat, sf.lng
+		FROM event_participants ep
+		JOIN posts p ON p.id = ep.post_id
+		LEFT JOIN sport_fields sf ON sf.id = p.campo_id
+		WHERE ep.user_id = $1 AND ep.status = 'confirmed'
+		AND p.data_partita + p.ora_partita <= LOCALTIMESTAMP
+		ORDER BY p.data_partita + p.ora_partita DESC
+		LIMIT $2`,
+		userID, recommendationHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sport, level string
+		var lat, lng sql.NullFloat64
+		if err := rows.Scan(&sport, &level, &lat, &lng); err != nil {
+			return nil, err
+		}
+		var field *recommend.Point
+		if lat.Valid && lng.Valid {
+			field = &recommend.Point{Lat: lat.Float64, Lng: lng.Float64}
+		}
+		profile.AddPlayed(sport, level, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	favoriteRows, err := r.db.Query(`
+		SELECT f.post_id, p.sport
+		FROM user_favorites f
+		JOIN posts p ON p.id = f.post_id
+		WHERE f.user_id = $1`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer favoriteRows.Close()
+
+	for favoriteRows.Next() {
+		var postID int
+		var sport string
+		if err := favoriteRows.Scan(&postID, &sport); err != nil {
+			return nil, err
+		}
+		profile.AddFavorite(postID, sport)
+	}
+	return profile, favoriteRows.Err()
+}
+
+// GetCandidates restituisce le prossime partite (entro horizonDays) che l'utente
+// non organizza e a cui non si è mai iscritto, con gli amici già confermati che
+// non si nascondono all'utente
+func (r *RecommendationRepository) GetCandidates(userID int64, horizonDays, limit int) ([]recommend.Candidate, error) {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.titolo, p.sport, p.livello, p.citta, p.provincia, p.data_partita + p.ora_partita,
+			p.numero_giocatori, COALESCE(sf.nome, ''), sf.lat, sf.lng,
+			(SELECT COUNT(*) FROM event_participants c WHERE c.post_id = p.id AND c.status = 'confirmed')
+		FROM posts p
+		LEFT JOIN sport_fields sf ON sf.id = p.campo_id
+		WHERE p.data_partita + p.ora_partita > LOCALTIMESTAMP
+		AND p.data_partita + p.ora_partita < LOCALTIMESTAMP + make_interval(days => $2)
+		AND p.autore_email <> (SELECT email FROM users WHERE id = $1)
+		AND NOT EXISTS(SELECT 1 FROM event_participants ep WHERE ep.post_id = p.id AND ep.user_id = $1)
+		ORDER BY p.data_partita + p.ora_partita, p.id
+		LIMIT $3`,
+		userID, horizonDays, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []recommend.Candidate
+	for rows.Next() {
+		var candidate recommend.Candidate
+		var lat, lng sql.NullFloat64
+		event := &candidate.Event
+		if err := rows.Scan(&event.PostID, &event.Titolo, &event.Sport, &event.Livello, &event.Citta,
+			&event.Provincia, &event.StartsAt, &candidate.Capacity, &event.Campo, &lat, &lng,
+			&candidate.Confirmed); err != nil {
+			return nil, err
+		}
+		if lat.Valid && lng.Valid {
+			candidate.Field = &recommend.Point{Lat: lat.Float64, Lng: lng.Float64}
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := r.attachFriendsGoing(userID, candidates); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// attachFriendsGoing aggiunge alle partite gli amici confermati, applicando le
+// loro impostazioni di privacy: chi si nasconde agli amici non viene nominato
+// né conta tra gli amici che partecipano
+func (r *RecommendationRepository) attachFriendsGoing(userID int64, candidates []recommend.Candidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	postIDs := make([]int, len(candidates))
+	index := make(map[int]int, len(candidates))
+	for i, candidate := range candidates {
+		postIDs[i] = candidate.Event.PostID
+		index[candidate.Event.PostID] = i
+	}
+
+	defaults := models.DefaultPrivacySettings()
+	rows, err := r.db.Query(`
+		SELECT fp.post_id, u.id, u.username,
+			COALESCE(ps.strangers, $3), COALESCE(ps.friends, $4), COALESCE(ps.co_participants, $5)
+		FROM event_participants fp
+		JOIN friendships f ON f.user1_id = LEAST(fp.user_id, $1::INTEGER) AND f.user2_id = GREATEST(fp.user_id, $1::INTEGER)
+		JOIN users u ON u.id = fp.user_id
+		LEFT JOIN user_privacy_settings ps ON ps.user_id = u.id
+		WHERE fp.post_id = ANY($2::INTEGER[]) AND fp.status = 'confirmed'
+		ORDER BY u.username`,
+		userID, pq.Array(postIDs), defaults.Strangers, defaults.Friends, defaults.CoParticipants)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	friends := make(map[int][]models.EventParticipant)
+	friendIDs := make(map[int64]bool)
+	for rows.Next() {
+		var postID int
+		var friend models.EventParticipant
+		if err := rows.Scan(&postID, &friend.UserID, &friend.Username,
+			&friend.Privacy.Strangers, &friend.Privacy.Friends, &friend.Privacy.CoParticipants); err != nil {
+			return err
+		}
+		friend.Status = models.ParticipantStatusConfirmed
+		friends[postID] = append(friends[postID], friend)
+		friendIDs[friend.UserID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// L'utente non è iscritto alle partite candidate: le vede solo da amico
+	viewer := privacy.Viewer{UserID: userID, FriendIDs: friendIDs}
+	for postID, participants := range friends {
+		event := &candidates[index[postID]].Event
+		for _, view := range privacy.BuildParticipantViews(viewer, participants) {
+			if !view.Anonymous {
+				event.FriendsGoing = append(event.FriendsGoing, view.Username)
+			}
+		}
+	}
+	return nil
+}
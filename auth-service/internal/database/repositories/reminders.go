@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+type ReminderRepository struct {
+	db *sql.DB
+}
+
+func NewReminderRepository(db *sql.DB) *ReminderRepository {
+	return &ReminderRepository{db: db}
+}
+
+// GetDueMatchReminders restituisce i partecipanti confermati da avvisare per il
+// promemoria kind: partite che iniziano tra minLead e lead da adesso, per utenti
+// che non hanno disattivato i promemoria e non l'hanno già ricevuto
+func (r *ReminderRepository) GetDueMatchReminders(kind string, lead, minLead time.Duration, limit int) ([]models.MatchReminder, error) {
+	rows, err := r.db.Query(`
+		SELECT ep.user_id, p.id, p.titolo, p.data_partita + p.ora_partita
+		FROM event_participants ep
+		JOIN posts p ON p.id = ep.post_id
+		LEFT JOIN notification_preferences np ON np.user_id = ep.user_id
+		LEFT JOIN match_reminders_sent mrs
+			ON mrs.user_id = ep.user_id AND mrs.post_id = ep.post_id AND mrs.kind = $1
+		WHERE ep.status = 'confirmed'
+		AND COALESCE(np.match_reminders, TRUE)
+		AND mrs.user_id IS NULL
+		AND p.data_partita BETWEEN CURRENT_DATE - 1 AND CURRENT_DATE + $2::INTEGER
+		AND p.data_partita + p.ora_partita > LOCALTIMESTAMP + make_interval(secs => $3)
+		AND p.data_partita + p.ora_partita <= LOCALTIMESTAMP + make_interval(secs => $4)
+		ORDER BY p.data_partita, p.ora_partita, ep.user_id
+		LIMIT $5`,
+		kind, int(lead.Hours()/24)+1, minLead.Seconds(), lead.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []models.MatchReminder
+	for rows.Next() {
+		reminder := models.MatchReminder{Kind: kind}
+		if err := rows.Scan(&reminder.UserID, &reminder.PostID, &reminder.Titolo, &reminder.StartsAt); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+// MarkReminderSent registra l'invio di un promemoria; restituisce false se
+// era già stato registrato (es. da un'altra replica)
+func (r *ReminderRepository) MarkReminderSent(userID int64, postID int, kind string) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO match_reminders_sent (user_id, post_id, kind)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, post_id, kind) DO NOTHING`,
+		userID, postID, kind)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Stati di partecipazione che occupano il calendario dell'utente: anche le
+// richieste in attesa e la lista d'attesa possono trasformarsi in un posto
+const commitmentStatusesSQL = `'confirmed', 'tentative', 'pending', 'waitlisted'`
+
+var commitmentStatuses = map[string]bool{
+	models.ParticipantStatusConfirmed:  true,
+	models.ParticipantStatusTentative:  true,
+	models.ParticipantStatusPending:    true,
+	models.ParticipantStatusWaitlisted: true,
+}
+
+// ErrScheduleConflict è restituito quando l'iscrizione si sovrappone ad altri
+// impegni dell'utente; l'errore concreto è un *ScheduleConflictError
+var ErrScheduleConflict = errors.New("l'orario si sovrappone ad altre partite")
+
+// ScheduleConflictError riporta gli impegni che hanno bloccato l'iscrizione
+type ScheduleConflictError struct {
+	Conflicts []models.Commitment
+}
+
+func (e *ScheduleConflictError) Error() string { return ErrScheduleConflict.Error() }
+func (e *ScheduleConflictError) Unwrap() error { return ErrScheduleConflict }
+
+// ScheduleCheck chiede di verificare le sovrapposizioni con gli altri impegni
+// dell'utente nella stessa transazione dell'iscrizione, dopo il lock dell'evento
+type ScheduleCheck struct {
+	Schedule config.ScheduleConfig
+	Force    bool // iscrive comunque, riportando le sovrapposizioni in ParticipationChange.Conflicts
+}
+
+// queryer è implementato sia da *sql.DB che da *sql.Tx
+type queryer interface {
+	queryRower
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// LocalToday restituisce la data odierna secondo l'orologio del database,
+// lo stesso a cui si riferiscono data_partita e ora_partita
+func (r *EventRepository) LocalToday() (time.Time, error) {
+	var today time.Time
+	err := r.db.QueryRow("SELECT CURRENT_DATE::TIMESTAMP").Scan(&today)
+	return today, err
+}
+
+// GetEventCommitment restituisce orario e dati di un evento per confrontarlo
+// con gli impegni dell'utente
+func (r *EventRepository) GetEventCommitment(postID int) (*models.Commitment, error) {
+	return getEventCommitment(r.db, postID)
+}
+
+func getEventCommitment(q queryRower, postID int) (*models.Commitment, error) {
+	commitment := &models.Commitment{PostID: postID}
+	err := q.QueryRow(`
+		SELECT titolo, sport, citta, provincia, data_partita + ora_partita
+		FROM posts WHERE id = $1`,
+		postID).Scan(&commitment.Titolo, &commitment.Sport, &commitment.Citta,
+		&commitment.Provincia, &commitment.StartsAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return commitment, nil
+}
+
+// GetUserCommitments restituisce le partite che iniziano tra from (incluso) e
+// until (escluso) in cui l'utente è organizzatore o partecipante attivo
+func (r *EventRepository) GetUserCommitments(userID int64, from, until time.Time) ([]models.Commitment, error) {
+	return getUserCommitments(r.db, userID, from, until)
+}
+
+func getUserCommitments(q queryer, userID int64, from, until time.Time) ([]models.Commitment, error) {
+	rows, err := q.Query(`
+		SELECT p.id, p.titolo, p.sport, p.citta, p.provincia, p.data_partita + p.ora_partita,
+			p.autore_email = u.email, COALESCE(ep.status, '')
+		FROM posts p
+		JOIN users u ON u.id = $1
+		LEFT JOIN event_participants ep ON ep.post_id = p.id AND ep.user_id = u.id
+			AND ep.status IN (`+commitmentStatusesSQL+`)
+		WHERE (p.autore_email = u.email OR ep.user_id IS NOT NULL)
+		AND p.data_partita + p.ora_partita >= $2
+		AND p.data_partita + p.ora_partita < $3
+		ORDER BY p.data_partita + p.ora_partita, p.id`,
+		userID, from, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commitments []models.Commitment
+	for rows.Next() {
+		var commitment models.Commitment
+		var isOrganizer bool
+		if err := rows.Scan(&commitment.PostID, &commitment.Titolo, &commitment.Sport, &commitment.Citta,
+			&commitment.Provincia, &commitment.StartsAt, &isOrganizer, &commitment.Status); err != nil {
+			return nil, err
+		}
+		commitment.Role = models.CommitmentRoleParticipant
+		if isOrganizer {
+			commitment.Role = models.CommitmentRoleOrganizer
+		}
+		commitments = append(commitments, commitment)
+	}
+	return commitments, rows.Err()
+}
+
+// scheduleConflicts restituisce gli impegni dell'utente che si sovrappongono
+// all'evento, con la durata presunta di ogni partita. Va chiamata dopo il lock
+// dell'evento: il lock dell'utente serializza anche le sue iscrizioni
+// concorrenti a eventi diversi.
+func scheduleConflicts(tx *sql.Tx, cfg config.ScheduleConfig, userID int64, postID int) ([]models.Commitment, error) {
+	if _, err := tx.Exec("SELECT 1 FROM users WHERE id = $1 FOR UPDATE", userID); err != nil {
+		return nil, err
+	}
+
+	event, err := getEventCommitment(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+	event.EndsAt = event.StartsAt.Add(cfg.MatchDuration(event.Sport))
+
+	commitments, err := getUserCommitments(tx, userID, event.StartsAt.Add(-cfg.MaxMatchDuration()), event.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []models.Commitment
+	for _, commitment := range commitments {
+		commitment.EndsAt = commitment.StartsAt.Add(cfg.MatchDuration(commitment.Sport))
+		if commitment.PostID != postID && overlaps(*event, commitment) {
+			conflicts = append(conflicts, commitment)
+		}
+	}
+	return conflicts, nil
+}
+
+// overlaps indica se due impegni si sovrappongono (gli estremi possono toccarsi)
+func overlaps(a, b models.Commitment) bool {
+	return a.StartsAt.Before(b.EndsAt) && b.StartsAt.Before(a.EndsAt)
+}
@@ -0,0 +1,567 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori legati alle serie di partite ricorrenti
+var (
+	ErrSeriesNotFound       = errors.New("serie non trovata")
+	ErrSeriesExists         = errors.New("il post fa già parte di una serie")
+	ErrNotSeriesOrganizer   = errors.New("solo l'organizzatore può gestire questa serie")
+	ErrNotInRoster          = errors.New("non fai parte dei giocatori fissi di questa serie")
+	ErrRosterMemberNotFound = errors.New("giocatore non presente nella serie")
+	ErrRosterUserNotFound   = errors.New("utente non trovato")
+	ErrSeriesSourceMissing  = errors.New("nessun post della serie da cui generare l'occorrenza")
+)
+
+// Stati di partecipazione al post modello che entrano nel roster alla creazione della serie
+var seriesRosterStatuses = []string{
+	models.ParticipantStatusConfirmed,
+	models.ParticipantStatusWaitlisted,
+	models.ParticipantStatusTentative,
+}
+
+const seriesColumns = `s.id, s.template_post_id, s.organizer_id, s.rrule, s.dtstart, s.horizon_days,
+	s.active, s.last_occurrence_at, s.created_at, s.updated_at`
+
+type SeriesRepository struct {
+	db *sql.DB
+}
+
+func NewSeriesRepository(db *sql.DB) *SeriesRepository {
+	return &SeriesRepository{db: db}
+}
+
+func scanSeries(scanner interface{ Scan(...interface{}) error }) (*models.EventSeries, error) {
+	var series models.EventSeries
+	err := scanner.Scan(&series.ID, &series.TemplatePostID, &series.OrganizerID, &series.RRule,
+		&series.DTStart, &series.HorizonDays, &series.Active, &series.LastOccurrenceAt,
+		&series.CreatedAt, &series.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// LocalNow restituisce LOCALTIMESTAMP del database, lo stesso riferimento
+// usato per confrontare data_partita + ora_partita
+func (r *SeriesRepository) LocalNow() (time.Time, error) {
+	var now time.Time
+	err := r.db.QueryRow("SELECT LOCALTIMESTAMP").Scan(&now)
+	return now, err
+}
+
+// CreateSeries crea una serie a partire dal post modello dell'organizzatore.
+// Il post modello è la prima occorrenza e i suoi partecipanti formano il roster iniziale.
+func (r *SeriesRepository) CreateSeries(organizerID int64, templatePostID int, rrule string, horizonDays int) (*models.EventSeries, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var dtstart time.Time
+	var authorID int64
+	err = tx.QueryRow(`
+		SELECT p.data_partita + p.ora_partita, COALESCE(u.id, 0)
+		FROM posts p
+		LEFT JOIN users u ON u.email = p.autore_email
+		WHERE p.id = $1`,
+		templatePostID).Scan(&dtstart, &authorID)
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if authorID != organizerID {
+		return nil, ErrNotEventOrganizer
+	}
+
+	// Un'occorrenza generata non può a sua volta diventare il modello di un'altra serie
+	var isOccurrence bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM event_series_occurrences WHERE post_id = $1)`,
+		templatePostID).Scan(&isOccurrence)
+	if err != nil {
+		return nil, err
+	}
+	if isOccurrence {
+		return nil, ErrSeriesExists
+	}
+
+	var seriesID int64
+	err = tx.QueryRow(`
+		INSERT INTO event_series (template_post_id, organizer_id, rrule, dtstart, horizon_days, last_occurrence_at)
+		VALUES ($1, $2, $3, $4, $5, $4)
+		ON CONFLICT (template_post_id) DO NOTHING
+		RETURNING id`,
+		templatePostID, organizerID, rrule, dtstart, horizonDays).Scan(&seriesID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSeriesExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("errore nella creazione della serie: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO event_series_occurrences (series_id, starts_at, post_id)
+		VALUES ($1, $2, $3)`,
+		seriesID, dtstart, templatePostID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO event_series_roster (series_id, user_id)
+		SELECT $1, user_id FROM event_participants
+		WHERE post_id = $2 AND status = ANY($3)`,
+		seriesID, templatePostID, pq.Array(seriesRosterStatuses))
+	if err != nil {
+		return nil, fmt.Errorf("errore nella creazione del roster: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetSeries(seriesID)
+}
+
+// GetSeries restituisce una serie (ErrSeriesNotFound se non esiste)
+func (r *SeriesRepository) GetSeries(seriesID int64) (*models.EventSeries, error) {
+	series, err := scanSeries(r.db.QueryRow(`
+		SELECT `+seriesColumns+` FROM event_series s WHERE s.id = $1`,
+		seriesID))
+	if err == sql.ErrNoRows {
+		return nil, ErrSeriesNotFound
+	}
+	return series, err
+}
+
+// GetUserSeries restituisce le serie organizzate dall'utente o di cui fa parte del roster
+func (r *SeriesRepository) GetUserSeries(userID int64) ([]models.EventSeries, error) {
+	return r.querySeries(`
+		SELECT `+seriesColumns+` FROM event_series s
+		WHERE s.organizer_id = $1
+		OR EXISTS(SELECT 1 FROM event_series_roster sr WHERE sr.series_id = s.id AND sr.user_id = $1)
+		ORDER BY s.active DESC, s.created_at DESC`,
+		userID)
+}
+
+// GetActiveSeries restituisce le serie attive, da elaborare nel job di generazione
+func (r *SeriesRepository) GetActiveSeries() ([]models.EventSeries, error) {
+	return r.querySeries(`
+		SELECT ` + seriesColumns + ` FROM event_series s
+		WHERE s.active
+		ORDER BY s.id`)
+}
+
+func (r *SeriesRepository) querySeries(query string, args ...interface{}) ([]models.EventSeries, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.EventSeries
+	for rows.Next() {
+		series, err := scanSeries(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *series)
+	}
+	return list, rows.Err()
+}
+
+// requireOrganizer verifica che la serie esista e che l'utente la organizzi
+func (r *SeriesRepository) requireOrganizer(organizerID, seriesID int64) error {
+	var ownerID int64
+	err := r.db.QueryRow("SELECT organizer_id FROM event_series WHERE id = $1", seriesID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrSeriesNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ownerID != organizerID {
+		return ErrNotSeriesOrganizer
+	}
+	return nil
+}
+
+// UpdateSeries aggiorna regola, orizzonte di generazione e stato della serie
+func (r *SeriesRepository) UpdateSeries(organizerID, seriesID int64, rrule string, horizonDays int, active bool) (*models.EventSeries, error) {
+	if err := r.requireOrganizer(organizerID, seriesID); err != nil {
+		return nil, err
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE event_series
+		SET rrule = $2, horizon_days = $3, active = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		seriesID, rrule, horizonDays, active)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSeries(seriesID)
+}
+
+// DeleteSeries elimina la serie; i post già generati restano come partite singole
+func (r *SeriesRepository) DeleteSeries(organizerID, seriesID int64) error {
+	if err := r.requireOrganizer(organizerID, seriesID); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec("DELETE FROM event_series WHERE id = $1", seriesID)
+	return err
+}
+
+// GetRoster restituisce i giocatori fissi della serie
+func (r *SeriesRepository) GetRoster(seriesID int64) ([]models.SeriesRosterMember, error) {
+	rows, err := r.db.Query(`
+		SELECT u.id, u.username, u.profile_picture, sr.added_at
+		FROM event_series_roster sr
+		JOIN users u ON u.id = sr.user_id
+		WHERE sr.series_id = $1
+		ORDER BY sr.added_at, u.id`,
+		seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roster []models.SeriesRosterMember
+	for rows.Next() {
+		var member models.SeriesRosterMember
+		var profilePic sql.NullString
+		if err := rows.Scan(&member.UserID, &member.Username, &profilePic, &member.AddedAt); err != nil {
+			return nil, err
+		}
+		member.ProfilePic = profilePictureOrAvatar(member.UserID, profilePic)
+		roster = append(roster, member)
+	}
+	return roster, rows.Err()
+}
+
+// IsRosterMember verifica se l'utente è tra i giocatori fissi della serie
+func (r *SeriesRepository) IsRosterMember(seriesID, userID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM event_series_roster WHERE series_id = $1 AND user_id = $2)`,
+		seriesID, userID).Scan(&exists)
+	return exists, err
+}
+
+// AddRosterMember aggiunge un giocatore fisso; restituisce false se era già presente
+func (r *SeriesRepository) AddRosterMember(organizerID, seriesID, userID int64) (bool, error) {
+	if err := r.requireOrganizer(organizerID, seriesID); err != nil {
+		return false, err
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO event_series_roster (series_id, user_id)
+		SELECT $1, id FROM users WHERE id = $2
+		ON CONFLICT (series_id, user_id) DO NOTHING`,
+		seriesID, userID)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		exists, err := r.IsRosterMember(seriesID, userID)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, ErrRosterUserNotFound
+		}
+	}
+	return rowsAffected > 0, nil
+}
+
+// RemoveRosterMember toglie un giocatore dal roster: può farlo l'organizzatore
+// o il giocatore stesso. Le occorrenze già generate non vengono modificate.
+func (r *SeriesRepository) RemoveRosterMember(actorID, seriesID, userID int64) error {
+	if actorID != userID {
+		if err := r.requireOrganizer(actorID, seriesID); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		DELETE FROM event_series_roster WHERE series_id = $1 AND user_id = $2`,
+		seriesID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRosterMemberNotFound
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM event_series_optouts WHERE series_id = $1 AND user_id = $2`,
+		seriesID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetOptOut registra (o annulla) la rinuncia di un giocatore del roster
+// all'occorrenza del giorno indicato
+func (r *SeriesRepository) SetOptOut(userID, seriesID int64, date time.Time, optOut bool) error {
+	member, err := r.IsRosterMember(seriesID, userID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrNotInRoster
+	}
+
+	day := date.Format("2006-01-02")
+	if optOut {
+		_, err = r.db.Exec(`
+			INSERT INTO event_series_optouts (series_id, user_id, occurrence_date)
+			VALUES ($1, $2, $3::DATE)
+			ON CONFLICT DO NOTHING`,
+			seriesID, userID, day)
+	} else {
+		_, err = r.db.Exec(`
+			DELETE FROM event_series_optouts
+			WHERE series_id = $1 AND user_id = $2 AND occurrence_date = $3::DATE`,
+			seriesID, userID, day)
+	}
+	return err
+}
+
+// GetOccurrencePostID restituisce il post dell'occorrenza del giorno indicato,
+// nil se non è ancora stata generata
+func (r *SeriesRepository) GetOccurrencePostID(seriesID int64, date time.Time) (*int, error) {
+	var postID int
+	err := r.db.QueryRow(`
+		SELECT post_id FROM event_series_occurrences
+		WHERE series_id = $1 AND starts_at::DATE = $2::DATE`,
+		seriesID, date.Format("2006-01-02")).Scan(&postID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &postID, nil
+}
+
+// GetOccurrences restituisce le occorrenze già generate a partire da from
+func (r *SeriesRepository) GetOccurrences(seriesID int64, from time.Time) ([]models.SeriesOccurrence, error) {
+	rows, err := r.db.Query(`
+		SELECT starts_at, post_id FROM event_series_occurrences
+		WHERE series_id = $1 AND starts_at >= $2
+		ORDER BY starts_at`,
+		seriesID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var occurrences []models.SeriesOccurrence
+	for rows.Next() {
+		var occurrence models.SeriesOccurrence
+		var postID int
+		if err := rows.Scan(&occurrence.StartsAt, &postID); err != nil {
+			return nil, err
+		}
+		occurrence.PostID = &postID
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, rows.Err()
+}
+
+// GetOptOutDates restituisce i giorni (formato 2006-01-02) a cui l'utente ha rinunciato
+func (r *SeriesRepository) GetOptOutDates(seriesID, userID int64, from time.Time) (map[string]bool, error) {
+	rows, err := r.db.Query(`
+		SELECT occurrence_date FROM event_series_optouts
+		WHERE series_id = $1 AND user_id = $2 AND occurrence_date >= $3::DATE`,
+		seriesID, userID, from.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dates := make(map[string]bool)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		dates[date.Format("2006-01-02")] = true
+	}
+	return dates, rows.Err()
+}
+
+// GenerateOccurrence crea il post dell'occorrenza startsAt copiando l'ultimo post
+// esistente della serie e vi iscrive il roster (tranne chi ha rinunciato),
+// rispettando la capienza. Restituisce nil se l'occorrenza esiste già.
+func (r *SeriesRepository) GenerateOccurrence(series *models.EventSeries, startsAt time.Time) (*models.GeneratedOccurrence, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Il lock sulla serie serializza la generazione tra più repliche
+	var lastOccurrenceAt time.Time
+	err = tx.QueryRow(`
+		SELECT last_occurrence_at FROM event_series WHERE id = $1 AND active FOR UPDATE`,
+		series.ID).Scan(&lastOccurrenceAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !startsAt.After(lastOccurrenceAt) {
+		return nil, nil
+	}
+
+	var sourcePostID int
+	err = tx.QueryRow(`
+		SELECT o.post_id FROM event_series_occurrences o
+		JOIN posts p ON p.id = o.post_id
+		WHERE o.series_id = $1
+		ORDER BY o.starts_at DESC
+		LIMIT 1`,
+		series.ID).Scan(&sourcePostID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSeriesSourceMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	occurrence := &models.GeneratedOccurrence{
+		SeriesID:    series.ID,
+		StartsAt:    startsAt,
+		OrganizerID: series.OrganizerID,
+	}
+
+	// posts appartiene al backend Python: created_at viene valorizzato come farebbe lui (UTC)
+	err = tx.QueryRow(`
+		INSERT INTO posts (titolo, provincia, citta, sport, data_partita, ora_partita, commento,
+			autore_email, campo_id, livello, numero_giocatori, created_at)
+		SELECT titolo, provincia, citta, sport, $2::DATE, $3::TIME, commento,
+			autore_email, campo_id, livello, numero_giocatori, NOW() AT TIME ZONE 'UTC'
+		FROM posts WHERE id = $1
+		RETURNING id, titolo`,
+		sourcePostID, startsAt.Format("2006-01-02"), startsAt.Format("15:04:05")).Scan(&occurrence.PostID, &occurrence.Titolo)
+	if err != nil {
+		return nil, fmt.Errorf("errore nella creazione del post dell'occorrenza: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO event_series_occurrences (series_id, starts_at, post_id)
+		VALUES ($1, $2, $3)`,
+		series.ID, startsAt, occurrence.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO event_settings (post_id, join_policy, updated_by)
+		SELECT $2, join_policy, updated_by FROM event_settings WHERE post_id = $1`,
+		sourcePostID, occurrence.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE event_series SET last_occurrence_at = $2 WHERE id = $1`,
+		series.ID, startsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrence.Enrollments, err = enrollSeriesRoster(tx, series.ID, occurrence.PostID, startsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return occurrence, nil
+}
+
+// enrollSeriesRoster iscrive all'occorrenza i giocatori attivi del roster che
+// non hanno rinunciato a quella data; oltre la capienza finiscono in lista d'attesa
+func enrollSeriesRoster(tx *sql.Tx, seriesID int64, postID int, startsAt time.Time) ([]models.SeriesEnrollment, error) {
+	rows, err := tx.Query(`
+		SELECT sr.user_id FROM event_series_roster sr
+		JOIN users u ON u.id = sr.user_id
+		WHERE sr.series_id = $1 AND COALESCE(u.is_active, TRUE)
+		AND NOT EXISTS(
+			SELECT 1 FROM event_series_optouts o
+			WHERE o.series_id = sr.series_id AND o.user_id = sr.user_id
+			AND o.occurrence_date = $2::DATE
+		)
+		ORDER BY sr.added_at, sr.user_id`,
+		seriesID, startsAt.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	event, err := lockEvent(tx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments := make([]models.SeriesEnrollment, 0, len(userIDs))
+	for _, userID := range userIDs {
+		change, err := applyParticipationStatus(tx, event, userID, postID, "", models.ParticipantStatusConfirmed)
+		if err != nil {
+			return nil, fmt.Errorf("errore nell'iscrizione dell'utente %d: %v", userID, err)
+		}
+		enrollments = append(enrollments, models.SeriesEnrollment{
+			UserID:           userID,
+			Status:           change.Status,
+			WaitlistPosition: change.WaitlistPosition,
+		})
+	}
+	return enrollments, nil
+}
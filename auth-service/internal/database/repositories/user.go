@@ -4,11 +4,23 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/lib/pq"
+	"trovagiocatoriAuth/internal/media"
 	"trovagiocatoriAuth/internal/models"
 	"trovagiocatoriAuth/internal/utils"
 )
 
+// Errori restituiti quando email o username sono già registrati
+var (
+	ErrEmailTaken    = errors.New("email già registrata")
+	ErrUsernameTaken = errors.New("username già in uso")
+)
+
+// Codice SQLSTATE di Postgres per la violazione di un vincolo UNIQUE
+const pqUniqueViolation = "23505"
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -19,19 +31,71 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 // CreateUser inserisce un nuovo utente nel database
 func (r *UserRepository) CreateUser(user models.User) (int64, error) {
+	return insertUser(r.db, user)
+}
+
+// queryRower è implementato sia da *sql.DB che da *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertUser esegue l'INSERT dell'utente, anche all'interno di una transazione
+func insertUser(q queryRower, user models.User) (int64, error) {
 	var userID int64
-	err := r.db.QueryRow(`
-		INSERT INTO users (nome, cognome, username, email, password, profile_picture)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
-		user.Nome, user.Cognome, user.Username, user.Email, user.Password, user.ProfilePic,
+	err := q.QueryRow(`
+		INSERT INTO users (nome, cognome, username, email, password, profile_picture, is_admin, invited_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		user.Nome, user.Cognome, user.Username, user.Email, user.Password, user.ProfilePic, user.IsAdmin, user.InvitedBy,
 	).Scan(&userID)
 
 	if err != nil {
+		if mapped := mapUserUniqueViolation(err); mapped != nil {
+			return 0, mapped
+		}
 		return 0, fmt.Errorf("errore nell'inserimento dell'utente: %v", err)
 	}
 	return userID, nil
 }
 
+// mapUserUniqueViolation traduce una violazione UNIQUE sulla tabella users
+// nell'errore tipizzato corrispondente al campo duplicato
+func mapUserUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != pqUniqueViolation {
+		return nil
+	}
+
+	switch {
+	case strings.Contains(pqErr.Constraint, "email"):
+		return ErrEmailTaken
+	case strings.Contains(pqErr.Constraint, "username"):
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+// CheckUsernameOrEmailTaken verifica (senza distinzione maiuscole/minuscole)
+// che username ed email non siano già usati da un altro utente
+func (r *UserRepository) CheckUsernameOrEmailTaken(username, email string) error {
+	var usernameTaken, emailTaken bool
+	err := r.db.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER($1)),
+			EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER($2))`,
+		username, email).Scan(&usernameTaken, &emailTaken)
+	if err != nil {
+		return err
+	}
+
+	if usernameTaken {
+		return ErrUsernameTaken
+	}
+	if emailTaken {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
 // VerifyUser verifica le credenziali di login
 func (r *UserRepository) VerifyUser(emailOrUsername, password string) (int64, error) {
 	var userID int64
@@ -70,11 +134,8 @@ func (r *UserRepository) GetUserProfile(userID string) (models.User, error) {
 		return user, err
 	}
 	
-	if profilePic.Valid {
-		user.ProfilePic = profilePic.String
-	} else {
-		user.ProfilePic = ""
-	}
+	user.ProfilePic = profilePictureOrAvatar(user.ID, profilePic)
+	user.HasProfilePicture = hasProfilePicture(profilePic)
 	
 	if isAdmin.Valid {
 		user.IsAdmin = isAdmin.Bool
@@ -97,16 +158,13 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 		&user.ID, &user.Nome, &user.Cognome, &user.Username, &user.Email, &profilePic, &user.IsAdmin,
 	)
 
-	if profilePic.Valid {
-		user.ProfilePic = profilePic.String
-	} else {
-		user.ProfilePic = ""
-	}
-
 	if err != nil {
 		return nil, err
 	}
 
+	user.ProfilePic = profilePictureOrAvatar(user.ID, profilePic)
+	user.HasProfilePicture = hasProfilePicture(profilePic)
+
 	return &user, nil
 }
 
@@ -168,4 +226,69 @@ func (r *UserRepository) UpdateUserPassword(userID int64, newPassword string) er
 		userID,
 	)
 	return err
-}
\ No newline at end of file
+}
+
+// profilePictureOrAvatar restituisce l'immagine caricata dall'utente oppure il nome
+// dell'avatar generato, così le API non espongono mai un'immagine profilo vuota
+func profilePictureOrAvatar(userID int64, profilePic sql.NullString) string {
+	if hasProfilePicture(profilePic) {
+		return profilePic.String
+	}
+	return media.AvatarFileName(userID)
+}
+
+func hasProfilePicture(profilePic sql.NullString) bool {
+	return profilePic.Valid && profilePic.String != ""
+}
+
+// UpdateProfilePicture imposta la nuova immagine profilo (stringa vuota per rimuoverla)
+// e restituisce quella precedente, così il chiamante può eliminarla dallo storage
+func (r *UserRepository) UpdateProfilePicture(userID int64, filename string) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullString
+	err = tx.QueryRow("SELECT profile_picture FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&previous)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("UPDATE users SET profile_picture = NULLIF($1, '') WHERE id = $2", filename, userID)
+	if err != nil {
+		return "", fmt.Errorf("errore nell'aggiornamento dell'immagine profilo: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return previous.String, nil
+}
+
+// CountProfilePictureReferences conta gli utenti che usano un'immagine: i nomi sono
+// content-addressed, quindi più utenti possono condividere lo stesso file
+func (r *UserRepository) CountProfilePictureReferences(filename string) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE profile_picture = $1", filename).Scan(&count)
+	return count, err
+}
+
+// LockProfilePicture acquisisce un advisory lock sul nome di un'immagine e
+// restituisce la funzione che lo rilascia. Chi salva un'immagine e la collega a un
+// utente e chi ne elimina i file non più usati lo tengono per tutta l'operazione,
+// così il conteggio dei riferimenti non può essere superato da un upload concorrente
+// dello stesso contenuto.
+func (r *UserRepository) LockProfilePicture(filename string) (func(), error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "profile_picture:"+filename); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// Il lock è legato alla transazione: il rollback lo rilascia
+	return func() { tx.Rollback() }, nil
+}
@@ -0,0 +1,306 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// ErrInvalidCursor è restituito quando il cursore di paginazione non è valido
+var ErrInvalidCursor = errors.New("cursore non valido")
+
+// Ordinamenti delle liste di preferiti e partecipazioni
+const (
+	PostListSortRecent   = "recent"    // salvataggio o iscrizione, dal più recente (default)
+	PostListSortOldest   = "oldest"    // salvataggio o iscrizione, dal meno recente
+	PostListSortDateAsc  = "date_asc"  // data della partita, dalla più vicina
+	PostListSortDateDesc = "date_desc" // data della partita, dalla più lontana
+)
+
+// Filtri temporali delle liste di preferiti e partecipazioni
+const (
+	PostListWhenAll      = "all"
+	PostListWhenUpcoming = "upcoming"
+	PostListWhenPast     = "past"
+)
+
+// Formato dei timestamp passati a Postgres come TIMESTAMP senza fuso
+const cursorTimestampLayout = "2006-01-02 15:04:05.999999"
+
+// PostListCursor indica l'ultimo elemento della pagina precedente
+type PostListCursor struct {
+	Key    time.Time // valore della colonna di ordinamento
+	PostID int
+}
+
+// Encode restituisce il cursore in forma opaca per i client
+func (c PostListCursor) Encode() string {
+	raw := strconv.FormatInt(c.Key.UnixNano(), 36) + "." + strconv.Itoa(c.PostID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodePostListCursor legge un cursore restituito da Encode
+func DecodePostListCursor(token string) (*PostListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 36, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	postID, err := strconv.Atoi(parts[1])
+	if err != nil || postID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &PostListCursor{Key: time.Unix(0, nanos).UTC(), PostID: postID}, nil
+}
+
+// PostListQuery contiene filtri, ordinamento e paginazione delle liste di
+// preferiti e partecipazioni
+type PostListQuery struct {
+	Statuses  []string // stati della partecipazione (ignorato per i preferiti)
+	When      string   // PostListWhen*
+	Sport     string
+	Provincia string
+	Sort      string // PostListSort*
+	Cursor    *PostListCursor
+	Limit     int  // 0: tutti gli elementi, senza paginazione
+	Expand    bool // include i dati del post, del campo e gli iscritti
+}
+
+// userPostSource descrive la tabella da cui si leggono i post dell'utente
+type userPostSource struct {
+	from      string // tabella con alias "s"
+	savedAt   string // colonna con la data di salvataggio o iscrizione
+	extraCols string // colonne aggiuntive lette prima dei dati del post
+}
+
+var (
+	favoritesSource = userPostSource{
+		from:    "user_favorites s",
+		savedAt: "COALESCE(s.created_at, 'epoch'::TIMESTAMP)",
+	}
+	participationsSource = userPostSource{
+		from:      "event_participants s",
+		savedAt:   "COALESCE(s.registered_at, 'epoch'::TIMESTAMP)",
+		extraCols: ", s.status",
+	}
+)
+
+// postDetailsColumns sono le colonne lette da scanPostDetails; le query che la
+// usano devono fare LEFT JOIN di posts come p, di sport_fields come sf e del
+// conteggio degli iscritti come pc
+const postDetailsColumns = `
+	p.id, p.titolo, p.sport, COALESCE(p.livello, ''), p.citta, p.provincia,
+	p.data_partita + p.ora_partita, COALESCE(p.numero_giocatori, 0),
+	sf.id, sf.nome, sf.indirizzo, sf.citta, sf.provincia, sf.lat, sf.lng,
+	COALESCE(pc.confirmed, 0), COALESCE(pc.waitlisted, 0)`
+
+// ListUserFavorites restituisce una pagina dei preferiti dell'utente e il
+// cursore della pagina successiva (nil se è l'ultima)
+func (r *EventRepository) ListUserFavorites(userID int64, query PostListQuery) ([]models.FavoriteEntry, *PostListCursor, error) {
+	rows, err := r.queryUserPosts(favoritesSource, userID, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.FavoriteEntry{}
+	var keys []PostListCursor
+	for rows.Next() {
+		var entry models.FavoriteEntry
+		var key time.Time
+		post, err := scanUserPost(rows, query.Expand, &entry.PostID, &entry.SavedAt, &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry.Post = post
+		entries = append(entries, entry)
+		keys = append(keys, PostListCursor{Key: key, PostID: entry.PostID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if query.Limit > 0 && len(entries) > query.Limit {
+		return entries[:query.Limit], &keys[query.Limit-1], nil
+	}
+	return entries, nil, nil
+}
+
+// ListUserParticipations restituisce una pagina delle partecipazioni
+// dell'utente negli stati richiesti e il cursore della pagina successiva
+func (r *EventRepository) ListUserParticipations(userID int64, query PostListQuery) ([]models.ParticipationEntry, *PostListCursor, error) {
+	rows, err := r.queryUserPosts(participationsSource, userID, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.ParticipationEntry{}
+	var keys []PostListCursor
+	for rows.Next() {
+		var entry models.ParticipationEntry
+		var key time.Time
+		post, err := scanUserPost(rows, query.Expand, &entry.PostID, &entry.RegisteredAt, &key, &entry.Status)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry.Post = post
+		entries = append(entries, entry)
+		keys = append(keys, PostListCursor{Key: key, PostID: entry.PostID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if query.Limit > 0 && len(entries) > query.Limit {
+		return entries[:query.Limit], &keys[query.Limit-1], nil
+	}
+	return entries, nil, nil
+}
+
+// queryUserPosts esegue in un'unica query filtri, ordinamento e paginazione a
+// cursore, unendo i dati del post solo quando servono. Legge Limit+1 righe per
+// sapere se esiste una pagina successiva; con Limit 0 le legge tutte.
+func (r *EventRepository) queryUserPosts(source userPostSource, userID int64, query PostListQuery) (*sql.Rows, error) {
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"s.user_id = $1"}
+	if source.extraCols != "" {
+		conditions = append(conditions, "s.status = ANY("+arg(pq.Array(query.Statuses))+")")
+	}
+
+	sortKey := source.savedAt
+	descending := true
+	switch query.Sort {
+	case PostListSortOldest:
+		descending = false
+	case PostListSortDateAsc, PostListSortDateDesc:
+		// Senza il post non c'è una data: i post eliminati sono esclusi
+		sortKey = "(p.data_partita + p.ora_partita)"
+		descending = query.Sort == PostListSortDateDesc
+		conditions = append(conditions, "p.id IS NOT NULL")
+	}
+
+	switch query.When {
+	case PostListWhenUpcoming:
+		conditions = append(conditions, "p.data_partita + p.ora_partita >= LOCALTIMESTAMP")
+	case PostListWhenPast:
+		conditions = append(conditions, "p.data_partita + p.ora_partita < LOCALTIMESTAMP")
+	}
+	if query.Sport != "" {
+		conditions = append(conditions, "LOWER(p.sport) = LOWER("+arg(query.Sport)+")")
+	}
+	if query.Provincia != "" {
+		conditions = append(conditions, "LOWER(p.provincia) = LOWER("+arg(query.Provincia)+")")
+	}
+
+	direction, comparison := "DESC", "<"
+	if !descending {
+		direction, comparison = "ASC", ">"
+	}
+	if query.Cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, s.post_id) %s (%s::TIMESTAMP, %s)",
+			sortKey, comparison, arg(query.Cursor.Key.UTC().Format(cursorTimestampLayout)), arg(query.Cursor.PostID)))
+	}
+
+	columns := "s.post_id, " + source.savedAt + ", " + sortKey + source.extraCols
+	joins := "LEFT JOIN posts p ON p.id = s.post_id"
+	if query.Expand {
+		columns += "," + postDetailsColumns
+		joins += `
+		LEFT JOIN sport_fields sf ON sf.id = p.campo_id
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE ep.status = 'confirmed') AS confirmed,
+				COUNT(*) FILTER (WHERE ep.status = 'waitlisted') AS waitlisted
+			FROM event_participants ep
+			WHERE ep.post_id = p.id
+		) pc ON p.id IS NOT NULL`
+	}
+
+	limit := ""
+	if query.Limit > 0 {
+		limit = "LIMIT " + arg(query.Limit+1)
+	}
+
+	return r.db.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		%s
+		WHERE %s
+		ORDER BY %s %s, s.post_id %s
+		%s`,
+		columns, source.from, joins, strings.Join(conditions, " AND "),
+		sortKey, direction, direction, limit), args...)
+}
+
+// scanUserPost legge ID, data di salvataggio, chiave di ordinamento, le
+// colonne aggiuntive e, con expand, i dati del post (nil se eliminato)
+func scanUserPost(rows *sql.Rows, expand bool, postID *int, savedAt, key *time.Time, extra ...interface{}) (*models.PostDetails, error) {
+	var sortKey sql.NullTime
+	dest := append([]interface{}{postID, savedAt, &sortKey}, extra...)
+
+	var post models.PostDetails
+	var id sql.NullInt64
+	var titolo, sport, livello, citta, provincia sql.NullString
+	var startsAt sql.NullTime
+	var numeroGiocatori int
+	var fieldID sql.NullInt64
+	var fieldNome, fieldIndirizzo, fieldCitta, fieldProvincia sql.NullString
+	var fieldLat, fieldLng sql.NullFloat64
+	if expand {
+		dest = append(dest, &id, &titolo, &sport, &livello, &citta, &provincia, &startsAt, &numeroGiocatori,
+			&fieldID, &fieldNome, &fieldIndirizzo, &fieldCitta, &fieldProvincia, &fieldLat, &fieldLng,
+			&post.ConfirmedCount, &post.WaitlistCount)
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	*key = sortKey.Time
+	if !expand || !id.Valid {
+		return nil, nil
+	}
+
+	post.ID = int(id.Int64)
+	post.Titolo = titolo.String
+	post.Sport = sport.String
+	post.Livello = livello.String
+	post.Citta = citta.String
+	post.Provincia = provincia.String
+	post.StartsAt = startsAt.Time
+	post.NumeroGiocatori = numeroGiocatori
+	if fieldID.Valid {
+		post.Field = &models.FieldSummary{
+			ID:        int(fieldID.Int64),
+			Nome:      fieldNome.String,
+			Indirizzo: fieldIndirizzo.String,
+			Citta:     fieldCitta.String,
+			Provincia: fieldProvincia.String,
+			Lat:       fieldLat.Float64,
+			Lng:       fieldLng.Float64,
+		}
+	}
+	post.SpotsLeft = post.NumeroGiocatori - post.ConfirmedCount
+	if post.SpotsLeft < 0 {
+		post.SpotsLeft = 0
+	}
+	return &post, nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// GetPrivacySettings restituisce le impostazioni di privacy dell'utente (default se mai impostate)
+func (r *UserRepository) GetPrivacySettings(userID int64) (*models.PrivacySettings, error) {
+	settings := models.DefaultPrivacySettings()
+
+	err := r.db.QueryRow(`
+		SELECT strangers, friends, co_participants
+		FROM user_privacy_settings WHERE user_id = $1`,
+		userID).Scan(&settings.Strangers, &settings.Friends, &settings.CoParticipants)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdatePrivacySettings salva le impostazioni di privacy dell'utente
+func (r *UserRepository) UpdatePrivacySettings(userID int64, settings *models.PrivacySettings) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_privacy_settings (user_id, strangers, friends, co_participants, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			strangers = $2,
+			friends = $3,
+			co_participants = $4,
+			updated_at = CURRENT_TIMESTAMP`,
+		userID, settings.Strangers, settings.Friends, settings.CoParticipants)
+	return err
+}
+
+// AvatarProfile contiene i dati con cui si genera l'avatar di un utente senza immagine
+type AvatarProfile struct {
+	Username  string
+	Nome      string
+	Cognome   string
+	Strangers string // visibilità del profilo per chi non è amico né co-partecipante
+}
+
+// GetAvatarProfile restituisce i dati per l'avatar generato di un utente attivo
+// senza immagine profilo; sql.ErrNoRows se l'utente non esiste, non è attivo o
+// ha un'immagine caricata
+func (r *UserRepository) GetAvatarProfile(userID int64) (*AvatarProfile, error) {
+	profile := AvatarProfile{Strangers: models.DefaultPrivacySettings().Strangers}
+	err := r.db.QueryRow(`
+		SELECT u.username, u.nome, u.cognome, COALESCE(ps.strangers, $2)
+		FROM users u
+		LEFT JOIN user_privacy_settings ps ON ps.user_id = u.id
+		WHERE u.id = $1 AND COALESCE(u.is_active, TRUE)
+		AND COALESCE(u.profile_picture, '') = ''`,
+		userID, profile.Strangers).Scan(&profile.Username, &profile.Nome, &profile.Cognome, &profile.Strangers)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
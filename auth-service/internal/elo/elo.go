@@ -0,0 +1,73 @@
+package elo
+
+import "math"
+
+const (
+	// InitialRating è il punteggio di partenza di un giocatore in ogni sport
+	InitialRating = 1500.0
+	// ProvisionalGames è il numero di partite sotto cui il punteggio è provvisorio
+	ProvisionalGames = 10
+	// Fattori K: i punteggi provvisori si muovono più in fretta
+	kProvisional = 40.0
+	kEstablished = 20.0
+)
+
+// Player è lo stato di un giocatore in uno sport prima di una partita
+type Player struct {
+	UserID int64
+	Rating float64
+	Games  int
+}
+
+// Expected restituisce la probabilità di vittoria della squadra con punteggio
+// medio a contro quella con punteggio medio b
+func Expected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// Update aggiorna in place i punteggi dei giocatori dopo una partita a squadre.
+// Ogni squadra è rappresentata dalla media dei punteggi dei suoi giocatori;
+// la variazione di ciascun giocatore dipende dal proprio fattore K, così i
+// nuovi arrivati raggiungono in fretta il loro livello senza sbilanciare gli altri.
+func Update(teamA, teamB []Player, scoreA, scoreB int) {
+	if len(teamA) == 0 || len(teamB) == 0 {
+		return
+	}
+
+	expectedA := Expected(average(teamA), average(teamB))
+
+	actualA := 0.5
+	switch {
+	case scoreA > scoreB:
+		actualA = 1
+	case scoreA < scoreB:
+		actualA = 0
+	}
+
+	apply(teamA, actualA-expectedA)
+	apply(teamB, expectedA-actualA)
+}
+
+// Provisional indica se il punteggio è basato su troppe poche partite
+func Provisional(games int) bool {
+	return games < ProvisionalGames
+}
+
+func apply(team []Player, delta float64) {
+	for i := range team {
+		k := kEstablished
+		if Provisional(team[i].Games) {
+			k = kProvisional
+		}
+		team[i].Rating = math.Round((team[i].Rating+k*delta)*100) / 100
+		team[i].Games++
+	}
+}
+
+func average(team []Player) float64 {
+	sum := 0.0
+	for _, p := range team {
+		sum += p.Rating
+	}
+	return sum / float64(len(team))
+}
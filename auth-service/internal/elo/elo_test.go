@@ -0,0 +1,134 @@
+package elo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpected(t *testing.T) {
+	tests := []struct {
+		a, b float64
+		want float64
+	}{
+		{1500, 1500, 0.5},
+		{1900, 1500, 10.0 / 11},
+		{1500, 1900, 1.0 / 11},
+		{1700, 1300, 10.0 / 11},
+	}
+
+	for _, tt := range tests {
+		if got := Expected(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Expected(%v, %v) = %v, atteso %v", tt.a, tt.b, got, tt.want)
+		}
+		if sum := Expected(tt.a, tt.b) + Expected(tt.b, tt.a); math.Abs(sum-1) > 1e-9 {
+			t.Errorf("Expected(%v, %v) + Expected(%v, %v) = %v, atteso 1", tt.a, tt.b, tt.b, tt.a, sum)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		teamA, teamB   []Player
+		scoreA, scoreB int
+		wantA, wantB   []float64
+	}{
+		{
+			name:   "vittoria tra provvisori",
+			teamA:  []Player{{UserID: 1, Rating: 1500}},
+			teamB:  []Player{{UserID: 2, Rating: 1500}},
+			scoreA: 3, scoreB: 1,
+			wantA: []float64{1520}, wantB: []float64{1480},
+		},
+		{
+			name:   "sconfitta tra giocatori affermati",
+			teamA:  []Player{{UserID: 1, Rating: 1500, Games: 10}},
+			teamB:  []Player{{UserID: 2, Rating: 1500, Games: 30}},
+			scoreA: 0, scoreB: 2,
+			wantA: []float64{1490}, wantB: []float64{1510},
+		},
+		{
+			name:   "pareggio tra pari non cambia i punteggi",
+			teamA:  []Player{{UserID: 1, Rating: 1500, Games: 12}},
+			teamB:  []Player{{UserID: 2, Rating: 1500, Games: 12}},
+			scoreA: 1, scoreB: 1,
+			wantA: []float64{1500}, wantB: []float64{1500},
+		},
+		{
+			name:   "vittoria a sorpresa",
+			teamA:  []Player{{UserID: 1, Rating: 1300, Games: 10}},
+			teamB:  []Player{{UserID: 2, Rating: 1700, Games: 10}},
+			scoreA: 2, scoreB: 1,
+			wantA: []float64{1318.18}, wantB: []float64{1681.82},
+		},
+		{
+			name:   "pareggio contro una squadra più forte",
+			teamA:  []Player{{UserID: 1, Rating: 1300, Games: 10}},
+			teamB:  []Player{{UserID: 2, Rating: 1700, Games: 10}},
+			scoreA: 0, scoreB: 0,
+			wantA: []float64{1308.18}, wantB: []float64{1691.82},
+		},
+		{
+			// Le squadre si confrontano sulla media; ognuno usa il proprio fattore K
+			name: "squadre miste",
+			teamA: []Player{
+				{UserID: 1, Rating: 1400, Games: 0},
+				{UserID: 2, Rating: 1600, Games: 20},
+			},
+			teamB: []Player{
+				{UserID: 3, Rating: 1500, Games: 5},
+				{UserID: 4, Rating: 1500, Games: 50},
+			},
+			scoreA: 5, scoreB: 4,
+			wantA: []float64{1420, 1610}, wantB: []float64{1480, 1490},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gamesA := games(tt.teamA)
+			gamesB := games(tt.teamB)
+
+			Update(tt.teamA, tt.teamB, tt.scoreA, tt.scoreB)
+
+			checkTeam(t, "A", tt.teamA, tt.wantA, gamesA)
+			checkTeam(t, "B", tt.teamB, tt.wantB, gamesB)
+		})
+	}
+}
+
+func TestUpdateEmptyTeam(t *testing.T) {
+	team := []Player{{UserID: 1, Rating: 1500, Games: 3}}
+	Update(team, nil, 2, 0)
+	if team[0].Rating != 1500 || team[0].Games != 3 {
+		t.Errorf("squadra avversaria vuota: %+v, atteso invariato", team[0])
+	}
+}
+
+func TestProvisional(t *testing.T) {
+	for games, want := range map[int]bool{0: true, ProvisionalGames - 1: true, ProvisionalGames: false, 100: false} {
+		if got := Provisional(games); got != want {
+			t.Errorf("Provisional(%d) = %v, atteso %v", games, got, want)
+		}
+	}
+}
+
+func games(team []Player) []int {
+	result := make([]int, len(team))
+	for i, p := range team {
+		result[i] = p.Games
+	}
+	return result
+}
+
+func checkTeam(t *testing.T, name string, team []Player, want []float64, gamesBefore []int) {
+	t.Helper()
+	for i, p := range team {
+		if math.Abs(p.Rating-want[i]) > 1e-9 {
+			t.Errorf("squadra %s, giocatore %d: punteggio %v, atteso %v", name, p.UserID, p.Rating, want[i])
+		}
+		if p.Games != gamesBefore[i]+1 {
+			t.Errorf("squadra %s, giocatore %d: %d partite, attese %d", name, p.UserID, p.Games, gamesBefore[i]+1)
+		}
+	}
+}
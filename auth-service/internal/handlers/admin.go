@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -18,14 +20,16 @@ type AdminHandler struct {
 	adminRepo *repositories.AdminRepository
 	userRepo  *repositories.UserRepository
 	banRepo   *repositories.BanRepository
+	eventRepo *repositories.EventRepository
 	sm        *sessions.SessionManager
 }
 
-func NewAdminHandler(adminRepo *repositories.AdminRepository, userRepo *repositories.UserRepository, banRepo *repositories.BanRepository, sm *sessions.SessionManager) *AdminHandler {
+func NewAdminHandler(adminRepo *repositories.AdminRepository, userRepo *repositories.UserRepository, banRepo *repositories.BanRepository, eventRepo *repositories.EventRepository, sm *sessions.SessionManager) *AdminHandler {
 	return &AdminHandler{
 		adminRepo: adminRepo,
 		userRepo:  userRepo,
 		banRepo:   banRepo,
+		eventRepo: eventRepo,
 		sm:        sm,
 	}
 }
@@ -48,8 +52,24 @@ func (h *AdminHandler) AdminDeletePostHandler() http.HandlerFunc {
 
 		fmt.Printf("[ADMIN] Attempting to delete post %d\n", postID)
 
+		// I dati del post vanno letti prima dell'eliminazione per l'archivio e le notifiche
+		cancellation, err := h.eventRepo.PrepareEventCancellation(postID)
+		if err != nil && !errors.Is(err, repositories.ErrEventNotFound) {
+			fmt.Printf("[ADMIN] Error reading post %d before deletion: %v\n", postID, err)
+			http.Error(w, "Errore interno", http.StatusInternalServerError)
+			return
+		}
+		if cancellation == nil {
+			cancellation = &models.EventCancellation{PostID: postID}
+		}
+
 		// Chiama il backend Python per eliminare il post
 		pythonURL := "http://backend_python:8000/admin/posts/" + strconv.Itoa(postID) //convertire un intero in una stringa 
+		reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+		if reason != "" {
+			// Il backend Python annulla l'evento prima di eliminare il post e deve conoscerne il motivo
+			pythonURL += "?reason=" + url.QueryEscape(reason)
+		}
 
 		cookie, _ := r.Cookie("session_id")
 
@@ -82,11 +102,29 @@ func (h *AdminHandler) AdminDeletePostHandler() http.HandlerFunc {
 
 		fmt.Printf("[ADMIN] Post %d successfully deleted\n", postID)
 
+		// Di norma l'evento è già stato annullato dal backend Python tramite
+		// l'endpoint interno; ripetere l'annullamento è innocuo e copre i casi
+		// in cui quella chiamata non è avvenuta
+		cancellation.Source = models.CancellationSourceAdmin
+		cancellation.Reason = reason
+		if adminID, err := middleware.GetUserIDFromSession(r, h.sm); err == nil {
+			cancellation.CancelledBy = &adminID
+		}
+
+		result, err := h.eventRepo.CancelEvent(cancellation)
+		if err != nil {
+			// Il post è già stato eliminato: l'annullamento può essere ripetuto dall'endpoint interno
+			fmt.Printf("[ADMIN] WARNING: Error cancelling event %d: %v\n", postID, err)
+		} else {
+			fmt.Printf("[ADMIN] Event %d cancelled, %d users notified\n", postID, result.NotifiedUsers)
+		}
+
 		// Risposta successo
 		response := map[string]interface{}{
-			"success": true,
-			"message": "Post eliminato con successo",
-			"post_id": postID,
+			"success":      true,
+			"message":      "Post eliminato con successo",
+			"post_id":      postID,
+			"cancellation": result,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -3,32 +3,46 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"trovagiocatoriAuth/internal/config"
 	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/media"
 	"trovagiocatoriAuth/internal/middleware"
 	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/services"
 	"trovagiocatoriAuth/internal/sessions"
+	"trovagiocatoriAuth/internal/storage"
 	"trovagiocatoriAuth/internal/utils"
+	"trovagiocatoriAuth/internal/validation"
 )
 
 type AuthHandler struct {
-	userRepo *repositories.UserRepository
-	banRepo  *repositories.BanRepository
-	sm       *sessions.SessionManager
+	userRepo        *repositories.UserRepository
+	banRepo         *repositories.BanRepository
+	inviteRepo      *repositories.InviteCodeRepository
+	ratingRepo      *repositories.RatingRepository
+	linkService     *services.EventLinkService
+	blobStore       storage.BlobStore
+	registrationCfg config.RegistrationConfig
+	sm              *sessions.SessionManager
 }
 
-func NewAuthHandler(userRepo *repositories.UserRepository, banRepo *repositories.BanRepository, sm *sessions.SessionManager) *AuthHandler {
+func NewAuthHandler(userRepo *repositories.UserRepository, banRepo *repositories.BanRepository, inviteRepo *repositories.InviteCodeRepository, ratingRepo *repositories.RatingRepository, linkService *services.EventLinkService, blobStore storage.BlobStore, registrationCfg config.RegistrationConfig, sm *sessions.SessionManager) *AuthHandler {
 	return &AuthHandler{
-		userRepo: userRepo,
-		banRepo:  banRepo,
-		sm:       sm,
+		userRepo:        userRepo,
+		banRepo:         banRepo,
+		inviteRepo:      inviteRepo,
+		ratingRepo:      ratingRepo,
+		linkService:     linkService,
+		blobStore:       blobStore,
+		registrationCfg: registrationCfg,
+		sm:              sm,
 	}
 }
 
@@ -55,18 +69,41 @@ type BanInfo struct {
 // RegisterHandler gestisce la registrazione di un nuovo utente
 func (h *AuthHandler) RegisterHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Limita la dimensione massima dell'upload a 5MB
-		r.ParseMultipartForm(5 << 20)
+		// Limita la dimensione massima della richiesta (immagine da 5MB più i campi del form)
+		r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadBytes+(1<<20))
+		if err := r.ParseMultipartForm(5 << 20); err != nil {
+			http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+			return
+		}
 
 		// Recupera i dati dal form
-		nome := r.FormValue("nome")
-		cognome := r.FormValue("cognome")
-		username := r.FormValue("username")
-		email := r.FormValue("email")
+		nome := strings.TrimSpace(r.FormValue("nome"))
+		cognome := strings.TrimSpace(r.FormValue("cognome"))
+		username := strings.TrimSpace(r.FormValue("username"))
+		email := strings.TrimSpace(r.FormValue("email"))
 		password := r.FormValue("password")
+		inviteCode := strings.TrimSpace(r.FormValue("invite_code"))
+		eventLink := strings.TrimSpace(r.FormValue("event_link"))
+
+		// Validazione dei campi con codici di errore per il frontend
+		if fieldErrors := validation.ValidateRegistration(nome, cognome, username, email, password); len(fieldErrors) > 0 {
+			h.respondWithRegisterError(w, http.StatusBadRequest, "validation_failed", "Dati di registrazione non validi", fieldErrors)
+			return
+		}
 
-		if nome == "" || cognome == "" || username == "" || email == "" || password == "" {
-			http.Error(w, "Tutti i campi sono obbligatori", http.StatusBadRequest)
+		// In modalità closed-beta la registrazione richiede un codice invito
+		if h.registrationCfg.InviteOnly && inviteCode == "" {
+			h.respondWithRegisterError(w, http.StatusForbidden, "invite_code_required", "Codice invito obbligatorio per la registrazione", nil)
+			return
+		}
+
+		// Controllo preventivo dei duplicati, prima di salvare l'immagine profilo
+		if err := h.userRepo.CheckUsernameOrEmailTaken(username, email); err != nil {
+			if h.handleDuplicateUserError(w, err) {
+				return
+			}
+			log.Printf("RegisterHandler: error checking duplicates for %s: %v\n", username, err)
+			h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nella registrazione", nil)
 			return
 		}
 
@@ -77,38 +114,24 @@ func (h *AuthHandler) RegisterHandler() http.HandlerFunc {
 			return
 		}
 
-		// Gestione immagine profilo
+		// Gestione immagine profilo: validata e ri-codificata subito, salvata con nome
+		// content-addressed insieme alla creazione dell'utente
 		var profilePictureFilename string
-		file, handler, err := r.FormFile("profile_picture")
+		var processed *media.ProcessedImage
+		file, _, err := r.FormFile("profile_picture")
 		if err == nil {
 			defer file.Close()
 
-			uploadDir := "uploads/profile_pictures"
-			if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-				if mkdirErr := os.MkdirAll(uploadDir, os.ModePerm); mkdirErr != nil {
-					http.Error(w, fmt.Sprintf("Error creating the directory: %v", mkdirErr), http.StatusInternalServerError)
+			processed, err = media.ProcessProfilePicture(file)
+			if err != nil {
+				if h.handleImageError(w, err) {
 					return
 				}
-			}
-
-			extension := filepath.Ext(handler.Filename)
-			profilePictureFilename = fmt.Sprintf("%s%s", username, extension)
-			filePath := filepath.Join(uploadDir, profilePictureFilename)
-
-			outFile, err := os.Create(filePath)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Error creating the file: %v", err), http.StatusInternalServerError)
+				log.Printf("RegisterHandler: error processing profile picture: %v\n", err)
+				h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nel salvataggio dell'immagine", nil)
 				return
 			}
-			defer outFile.Close()
-
-			_, err = io.Copy(outFile, file)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Error writing the file: %v", err), http.StatusInternalServerError)
-				return
-			}
-			fmt.Printf(" Image successfully saved: %s\n", filePath)
-
+			profilePictureFilename = processed.FileName(media.VariantOriginal)
 		}
 
 		newUser := models.User{
@@ -120,11 +143,47 @@ func (h *AuthHandler) RegisterHandler() http.HandlerFunc {
 			ProfilePic: profilePictureFilename,
 		}
 
-		userID, err := h.userRepo.CreateUser(newUser)
+		var userID int64
+		createUser := func() error {
+			var err error
+			if inviteCode != "" {
+				userID, err = h.inviteRepo.RegisterWithInviteCode(newUser, inviteCode)
+			} else {
+				userID, err = h.userRepo.CreateUser(newUser)
+			}
+			return err
+		}
+		// Con un'immagine, se la creazione dell'utente fallisce i file appena
+		// salvati vengono eliminati
+		if processed != nil {
+			err = h.storeProfilePicture(r, processed, createUser)
+		} else {
+			err = createUser()
+		}
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Errore nella registrazione: %v", err), http.StatusInternalServerError)
+			if errors.Is(err, errProfilePictureStorage) {
+				log.Printf("RegisterHandler: error saving profile picture: %v\n", err)
+				h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nel salvataggio dell'immagine", nil)
+				return
+			}
+			if code := inviteCodeErrorCode(err); code != "" {
+				h.respondWithRegisterError(w, http.StatusForbidden, code, err.Error(), nil)
+				return
+			}
+			if h.handleDuplicateUserError(w, err) {
+				return
+			}
+			log.Printf("RegisterHandler: error creating user %s: %v\n", username, err)
+			h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nella registrazione", nil)
 			return
 		}
+		if processed != nil {
+			fmt.Printf(" Image successfully saved: %s\n", profilePictureFilename)
+		}
+
+		if profilePictureFilename == "" {
+			profilePictureFilename = media.AvatarFileName(userID)
+		}
 
 		// Crea una sessione e salva il cookie
 		sessionID, _ := h.sm.CreateSession(userID)
@@ -134,11 +193,33 @@ func (h *AuthHandler) RegisterHandler() http.HandlerFunc {
 			Path:  "/", //il cookie è valido per tutto il dominio e tutti i percorsi del sito.
 		})
 
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{  //encode prende un oggetto in go e lo converte in json
+		response := map[string]interface{}{
 			"message":         "Registrazione completata con successo",
 			"profile_picture": profilePictureFilename,
-		})
+		}
+
+		// Registrazione arrivata da un link di invito: iscrive subito l'utente alla
+		// partita; un link non più valido non blocca la registrazione
+		if eventLink != "" && h.linkService != nil {
+			postID, change, err := h.linkService.Redeem(eventLink, userID, true)
+			if err != nil {
+				log.Printf("RegisterHandler: event link not redeemed for userID %d: %v\n", userID, err)
+				response["event_join"] = map[string]interface{}{
+					"success": false,
+					"error":   err.Error(),
+				}
+			} else {
+				response["event_join"] = map[string]interface{}{
+					"success":           true,
+					"post_id":           postID,
+					"status":            change.Status,
+					"waitlist_position": change.WaitlistPosition,
+				}
+			}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response) //encode prende un oggetto in go e lo converte in json
 	}
 }
 
@@ -280,6 +361,13 @@ func (h *AuthHandler) ProfileBySessionHandler() http.HandlerFunc {
 			return
 		}
 
+		// La reputazione è accessoria: se non si riesce a calcolarla il profilo viene comunque restituito
+		if rep, err := h.ratingRepo.GetReputation(userID); err != nil {
+			log.Printf("ProfileBySessionHandler: reputation of UserID=%d not available, err=%v\n", userID, err)
+		} else {
+			user.Reputation = rep
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(user)
 	}
@@ -371,21 +459,6 @@ func (h *AuthHandler) UpdatePasswordHandler() http.HandlerFunc {
 	}
 }
 
-// ServeProfilePicture serve l'immagine del profilo
-func (h *AuthHandler) ServeProfilePicture() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		filename := r.URL.Path[len("/images/"):]
-		filePath := filepath.Join("uploads/profile_pictures", filename)
-
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			http.Error(w, "Immagine non trovata", http.StatusNotFound)
-			return
-		}
-
-		http.ServeFile(w, r, filePath)
-	}
-}
-
 // GetUserEmailHandler ottiene l'email dell'utente corrente
 func (h *AuthHandler) GetUserEmailHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -411,6 +484,63 @@ func (h *AuthHandler) GetUserEmailHandler() http.HandlerFunc {
 	}
 }
 
+// RegisterErrorResponse rappresenta un errore di registrazione machine-readable
+type RegisterErrorResponse struct {
+	Success bool                    `json:"success"`
+	Error   string                  `json:"error"`
+	Code    string                  `json:"code"`
+	Field   string                  `json:"field,omitempty"`
+	Errors  []validation.FieldError `json:"errors,omitempty"`
+}
+
+// respondWithRegisterError risponde con un errore di registrazione in formato JSON
+func (h *AuthHandler) respondWithRegisterError(w http.ResponseWriter, statusCode int, code, message string, fieldErrors []validation.FieldError) {
+	response := RegisterErrorResponse{
+		Success: false,
+		Error:   message,
+		Code:    code,
+		Errors:  fieldErrors,
+	}
+	if len(fieldErrors) == 1 {
+		response.Field = fieldErrors[0].Field
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDuplicateUserError risponde 409 se l'errore indica email o username già registrati
+func (h *AuthHandler) handleDuplicateUserError(w http.ResponseWriter, err error) bool {
+	var fieldErr validation.FieldError
+	switch {
+	case errors.Is(err, repositories.ErrEmailTaken):
+		fieldErr = validation.FieldError{Field: "email", Code: validation.CodeEmailTaken, Message: "Email già registrata"}
+	case errors.Is(err, repositories.ErrUsernameTaken):
+		fieldErr = validation.FieldError{Field: "username", Code: validation.CodeUsernameTaken, Message: "Username già in uso"}
+	default:
+		return false
+	}
+
+	h.respondWithRegisterError(w, http.StatusConflict, fieldErr.Code, fieldErr.Message, []validation.FieldError{fieldErr})
+	return true
+}
+
+// inviteCodeErrorCode restituisce il codice di errore se l'errore deriva dalla validazione del codice invito
+func inviteCodeErrorCode(err error) string {
+	switch {
+	case errors.Is(err, repositories.ErrInviteCodeNotFound):
+		return "invite_code_invalid"
+	case errors.Is(err, repositories.ErrInviteCodeInactive):
+		return "invite_code_inactive"
+	case errors.Is(err, repositories.ErrInviteCodeExpired):
+		return "invite_code_expired"
+	case errors.Is(err, repositories.ErrInviteCodeExhausted):
+		return "invite_code_exhausted"
+	}
+	return ""
+}
+
 // Helper function per rispondere con errore
 func (h *AuthHandler) respondWithError(w http.ResponseWriter, message string, statusCode int) {
 	response := LoginResponse{
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"trovagiocatoriAuth/internal/calendar"
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+// Stati di partecipazione esportati nel feed
+var calendarFeedStatuses = []string{
+	models.ParticipantStatusConfirmed,
+	models.ParticipantStatusTentative,
+	models.ParticipantStatusWaitlisted,
+}
+
+const (
+	calendarFeedPath  = "/calendar/"
+	calendarEventPath = "/calendar/events/"
+	calendarExt       = ".ics"
+	// I client calendario interrogano il feed periodicamente: con l'ETag la
+	// maggior parte delle richieste si chiude con un 304
+	calendarCacheControl = "private, max-age=300"
+	calendarRefresh      = time.Hour
+)
+
+type CalendarHandler struct {
+	calendarRepo *repositories.CalendarRepository
+	cfg          config.CalendarConfig
+	location     *time.Location
+	sm           *sessions.SessionManager
+}
+
+func NewCalendarHandler(calendarRepo *repositories.CalendarRepository, cfg config.CalendarConfig, sm *sessions.SessionManager) *CalendarHandler {
+	location, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		fmt.Printf("[CALENDAR] WARNING: Unknown time zone %q, using UTC: %v\n", cfg.TimeZone, err)
+		location = time.UTC
+	}
+
+	return &CalendarHandler{
+		calendarRepo: calendarRepo,
+		cfg:          cfg,
+		location:     location,
+		sm:           sm,
+	}
+}
+
+// SubscriptionHandler gestisce l'URL segreto del feed dell'utente:
+// GET lo restituisce (creandolo se necessario), POST lo rigenera, DELETE lo revoca
+func (h *CalendarHandler) SubscriptionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		var token string
+		var createdAt time.Time
+		switch r.Method {
+		case http.MethodGet:
+			token, createdAt, err = h.calendarRepo.GetOrCreateToken(userID)
+		case http.MethodPost:
+			token, createdAt, err = h.calendarRepo.RotateToken(userID)
+			if err == nil {
+				fmt.Printf("[CALENDAR] Feed token rotated for user %d\n", userID)
+			}
+		case http.MethodDelete:
+			if err := h.calendarRepo.RevokeToken(userID); err != nil {
+				fmt.Printf("[CALENDAR] Error revoking feed token for user %d: %v\n", userID, err)
+				http.Error(w, "Errore durante la revoca del calendario", http.StatusInternalServerError)
+				return
+			}
+			fmt.Printf("[CALENDAR] Feed token revoked for user %d\n", userID)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"message": "Link del calendario revocato",
+			})
+			return
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			fmt.Printf("[CALENDAR] Error getting feed token for user %d: %v\n", userID, err)
+			http.Error(w, "Errore durante la generazione del link del calendario", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.CalendarSubscription{
+			Token:     token,
+			URL:       h.feedURL(r, token),
+			CreatedAt: createdAt,
+		})
+	}
+}
+
+// FeedHandler serve /calendar/{token}.ics con le partite dell'utente proprietario del token.
+// Non richiede sessione: il token stesso è la credenziale, come per i client calendario.
+func (h *CalendarHandler) FeedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, calendarFeedPath)
+		token := strings.TrimSuffix(name, calendarExt)
+		if token == "" || token == name || strings.Contains(token, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		userID, err := h.calendarRepo.GetUserIDByToken(token)
+		if errors.Is(err, repositories.ErrCalendarTokenNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			fmt.Printf("[CALENDAR] Error resolving feed token: %v\n", err)
+			http.Error(w, "Errore durante il recupero del calendario", http.StatusInternalServerError)
+			return
+		}
+
+		entries, err := h.calendarRepo.GetCalendarEntries(userID, calendarFeedStatuses)
+		if err != nil {
+			fmt.Printf("[CALENDAR] Error getting entries for user %d: %v\n", userID, err)
+			http.Error(w, "Errore durante il recupero del calendario", http.StatusInternalServerError)
+			return
+		}
+
+		cal := &calendar.Calendar{
+			Name:            "Trovagiocatori - Le mie partite",
+			RefreshInterval: calendarRefresh,
+		}
+		for _, entry := range entries {
+			cal.Events = append(cal.Events, h.calendarEvent(entry))
+		}
+
+		w.Header().Set("Content-Disposition", `inline; filename="trovagiocatori.ics"`)
+		serveCalendar(w, r, cal.Encode())
+	}
+}
+
+// EventICSHandler serve /calendar/events/{postID}.ics per aggiungere una singola partita
+func (h *CalendarHandler) EventICSHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, calendarEventPath)
+		postID, err := strconv.Atoi(strings.TrimSuffix(name, calendarExt))
+		if err != nil || !strings.HasSuffix(name, calendarExt) {
+			http.Error(w, "Post ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := h.calendarRepo.GetCalendarEntry(postID)
+		if errors.Is(err, repositories.ErrEventNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			fmt.Printf("[CALENDAR] Error getting event %d: %v\n", postID, err)
+			http.Error(w, "Errore durante il recupero dell'evento", http.StatusInternalServerError)
+			return
+		}
+
+		cal := &calendar.Calendar{Events: []calendar.Event{h.calendarEvent(*entry)}}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="partita-%d.ics"`, postID))
+		serveCalendar(w, r, cal.Encode())
+	}
+}
+
+// calendarEvent converte una partita nel VEVENT corrispondente
+func (h *CalendarHandler) calendarEvent(entry models.CalendarEntry) calendar.Event {
+	// data_partita + ora_partita è un orario locale senza fuso
+	s := entry.StartsAt
+	start := time.Date(s.Year(), s.Month(), s.Day(), s.Hour(), s.Minute(), s.Second(), 0, h.location)
+
+	event := calendar.Event{
+		UID:          fmt.Sprintf("post-%d@trovagiocatori", entry.PostID),
+		Summary:      entry.Titolo,
+		Start:        start,
+		End:          start.Add(time.Duration(h.cfg.EventDurationMinutes) * time.Minute),
+		LastModified: entry.LastModified,
+		Status:       calendar.StatusConfirmed,
+	}
+
+	switch entry.Status {
+	case models.ParticipantStatusTentative:
+		event.Status = calendar.StatusTentative
+	case models.ParticipantStatusWaitlisted:
+		event.Status = calendar.StatusTentative
+		event.Summary = "[Lista d'attesa] " + entry.Titolo
+	}
+
+	description := fmt.Sprintf("Sport: %s\nLivello: %s", entry.Sport, entry.Livello)
+	if entry.Commento != "" {
+		description += "\n\n" + entry.Commento
+	}
+	event.Description = description
+
+	var location []string
+	for _, part := range []string{entry.FieldName, entry.FieldAddress} {
+		if part != "" {
+			location = append(location, part)
+		}
+	}
+	location = append(location, fmt.Sprintf("%s (%s)", entry.Citta, entry.Provincia))
+	event.Location = strings.Join(location, ", ")
+
+	if entry.Latitude != nil && entry.Longitude != nil {
+		event.HasGeo = true
+		event.Latitude = *entry.Latitude
+		event.Longitude = *entry.Longitude
+	}
+
+	return event
+}
+
+// feedURL costruisce l'URL pubblico del feed, dalla configurazione o dalla richiesta
+func (h *CalendarHandler) feedURL(r *http.Request, token string) string {
+	base := strings.TrimSuffix(h.cfg.PublicBaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + calendarFeedPath + token + calendarExt
+}
+
+// serveCalendar scrive il file iCalendar con un ETag forte calcolato sul contenuto:
+// http.ServeContent risponde 304 quando If-None-Match corrisponde. Non si imposta
+// Last-Modified perché la rimozione di una partita non ne aggiornerebbe la data.
+func serveCalendar(w http.ResponseWriter, r *http.Request, data []byte) {
+	sum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", calendar.ContentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", calendarCacheControl)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
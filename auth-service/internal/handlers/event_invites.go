@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// BulkEventInviteRequest invita più amici a un evento: per ID, tramite un gruppo salvato o entrambi
+type BulkEventInviteRequest struct {
+	PostID  int     `json:"post_id"`
+	UserIDs []int64 `json:"user_ids"`
+	GroupID int64   `json:"group_id"`
+	Message string  `json:"message"`
+}
+
+type BulkEventInviteResponse struct {
+	Success bool                      `json:"success"`
+	PostID  int                       `json:"post_id"`
+	Invited int                       `json:"invited"`
+	Skipped int                       `json:"skipped"`
+	Results []models.BulkInviteResult `json:"results"`
+}
+
+// BulkEventInviteHandler invia in un'unica transazione gli inviti a un evento a più
+// amici, restituendo l'esito per ogni destinatario
+func (h *EventHandler) BulkEventInviteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		var req BulkEventInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+			return
+		}
+		if req.PostID <= 0 {
+			http.Error(w, "Post ID non valido", http.StatusBadRequest)
+			return
+		}
+		if len(req.UserIDs) == 0 && req.GroupID == 0 {
+			http.Error(w, "Indica almeno un destinatario o un gruppo", http.StatusBadRequest)
+			return
+		}
+
+		fmt.Printf("[EVENT_INVITE] Bulk invitation from userID %d for event %d (%d users, group %d)\n",
+			userID, req.PostID, len(req.UserIDs), req.GroupID)
+
+		senderUsername := "Utente sconosciuto" //fallback
+		if senderProfile, err := h.userRepo.GetUserProfile(fmt.Sprintf("%d", userID)); err == nil {
+			senderUsername = senderProfile.Username
+		} else {
+			fmt.Printf("[EVENT_INVITE] WARNING: Unable to get sender profile: %v\n", err)
+		}
+
+		results, err := h.eventRepo.SendBulkEventInvites(&repositories.BulkInvite{
+			SenderID:       userID,
+			PostID:         req.PostID,
+			RecipientIDs:   req.UserIDs,
+			GroupID:        req.GroupID,
+			Message:        req.Message,
+			SenderUsername: senderUsername,
+			EventTitle:     h.eventTitle(req.PostID),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repositories.ErrEventNotFound):
+				http.Error(w, "Evento non trovato", http.StatusNotFound)
+			case errors.Is(err, repositories.ErrFriendGroupNotFound):
+				http.Error(w, "Gruppo di amici non trovato", http.StatusNotFound)
+			case errors.Is(err, repositories.ErrEventAlreadyStarted):
+				http.Error(w, "L'evento è già iniziato", http.StatusConflict)
+			case errors.Is(err, repositories.ErrNoInviteRecipients):
+				http.Error(w, "Indica almeno un destinatario o un gruppo", http.StatusBadRequest)
+			case errors.Is(err, repositories.ErrTooManyInviteRecipients):
+				http.Error(w, fmt.Sprintf("Puoi invitare al massimo %d persone alla volta", repositories.MaxBulkInviteRecipients), http.StatusBadRequest)
+			default:
+				fmt.Printf("[EVENT_INVITE] Error while sending bulk invitations: %v\n", err)
+				http.Error(w, "Errore durante l'invio degli inviti", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		response := BulkEventInviteResponse{
+			Success: true,
+			PostID:  req.PostID,
+			Results: results,
+		}
+		for _, result := range results {
+			if result.Status == models.BulkInviteStatusInvited {
+				response.Invited++
+			} else {
+				response.Skipped++
+			}
+		}
+
+		fmt.Printf("[EVENT_INVITE] Bulk invitation for event %d: %d invited, %d skipped\n", req.PostID, response.Invited, response.Skipped)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetSentEventInvitesHandler restituisce gli inviti inviati dall'utente con il loro stato
+// ("/events/invites/sent?post_id=&status=&limit=&offset=")
+func (h *EventHandler) GetSentEventInvitesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		postID := 0
+		if value := query.Get("post_id"); value != "" {
+			postID, err = strconv.Atoi(value)
+			if err != nil || postID <= 0 {
+				http.Error(w, "post_id non valido", http.StatusBadRequest)
+				return
+			}
+		}
+
+		status := query.Get("status")
+		switch status {
+		case "", models.EventInviteStatusPending, models.EventInviteStatusAccepted, models.EventInviteStatusRejected,
+			models.EventInviteStatusExpired, models.EventInviteStatusRevoked:
+		default:
+			http.Error(w, "Stato non valido", http.StatusBadRequest)
+			return
+		}
+
+		limit := 50
+		if value, err := strconv.Atoi(query.Get("limit")); err == nil && value > 0 && value <= 100 {
+			limit = value
+		}
+		offset := 0
+		if value, err := strconv.Atoi(query.Get("offset")); err == nil && value >= 0 {
+			offset = value
+		}
+
+		invites, err := h.eventRepo.GetSentEventInvites(userID, postID, status, limit, offset)
+		if err != nil {
+			fmt.Printf("[EVENT_INVITE] Error retrieving invites sent by userID %d: %v\n", userID, err)
+			http.Error(w, "Errore durante il recupero degli inviti", http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"success": true,
+			"invites": invites,
+			"count":   len(invites),
+			"limit":   limit,
+			"offset":  offset,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// RevokeEventInviteHandler ritira un invito ancora in attesa e ne rimuove la notifica
+func (h *EventHandler) RevokeEventInviteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		inviteID, err := strconv.ParseInt(r.URL.Query().Get("invite_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invite_id non valido", http.StatusBadRequest)
+			return
+		}
+
+		receiverID, postID, err := h.eventRepo.RevokeEventInvite(inviteID, userID)
+		if errors.Is(err, repositories.ErrInviteNotFound) {
+			http.Error(w, "Invito non trovato o non più in attesa", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			fmt.Printf("[EVENT_INVITE] Error revoking invite %d: %v\n", inviteID, err)
+			http.Error(w, "Errore durante la revoca dell'invito", http.StatusInternalServerError)
+			return
+		}
+
+		if h.notificationRepo != nil {
+			if err := h.notificationRepo.DeleteNotificationByRelated(receiverID, models.NotificationTypeEventInvite, int64(postID)); err != nil {
+				fmt.Printf("[EVENT_INVITE] WARNING: Error removing notification after revocation: %v\n", err)
+			}
+		}
+
+		fmt.Printf("[EVENT_INVITE] Invite %d revoked by userID %d\n", inviteID, userID)
+
+		response := EventInviteResponse{
+			Success: true,
+			Message: "Invito revocato",
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/invitelink"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/services"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+// Percorso pubblico dei link di invito: /join/{token}
+const eventLinkPath = "/join/"
+
+// Limiti dei parametri di un link di invito
+const (
+	maxEventLinkUses        = 1000
+	maxEventLinkExpiryHours = 24 * 90
+)
+
+type EventLinkHandler struct {
+	eventRepo   *repositories.EventRepository
+	linkService *services.EventLinkService
+	cfg         config.EventLinkConfig
+	sm          *sessions.SessionManager
+}
+
+func NewEventLinkHandler(eventRepo *repositories.EventRepository, linkService *services.EventLinkService, cfg config.EventLinkConfig, sm *sessions.SessionManager) *EventLinkHandler {
+	return &EventLinkHandler{
+		eventRepo:   eventRepo,
+		linkService: linkService,
+		cfg:         cfg,
+		sm:          sm,
+	}
+}
+
+// CreateEventLinkRequest crea un link di invito; 0 = nessun limite di utilizzi,
+// scadenza all'inizio della partita
+type CreateEventLinkRequest struct {
+	PostID         int `json:"post_id"`
+	MaxUses        int `json:"max_uses"`
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+// EventLinksHandler gestisce "/event-links": GET ?post_id= elenca i link della
+// partita, POST ne crea uno. Solo l'organizzatore può gestire i link.
+func (h *EventLinkHandler) EventLinksHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			postID, err := strconv.Atoi(r.URL.Query().Get("post_id"))
+			if err != nil || postID <= 0 {
+				http.Error(w, "post_id non valido", http.StatusBadRequest)
+				return
+			}
+
+			links, err := h.eventRepo.GetEventLinks(userID, postID)
+			if h.handleEventLinkError(w, err) {
+				return
+			}
+			for i := range links {
+				h.fillLinkURL(r, &links[i])
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"post_id": postID,
+				"links":   links,
+				"count":   len(links),
+			})
+
+		case http.MethodPost:
+			var req CreateEventLinkRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+			if req.PostID <= 0 {
+				http.Error(w, "post_id non valido", http.StatusBadRequest)
+				return
+			}
+			if req.MaxUses < 0 || req.MaxUses > maxEventLinkUses {
+				http.Error(w, fmt.Sprintf("max_uses deve essere tra 0 e %d", maxEventLinkUses), http.StatusBadRequest)
+				return
+			}
+			if req.ExpiresInHours < 0 || req.ExpiresInHours > maxEventLinkExpiryHours {
+				http.Error(w, fmt.Sprintf("expires_in_hours deve essere tra 0 e %d", maxEventLinkExpiryHours), http.StatusBadRequest)
+				return
+			}
+
+			link, err := h.eventRepo.CreateEventLink(userID, req.PostID, req.MaxUses, time.Duration(req.ExpiresInHours)*time.Hour)
+			if h.handleEventLinkError(w, err) {
+				return
+			}
+			h.fillLinkURL(r, link)
+
+			fmt.Printf("[EVENT_LINKS] userID %d created link %d for event %d\n", userID, link.ID, req.PostID)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"link":    link,
+			})
+
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// EventLinkRoutesHandler gestisce "/event-links/{linkID}": GET restituisce il
+// link con i suoi utilizzi, DELETE lo disattiva
+func (h *EventLinkHandler) EventLinkRoutesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		linkID, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/event-links/"), "/"), 10, 64)
+		if err != nil || linkID <= 0 {
+			http.Error(w, "ID link non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			link, err := h.eventRepo.GetEventLink(userID, linkID)
+			if h.handleEventLinkError(w, err) {
+				return
+			}
+			h.fillLinkURL(r, link)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"link":    link,
+			})
+
+		case http.MethodDelete:
+			if h.handleEventLinkError(w, h.eventRepo.RevokeEventLink(userID, linkID)) {
+				return
+			}
+
+			fmt.Printf("[EVENT_LINKS] userID %d revoked link %d\n", userID, linkID)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"message": "Link disattivato",
+			})
+
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// JoinEventLinkHandler gestisce "/join/{token}": GET mostra l'anteprima della
+// partita anche senza sessione (chi non ha un account può registrarsi passando
+// il token nel campo event_link), POST iscrive l'utente loggato
+func (h *EventLinkHandler) JoinEventLinkHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.Trim(strings.TrimPrefix(r.URL.Path, eventLinkPath), "/")
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			preview, err := h.linkService.Preview(token)
+			if h.handleEventLinkError(w, err) {
+				return
+			}
+
+			response := map[string]interface{}{
+				"success":        true,
+				"event":          preview,
+				"requires_login": true,
+			}
+			if userID, err := middleware.GetUserIDFromSession(r, h.sm); err == nil {
+				status, _, err := h.eventRepo.GetParticipationStatus(userID, preview.PostID)
+				if err == nil && status != "" {
+					response["participation_status"] = status
+				}
+				response["requires_login"] = false
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+
+		case http.MethodPost:
+			userID, err := middleware.GetUserIDFromSession(r, h.sm)
+			if err != nil {
+				http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+				return
+			}
+
+			postID, change, err := h.linkService.Redeem(token, userID, false)
+			if h.handleEventLinkError(w, err) {
+				return
+			}
+
+			response := ParticipationResponse{
+				Success:       true,
+				IsParticipant: change.Status == models.ParticipantStatusConfirmed,
+				Status:        change.Status,
+				Message:       eventLinkJoinMessage(change),
+			}
+			if change.Status == models.ParticipantStatusWaitlisted {
+				response.WaitlistPosition = change.WaitlistPosition
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"post_id":       postID,
+				"participation": response,
+			})
+
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// fillLinkURL completa il link con token firmato e URL pubblico
+func (h *EventLinkHandler) fillLinkURL(r *http.Request, link *models.EventLink) {
+	link.Token = h.linkService.Token(link.ID)
+
+	base := strings.TrimSuffix(h.cfg.PublicBaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	link.URL = base + eventLinkPath + link.Token
+}
+
+// eventLinkJoinMessage descrive l'esito dell'iscrizione tramite link
+func eventLinkJoinMessage(change *models.ParticipationChange) string {
+	switch change.Status {
+	case models.ParticipantStatusWaitlisted:
+		return fmt.Sprintf("Evento al completo: sei in lista d'attesa (posizione %d)", change.WaitlistPosition)
+	case models.ParticipantStatusPending:
+		return "Richiesta di partecipazione inviata all'organizzatore"
+	case models.ParticipantStatusConfirmed:
+		if change.PreviousStatus == models.ParticipantStatusConfirmed {
+			return "Sei già iscritto a questo evento"
+		}
+	}
+	return "Iscrizione all'evento avvenuta con successo"
+}
+
+// handleEventLinkError traduce gli errori dei link in risposte HTTP; restituisce true se ha risposto
+func (h *EventLinkHandler) handleEventLinkError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, invitelink.ErrInvalidToken),
+		errors.Is(err, repositories.ErrEventLinkNotFound),
+		errors.Is(err, repositories.ErrEventNotFound):
+		http.Error(w, "Link di invito non valido", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrEventLinkRevoked),
+		errors.Is(err, repositories.ErrEventLinkExpired),
+		errors.Is(err, repositories.ErrEventLinkExhausted):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, repositories.ErrNotEventOrganizer),
+		errors.Is(err, repositories.ErrJoinNotAllowed),
+		errors.Is(err, repositories.ErrRemovedFromEvent):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrEventAlreadyStarted),
+		errors.Is(err, repositories.ErrTooManyEventLinks):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		fmt.Printf("[EVENT_LINKS] Error: %v\n", err)
+		http.Error(w, "Errore interno del server", http.StatusInternalServerError)
+	}
+	return true
+}
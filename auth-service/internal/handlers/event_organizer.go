@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/validation"
+)
+
+// ========== ENDPOINT ORGANIZZATORE ==========
+
+type EventSettingsRequest struct {
+	JoinPolicy string `json:"join_policy"`
+}
+
+// EventRoutesHandler smista le richieste "/events/{id}/..." verso l'handler corretto:
+//
+//	GET    /events/{id}/participants
+//	DELETE /events/{id}/participants/{userID}
+//	GET    /events/{id}/requests
+//	POST   /events/{id}/requests/{userID}/approve
+//	POST   /events/{id}/requests/{userID}/reject
+//	GET    /events/{id}/settings
+//	PUT    /events/{id}/settings
+//	GET    /events/{id}/teams?count=2
+//	POST   /events/{id}/teams/shuffle?count=2
+//	POST   /events/{id}/teams/lock
+//	DELETE /events/{id}/teams/lock
+//	POST   /events/{id}/teams/announce
+//	PUT    /events/{id}/skills/{userID}
+func (h *EventHandler) EventRoutesHandler() http.HandlerFunc {
+	participantsHandler := h.GetEventParticipantsHandler()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/events/"), "/"), "/")
+		if len(parts) < 2 {
+			http.NotFound(w, r)
+			return
+		}
+
+		postID, err := strconv.Atoi(parts[0])
+		if err != nil {
+			http.Error(w, "Post ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		// Solo alcune risorse hanno un ID utente come terzo segmento
+		var targetUserID int64
+		if len(parts) >= 3 && (parts[1] == "participants" || parts[1] == "requests" || parts[1] == "skills") {
+			targetUserID, err = strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				http.Error(w, "User ID non valido", http.StatusBadRequest)
+				return
+			}
+		}
+
+		switch {
+		case parts[1] == "participants" && len(parts) == 2:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			participantsHandler(w, r)
+
+		case parts[1] == "participants" && len(parts) == 3:
+			if r.Method != http.MethodDelete {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.removeParticipant(w, r, postID, targetUserID)
+
+		case parts[1] == "requests" && len(parts) == 2:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.listJoinRequests(w, r, postID)
+
+		case parts[1] == "requests" && len(parts) == 4 && (parts[3] == "approve" || parts[3] == "reject"):
+			if r.Method != http.MethodPost {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.decideJoinRequest(w, r, postID, targetUserID, parts[3] == "approve")
+
+		case parts[1] == "settings" && len(parts) == 2:
+			switch r.Method {
+			case http.MethodGet:
+				h.getEventSettings(w, postID)
+			case http.MethodPut:
+				h.updateEventSettings(w, r, postID)
+			default:
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			}
+
+		case parts[1] == "teams" && len(parts) == 2:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.getEventTeams(w, r, postID)
+
+		case parts[1] == "teams" && len(parts) == 3 && parts[2] == "shuffle":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.shuffleEventTeams(w, r, postID)
+
+		case parts[1] == "teams" && len(parts) == 3 && parts[2] == "lock":
+			switch r.Method {
+			case http.MethodPost:
+				h.lockEventTeams(w, r, postID)
+			case http.MethodDelete:
+				h.unlockEventTeams(w, r, postID)
+			default:
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			}
+
+		case parts[1] == "teams" && len(parts) == 3 && parts[2] == "announce":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.announceEventTeams(w, r, postID)
+
+		case parts[1] == "skills" && len(parts) == 3:
+			if r.Method != http.MethodPut {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.setParticipantSkill(w, r, postID, targetUserID)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// listJoinRequests restituisce all'organizzatore le richieste in attesa
+func (h *EventHandler) listJoinRequests(w http.ResponseWriter, r *http.Request, postID int) {
+	userID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	requests, err := h.eventRepo.GetJoinRequests(userID, postID)
+	if err != nil {
+		if h.handleParticipationError(w, err) {
+			return
+		}
+		fmt.Printf("[EVENTS] Error getting join requests for event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante il recupero delle richieste", http.StatusInternalServerError)
+		return
+	}
+
+	if requests == nil {
+		requests = []models.EventJoinRequest{}
+	}
+
+	response := map[string]interface{}{
+		"success":  true,
+		"requests": requests,
+		"count":    len(requests),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// decideJoinRequest approva o rifiuta una richiesta e avvisa il giocatore
+func (h *EventHandler) decideJoinRequest(w http.ResponseWriter, r *http.Request, postID int, playerID int64, approve bool) {
+	organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	change, err := h.eventRepo.DecideJoinRequest(organizerID, playerID, postID, approve)
+	if err != nil {
+		if h.handleParticipationError(w, err) {
+			return
+		}
+		fmt.Printf("[EVENTS] Error deciding join request of user %d for event %d: %v\n", playerID, postID, err)
+		http.Error(w, "Errore durante la gestione della richiesta", http.StatusInternalServerError)
+		return
+	}
+
+	eventTitle := h.eventTitle(postID)
+	response := ParticipationResponse{
+		Success: true,
+		Status:  change.Status,
+	}
+
+	switch change.Status {
+	case models.ParticipantStatusWaitlisted:
+		response.WaitlistPosition = change.WaitlistPosition
+		response.Message = fmt.Sprintf("Richiesta approvata: l'evento è al completo, il giocatore è in lista d'attesa (posizione %d)", change.WaitlistPosition)
+		h.notifyParticipationDecision(playerID, organizerID, postID, "Richiesta approvata",
+			fmt.Sprintf("La tua richiesta per l'evento %s è stata approvata: sei in lista d'attesa (posizione %d)", eventTitle, change.WaitlistPosition))
+	case models.ParticipantStatusConfirmed:
+		response.IsParticipant = true
+		response.Message = "Richiesta approvata"
+		h.notifyParticipationDecision(playerID, organizerID, postID, "Richiesta approvata",
+			fmt.Sprintf("La tua richiesta è stata approvata: sei iscritto all'evento %s", eventTitle))
+	default:
+		response.Message = "Richiesta rifiutata"
+		h.notifyParticipationDecision(playerID, organizerID, postID, "Richiesta rifiutata",
+			fmt.Sprintf("La tua richiesta di partecipazione all'evento %s è stata rifiutata", eventTitle))
+	}
+
+	fmt.Printf("[EVENTS] Organizer %d set join request of user %d for event %d to %s\n", organizerID, playerID, postID, change.Status)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// removeParticipant rimuove un giocatore dall'evento e promuove la lista d'attesa
+func (h *EventHandler) removeParticipant(w http.ResponseWriter, r *http.Request, postID int, playerID int64) {
+	organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	change, err := h.eventRepo.RemoveParticipant(organizerID, playerID, postID)
+	if err != nil {
+		if h.handleParticipationError(w, err) {
+			return
+		}
+		fmt.Printf("[EVENTS] Error removing user %d from event %d: %v\n", playerID, postID, err)
+		http.Error(w, "Errore durante la rimozione del partecipante", http.StatusInternalServerError)
+		return
+	}
+
+	h.notifyParticipationDecision(playerID, organizerID, postID, "Rimosso dall'evento",
+		fmt.Sprintf("L'organizzatore ti ha rimosso dall'evento %s", h.eventTitle(postID)))
+	h.notifyWaitlistPromotions(postID, change.PromotedUserIDs)
+
+	fmt.Printf("[EVENTS] Organizer %d removed user %d from event %d\n", organizerID, playerID, postID)
+
+	response := ParticipationResponse{
+		Success: true,
+		Status:  change.Status,
+		Message: "Partecipante rimosso dall'evento",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getEventSettings restituisce la politica di iscrizione dell'evento
+func (h *EventHandler) getEventSettings(w http.ResponseWriter, postID int) {
+	settings, err := h.eventRepo.GetEventSettings(postID)
+	if err != nil {
+		if h.handleParticipationError(w, err) {
+			return
+		}
+		http.Error(w, "Errore durante il recupero delle impostazioni", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// updateEventSettings aggiorna la politica di iscrizione (solo organizzatore)
+func (h *EventHandler) updateEventSettings(w http.ResponseWriter, r *http.Request, postID int) {
+	userID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	var req EventSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+
+	if !validation.IsJoinPolicy(req.JoinPolicy) {
+		http.Error(w, "Politica di iscrizione non valida: usa open, approval, friends_only o invite_only", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.eventRepo.SetJoinPolicy(userID, postID, req.JoinPolicy)
+	if err != nil {
+		if h.handleParticipationError(w, err) {
+			return
+		}
+		fmt.Printf("[EVENTS] Error updating settings of event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante l'aggiornamento delle impostazioni", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[EVENTS] Join policy of event %d set to %s by user %d\n", postID, settings.JoinPolicy, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
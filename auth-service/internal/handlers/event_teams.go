@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/privacy"
+	"trovagiocatoriAuth/internal/teams"
+)
+
+// ========== ENDPOINT SQUADRE ==========
+
+// Numero di squadre usato quando non specificato
+const defaultTeamCount = 2
+
+type LockTeamsRequest struct {
+	Count    int        `json:"count"`
+	Seed     int64      `json:"seed"`
+	Pairs    [][2]int64 `json:"pairs"`
+	Announce bool       `json:"announce"`
+}
+
+type ParticipantSkillRequest struct {
+	Skill float64 `json:"skill"`
+}
+
+// getEventTeams restituisce le squadre bloccate a chiunque; se non sono ancora
+// bloccate l'organizzatore ottiene un'anteprima generata con count, seed e pairs
+func (h *EventHandler) getEventTeams(w http.ResponseWriter, r *http.Request, postID int) {
+	// Come la lista dei partecipanti, le squadre sono visibili solo con una sessione
+	viewerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	locked, err := h.eventRepo.GetLockedTeams(postID)
+	if err == nil {
+		viewer, err := h.participantViewer(viewerID, postID)
+		if err == nil {
+			err = h.applyTeamPrivacy(viewer, locked)
+		}
+		if err != nil {
+			fmt.Printf("[TEAMS] Error applying privacy to teams of event %d: %v\n", postID, err)
+			http.Error(w, "Errore durante il recupero delle squadre", http.StatusInternalServerError)
+			return
+		}
+		if !viewer.IsOrganizer {
+			hideTeamSkills(locked)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(locked)
+		return
+	}
+	if !errors.Is(err, repositories.ErrTeamsNotFound) {
+		fmt.Printf("[TEAMS] Error getting locked teams of event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante il recupero delle squadre", http.StatusInternalServerError)
+		return
+	}
+
+	count, pairs, err := parseTeamsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	seed := int64(postID)
+	if value := r.URL.Query().Get("seed"); value != "" {
+		seed, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Seed non valido", http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.writeTeamsPreview(w, viewerID, postID, count, seed, pairs)
+}
+
+// shuffleEventTeams genera una nuova suddivisione con un seed casuale (solo organizzatore)
+func (h *EventHandler) shuffleEventTeams(w http.ResponseWriter, r *http.Request, postID int) {
+	userID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	count, pairs, err := parseTeamsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.eventRepo.GetLockedTeams(postID); err == nil {
+		h.handleParticipationError(w, repositories.ErrTeamsLocked)
+		return
+	}
+
+	h.writeTeamsPreview(w, userID, postID, count, rand.Int63(), pairs)
+}
+
+// lockEventTeams rigenera le squadre dal seed indicato e le salva; con
+// announce=true i giocatori ricevono una notifica con la propria squadra
+func (h *EventHandler) lockEventTeams(w http.ResponseWriter, r *http.Request, postID int) {
+	organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	var req LockTeamsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+	if req.Count == 0 {
+		req.Count = defaultTeamCount
+	}
+
+	result, err := h.buildEventTeams(organizerID, postID, req.Count, req.Seed, req.Pairs)
+	if err != nil {
+		if h.handleTeamsError(w, err) {
+			return
+		}
+		fmt.Printf("[TEAMS] Error generating teams for event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante la generazione delle squadre", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.eventRepo.LockTeams(organizerID, result); err != nil {
+		if h.handleTeamsError(w, err) {
+			return
+		}
+		fmt.Printf("[TEAMS] Error locking teams for event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante il salvataggio delle squadre", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[TEAMS] Organizer %d locked %d teams for event %d (seed %d)\n", organizerID, result.TeamCount, postID, result.Seed)
+
+	if req.Announce {
+		h.announceTeams(organizerID, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// unlockEventTeams elimina le squadre bloccate (solo organizzatore)
+func (h *EventHandler) unlockEventTeams(w http.ResponseWriter, r *http.Request, postID int) {
+	organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.eventRepo.UnlockTeams(organizerID, postID); err != nil {
+		if h.handleTeamsError(w, err) {
+			return
+		}
+		fmt.Printf("[TEAMS] Error unlocking teams for event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante lo sblocco delle squadre", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[TEAMS] Organizer %d unlocked teams for event %d\n", organizerID, postID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Squadre sbloccate",
+	})
+}
+
+// announceEventTeams invia di nuovo ai giocatori le squadre bloccate (solo organizzatore)
+func (h *EventHandler) announceEventTeams(w http.ResponseWriter, r *http.Request, postID int) {
+	organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	isOrganizer, err := h.eventRepo.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		http.Error(w, "Errore durante la verifica dell'organizzatore", http.StatusInternalServerError)
+		return
+	}
+	if !isOrganizer {
+		h.handleParticipationError(w, repositories.ErrNotEventOrganizer)
+		return
+	}
+
+	locked, err := h.eventRepo.GetLockedTeams(postID)
+	if err != nil {
+		if h.handleTeamsError(w, err) {
+			return
+		}
+		http.Error(w, "Errore durante il recupero delle squadre", http.StatusInternalServerError)
+		return
+	}
+
+	notified := h.announceTeams(organizerID, locked)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"notified": notified,
+	})
+}
+
+// setParticipantSkill imposta l'abilità (1-5) di un partecipante per l'evento
+func (h *EventHandler) setParticipantSkill(w http.ResponseWriter, r *http.Request, postID int, playerID int64) {
+	organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+		return
+	}
+
+	var req ParticipantSkillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+	if req.Skill < 1 || req.Skill > 5 {
+		http.Error(w, "L'abilità deve essere compresa tra 1 e 5", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.eventRepo.SetParticipantSkill(organizerID, playerID, postID, req.Skill); err != nil {
+		if h.handleParticipationError(w, err) {
+			return
+		}
+		fmt.Printf("[TEAMS] Error setting skill of user %d for event %d: %v\n", playerID, postID, err)
+		http.Error(w, "Errore durante il salvataggio dell'abilità", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user_id": playerID,
+		"skill":   req.Skill,
+	})
+}
+
+// writeTeamsPreview genera e restituisce le squadre senza salvarle
+func (h *EventHandler) writeTeamsPreview(w http.ResponseWriter, organizerID int64, postID, count int, seed int64, pairs [][2]int64) {
+	result, err := h.buildEventTeams(organizerID, postID, count, seed, pairs)
+	if err != nil {
+		if h.handleTeamsError(w, err) {
+			return
+		}
+		fmt.Printf("[TEAMS] Error generating teams for event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante la generazione delle squadre", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// buildEventTeams divide i partecipanti confermati in squadre equilibrate (solo organizzatore)
+func (h *EventHandler) buildEventTeams(organizerID int64, postID, count int, seed int64, pairs [][2]int64) (*models.EventTeams, error) {
+	isOrganizer, err := h.eventRepo.IsEventOrganizer(organizerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganizer {
+		return nil, repositories.ErrNotEventOrganizer
+	}
+
+	if err := h.eventRepo.ValidateTeamPairs(pairs); err != nil {
+		return nil, err
+	}
+
+	participants, err := h.eventRepo.GetEventParticipants(postID, []string{models.ParticipantStatusConfirmed})
+	if err != nil {
+		return nil, err
+	}
+	skills, err := h.eventRepo.GetParticipantSkills(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	players := make([]models.TeamPlayer, 0, len(participants))
+	for _, participant := range participants {
+		player, ok := skills[participant.UserID]
+		if !ok {
+			continue
+		}
+		player.Username = participant.Username
+		player.ProfilePic = participant.ProfilePic
+		players = append(players, player)
+	}
+
+	generated, err := teams.Balance(players, pairs, count, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.EventTeams{
+		PostID:    postID,
+		TeamCount: count,
+		Seed:      seed,
+		Pairs:     pairs,
+		Teams:     generated,
+	}, nil
+}
+
+// announceTeams notifica a ogni giocatore la propria squadra e i compagni
+func (h *EventHandler) announceTeams(organizerID int64, result *models.EventTeams) int {
+	if h.notificationRepo == nil {
+		return 0
+	}
+
+	eventTitle := h.eventTitle(result.PostID)
+	notified := 0
+	for _, team := range result.Teams {
+		for _, player := range team.Players {
+			if player.UserID == organizerID {
+				continue
+			}
+
+			var teammates []string
+			for _, other := range team.Players {
+				if other.UserID != player.UserID {
+					teammates = append(teammates, other.Username)
+				}
+			}
+
+			message := fmt.Sprintf("Per l'evento %s giochi nella squadra %d", eventTitle, team.Number)
+			if len(teammates) > 0 {
+				message += " con " + strings.Join(teammates, ", ")
+			}
+
+			if err := h.notificationRepo.CreateEventUpdateNotification(player.UserID, int64(result.PostID), &organizerID, "Squadre formate", message); err != nil {
+				fmt.Printf("[TEAMS] WARNING: Error announcing team to user %d: %v\n", player.UserID, err)
+				continue
+			}
+			notified++
+		}
+	}
+
+	fmt.Printf("[TEAMS] Teams of event %d announced to %d players\n", result.PostID, notified)
+	return notified
+}
+
+// handleTeamsError gestisce gli errori della generazione squadre, oltre a
+// quelli comuni di partecipazione; restituisce true se ha risposto
+func (h *EventHandler) handleTeamsError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, teams.ErrNotEnoughPlayers),
+		errors.Is(err, teams.ErrPairTooLarge),
+		errors.Is(err, teams.ErrUnknownPlayer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	}
+	return h.handleParticipationError(w, err)
+}
+
+// parseTeamsQuery legge count (default 2) e pairs ("1-2,3-4") dalla query string
+func parseTeamsQuery(r *http.Request) (int, [][2]int64, error) {
+	count := defaultTeamCount
+	if value := r.URL.Query().Get("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Numero di squadre non valido")
+		}
+		count = parsed
+	}
+
+	var pairs [][2]int64
+	if value := r.URL.Query().Get("pairs"); value != "" {
+		for _, item := range strings.Split(value, ",") {
+			ids := strings.Split(strings.TrimSpace(item), "-")
+			if len(ids) != 2 {
+				return 0, nil, fmt.Errorf("Coppia non valida: %s", item)
+			}
+			a, errA := strconv.ParseInt(ids[0], 10, 64)
+			b, errB := strconv.ParseInt(ids[1], 10, 64)
+			if errA != nil || errB != nil {
+				return 0, nil, fmt.Errorf("Coppia non valida: %s", item)
+			}
+			pairs = append(pairs, [2]int64{a, b})
+		}
+	}
+
+	return count, pairs, nil
+}
+
+// hideTeamSkills nasconde le abilità ai giocatori che non organizzano l'evento
+// applyTeamPrivacy applica ai giocatori delle squadre bloccate le loro
+// impostazioni di privacy, come nella lista dei partecipanti
+func (h *EventHandler) applyTeamPrivacy(viewer privacy.Viewer, result *models.EventTeams) error {
+	for t := range result.Teams {
+		players := result.Teams[t].Players
+		for p, player := range players {
+			settings, err := h.userRepo.GetPrivacySettings(player.UserID)
+			if err != nil {
+				return err
+			}
+			participant := models.EventParticipant{
+				UserID:     player.UserID,
+				Username:   player.Username,
+				ProfilePic: player.ProfilePic,
+				Status:     models.ParticipantStatusConfirmed,
+				Privacy:    *settings,
+			}
+			if privacy.BuildParticipantViews(viewer, []models.EventParticipant{participant})[0].Anonymous {
+				players[p] = models.TeamPlayer{Anonymous: true}
+			}
+		}
+	}
+	return nil
+}
+
+func hideTeamSkills(result *models.EventTeams) {
+	for t := range result.Teams {
+		result.Teams[t].TotalSkill = 0
+		for p := range result.Teams[t].Players {
+			result.Teams[t].Players[p].Skill = 0
+			result.Teams[t].Players[p].SkillSource = ""
+		}
+	}
+}
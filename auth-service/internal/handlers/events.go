@@ -2,29 +2,42 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"trovagiocatoriAuth/internal/config"
 	"trovagiocatoriAuth/internal/database/repositories"
 	"trovagiocatoriAuth/internal/middleware"
 	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/postcatalog"
+	"trovagiocatoriAuth/internal/privacy"
 	"trovagiocatoriAuth/internal/sessions"
+	"trovagiocatoriAuth/internal/validation"
 )
 
 type EventHandler struct {
 	eventRepo        *repositories.EventRepository
 	userRepo         *repositories.UserRepository
 	notificationRepo *repositories.NotificationRepository
+	scheduleCfg      config.ScheduleConfig
+	privacyCfg       config.PrivacyConfig
+	catalog          postcatalog.PostCatalog
 	sm               *sessions.SessionManager
 }
 
-func NewEventHandler(eventRepo *repositories.EventRepository, userRepo *repositories.UserRepository, sm *sessions.SessionManager) *EventHandler {
+func NewEventHandler(eventRepo *repositories.EventRepository, userRepo *repositories.UserRepository, notificationRepo *repositories.NotificationRepository, scheduleCfg config.ScheduleConfig, privacyCfg config.PrivacyConfig, catalog postcatalog.PostCatalog, sm *sessions.SessionManager) *EventHandler {
 	return &EventHandler{
-		eventRepo: eventRepo,
-		userRepo:  userRepo,
-		sm:        sm,
+		eventRepo:        eventRepo,
+		userRepo:         userRepo,
+		notificationRepo: notificationRepo,
+		scheduleCfg:      scheduleCfg,
+		privacyCfg:       privacyCfg,
+		catalog:          catalog,
+		sm:               sm,
 	}
 }
 
@@ -139,7 +152,22 @@ func (h *EventHandler) CheckFavoriteHandler() http.HandlerFunc {
 	}
 }
 
-// GetUserFavoritesHandler restituisce tutti i preferiti dell'utente
+// UserFavoritesResponse è una pagina dei preferiti; Favorites e Posts restano
+// per i client che non usano Items (Posts solo nella lista non paginata e senza expand)
+type UserFavoritesResponse struct {
+	Success    bool                   `json:"success"`
+	Favorites  []int                  `json:"favorites"`
+	Posts      []*models.PostSummary  `json:"posts"`
+	Items      []models.FavoriteEntry `json:"items"`
+	Count      int                    `json:"count"`
+	Limit      int                    `json:"limit,omitempty"`
+	HasMore    bool                   `json:"has_more"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// GetUserFavoritesHandler restituisce i preferiti dell'utente; la lista è
+// paginata a cursore solo se la richiesta indica limit o cursor
+// ("/favorites?when=upcoming|past&sport=&provincia=&sort=&limit=&cursor=&expand=post")
 func (h *EventHandler) GetUserFavoritesHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := middleware.GetUserIDFromSession(r, h.sm)
@@ -148,15 +176,35 @@ func (h *EventHandler) GetUserFavoritesHandler() http.HandlerFunc {
 			return
 		}
 
-		favorites, err := h.eventRepo.GetUserFavorites(userID)
+		query, err := parsePostListQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		favorites, next, err := h.eventRepo.ListUserFavorites(userID, query)
 		if err != nil {
+			fmt.Printf("[FAVORITES] Error listing favorites for userID %d: %v\n", userID, err)
 			http.Error(w, "Errore durante il recupero dei preferiti", http.StatusInternalServerError)
 			return
 		}
 
-		response := map[string]interface{}{
-			"success":   true,
-			"favorites": favorites,
+		response := UserFavoritesResponse{
+			Success:   true,
+			Favorites: make([]int, 0, len(favorites)),
+			Items:     favorites,
+			Count:     len(favorites),
+			Limit:     query.Limit,
+			HasMore:   next != nil,
+		}
+		for _, favorite := range favorites {
+			response.Favorites = append(response.Favorites, favorite.PostID)
+		}
+		if isLegacyPostList(query) {
+			response.Posts = h.postSummaries(r, response.Favorites)
+		}
+		if next != nil {
+			response.NextCursor = next.Encode()
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -167,13 +215,18 @@ func (h *EventHandler) GetUserFavoritesHandler() http.HandlerFunc {
 // ========== ENDPOINT PARTECIPAZIONE EVENTI ==========
 
 type ParticipationRequest struct {
-	PostID int `json:"post_id"`
+	PostID int  `json:"post_id"`
+	Force  bool `json:"force"` // iscrive anche se l'utente ha altre partite nello stesso orario
 }
 
 type ParticipationResponse struct {
-	Success       bool   `json:"success"`
-	IsParticipant bool   `json:"is_participant"`
-	Message       string `json:"message,omitempty"`
+	Success          bool                `json:"success"`
+	IsParticipant    bool                `json:"is_participant"`
+	Status           string              `json:"status,omitempty"`
+	WaitlistPosition int                 `json:"waitlist_position,omitempty"`
+	Message          string              `json:"message,omitempty"`
+	Warning          string              `json:"warning,omitempty"`
+	Conflicts        []models.Commitment `json:"conflicts,omitempty"`
 }
 
 // JoinEventHandler - Iscrive l'utente a un evento
@@ -191,17 +244,49 @@ func (h *EventHandler) JoinEventHandler() http.HandlerFunc {
 			return
 		}
 
-		if err := h.eventRepo.JoinEvent(userID, req.PostID); err != nil {
-			if strings.Contains(err.Error(), "già iscritto") {
+		result, err := h.eventRepo.JoinEvent(userID, req.PostID, &repositories.ScheduleCheck{Schedule: h.scheduleCfg, Force: req.Force})
+		if err != nil {
+			var conflictErr *repositories.ScheduleConflictError
+			if errors.As(err, &conflictErr) {
+				response := ParticipationResponse{
+					Success:   false,
+					Message:   "Iscrizione non effettuata: l'orario si sovrappone ad altre partite",
+					Warning:   scheduleConflictWarning,
+					Conflicts: conflictErr.Conflicts,
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+			if errors.Is(err, repositories.ErrAlreadyParticipant) {
 				response := ParticipationResponse{
 					Success:       false,
 					IsParticipant: true,
+					Status:        models.ParticipantStatusConfirmed,
 					Message:       "Sei già iscritto a questo evento",
 				}
 				w.Header().Set("Content-Type", "application/json")
 				json.NewEncoder(w).Encode(response)
 				return
 			}
+			if errors.Is(err, repositories.ErrAlreadyWaitlisted) {
+				_, position, _ := h.eventRepo.GetParticipationStatus(userID, req.PostID)
+				response := ParticipationResponse{
+					Success:          false,
+					IsParticipant:    false,
+					Status:           models.ParticipantStatusWaitlisted,
+					WaitlistPosition: position,
+					Message:          "Sei già in lista d'attesa per questo evento",
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+			if h.handleParticipationError(w, err) {
+				return
+			}
+			fmt.Printf("[EVENTS] Error joining event %d for user %d: %v\n", req.PostID, userID, err)
 			http.Error(w, "Errore durante l'iscrizione all'evento", http.StatusInternalServerError)
 			return
 		}
@@ -209,8 +294,25 @@ func (h *EventHandler) JoinEventHandler() http.HandlerFunc {
 		response := ParticipationResponse{
 			Success:       true,
 			IsParticipant: true,
+			Status:        result.Status,
 			Message:       "Iscrizione all'evento avvenuta con successo",
 		}
+		switch result.Status {
+		case models.ParticipantStatusWaitlisted:
+			response.IsParticipant = false
+			response.WaitlistPosition = result.WaitlistPosition
+			response.Message = fmt.Sprintf("Evento al completo: sei in lista d'attesa (posizione %d)", result.WaitlistPosition)
+		case models.ParticipantStatusPending:
+			response.IsParticipant = false
+			response.Message = "Richiesta di partecipazione inviata all'organizzatore"
+			if result.PreviousStatus != models.ParticipantStatusPending {
+				h.notifyJoinRequest(req.PostID, userID)
+			}
+		}
+		if len(result.Conflicts) > 0 {
+			response.Warning = "Attenzione: hai altre partite nello stesso orario"
+			response.Conflicts = result.Conflicts
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
@@ -232,11 +334,18 @@ func (h *EventHandler) LeaveEventHandler() http.HandlerFunc {
 			return
 		}
 
-		if err := h.eventRepo.LeaveEvent(userID, req.PostID); err != nil {
+		result, err := h.eventRepo.LeaveEvent(userID, req.PostID)
+		if err != nil {
+			if h.handleParticipationError(w, err) {
+				return
+			}
+			fmt.Printf("[EVENTS] Error leaving event %d for user %d: %v\n", req.PostID, userID, err)
 			http.Error(w, "Errore durante la disiscrizione dall'evento", http.StatusInternalServerError)
 			return
 		}
 
+		h.notifyWaitlistPromotions(req.PostID, result.PromotedUserIDs)
+
 		response := ParticipationResponse{
 			Success:       true,
 			IsParticipant: false,
@@ -270,15 +379,17 @@ func (h *EventHandler) CheckParticipationHandler() http.HandlerFunc {
 			return
 		}
 
-		isParticipant, err := h.eventRepo.IsEventParticipant(userID, postID)
+		status, position, err := h.eventRepo.GetParticipationStatus(userID, postID)
 		if err != nil {
 			http.Error(w, "Errore durante la verifica partecipazione", http.StatusInternalServerError)
 			return
 		}
 
 		response := ParticipationResponse{
-			Success:       true,
-			IsParticipant: isParticipant,
+			Success:          true,
+			IsParticipant:    status == models.ParticipantStatusConfirmed,
+			Status:           status,
+			WaitlistPosition: position,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -286,7 +397,19 @@ func (h *EventHandler) CheckParticipationHandler() http.HandlerFunc {
 	}
 }
 
+// EventParticipantsResponse è la lista dei partecipanti filtrata secondo la privacy di ciascuno
+type EventParticipantsResponse struct {
+	Success       bool                     `json:"success"`
+	PostID        int                      `json:"post_id"`
+	Participants  []models.ParticipantView `json:"participants"`
+	Count         int                      `json:"count"`
+	WaitlistCount int                      `json:"waitlist_count"`
+}
+
 // GetEventParticipantsHandler - Ottiene la lista dei partecipanti a un evento
+// (?status=confirmed,tentative oppure ?status=all; default confirmed). Ogni
+// partecipante è mostrato secondo le sue impostazioni di privacy; chi non è
+// organizzatore né admin vede solo confermati, incerti e lista d'attesa.
 func (h *EventHandler) GetEventParticipantsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Estrai post_id dall'URL
@@ -302,16 +425,53 @@ func (h *EventHandler) GetEventParticipantsHandler() http.HandlerFunc {
 			return
 		}
 
-		participants, err := h.eventRepo.GetEventParticipants(postID)
+		statuses, err := parseParticipationStatuses(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var viewer privacy.Viewer
+		if userID, err := middleware.GetUserIDFromSession(r, h.sm); err == nil {
+			viewer, err = h.participantViewer(userID, postID)
+			if err != nil {
+				fmt.Printf("[PARTICIPANTS] Error loading viewer %d for event %d: %v\n", userID, postID, err)
+				http.Error(w, "Errore durante il recupero dei partecipanti", http.StatusInternalServerError)
+				return
+			}
+		} else if !h.privacyCfg.PublicParticipantLists {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		// Rifiutati, rimossi, assenti e richieste in attesa li vedono solo
+		// l'organizzatore e gli admin
+		if !viewer.IsOrganizer && !viewer.IsAdmin {
+			statuses, err = publicParticipationStatuses(r, statuses)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
+		participants, err := h.eventRepo.GetEventParticipants(postID, statuses)
 		if err != nil {
 			http.Error(w, "Errore durante il recupero dei partecipanti", http.StatusInternalServerError)
 			return
 		}
 
-		response := map[string]interface{}{
-			"success":      true,
-			"participants": participants,
-			"count":        len(participants),
+		waitlistCount, err := h.eventRepo.GetEventWaitlistCount(postID)
+		if err != nil {
+			http.Error(w, "Errore durante il recupero dei partecipanti", http.StatusInternalServerError)
+			return
+		}
+
+		response := EventParticipantsResponse{
+			Success:       true,
+			PostID:        postID,
+			Participants:  privacy.BuildParticipantViews(viewer, participants),
+			Count:         len(participants),
+			WaitlistCount: waitlistCount,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -319,7 +479,47 @@ func (h *EventHandler) GetEventParticipantsHandler() http.HandlerFunc {
 	}
 }
 
-// GetUserParticipationsHandler - Ottiene tutti gli eventi a cui l'utente è iscritto
+// participantViewer ricava il rapporto tra l'utente e l'evento di cui consulta i partecipanti
+func (h *EventHandler) participantViewer(userID int64, postID int) (privacy.Viewer, error) {
+	viewer := privacy.Viewer{UserID: userID}
+
+	isAdmin, err := h.userRepo.CheckUserIsAdmin(userID)
+	if err != nil {
+		return viewer, err
+	}
+	viewer.IsAdmin = isAdmin
+
+	viewer.IsOrganizer, err = h.eventRepo.IsEventOrganizer(userID, postID)
+	if err != nil {
+		return viewer, err
+	}
+
+	status, _, err := h.eventRepo.GetParticipationStatus(userID, postID)
+	if err != nil {
+		return viewer, err
+	}
+	viewer.IsParticipant = status == models.ParticipantStatusConfirmed || status == models.ParticipantStatusTentative
+
+	viewer.FriendIDs, err = h.eventRepo.GetParticipantFriendIDs(userID, postID)
+	return viewer, err
+}
+
+// UserParticipationsResponse è una pagina delle partecipazioni; Participations
+// e Posts restano per i client che non usano Items (Posts solo nella lista non
+// paginata e senza expand)
+type UserParticipationsResponse struct {
+	Success        bool                        `json:"success"`
+	Participations []int                       `json:"participations"`
+	Posts          []*models.PostSummary       `json:"posts"`
+	Items          []models.ParticipationEntry `json:"items"`
+	Count          int                         `json:"count"`
+	Limit          int                         `json:"limit,omitempty"`
+	HasMore        bool                        `json:"has_more"`
+	NextCursor     string                      `json:"next_cursor,omitempty"`
+}
+
+// GetUserParticipationsHandler - Ottiene gli eventi dell'utente, filtrabili con
+// ?status= e con gli stessi filtri, ordinamenti e paginazione dei preferiti
 func (h *EventHandler) GetUserParticipationsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := middleware.GetUserIDFromSession(r, h.sm)
@@ -328,16 +528,163 @@ func (h *EventHandler) GetUserParticipationsHandler() http.HandlerFunc {
 			return
 		}
 
-		participations, err := h.eventRepo.GetUserParticipations(userID)
+		statuses, err := parseParticipationStatuses(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query, err := parsePostListQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Statuses = statuses
+
+		participations, next, err := h.eventRepo.ListUserParticipations(userID, query)
 		if err != nil {
+			fmt.Printf("[EVENTS] Error listing participations for userID %d: %v\n", userID, err)
 			http.Error(w, "Errore durante il recupero delle partecipazioni", http.StatusInternalServerError)
 			return
 		}
 
+		response := UserParticipationsResponse{
+			Success:        true,
+			Participations: make([]int, 0, len(participations)),
+			Items:          participations,
+			Count:          len(participations),
+			Limit:          query.Limit,
+			HasMore:        next != nil,
+		}
+		for _, participation := range participations {
+			response.Participations = append(response.Participations, participation.PostID)
+		}
+		if isLegacyPostList(query) {
+			response.Posts = h.postSummaries(r, response.Participations)
+		}
+		if next != nil {
+			response.NextCursor = next.Encode()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+type RSVPRequest struct {
+	PostID int    `json:"post_id"`
+	Status string `json:"status"`
+}
+
+// Stati che l'utente può impostare da sé; no_show è riservato all'organizzatore
+var rsvpStatuses = map[string]bool{
+	models.ParticipantStatusConfirmed: true,
+	models.ParticipantStatusTentative: true,
+	models.ParticipantStatusDeclined:  true,
+	models.ParticipantStatusCancelled: true,
+}
+
+// RSVPHandler - Imposta la risposta dell'utente a un evento (confirmed, tentative, declined, cancelled)
+func (h *EventHandler) RSVPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		var req RSVPRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+			return
+		}
+
+		if !rsvpStatuses[req.Status] {
+			http.Error(w, "Stato non valido: usa confirmed, tentative, declined o cancelled", http.StatusBadRequest)
+			return
+		}
+
+		change, err := h.eventRepo.SetParticipationStatus(userID, req.PostID, req.Status)
+		if err != nil {
+			if h.handleParticipationError(w, err) {
+				return
+			}
+			fmt.Printf("[EVENTS] Error updating RSVP for user %d on event %d: %v\n", userID, req.PostID, err)
+			http.Error(w, "Errore durante l'aggiornamento della partecipazione", http.StatusInternalServerError)
+			return
+		}
+
+		h.notifyWaitlistPromotions(req.PostID, change.PromotedUserIDs)
+
+		response := ParticipationResponse{
+			Success:          true,
+			IsParticipant:    change.Status == models.ParticipantStatusConfirmed,
+			Status:           change.Status,
+			WaitlistPosition: change.WaitlistPosition,
+			Message:          "Risposta all'evento aggiornata",
+		}
+		switch change.Status {
+		case models.ParticipantStatusWaitlisted:
+			response.Message = fmt.Sprintf("Evento al completo: sei in lista d'attesa (posizione %d)", change.WaitlistPosition)
+		case models.ParticipantStatusPending:
+			response.Message = "Richiesta di partecipazione inviata all'organizzatore"
+			if change.PreviousStatus != models.ParticipantStatusPending {
+				h.notifyJoinRequest(req.PostID, userID)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+type AttendanceRequest struct {
+	PostID int    `json:"post_id"`
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// MarkAttendanceHandler - L'organizzatore segna un partecipante come no_show
+// (o annulla la segnalazione con confirmed) dopo l'inizio dell'evento
+func (h *EventHandler) MarkAttendanceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		organizerID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		var req AttendanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+			return
+		}
+
+		change, err := h.eventRepo.MarkAttendance(organizerID, req.UserID, req.PostID, req.Status)
+		if err != nil {
+			if h.handleParticipationError(w, err) {
+				return
+			}
+			fmt.Printf("[EVENTS] Error marking attendance for user %d on event %d: %v\n", req.UserID, req.PostID, err)
+			http.Error(w, "Errore durante l'aggiornamento della presenza", http.StatusInternalServerError)
+			return
+		}
+
 		response := map[string]interface{}{
-			"success":        true,
-			"participations": participations,
-			"count":          len(participations),
+			"success":         true,
+			"user_id":         req.UserID,
+			"status":          change.Status,
+			"previous_status": change.PreviousStatus,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -345,6 +692,152 @@ func (h *EventHandler) GetUserParticipationsHandler() http.HandlerFunc {
 	}
 }
 
+// parseParticipationStatuses legge il filtro ?status= (valori separati da virgola,
+// "all" per tutti gli stati); senza filtro restituisce solo i confermati
+func parseParticipationStatuses(r *http.Request) ([]string, error) {
+	param := strings.TrimSpace(r.URL.Query().Get("status"))
+	if param == "" {
+		return []string{models.ParticipantStatusConfirmed}, nil
+	}
+	if param == "all" {
+		return []string{
+			models.ParticipantStatusConfirmed,
+			models.ParticipantStatusWaitlisted,
+			models.ParticipantStatusTentative,
+			models.ParticipantStatusDeclined,
+			models.ParticipantStatusCancelled,
+			models.ParticipantStatusNoShow,
+		}, nil
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(param, ",") {
+		status = strings.TrimSpace(status)
+		if !validation.IsParticipationStatus(status) {
+			return nil, fmt.Errorf("Stato non valido: %s", status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Stati dei partecipanti visibili a chiunque consulti l'evento
+var publicParticipantStatuses = map[string]bool{
+	models.ParticipantStatusConfirmed:  true,
+	models.ParticipantStatusTentative:  true,
+	models.ParticipantStatusWaitlisted: true,
+}
+
+// publicParticipationStatuses limita il filtro agli stati pubblici: "all" si
+// riduce a questi, mentre uno stato riservato chiesto esplicitamente è un errore
+func publicParticipationStatuses(r *http.Request, statuses []string) ([]string, error) {
+	all := strings.TrimSpace(r.URL.Query().Get("status")) == "all"
+
+	var allowed []string
+	for _, status := range statuses {
+		if publicParticipantStatuses[status] {
+			allowed = append(allowed, status)
+		} else if !all {
+			return nil, fmt.Errorf("Solo l'organizzatore può filtrare i partecipanti per stato %s", status)
+		}
+	}
+	return allowed, nil
+}
+
+// Dimensione delle pagine di preferiti e partecipazioni
+const (
+	defaultPostListLimit = 50
+	maxPostListLimit     = 100
+)
+
+// isLegacyPostList indica una richiesta dei vecchi client (senza limit, cursor
+// né expand), gli unici a cui servono ancora i riepiloghi in Posts
+func isLegacyPostList(query repositories.PostListQuery) bool {
+	return query.Limit == 0 && query.Cursor == nil && !query.Expand
+}
+
+// parsePostListQuery legge filtri, ordinamento e paginazione delle liste di
+// preferiti e partecipazioni; senza limit né cursor la lista non è paginata
+func parsePostListQuery(r *http.Request) (repositories.PostListQuery, error) {
+	params := r.URL.Query()
+	query := repositories.PostListQuery{
+		When:      strings.TrimSpace(params.Get("when")),
+		Sport:     strings.TrimSpace(params.Get("sport")),
+		Provincia: strings.TrimSpace(params.Get("provincia")),
+		Sort:      strings.TrimSpace(params.Get("sort")),
+		Expand:    params.Get("expand") == "post",
+	}
+
+	switch query.When {
+	case "":
+		query.When = repositories.PostListWhenAll
+	case repositories.PostListWhenAll, repositories.PostListWhenUpcoming, repositories.PostListWhenPast:
+	default:
+		return query, fmt.Errorf("Filtro when non valido: usa upcoming, past o all")
+	}
+
+	switch query.Sort {
+	case "":
+		query.Sort = repositories.PostListSortRecent
+	case repositories.PostListSortRecent, repositories.PostListSortOldest,
+		repositories.PostListSortDateAsc, repositories.PostListSortDateDesc:
+	default:
+		return query, fmt.Errorf("Ordinamento non valido: usa recent, oldest, date_asc o date_desc")
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 || value > maxPostListLimit {
+			return query, fmt.Errorf("Limite non valido: usa un numero da 1 a %d", maxPostListLimit)
+		}
+		query.Limit = value
+	}
+
+	if token := params.Get("cursor"); token != "" {
+		cursor, err := repositories.DecodePostListCursor(token)
+		if err != nil {
+			return query, err
+		}
+		query.Cursor = cursor
+		if query.Limit == 0 {
+			query.Limit = defaultPostListLimit
+		}
+	}
+	return query, nil
+}
+
+// handleParticipationError risponde con lo status HTTP adatto agli errori noti
+// del ciclo di vita della partecipazione
+func (h *EventHandler) handleParticipationError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repositories.ErrEventCancelled):
+		http.Error(w, "Evento annullato", http.StatusGone)
+	case errors.Is(err, repositories.ErrEventNotFound):
+		http.Error(w, "Evento non trovato", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrNotEventOrganizer),
+		errors.Is(err, repositories.ErrJoinNotAllowed),
+		errors.Is(err, repositories.ErrRemovedFromEvent):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrJoinRequestNotFound),
+		errors.Is(err, repositories.ErrParticipantNotFound),
+		errors.Is(err, repositories.ErrTeamsNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repositories.ErrCannotRemoveSelf),
+		errors.Is(err, repositories.ErrPairNotFriends):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrTeamsLocked),
+		errors.Is(err, repositories.ErrAlreadyParticipant),
+		errors.Is(err, repositories.ErrAlreadyWaitlisted),
+		errors.Is(err, repositories.ErrEventNotStarted),
+		errors.Is(err, repositories.ErrEventAlreadyStarted),
+		errors.Is(err, validation.ErrInvalidTransition):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
 // ========== ENDPOINT INVITI EVENTI ==========
 
 type EventInviteRequest struct {
@@ -354,8 +847,10 @@ type EventInviteRequest struct {
 }
 
 type EventInviteResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
+	Success   bool                `json:"success"`
+	Message   string              `json:"message,omitempty"`
+	Warning   string              `json:"warning,omitempty"`
+	Conflicts []models.Commitment `json:"conflicts,omitempty"`
 }
 
 // SendEventInviteHandler - Invia un invito per un evento a un amico con notifica
@@ -407,8 +902,36 @@ func (h *EventHandler) SendEventInviteHandler() http.HandlerFunc {
 
 		// Invia l'invito
 		if err := h.eventRepo.SendEventInvite(userID, friendUserID, req.PostID, req.Message); err != nil {
-			fmt.Printf("[EVENT_INVITE] Error while sending: %v\n", err)
-			http.Error(w, "Errore durante l'invio dell'invito", http.StatusInternalServerError)
+			var message string
+			switch {
+			case errors.Is(err, repositories.ErrEventNotFound):
+				http.Error(w, "Evento non trovato", http.StatusNotFound)
+				return
+			case errors.Is(err, repositories.ErrEventAlreadyStarted):
+				message = "L'evento è già iniziato"
+			case errors.Is(err, repositories.ErrInviteCooldown):
+				message = fmt.Sprintf("Hai già invitato questo utente di recente: potrai invitarlo di nuovo tra %d ore", int(repositories.InviteCooldown.Hours()))
+			case errors.Is(err, repositories.ErrInviteSelf):
+				message = "Non puoi invitare te stesso"
+			case errors.Is(err, repositories.ErrInviteNotFriend):
+				message = "Puoi invitare solo i tuoi amici"
+			case errors.Is(err, repositories.ErrInviteOrganizer):
+				message = "L'utente è l'organizzatore dell'evento"
+			case errors.Is(err, repositories.ErrInviteAlreadyParticipant):
+				message = "L'utente partecipa già all'evento"
+			case errors.Is(err, repositories.ErrInviteExcluded):
+				message = "L'utente è stato escluso dall'organizzatore e non può essere invitato"
+			default:
+				fmt.Printf("[EVENT_INVITE] Error while sending: %v\n", err)
+				http.Error(w, "Errore durante l'invio dell'invito", http.StatusInternalServerError)
+				return
+			}
+			response := EventInviteResponse{
+				Success: false,
+				Message: message,
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
 			return
 		}
 
@@ -419,10 +942,7 @@ func (h *EventHandler) SendEventInviteHandler() http.HandlerFunc {
 		}
 
 		// Ottieni il titolo dell'evento per la notifica
-		eventTitle := "Evento sportivo" //fallback
-		if postDetails, err := h.eventRepo.GetPostTitleByID(req.PostID); err == nil {
-			eventTitle = postDetails
-		}
+		eventTitle := h.eventTitle(req.PostID)
 
 		// Crea la notifica per l'invito evento
 		senderUsername := "Utente sconosciuto" //fallback
@@ -478,6 +998,11 @@ func (h *EventHandler) GetEventInvitesHandler() http.HandlerFunc {
 	}
 }
 
+// AcceptEventInviteRequest è il body, facoltativo, dell'accettazione di un invito
+type AcceptEventInviteRequest struct {
+	Force bool `json:"force"` // accetta anche se l'utente ha altre partite nello stesso orario
+}
+
 // AcceptEventInviteHandler - Accetta un invito per un evento e rimuove la notifica
 func (h *EventHandler) AcceptEventInviteHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -499,11 +1024,42 @@ func (h *EventHandler) AcceptEventInviteHandler() http.HandlerFunc {
 			return
 		}
 
+		// Come per l'iscrizione diretta, force nel body conferma nonostante le sovrapposizioni
+		var req AcceptEventInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+			return
+		}
+
 		// Ottieni i dettagli dell'invito prima di accettarlo per la notifica
 		postID, getPostIDErr := h.eventRepo.GetEventInvitePostID(inviteID)
 
 		// Accetta l'invito (questo iscriverà automaticamente l'utente all'evento)
-		if err := h.eventRepo.AcceptEventInvite(inviteID, userID); err != nil {
+		result, err := h.eventRepo.AcceptEventInvite(inviteID, userID, &repositories.ScheduleCheck{Schedule: h.scheduleCfg, Force: req.Force})
+		if err != nil {
+			var conflictErr *repositories.ScheduleConflictError
+			if errors.As(err, &conflictErr) {
+				response := EventInviteResponse{
+					Success:   false,
+					Message:   "Invito non accettato: l'orario si sovrappone ad altre partite",
+					Warning:   scheduleConflictWarning,
+					Conflicts: conflictErr.Conflicts,
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+			if errors.Is(err, repositories.ErrInviteExpired) {
+				if getPostIDErr == nil && h.notificationRepo != nil {
+					h.notificationRepo.DeleteNotificationByRelated(userID, models.NotificationTypeEventInvite, postID)
+				}
+				http.Error(w, "Invito scaduto: la partita è già iniziata", http.StatusGone)
+				return
+			}
+			if h.handleParticipationError(w, err) {
+				return
+			}
 			http.Error(w, "Errore durante l'accettazione dell'invito", http.StatusInternalServerError)
 			return
 		}
@@ -523,6 +1079,19 @@ func (h *EventHandler) AcceptEventInviteHandler() http.HandlerFunc {
 			Success: true,
 			Message: "Invito accettato! Sei ora iscritto all'evento",
 		}
+		switch result.Status {
+		case models.ParticipantStatusWaitlisted:
+			response.Message = fmt.Sprintf("Invito accettato! L'evento è al completo: sei in lista d'attesa (posizione %d)", result.WaitlistPosition)
+		case models.ParticipantStatusPending:
+			response.Message = "Invito accettato! La partecipazione deve essere approvata dall'organizzatore"
+			if result.PreviousStatus != models.ParticipantStatusPending {
+				h.notifyJoinRequest(int(postID), userID)
+			}
+		}
+		if len(result.Conflicts) > 0 {
+			response.Warning = "Attenzione: hai altre partite nello stesso orario"
+			response.Conflicts = result.Conflicts
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
@@ -622,4 +1191,81 @@ func (h *EventHandler) GetAvailableFriendsForInviteHandler() http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 	}
-}
\ No newline at end of file
+}
+
+// notifyWaitlistPromotions avvisa gli utenti passati dalla lista d'attesa agli iscritti
+func (h *EventHandler) notifyWaitlistPromotions(postID int, promotedUserIDs []int64) {
+	if h.notificationRepo == nil || len(promotedUserIDs) == 0 {
+		return
+	}
+
+	eventTitle := h.eventTitle(postID)
+
+	for _, promotedID := range promotedUserIDs {
+		if err := h.notificationRepo.CreateWaitlistPromotionNotification(promotedID, int64(postID), eventTitle); err != nil {
+			fmt.Printf("[EVENTS] WARNING: Error notifying waitlist promotion to user %d: %v\n", promotedID, err)
+		} else {
+			fmt.Printf("[EVENTS] User %d promoted from waitlist for event %d\n", promotedID, postID)
+		}
+	}
+}
+
+// notifyJoinRequest avvisa l'organizzatore di una nuova richiesta di partecipazione
+func (h *EventHandler) notifyJoinRequest(postID int, requesterID int64) {
+	if h.notificationRepo == nil {
+		return
+	}
+
+	organizerID, err := h.eventRepo.GetEventOrganizerID(postID)
+	if err != nil {
+		fmt.Printf("[EVENTS] WARNING: Error getting organizer of event %d: %v\n", postID, err)
+		return
+	}
+
+	requesterName := "Utente sconosciuto" //fallback
+	if requester, err := h.userRepo.GetUserProfile(fmt.Sprintf("%d", requesterID)); err == nil {
+		requesterName = requester.Username
+	}
+
+	message := fmt.Sprintf("%s ha chiesto di partecipare all'evento: %s", requesterName, h.eventTitle(postID))
+	if err := h.notificationRepo.CreateEventUpdateNotification(organizerID, int64(postID), &requesterID, "Nuova richiesta di partecipazione", message); err != nil {
+		fmt.Printf("[EVENTS] WARNING: Error notifying join request to organizer %d: %v\n", organizerID, err)
+	}
+}
+
+// notifyParticipationDecision avvisa il giocatore di una decisione dell'organizzatore
+func (h *EventHandler) notifyParticipationDecision(userID, organizerID int64, postID int, title, message string) {
+	if h.notificationRepo == nil {
+		return
+	}
+
+	if err := h.notificationRepo.CreateEventUpdateNotification(userID, int64(postID), &organizerID, title, message); err != nil {
+		fmt.Printf("[EVENTS] WARNING: Error notifying decision to user %d: %v\n", userID, err)
+	}
+}
+
+// postSummaries restituisce titolo, data e luogo dei post nello stesso ordine
+// degli ID, omettendo quelli non più esistenti; in caso di errore del catalogo
+// restituisce una lista vuota e la risposta contiene solo gli ID
+func (h *EventHandler) postSummaries(r *http.Request, postIDs []int) []*models.PostSummary {
+	summaries := []*models.PostSummary{}
+	if h.catalog == nil || len(postIDs) == 0 {
+		return summaries
+	}
+
+	posts, err := h.catalog.GetMany(r.Context(), postIDs)
+	if err != nil {
+		fmt.Printf("[EVENTS] WARNING: Error loading post details: %v\n", err)
+		return summaries
+	}
+	for _, postID := range postIDs {
+		if post, ok := posts[postID]; ok {
+			summaries = append(summaries, post)
+		}
+	}
+	return summaries
+}
+
+func (h *EventHandler) eventTitle(postID int) string {
+	return postcatalog.Title(h.catalog, postID)
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/postcatalog"
+)
+
+// Lunghezza massima del nome di una raccolta di preferiti
+const maxCollectionNameLength = 60
+
+// FavoriteCollectionRequest crea o aggiorna una raccolta di preferiti
+type FavoriteCollectionRequest struct {
+	Name       string `json:"name"`
+	Visibility string `json:"visibility"`
+}
+
+// CollectionItemsRequest aggiunge un post (PostID) o riordina la raccolta (PostIDs)
+type CollectionItemsRequest struct {
+	PostID  int   `json:"post_id"`
+	PostIDs []int `json:"post_ids"`
+}
+
+// FavoriteCollectionsHandler gestisce "/favorites/collections": GET elenca le
+// raccolte dell'utente (o, con ?user_id=, quelle che un amico condivide), POST ne crea una
+func (h *EventHandler) FavoriteCollectionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			ownerID := userID
+			if raw := r.URL.Query().Get("user_id"); raw != "" {
+				ownerID, err = strconv.ParseInt(raw, 10, 64)
+				if err != nil || ownerID <= 0 {
+					http.Error(w, "ID utente non valido", http.StatusBadRequest)
+					return
+				}
+			}
+
+			collections, err := h.eventRepo.GetFavoriteCollections(userID, ownerID)
+			if h.handleCollectionError(w, err) {
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":     true,
+				"collections": collections,
+				"count":       len(collections),
+			})
+		case http.MethodPost:
+			req, ok := decodeFavoriteCollectionRequest(w, r)
+			if !ok {
+				return
+			}
+
+			collection, err := h.eventRepo.CreateFavoriteCollection(userID, req.Name, req.Visibility)
+			if h.handleCollectionError(w, err) {
+				return
+			}
+
+			fmt.Printf("[COLLECTIONS] userID %d created collection %d (%s)\n", userID, collection.ID, collection.Visibility)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":    true,
+				"collection": collection,
+			})
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// FavoriteCollectionRoutesHandler gestisce le rotte sotto "/favorites/collections/":
+//   - GET "followed": raccolte seguite dall'utente
+//   - GET, PUT (nome e visibilità), DELETE "{id}"
+//   - POST (aggiunge un post) e PUT (riordina) "{id}/items"
+//   - DELETE "{id}/items/{postID}"
+//   - POST (segui) e DELETE (smetti di seguire) "{id}/follow"
+func (h *EventHandler) FavoriteCollectionRoutesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/favorites/collections/"), "/"), "/")
+		if len(parts) == 1 && parts[0] == "followed" {
+			h.followedCollections(w, r, userID)
+			return
+		}
+
+		collectionID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || collectionID <= 0 {
+			http.Error(w, "ID raccolta non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case len(parts) == 1:
+			h.collection(w, r, userID, collectionID)
+		case len(parts) == 2 && parts[1] == "items":
+			h.collectionItems(w, r, userID, collectionID)
+		case len(parts) == 3 && parts[1] == "items":
+			postID, err := strconv.Atoi(parts[2])
+			if err != nil || postID <= 0 {
+				http.Error(w, "ID post non valido", http.StatusBadRequest)
+				return
+			}
+			if r.Method != http.MethodDelete {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			if h.handleCollectionError(w, h.eventRepo.RemoveFavoriteCollectionItem(userID, collectionID, postID)) {
+				return
+			}
+			h.writeCollection(w, r, userID, collectionID)
+		case len(parts) == 2 && parts[1] == "follow":
+			h.collectionFollow(w, r, userID, collectionID)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// SharedFavoriteCollectionHandler gestisce "/favorites/shared/{token}": mostra a
+// chiunque abbia il link una raccolta condivisa, anche senza aver effettuato l'accesso
+func (h *EventHandler) SharedFavoriteCollectionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.Trim(strings.TrimPrefix(r.URL.Path, "/favorites/shared/"), "/")
+		if token == "" || strings.Contains(token, "/") {
+			http.Error(w, "Raccolta non trovata", http.StatusNotFound)
+			return
+		}
+
+		var viewerID int64
+		if userID, err := middleware.GetUserIDFromSession(r, h.sm); err == nil {
+			viewerID = userID
+		}
+
+		collection, err := h.eventRepo.GetSharedFavoriteCollection(viewerID, token)
+		if h.handleCollectionError(w, err) {
+			return
+		}
+		h.attachCollectionPosts(r.Context(), collection)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    true,
+			"collection": collection,
+		})
+	}
+}
+
+// collection gestisce GET, PUT e DELETE di una singola raccolta
+func (h *EventHandler) collection(w http.ResponseWriter, r *http.Request, userID, collectionID int64) {
+	switch r.Method {
+	case http.MethodGet:
+		h.writeCollection(w, r, userID, collectionID)
+	case http.MethodPut:
+		req, ok := decodeFavoriteCollectionRequest(w, r)
+		if !ok {
+			return
+		}
+
+		if _, err := h.eventRepo.UpdateFavoriteCollection(userID, collectionID, req.Name, req.Visibility); h.handleCollectionError(w, err) {
+			return
+		}
+		h.writeCollection(w, r, userID, collectionID)
+	case http.MethodDelete:
+		if h.handleCollectionError(w, h.eventRepo.DeleteFavoriteCollection(userID, collectionID)) {
+			return
+		}
+
+		fmt.Printf("[COLLECTIONS] userID %d deleted collection %d\n", userID, collectionID)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Raccolta eliminata",
+		})
+	default:
+		http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+	}
+}
+
+// collectionItems aggiunge un post alla raccolta (POST) o ne cambia l'ordine (PUT)
+func (h *EventHandler) collectionItems(w http.ResponseWriter, r *http.Request, userID, collectionID int64) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CollectionItemsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if req.PostID <= 0 {
+			http.Error(w, "ID post non valido", http.StatusBadRequest)
+			return
+		}
+		if h.catalog != nil {
+			if _, err := h.catalog.Get(r.Context(), req.PostID); errors.Is(err, postcatalog.ErrPostNotFound) {
+				http.Error(w, "Post non trovato", http.StatusNotFound)
+				return
+			} else if err != nil {
+				// Il catalogo non è raggiungibile: il post viene salvato comunque
+				fmt.Printf("[COLLECTIONS] WARNING: Error checking post %d: %v\n", req.PostID, err)
+			}
+		}
+		if h.handleCollectionError(w, h.eventRepo.AddFavoriteCollectionItem(userID, collectionID, req.PostID)) {
+			return
+		}
+	} else {
+		seen := make(map[int]bool, len(req.PostIDs))
+		for _, postID := range req.PostIDs {
+			if postID <= 0 || seen[postID] {
+				http.Error(w, "L'ordine contiene ID post non validi o duplicati", http.StatusBadRequest)
+				return
+			}
+			seen[postID] = true
+		}
+		if h.handleCollectionError(w, h.eventRepo.ReorderFavoriteCollection(userID, collectionID, req.PostIDs)) {
+			return
+		}
+	}
+
+	h.writeCollection(w, r, userID, collectionID)
+}
+
+// collectionFollow segue (POST) o smette di seguire (DELETE) la raccolta di un amico
+func (h *EventHandler) collectionFollow(w http.ResponseWriter, r *http.Request, userID, collectionID int64) {
+	switch r.Method {
+	case http.MethodPost:
+		if h.handleCollectionError(w, h.eventRepo.FollowFavoriteCollection(userID, collectionID)) {
+			return
+		}
+		fmt.Printf("[COLLECTIONS] userID %d followed collection %d\n", userID, collectionID)
+	case http.MethodDelete:
+		if h.handleCollectionError(w, h.eventRepo.UnfollowFavoriteCollection(userID, collectionID)) {
+			return
+		}
+	default:
+		http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"following": r.Method == http.MethodPost,
+	})
+}
+
+// followedCollections restituisce le raccolte che l'utente segue
+func (h *EventHandler) followedCollections(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		return
+	}
+
+	collections, err := h.eventRepo.GetFollowedFavoriteCollections(userID)
+	if h.handleCollectionError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"collections": collections,
+		"count":       len(collections),
+	})
+}
+
+// writeCollection risponde con la raccolta aggiornata e i dati dei suoi post
+func (h *EventHandler) writeCollection(w http.ResponseWriter, r *http.Request, userID, collectionID int64) {
+	collection, err := h.eventRepo.GetFavoriteCollection(userID, collectionID)
+	if h.handleCollectionError(w, err) {
+		return
+	}
+	h.attachCollectionPosts(r.Context(), collection)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"collection": collection,
+	})
+}
+
+// attachCollectionPosts aggiunge ai post della raccolta titolo, data e luogo;
+// se il catalogo non risponde la raccolta viene restituita con i soli ID
+func (h *EventHandler) attachCollectionPosts(ctx context.Context, collection *models.FavoriteCollection) {
+	if h.catalog == nil || len(collection.Items) == 0 {
+		return
+	}
+
+	postIDs := make([]int, 0, len(collection.Items))
+	for _, item := range collection.Items {
+		postIDs = append(postIDs, item.PostID)
+	}
+
+	posts, err := h.catalog.GetMany(ctx, postIDs)
+	if err != nil {
+		fmt.Printf("[COLLECTIONS] WARNING: Error loading posts for collection %d: %v\n", collection.ID, err)
+		return
+	}
+	for i := range collection.Items {
+		collection.Items[i].Post = posts[collection.Items[i].PostID]
+	}
+}
+
+// decodeFavoriteCollectionRequest legge e valida nome e visibilità della raccolta
+func decodeFavoriteCollectionRequest(w http.ResponseWriter, r *http.Request) (FavoriteCollectionRequest, bool) {
+	var req FavoriteCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return req, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxCollectionNameLength {
+		http.Error(w, fmt.Sprintf("Il nome della raccolta deve avere tra 1 e %d caratteri", maxCollectionNameLength), http.StatusBadRequest)
+		return req, false
+	}
+
+	switch req.Visibility {
+	case "":
+		req.Visibility = models.CollectionVisibilityPrivate
+	case models.CollectionVisibilityPrivate, models.CollectionVisibilityFriends, models.CollectionVisibilityLink:
+	default:
+		http.Error(w, "Visibilità non valida: usa private, friends o link", http.StatusBadRequest)
+		return req, false
+	}
+
+	return req, true
+}
+
+// handleCollectionError traduce gli errori delle raccolte in risposte HTTP; restituisce true se ha risposto
+func (h *EventHandler) handleCollectionError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repositories.ErrCollectionNotFound):
+		http.Error(w, "Raccolta non trovata", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrCollectionItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repositories.ErrCollectionExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrCannotFollowCollection):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrTooManyCollections), errors.Is(err, repositories.ErrDefaultCollection),
+		errors.Is(err, repositories.ErrCollectionOrderMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		fmt.Printf("[COLLECTIONS] Error: %v\n", err)
+		http.Error(w, "Errore interno del server", http.StatusInternalServerError)
+	}
+	return true
+}
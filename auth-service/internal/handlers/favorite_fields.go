@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+)
+
+// FavoriteFieldRequest salva un campo tra i preferiti o ne cambia gli avvisi;
+// senza alerts gli avvisi di nuove partite sono attivi
+type FavoriteFieldRequest struct {
+	FieldID int   `json:"field_id"`
+	Alerts  *bool `json:"alerts"`
+}
+
+// FavoriteFieldsHandler gestisce "/favorites/fields": GET elenca i campi
+// preferiti dell'utente, POST ne aggiunge uno
+func (h *EventHandler) FavoriteFieldsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			fields, err := h.eventRepo.GetFavoriteFields(userID)
+			if h.handleFavoriteFieldError(w, err) {
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"fields":  fields,
+				"count":   len(fields),
+			})
+		case http.MethodPost:
+			var req FavoriteFieldRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+			if req.FieldID <= 0 {
+				http.Error(w, "ID campo non valido", http.StatusBadRequest)
+				return
+			}
+
+			alerts := req.Alerts == nil || *req.Alerts
+			field, err := h.eventRepo.AddFavoriteField(userID, req.FieldID, alerts)
+			if h.handleFavoriteFieldError(w, err) {
+				return
+			}
+
+			fmt.Printf("[FAVORITE_FIELDS] userID %d saved field %d (alerts: %t)\n", userID, req.FieldID, alerts)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"field":   field,
+			})
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// FavoriteFieldRoutesHandler gestisce "/favorites/fields/{fieldID}": GET indica
+// se il campo è tra i preferiti, PUT attiva o disattiva gli avvisi, DELETE lo rimuove
+func (h *EventHandler) FavoriteFieldRoutesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		fieldID, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/favorites/fields/"), "/"))
+		if err != nil || fieldID <= 0 {
+			http.Error(w, "ID campo non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			field, err := h.eventRepo.GetFavoriteField(userID, fieldID)
+			if err != nil && !errors.Is(err, repositories.ErrFavoriteFieldNotFound) {
+				h.handleFavoriteFieldError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":     true,
+				"field_id":    fieldID,
+				"is_favorite": field != nil,
+				"alerts":      field != nil && field.Alerts,
+			})
+		case http.MethodPut:
+			var req FavoriteFieldRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+			if req.Alerts == nil {
+				http.Error(w, "Il campo alerts è obbligatorio", http.StatusBadRequest)
+				return
+			}
+
+			field, err := h.eventRepo.SetFavoriteFieldAlerts(userID, fieldID, *req.Alerts)
+			if h.handleFavoriteFieldError(w, err) {
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"field":   field,
+			})
+		case http.MethodDelete:
+			if h.handleFavoriteFieldError(w, h.eventRepo.RemoveFavoriteField(userID, fieldID)) {
+				return
+			}
+
+			fmt.Printf("[FAVORITE_FIELDS] userID %d removed field %d\n", userID, fieldID)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":     true,
+				"field_id":    fieldID,
+				"is_favorite": false,
+			})
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleFavoriteFieldError traduce gli errori dei campi preferiti in risposte HTTP; restituisce true se ha risposto
+func (h *EventHandler) handleFavoriteFieldError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repositories.ErrFieldNotFound):
+		http.Error(w, "Campo non trovato", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrFavoriteFieldNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		fmt.Printf("[FAVORITE_FIELDS] Error: %v\n", err)
+		http.Error(w, "Errore interno del server", http.StatusInternalServerError)
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+)
+
+// Lunghezza massima del nome di un gruppo di amici
+const maxFriendGroupNameLength = 60
+
+// FriendGroupRequest crea o aggiorna un gruppo di amici
+type FriendGroupRequest struct {
+	Name      string  `json:"name"`
+	MemberIDs []int64 `json:"member_ids"`
+}
+
+// FriendGroupsHandler gestisce "/friends/groups": GET elenca i gruppi dell'utente, POST ne crea uno
+func (h *FriendHandler) FriendGroupsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			groups, err := h.friendRepo.GetFriendGroups(userID)
+			if err != nil {
+				fmt.Printf("[FRIEND_GROUPS] Error loading groups for userID %d: %v\n", userID, err)
+				http.Error(w, "Errore durante il recupero dei gruppi", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"groups":  groups,
+				"count":   len(groups),
+			})
+		case http.MethodPost:
+			name, memberIDs, ok := decodeFriendGroupRequest(w, r)
+			if !ok {
+				return
+			}
+
+			group, err := h.friendRepo.CreateFriendGroup(userID, name, memberIDs)
+			if h.handleFriendGroupError(w, err) {
+				return
+			}
+
+			fmt.Printf("[FRIEND_GROUPS] userID %d created group %d with %d members\n", userID, group.ID, len(group.Members))
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"group":   group,
+			})
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// FriendGroupRoutesHandler gestisce "/friends/groups/{groupID}": GET, PUT (nome e membri) e DELETE
+func (h *FriendHandler) FriendGroupRoutesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		groupID, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/friends/groups/"), "/"), 10, 64)
+		if err != nil || groupID <= 0 {
+			http.Error(w, "ID gruppo non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			group, err := h.friendRepo.GetFriendGroup(userID, groupID)
+			if h.handleFriendGroupError(w, err) {
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"group":   group,
+			})
+		case http.MethodPut:
+			name, memberIDs, ok := decodeFriendGroupRequest(w, r)
+			if !ok {
+				return
+			}
+
+			group, err := h.friendRepo.UpdateFriendGroup(userID, groupID, name, memberIDs)
+			if h.handleFriendGroupError(w, err) {
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"group":   group,
+			})
+		case http.MethodDelete:
+			if h.handleFriendGroupError(w, h.friendRepo.DeleteFriendGroup(userID, groupID)) {
+				return
+			}
+
+			fmt.Printf("[FRIEND_GROUPS] userID %d deleted group %d\n", userID, groupID)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"message": "Gruppo eliminato",
+			})
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// decodeFriendGroupRequest legge e valida il corpo della richiesta, eliminando i membri duplicati
+func decodeFriendGroupRequest(w http.ResponseWriter, r *http.Request) (string, []int64, bool) {
+	var req FriendGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxFriendGroupNameLength {
+		http.Error(w, fmt.Sprintf("Il nome del gruppo deve avere tra 1 e %d caratteri", maxFriendGroupNameLength), http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	seen := make(map[int64]bool, len(req.MemberIDs))
+	memberIDs := make([]int64, 0, len(req.MemberIDs))
+	for _, memberID := range req.MemberIDs {
+		if memberID <= 0 {
+			http.Error(w, "ID membro non valido", http.StatusBadRequest)
+			return "", nil, false
+		}
+		if !seen[memberID] {
+			seen[memberID] = true
+			memberIDs = append(memberIDs, memberID)
+		}
+	}
+	if len(memberIDs) > repositories.MaxFriendGroupMembers {
+		http.Error(w, fmt.Sprintf("Un gruppo può avere al massimo %d membri", repositories.MaxFriendGroupMembers), http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	return name, memberIDs, true
+}
+
+// handleFriendGroupError traduce gli errori dei gruppi in risposte HTTP; restituisce true se ha risposto
+func (h *FriendHandler) handleFriendGroupError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repositories.ErrFriendGroupNotFound):
+		http.Error(w, "Gruppo di amici non trovato", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrFriendGroupExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrGroupMemberNotFriend), errors.Is(err, repositories.ErrTooManyFriendGroups):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		fmt.Printf("[FRIEND_GROUPS] Error: %v\n", err)
+		http.Error(w, "Errore interno del server", http.StatusInternalServerError)
+	}
+	return true
+}
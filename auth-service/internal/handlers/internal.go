@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// internalTokenHeader è l'header con cui gli altri servizi si autenticano
+const internalTokenHeader = "X-Internal-Token"
+
+// InternalHandler espone gli endpoint chiamati dal backend Python, non dai client
+type InternalHandler struct {
+	eventRepo *repositories.EventRepository
+	userRepo  *repositories.UserRepository
+	cfg       config.InternalConfig
+}
+
+func NewInternalHandler(eventRepo *repositories.EventRepository, userRepo *repositories.UserRepository, cfg config.InternalConfig) *InternalHandler {
+	if cfg.APIToken == "" {
+		fmt.Printf("[INTERNAL] WARNING: INTERNAL_API_TOKEN not set, internal endpoints are disabled\n")
+	}
+
+	return &InternalHandler{
+		eventRepo: eventRepo,
+		userRepo:  userRepo,
+		cfg:       cfg,
+	}
+}
+
+// CancelEventRequest è inviata dal backend Python quando un post viene eliminato
+// dal suo autore o, con source "admin", da un amministratore
+type CancelEventRequest struct {
+	PostID           int    `json:"post_id"`
+	AuthorEmail      string `json:"author_email"`
+	Titolo           string `json:"titolo"`
+	Reason           string `json:"reason"`
+	Source           string `json:"source"`             // "author" (default) o "admin"
+	CancelledByEmail string `json:"cancelled_by_email"` // se vuoto, l'autore
+}
+
+// authorized verifica il token condiviso; senza token configurato nessuna richiesta è accettata
+func (h *InternalHandler) authorized(r *http.Request) bool {
+	if h.cfg.APIToken == "" {
+		return false
+	}
+	token := r.Header.Get(internalTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.APIToken)) == 1
+}
+
+// CancelEventHandler annulla l'evento di un post eliminato dal suo autore o da
+// un amministratore. Può essere chiamato prima o dopo l'eliminazione del post
+// ed è idempotente.
+func (h *InternalHandler) CancelEventHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !h.authorized(r) {
+			http.Error(w, "Forbidden: token interno non valido", http.StatusForbidden)
+			return
+		}
+
+		var req CancelEventRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+			return
+		}
+		if req.PostID <= 0 {
+			http.Error(w, "Post ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		source := strings.TrimSpace(req.Source)
+		switch source {
+		case "":
+			source = models.CancellationSourceAuthor
+		case models.CancellationSourceAuthor, models.CancellationSourceAdmin:
+		default:
+			http.Error(w, "Origine dell'annullamento non valida", http.StatusBadRequest)
+			return
+		}
+
+		cancellation := &models.EventCancellation{
+			PostID: req.PostID,
+			Titolo: strings.TrimSpace(req.Titolo),
+			Reason: strings.TrimSpace(req.Reason),
+			Source: source,
+		}
+
+		cancelledBy := strings.TrimSpace(req.CancelledByEmail)
+		if cancelledBy == "" {
+			cancelledBy = req.AuthorEmail
+		}
+		if cancelledBy != "" {
+			if userID, err := h.userRepo.GetUserIDByEmail(cancelledBy); err == nil {
+				cancellation.CancelledBy = &userID
+			}
+		}
+
+		result, err := h.eventRepo.CancelEvent(cancellation)
+		if err != nil {
+			fmt.Printf("[INTERNAL] Error cancelling event %d: %v\n", req.PostID, err)
+			http.Error(w, "Errore durante l'annullamento dell'evento", http.StatusInternalServerError)
+			return
+		}
+
+		if !result.AlreadyCancelled {
+			fmt.Printf("[INTERNAL] Event %d cancelled (source: %s), %d users notified\n", req.PostID, source, result.NotifiedUsers)
+		}
+
+		response := map[string]interface{}{
+			"success":      true,
+			"cancellation": result,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// EventParticipantCountHandler restituisce il numero di iscritti confermati e in
+// lista d'attesa di un evento ("?post_id="), senza dati dei partecipanti
+func (h *InternalHandler) EventParticipantCountHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !h.authorized(r) {
+			http.Error(w, "Forbidden: token interno non valido", http.StatusForbidden)
+			return
+		}
+
+		postID, err := strconv.Atoi(r.URL.Query().Get("post_id"))
+		if err != nil || postID <= 0 {
+			http.Error(w, "Post ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		count, err := h.eventRepo.GetEventParticipantCount(postID)
+		if err != nil {
+			fmt.Printf("[INTERNAL] Error counting participants of event %d: %v\n", postID, err)
+			http.Error(w, "Errore durante il recupero dei partecipanti", http.StatusInternalServerError)
+			return
+		}
+
+		waitlistCount, err := h.eventRepo.GetEventWaitlistCount(postID)
+		if err != nil {
+			fmt.Printf("[INTERNAL] Error counting waitlist of event %d: %v\n", postID, err)
+			http.Error(w, "Errore durante il recupero dei partecipanti", http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"success":        true,
+			"post_id":        postID,
+			"count":          count,
+			"waitlist_count": waitlistCount,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
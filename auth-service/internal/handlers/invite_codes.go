@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+// Limite massimo di utilizzi impostabile su un singolo codice
+const maxInviteCodeUses = 1000
+
+type InviteCodeHandler struct {
+	inviteRepo *repositories.InviteCodeRepository
+	sm         *sessions.SessionManager
+}
+
+func NewInviteCodeHandler(inviteRepo *repositories.InviteCodeRepository, sm *sessions.SessionManager) *InviteCodeHandler {
+	return &InviteCodeHandler{
+		inviteRepo: inviteRepo,
+		sm:         sm,
+	}
+}
+
+// InviteCodeResponse rappresenta la risposta per le operazioni sui codici invito
+type InviteCodeResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// InviteCodesHandler gestisce elenco (GET) e creazione (POST) dei codici invito
+func (h *InviteCodeHandler) InviteCodesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.listInviteCodes(w)
+		case http.MethodPost:
+			h.createInviteCode(w, r)
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// InviteCodeDetailHandler gestisce /admin/invite-codes/{id} (DELETE disattiva)
+// e /admin/invite-codes/{id}/redemptions (GET utilizzi del codice)
+func (h *InviteCodeHandler) InviteCodeDetailHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/invite-codes/"), "/")
+		pathParts := strings.Split(path, "/")
+
+		codeID, err := strconv.ParseInt(pathParts[0], 10, 64)
+		if err != nil {
+			http.Error(w, "ID codice invito non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case len(pathParts) == 1 && r.Method == http.MethodDelete:
+			h.deactivateInviteCode(w, codeID)
+		case len(pathParts) == 2 && pathParts[1] == "redemptions" && r.Method == http.MethodGet:
+			h.listRedemptions(w, codeID)
+		default:
+			http.Error(w, "Endpoint non trovato", http.StatusNotFound)
+		}
+	}
+}
+
+func (h *InviteCodeHandler) listInviteCodes(w http.ResponseWriter) {
+	codes, err := h.inviteRepo.GetAllInviteCodes()
+	if err != nil {
+		fmt.Printf("[INVITE_CODES] Error retrieving invite codes: %v\n", err)
+		http.Error(w, "Errore nel recupero dei codici invito", http.StatusInternalServerError)
+		return
+	}
+
+	response := InviteCodeResponse{
+		Success: true,
+		Data:    codes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *InviteCodeHandler) createInviteCode(w http.ResponseWriter, r *http.Request) {
+	adminID, err := middleware.GetUserIDFromSession(r, h.sm)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateInviteCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+
+	// Validazioni
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 0 || req.MaxUses > maxInviteCodeUses {
+		http.Error(w, fmt.Sprintf("max_uses deve essere compreso tra 1 e %d", maxInviteCodeUses), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		http.Error(w, "expires_in_hours non valido", http.StatusBadRequest)
+		return
+	}
+	if req.Role != "" && req.Role != "user" && req.Role != "admin" {
+		http.Error(w, "role deve essere 'user' o 'admin'", http.StatusBadRequest)
+		return
+	}
+
+	code, err := h.inviteRepo.CreateInviteCode(&req, adminID)
+	if err != nil {
+		fmt.Printf("[INVITE_CODES] Error creating invite code: %v\n", err)
+		http.Error(w, "Errore nella creazione del codice invito", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[INVITE_CODES] Invite code %d created by admin %d (max_uses=%d)\n", code.ID, adminID, code.MaxUses)
+
+	response := InviteCodeResponse{
+		Success: true,
+		Message: "Codice invito creato con successo",
+		Data:    code,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *InviteCodeHandler) deactivateInviteCode(w http.ResponseWriter, codeID int64) {
+	err := h.inviteRepo.DeactivateInviteCode(codeID)
+	if errors.Is(err, repositories.ErrInviteCodeNotFound) {
+		http.Error(w, "Codice invito non trovato", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Printf("[INVITE_CODES] Error deactivating invite code %d: %v\n", codeID, err)
+		http.Error(w, "Errore nella disattivazione del codice invito", http.StatusInternalServerError)
+		return
+	}
+
+	response := InviteCodeResponse{
+		Success: true,
+		Message: "Codice invito disattivato",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *InviteCodeHandler) listRedemptions(w http.ResponseWriter, codeID int64) {
+	redemptions, err := h.inviteRepo.GetInviteCodeRedemptions(codeID)
+	if err != nil {
+		fmt.Printf("[INVITE_CODES] Error retrieving redemptions for code %d: %v\n", codeID, err)
+		http.Error(w, "Errore nel recupero degli utilizzi del codice", http.StatusInternalServerError)
+		return
+	}
+
+	response := InviteCodeResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"code_id":     codeID,
+			"redemptions": redemptions,
+			"count":       len(redemptions),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/postcatalog"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+// Numero massimo di variazioni di punteggio restituite per sport
+const ratingHistoryLimit = 20
+
+type ResultHandler struct {
+	resultRepo       *repositories.MatchResultRepository
+	eventRepo        *repositories.EventRepository
+	notificationRepo *repositories.NotificationRepository
+	catalog          postcatalog.PostCatalog
+	sm               *sessions.SessionManager
+}
+
+func NewResultHandler(resultRepo *repositories.MatchResultRepository, eventRepo *repositories.EventRepository, notificationRepo *repositories.NotificationRepository, catalog postcatalog.PostCatalog, sm *sessions.SessionManager) *ResultHandler {
+	return &ResultHandler{
+		resultRepo:       resultRepo,
+		eventRepo:        eventRepo,
+		notificationRepo: notificationRepo,
+		catalog:          catalog,
+		sm:               sm,
+	}
+}
+
+// RecordResultRequest è il risultato inviato dall'organizzatore; se le squadre
+// sono omesse vengono usate quelle bloccate per l'evento
+type RecordResultRequest struct {
+	ScoreA *int    `json:"score_a"`
+	ScoreB *int    `json:"score_b"`
+	TeamA  []int64 `json:"team_a"`
+	TeamB  []int64 `json:"team_b"`
+}
+
+// RecalculateRatingsRequest indica lo sport da ricalcolare (vuoto = tutti)
+type RecalculateRatingsRequest struct {
+	Sport string `json:"sport"`
+}
+
+// ResultRoutesHandler smista le richieste "/results/...":
+//
+//	GET  /results/events/{id}
+//	PUT  /results/events/{id}
+//	POST /results/events/{id}/confirm
+//	POST /results/events/{id}/dispute
+//	GET  /results/leaderboard?sport=Calcio&provincia=Milano&scope=friends
+//	GET  /results/users/{userID}
+func (h *ResultHandler) ResultRoutesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/results/"), "/"), "/")
+
+		switch {
+		case parts[0] == "leaderboard" && len(parts) == 1:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.getLeaderboard(w, r, userID)
+
+		case parts[0] == "users" && len(parts) == 2:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			targetUserID, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				http.Error(w, "User ID non valido", http.StatusBadRequest)
+				return
+			}
+			h.getUserRatings(w, targetUserID)
+
+		case parts[0] == "events" && (len(parts) == 2 || len(parts) == 3):
+			postID, err := strconv.Atoi(parts[1])
+			if err != nil {
+				http.Error(w, "Post ID non valido", http.StatusBadRequest)
+				return
+			}
+
+			if len(parts) == 2 {
+				switch r.Method {
+				case http.MethodGet:
+					h.getResult(w, userID, postID)
+				case http.MethodPut:
+					h.recordResult(w, r, userID, postID)
+				default:
+					http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				}
+				return
+			}
+
+			if parts[2] != "confirm" && parts[2] != "dispute" {
+				http.NotFound(w, r)
+				return
+			}
+			if r.Method != http.MethodPost {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.voteResult(w, userID, postID, parts[2] == "confirm")
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// RecalculateRatingsHandler rigenera punteggi e storico di uno sport (o di
+// tutti) a partire dai risultati confermati; riservato agli amministratori
+func (h *ResultHandler) RecalculateRatingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req RecalculateRatingsRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+		}
+
+		sports := []string{req.Sport}
+		if req.Sport == "" {
+			var err error
+			sports, err = h.resultRepo.GetRatedSports()
+			if err != nil {
+				fmt.Printf("[RESULTS] Error getting rated sports: %v\n", err)
+				http.Error(w, "Errore durante il ricalcolo dei punteggi", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		replayed := make(map[string]int, len(sports))
+		for _, sport := range sports {
+			matches, err := h.resultRepo.RecalculateRatings(sport)
+			if err != nil {
+				fmt.Printf("[RESULTS] Error recalculating ratings for %s: %v\n", sport, err)
+				http.Error(w, "Errore durante il ricalcolo dei punteggi", http.StatusInternalServerError)
+				return
+			}
+			replayed[sport] = matches
+			fmt.Printf("[RESULTS] Ratings for %s recalculated from %d matches\n", sport, matches)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"matches": replayed,
+		})
+	}
+}
+
+func (h *ResultHandler) getResult(w http.ResponseWriter, userID int64, postID int) {
+	result, err := h.resultRepo.GetResult(postID, userID)
+	if err != nil {
+		if h.handleResultError(w, err) {
+			return
+		}
+		fmt.Printf("[RESULTS] Error getting result of event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante il recupero del risultato", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *ResultHandler) recordResult(w http.ResponseWriter, r *http.Request, organizerID int64, postID int) {
+	var req RecordResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+	if req.ScoreA == nil || req.ScoreB == nil || *req.ScoreA < 0 || *req.ScoreB < 0 {
+		http.Error(w, "score_a e score_b sono obbligatori e non possono essere negativi", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.TeamA) == 0 && len(req.TeamB) == 0 {
+		locked, err := h.eventRepo.GetLockedTeams(postID)
+		if errors.Is(err, repositories.ErrTeamsNotFound) || (err == nil && len(locked.Teams) != 2) {
+			http.Error(w, "Indica team_a e team_b oppure blocca prima due squadre", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			fmt.Printf("[RESULTS] Error getting locked teams of event %d: %v\n", postID, err)
+			http.Error(w, "Errore durante il recupero delle squadre", http.StatusInternalServerError)
+			return
+		}
+		req.TeamA = teamPlayerIDs(locked.Teams[0])
+		req.TeamB = teamPlayerIDs(locked.Teams[1])
+	}
+
+	result, err := h.resultRepo.RecordResult(organizerID, postID, *req.ScoreA, *req.ScoreB, req.TeamA, req.TeamB)
+	if err != nil {
+		if h.handleResultError(w, err) {
+			return
+		}
+		fmt.Printf("[RESULTS] Error recording result of event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante la registrazione del risultato", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[RESULTS] Organizer %d recorded result %d-%d for event %d\n", organizerID, result.ScoreA, result.ScoreB, postID)
+
+	if result.Status == models.MatchResultStatusConfirmed {
+		h.notifyPlayers(result, organizerID, "Risultato confermato",
+			fmt.Sprintf("%s: il risultato %d-%d è stato confermato", h.eventTitle(postID), result.ScoreA, result.ScoreB))
+	} else {
+		h.notifyPlayers(result, organizerID, "Risultato da confermare",
+			fmt.Sprintf("%s: %d-%d. Conferma o contesta il risultato", h.eventTitle(postID), result.ScoreA, result.ScoreB))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *ResultHandler) voteResult(w http.ResponseWriter, userID int64, postID int, agree bool) {
+	result, err := h.resultRepo.VoteResult(userID, postID, agree)
+	if err != nil {
+		if h.handleResultError(w, err) {
+			return
+		}
+		fmt.Printf("[RESULTS] Error voting result of event %d by user %d: %v\n", postID, userID, err)
+		http.Error(w, "Errore durante il voto del risultato", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[RESULTS] User %d voted result of event %d (agree=%t, status=%s)\n", userID, postID, agree, result.Status)
+
+	if result.Status == models.MatchResultStatusConfirmed {
+		h.notifyPlayers(result, userID, "Risultato confermato",
+			fmt.Sprintf("%s: il risultato %d-%d è stato confermato", h.eventTitle(postID), result.ScoreA, result.ScoreB))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *ResultHandler) getLeaderboard(w http.ResponseWriter, r *http.Request, userID int64) {
+	query := r.URL.Query()
+	sport := strings.TrimSpace(query.Get("sport"))
+	if sport == "" {
+		http.Error(w, "Il parametro sport è obbligatorio", http.StatusBadRequest)
+		return
+	}
+	provincia := strings.TrimSpace(query.Get("provincia"))
+
+	var friendsOf int64
+	switch query.Get("scope") {
+	case "", "all":
+	case "friends":
+		friendsOf = userID
+	default:
+		http.Error(w, "scope deve essere all o friends", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if value, err := strconv.Atoi(query.Get("limit")); err == nil && value > 0 && value <= 100 {
+		limit = value
+	}
+	offset := 0
+	if value, err := strconv.Atoi(query.Get("offset")); err == nil && value >= 0 {
+		offset = value
+	}
+
+	entries, err := h.resultRepo.GetLeaderboard(sport, provincia, friendsOf, limit, offset)
+	if err != nil {
+		fmt.Printf("[RESULTS] Error getting leaderboard for %s: %v\n", sport, err)
+		http.Error(w, "Errore durante il recupero della classifica", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.LeaderboardEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"sport":     sport,
+		"provincia": provincia,
+		"entries":   entries,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+func (h *ResultHandler) getUserRatings(w http.ResponseWriter, userID int64) {
+	ratings, err := h.resultRepo.GetUserRatings(userID, ratingHistoryLimit)
+	if err != nil {
+		fmt.Printf("[RESULTS] Error getting ratings of user %d: %v\n", userID, err)
+		http.Error(w, "Errore durante il recupero dei punteggi", http.StatusInternalServerError)
+		return
+	}
+	if ratings == nil {
+		ratings = []models.SportRating{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user_id": userID,
+		"ratings": ratings,
+	})
+}
+
+// notifyPlayers avvisa i giocatori del risultato, escluso chi ha fatto l'azione
+func (h *ResultHandler) notifyPlayers(result *models.MatchResult, actorID int64, title, message string) {
+	for _, team := range [][]models.MatchResultPlayer{result.TeamA, result.TeamB} {
+		for _, player := range team {
+			if player.UserID == actorID {
+				continue
+			}
+			if err := h.notificationRepo.CreateEventUpdateNotification(player.UserID, int64(result.PostID), &actorID, title, message); err != nil {
+				fmt.Printf("[RESULTS] WARNING: Error notifying user %d about result of event %d: %v\n", player.UserID, result.PostID, err)
+			}
+		}
+	}
+}
+
+func (h *ResultHandler) eventTitle(postID int) string {
+	return postcatalog.Title(h.catalog, postID)
+}
+
+// handleResultError mappa gli errori dei risultati sui codici HTTP; restituisce true se ha risposto
+func (h *ResultHandler) handleResultError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repositories.ErrEventNotFound), errors.Is(err, repositories.ErrResultNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repositories.ErrNotEventOrganizer), errors.Is(err, repositories.ErrNotResultPlayer):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrInvalidResultTeams):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrEventNotStarted), errors.Is(err, repositories.ErrResultConfirmed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
+func teamPlayerIDs(team models.Team) []int64 {
+	ids := make([]int64, 0, len(team.Players))
+	for _, player := range team.Players {
+		ids = append(ids, player.UserID)
+	}
+	return ids
+}
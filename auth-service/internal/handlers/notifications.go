@@ -1,28 +1,43 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"trovagiocatoriAuth/internal/database/repositories"
 	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/postcatalog"
 
 	"trovagiocatoriAuth/internal/sessions"
 )
 
 type NotificationHandler struct {
 	notificationRepo *repositories.NotificationRepository
+	catalog          postcatalog.PostCatalog
 	sm               *sessions.SessionManager
 }
 
-func NewNotificationHandler(notificationRepo *repositories.NotificationRepository, sm *sessions.SessionManager) *NotificationHandler {
+func NewNotificationHandler(notificationRepo *repositories.NotificationRepository, catalog postcatalog.PostCatalog, sm *sessions.SessionManager) *NotificationHandler {
 	return &NotificationHandler{
 		notificationRepo: notificationRepo,
+		catalog:          catalog,
 		sm:               sm,
 	}
 }
 
+// Tipi di notifica il cui related_id è l'ID di un post
+var postNotificationTypes = map[models.NotificationType]bool{
+	models.NotificationTypeEventInvite:      true,
+	models.NotificationTypeEventUpdate:      true,
+	models.NotificationTypeMatchReminder:    true,
+	models.NotificationTypeSeriesOccurrence: true,
+	models.NotificationTypeFieldAlert:       true,
+}
+
 // NotificationResponse rappresenta la risposta per le operazioni sulle notifiche
 type NotificationResponse struct {
 	Success bool        `json:"success"`
@@ -64,6 +79,8 @@ func (h *NotificationHandler) GetNotificationsHandler() http.HandlerFunc {
 			return
 		}
 
+		h.attachPosts(r.Context(), notifications)
+
 		// Risposta
 		response := NotificationResponse{
 			Success: true,
@@ -222,3 +239,83 @@ func (h *NotificationHandler) DeleteNotificationHandler() http.HandlerFunc {
 	}
 }
 
+// UpdatePreferencesRequest contiene le preferenze da modificare (i campi assenti restano invariati)
+type UpdatePreferencesRequest struct {
+	MatchReminders *bool `json:"match_reminders"`
+}
+
+// PreferencesHandler restituisce (GET) o aggiorna (PUT) le preferenze di notifica dell'utente
+func (h *NotificationHandler) PreferencesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		preferences, err := h.notificationRepo.GetPreferences(userID)
+		if err != nil {
+			http.Error(w, "Errore durante il recupero delle preferenze", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var req UpdatePreferencesRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+
+			if req.MatchReminders != nil {
+				preferences.MatchReminders = *req.MatchReminders
+			}
+
+			if err := h.notificationRepo.UpdatePreferences(userID, preferences); err != nil {
+				http.Error(w, "Errore durante l'aggiornamento delle preferenze", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		response := NotificationResponse{
+			Success: true,
+			Data:    preferences,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// attachPosts aggiunge alle notifiche sulle partite titolo, data e luogo del post;
+// se il catalogo non risponde le notifiche vengono restituite senza
+func (h *NotificationHandler) attachPosts(ctx context.Context, notifications []models.Notification) {
+	if h.catalog == nil {
+		return
+	}
+
+	var postIDs []int
+	for _, notification := range notifications {
+		if postNotificationTypes[notification.Type] && notification.RelatedID != nil {
+			postIDs = append(postIDs, int(*notification.RelatedID))
+		}
+	}
+	if len(postIDs) == 0 {
+		return
+	}
+
+	posts, err := h.catalog.GetMany(ctx, postIDs)
+	if err != nil {
+		fmt.Printf("[NOTIFICATIONS] WARNING: Error loading posts for notifications: %v\n", err)
+		return
+	}
+	for i := range notifications {
+		if postNotificationTypes[notifications[i].Type] && notifications[i].RelatedID != nil {
+			notifications[i].Post = posts[int(*notifications[i].RelatedID)]
+		}
+	}
+}
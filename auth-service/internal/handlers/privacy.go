@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/privacy"
+)
+
+// UpdatePrivacyRequest contiene i livelli di visibilità da modificare
+// ("full", "basic" o "hidden"; i campi assenti restano invariati)
+type UpdatePrivacyRequest struct {
+	Strangers      *string `json:"strangers"`
+	Friends        *string `json:"friends"`
+	CoParticipants *string `json:"co_participants"`
+}
+
+// PrivacySettingsHandler restituisce (GET) o aggiorna (PUT) cosa vedono
+// dell'utente, nelle liste dei partecipanti, sconosciuti, amici e co-partecipanti
+func (h *AuthHandler) PrivacySettingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		settings, err := h.userRepo.GetPrivacySettings(userID)
+		if err != nil {
+			fmt.Printf("[PRIVACY] Error loading settings for userID %d: %v\n", userID, err)
+			http.Error(w, "Errore durante il recupero delle impostazioni di privacy", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var req UpdatePrivacyRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+
+			for _, update := range []struct {
+				value  *string
+				target *string
+			}{
+				{req.Strangers, &settings.Strangers},
+				{req.Friends, &settings.Friends},
+				{req.CoParticipants, &settings.CoParticipants},
+			} {
+				if update.value == nil {
+					continue
+				}
+				if !privacy.ValidVisibility(*update.value) {
+					http.Error(w, "Livello di visibilità non valido: usa full, basic o hidden", http.StatusBadRequest)
+					return
+				}
+				*update.target = *update.value
+			}
+
+			if err := h.userRepo.UpdatePrivacySettings(userID, settings); err != nil {
+				fmt.Printf("[PRIVACY] Error saving settings for userID %d: %v\n", userID, err)
+				http.Error(w, "Errore durante l'aggiornamento delle impostazioni di privacy", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"settings": settings,
+		})
+	}
+}
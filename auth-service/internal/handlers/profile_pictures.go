@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"trovagiocatoriAuth/internal/media"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/storage"
+	"trovagiocatoriAuth/internal/validation"
+)
+
+// Prefisso delle chiavi delle immagini profilo nel BlobStore
+const profilePicturePrefix = "profile_pictures/"
+
+// errProfilePictureStorage distingue gli errori del BlobStore da quelli
+// dell'operazione che collega l'immagine all'utente
+var errProfilePictureStorage = errors.New("errore nel salvataggio dell'immagine profilo")
+
+// Durata di default e massima degli URL firmati
+const (
+	defaultSignedURLTTL = 15 * time.Minute
+	maxSignedURLTTL     = 24 * time.Hour
+)
+
+// Cache-Control delle immagini profilo: i nomi content-addressed non cambiano mai,
+// mentre le immagini legacy e gli avatar generati vanno rivalidati
+const (
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	legacyCacheControl    = "public, max-age=3600, must-revalidate"
+	avatarCacheControl    = "public, max-age=86400, must-revalidate"
+)
+
+// ServeProfilePicture serve l'immagine del profilo nella dimensione richiesta
+// con ?size=thumb|medium|original (default original). Gli utenti senza immagine
+// hanno un nome "avatar_<id>.png", generato al volo (?style=initials|identicon).
+func (h *AuthHandler) ServeProfilePicture() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// path.Base impedisce di uscire dal prefisso delle immagini profilo
+		filename := path.Base(r.URL.Path[len("/images/"):])
+		if filename == "." || filename == "/" || strings.HasPrefix(filename, ".") {
+			http.Error(w, "Immagine non trovata", http.StatusNotFound)
+			return
+		}
+
+		variant, ok := media.ParseVariant(r.URL.Query().Get("size"))
+		if !ok {
+			http.Error(w, "Parametro size non valido: usa thumb, medium o original", http.StatusBadRequest)
+			return
+		}
+
+		if userID, ok := media.ParseAvatarFileName(filename); ok {
+			h.serveGeneratedAvatar(w, r, userID, variant)
+			return
+		}
+
+		data, info, err := storage.ReadAll(r.Context(), h.blobStore, profilePicturePrefix+media.VariantFileName(filename, variant))
+		if errors.Is(err, storage.ErrBlobNotFound) && variant != media.VariantOriginal {
+			// Le immagini caricate prima della pipeline non hanno varianti ridimensionate
+			data, info, err = storage.ReadAll(r.Context(), h.blobStore, profilePicturePrefix+filename)
+		}
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			http.Error(w, "Immagine non trovata", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			fmt.Printf("[IMAGES] Error reading %s: %v\n", filename, err)
+			http.Error(w, "Errore nel recupero dell'immagine", http.StatusInternalServerError)
+			return
+		}
+
+		if media.IsContentAddressed(filename) {
+			w.Header().Set("Cache-Control", immutableCacheControl)
+		} else {
+			w.Header().Set("Cache-Control", legacyCacheControl)
+		}
+		serveBlob(w, r, filename, data, info)
+	}
+}
+
+// serveGeneratedAvatar genera l'avatar deterministico di un utente senza immagine
+// profilo. L'URL è pubblico: valgono le impostazioni di privacy verso gli
+// sconosciuti, quindi gli utenti nascosti, disattivati o con un'immagine
+// caricata risultano inesistenti e nome e cognome compaiono solo nei profili pubblici.
+func (h *AuthHandler) serveGeneratedAvatar(w http.ResponseWriter, r *http.Request, userID int64, variant media.Variant) {
+	profile, err := h.userRepo.GetAvatarProfile(userID)
+	if err != nil || profile.Strangers == models.ProfileVisibilityHidden {
+		if err != nil && err != sql.ErrNoRows {
+			fmt.Printf("[IMAGES] Error reading avatar profile of user %d: %v\n", userID, err)
+		}
+		http.Error(w, "Immagine non trovata", http.StatusNotFound)
+		return
+	}
+
+	initials := media.Initials("", "", profile.Username)
+	if profile.Strangers == models.ProfileVisibilityFull {
+		initials = media.Initials(profile.Nome, profile.Cognome, profile.Username)
+	}
+
+	style := r.URL.Query().Get("style")
+	data, err := media.GenerateAvatar(style, profile.Username, initials, media.AvatarSide(variant))
+	if err != nil {
+		http.Error(w, "Parametro style non valido: usa initials o identicon", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", avatarCacheControl)
+	serveBlob(w, r, media.AvatarFileName(userID), data, &storage.BlobInfo{ContentType: "image/png"})
+}
+
+// ProfilePictureHandler gestisce l'immagine profilo dell'utente autenticato:
+// PUT (multipart, campo "profile_picture") la sostituisce, DELETE la rimuove
+func (h *AuthHandler) ProfilePictureHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			h.updateProfilePicture(w, r, userID)
+		case http.MethodDelete:
+			h.deleteProfilePicture(w, r, userID)
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (h *AuthHandler) updateProfilePicture(w http.ResponseWriter, r *http.Request, userID int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadBytes+(1<<20))
+	if err := r.ParseMultipartForm(5 << 20); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("profile_picture")
+	if err != nil {
+		fieldErr := validation.FieldError{Field: "profile_picture", Code: validation.CodeRequired, Message: "Immagine profilo obbligatoria"}
+		h.respondWithRegisterError(w, http.StatusBadRequest, validation.CodeRequired, fieldErr.Message, []validation.FieldError{fieldErr})
+		return
+	}
+	defer file.Close()
+
+	processed, err := media.ProcessProfilePicture(file)
+	if err != nil {
+		if h.handleImageError(w, err) {
+			return
+		}
+		fmt.Printf("[IMAGES] Error processing profile picture for user %d: %v\n", userID, err)
+		h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nel salvataggio dell'immagine", nil)
+		return
+	}
+
+	filename := processed.FileName(media.VariantOriginal)
+	var previous string
+	err = h.storeProfilePicture(r, processed, func() error {
+		var err error
+		previous, err = h.userRepo.UpdateProfilePicture(userID, filename)
+		return err
+	})
+	if errors.Is(err, errProfilePictureStorage) {
+		fmt.Printf("[IMAGES] Error saving profile picture for user %d: %v\n", userID, err)
+		h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nel salvataggio dell'immagine", nil)
+		return
+	}
+	if err != nil {
+		fmt.Printf("[IMAGES] Error updating profile picture for user %d: %v\n", userID, err)
+		h.respondWithRegisterError(w, http.StatusInternalServerError, "internal_error", "Errore nell'aggiornamento dell'immagine profilo", nil)
+		return
+	}
+	h.deleteUnusedProfilePicture(r, previous)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"message":         "Immagine profilo aggiornata con successo",
+		"profile_picture": filename,
+	})
+}
+
+func (h *AuthHandler) deleteProfilePicture(w http.ResponseWriter, r *http.Request, userID int64) {
+	previous, err := h.userRepo.UpdateProfilePicture(userID, "")
+	if err != nil {
+		fmt.Printf("[IMAGES] Error removing profile picture for user %d: %v\n", userID, err)
+		http.Error(w, "Errore nella rimozione dell'immagine profilo", http.StatusInternalServerError)
+		return
+	}
+	h.deleteUnusedProfilePicture(r, previous)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"message":         "Immagine profilo rimossa",
+		"profile_picture": media.AvatarFileName(userID),
+	})
+}
+
+// deleteUnusedProfilePicture elimina dallo storage un'immagine (con le sue varianti)
+// che non è più usata da nessun utente. Gli errori vengono solo loggati: l'immagine
+// orfana non compromette l'aggiornamento del profilo.
+func (h *AuthHandler) deleteUnusedProfilePicture(r *http.Request, filename string) {
+	if filename == "" {
+		return
+	}
+
+	unlock, err := h.userRepo.LockProfilePicture(filename)
+	if err != nil {
+		fmt.Printf("[IMAGES] Error locking %s: %v\n", filename, err)
+		return
+	}
+	defer unlock()
+
+	h.deleteProfilePictureIfUnused(r, filename)
+}
+
+// deleteProfilePictureIfUnused elimina le varianti di un'immagine senza più
+// riferimenti; il chiamante deve tenere il lock sul nome dell'immagine
+func (h *AuthHandler) deleteProfilePictureIfUnused(r *http.Request, filename string) {
+	count, err := h.userRepo.CountProfilePictureReferences(filename)
+	if err != nil || count > 0 {
+		return
+	}
+
+	for _, variant := range media.Variants {
+		key := profilePicturePrefix + media.VariantFileName(filename, variant)
+		if err := h.blobStore.Delete(r.Context(), key); err != nil {
+			fmt.Printf("[IMAGES] Error deleting %s: %v\n", key, err)
+		}
+	}
+}
+
+// ProfilePictureURLHandler restituisce un URL firmato e temporaneo per
+// l'immagine profilo dell'utente autenticato (?size=...&ttl=secondi)
+func (h *AuthHandler) ProfilePictureURLHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		variant, ok := media.ParseVariant(r.URL.Query().Get("size"))
+		if !ok {
+			http.Error(w, "Parametro size non valido: usa thumb, medium o original", http.StatusBadRequest)
+			return
+		}
+
+		ttl := defaultSignedURLTTL
+		if ttlStr := r.URL.Query().Get("ttl"); ttlStr != "" {
+			seconds, err := strconv.Atoi(ttlStr)
+			if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxSignedURLTTL {
+				http.Error(w, "Parametro ttl non valido", http.StatusBadRequest)
+				return
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+
+		user, err := h.userRepo.GetUserProfile(fmt.Sprintf("%d", userID))
+		if err != nil {
+			http.Error(w, "Utente non trovato", http.StatusNotFound)
+			return
+		}
+		if !user.HasProfilePicture {
+			http.Error(w, "Nessuna immagine profilo impostata", http.StatusNotFound)
+			return
+		}
+
+		key := profilePicturePrefix + media.VariantFileName(user.ProfilePic, variant)
+		if _, err := h.blobStore.Stat(r.Context(), key); errors.Is(err, storage.ErrBlobNotFound) {
+			key = profilePicturePrefix + user.ProfilePic
+		}
+
+		signedURL, err := h.blobStore.SignedURL(key, ttl)
+		if err != nil {
+			fmt.Printf("[IMAGES] Error signing URL for %s: %v\n", key, err)
+			http.Error(w, "Errore nella generazione dell'URL", http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"success":    true,
+			"url":        signedURL,
+			"expires_at": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ServeSignedMediaHandler serve gli URL firmati generati dallo storage locale
+func (h *AuthHandler) ServeSignedMediaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		verifier, ok := h.blobStore.(storage.SignedURLVerifier)
+		if !ok {
+			http.Error(w, "Endpoint non disponibile con lo storage configurato", http.StatusNotFound)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, storage.SignedMediaPath)
+		query := r.URL.Query()
+		if err := verifier.VerifySignedURL(key, query.Get("expires"), query.Get("signature")); err != nil {
+			status := http.StatusForbidden
+			if errors.Is(err, storage.ErrURLExpired) {
+				status = http.StatusGone
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		data, info, err := storage.ReadAll(r.Context(), h.blobStore, key)
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			http.Error(w, "Oggetto non trovato", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			fmt.Printf("[MEDIA] Error reading %s: %v\n", key, err)
+			http.Error(w, "Errore nel recupero del file", http.StatusInternalServerError)
+			return
+		}
+
+		// Gli URL firmati non devono finire in cache condivise
+		w.Header().Set("Cache-Control", "private, no-store")
+		serveBlob(w, r, path.Base(key), data, info)
+	}
+}
+
+// serveBlob scrive il contenuto di un oggetto gestendo Range e richieste condizionali.
+// L'ETag forte deriva dal contenuto, così è identico su tutte le repliche e backend
+// e http.ServeContent può rispondere 304 a If-None-Match.
+func serveBlob(w http.ResponseWriter, r *http.Request, name string, data []byte, info *storage.BlobInfo) {
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.LastModified, bytes.NewReader(data))
+}
+
+// storeProfilePicture salva le varianti dell'immagine ed esegue attach (che la
+// collega all'utente) tenendo il lock sul nome dell'immagine. Se attach fallisce
+// i file salvati e non usati da nessun altro utente vengono eliminati; gli errori
+// del BlobStore sono restituiti come errProfilePictureStorage.
+func (h *AuthHandler) storeProfilePicture(r *http.Request, processed *media.ProcessedImage, attach func() error) error {
+	filename := processed.FileName(media.VariantOriginal)
+	unlock, err := h.userRepo.LockProfilePicture(filename)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errProfilePictureStorage, err)
+	}
+	defer unlock()
+
+	if err := h.saveProfilePicture(r, processed); err != nil {
+		h.deleteProfilePictureIfUnused(r, filename)
+		return fmt.Errorf("%w: %v", errProfilePictureStorage, err)
+	}
+	if err := attach(); err != nil {
+		h.deleteProfilePictureIfUnused(r, filename)
+		return err
+	}
+	return nil
+}
+
+// saveProfilePicture salva nel BlobStore tutte le varianti dell'immagine.
+// I nomi sono content-addressed: se un oggetto esiste già non viene riscritto.
+func (h *AuthHandler) saveProfilePicture(r *http.Request, processed *media.ProcessedImage) error {
+	for _, variant := range media.Variants {
+		key := profilePicturePrefix + processed.FileName(variant)
+		if _, err := h.blobStore.Stat(r.Context(), key); err == nil {
+			continue
+		} else if !errors.Is(err, storage.ErrBlobNotFound) {
+			return err
+		}
+
+		if err := h.blobStore.Put(r.Context(), key, processed.Variants[variant], processed.ContentType); err != nil {
+			return fmt.Errorf("error writing %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// handleImageError risponde 400 con un codice machine-readable se l'upload non è un'immagine valida
+func (h *AuthHandler) handleImageError(w http.ResponseWriter, err error) bool {
+	var code string
+	switch {
+	case errors.Is(err, media.ErrImageTooLarge):
+		code = "image_too_large"
+	case errors.Is(err, media.ErrImageUnsupportedFormat):
+		code = "image_unsupported_format"
+	case errors.Is(err, media.ErrImageCorrupted):
+		code = "image_invalid"
+	case errors.Is(err, media.ErrImageDimensions):
+		code = "image_dimensions"
+	case errors.Is(err, media.ErrImageAnimated):
+		code = "image_animated"
+	default:
+		return false
+	}
+
+	fieldErr := validation.FieldError{Field: "profile_picture", Code: code, Message: err.Error()}
+	h.respondWithRegisterError(w, http.StatusBadRequest, code, err.Error(), []validation.FieldError{fieldErr})
+	return true
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+type RatingHandler struct {
+	ratingRepo *repositories.RatingRepository
+	sm         *sessions.SessionManager
+}
+
+func NewRatingHandler(ratingRepo *repositories.RatingRepository, sm *sessions.SessionManager) *RatingHandler {
+	return &RatingHandler{
+		ratingRepo: ratingRepo,
+		sm:         sm,
+	}
+}
+
+// RatePlayerRequest è la valutazione di un compagno; con no_show=true i voti vanno omessi
+type RatePlayerRequest struct {
+	RatedUserID   int64 `json:"rated_user_id"`
+	Sportsmanship *int  `json:"sportsmanship"`
+	Skill         *int  `json:"skill"`
+	Punctuality   *int  `json:"punctuality"`
+	NoShow        bool  `json:"no_show"`
+}
+
+// EventRatingsHandler gestisce "/ratings/events/{postID}": GET restituisce i
+// compagni valutabili, POST registra una valutazione
+func (h *RatingHandler) EventRatingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		postID, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ratings/events/"), "/"))
+		if err != nil {
+			http.Error(w, "Post ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			h.getRateableParticipants(w, userID, postID)
+		case http.MethodPost:
+			h.ratePlayer(w, r, userID, postID)
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// UserReputationHandler restituisce la reputazione di un giocatore ("/ratings/users/{userID}")
+func (h *RatingHandler) UserReputationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if _, err := middleware.GetUserIDFromSession(r, h.sm); err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ratings/users/"), "/"), 10, 64)
+		if err != nil {
+			http.Error(w, "User ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		rep, err := h.ratingRepo.GetReputation(userID)
+		if err != nil {
+			fmt.Printf("[RATINGS] Error computing reputation of user %d: %v\n", userID, err)
+			http.Error(w, "Errore durante il calcolo della reputazione", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rep)
+	}
+}
+
+func (h *RatingHandler) getRateableParticipants(w http.ResponseWriter, userID int64, postID int) {
+	participants, err := h.ratingRepo.GetRateableParticipants(userID, postID)
+	if err != nil {
+		if h.handleRatingError(w, err) {
+			return
+		}
+		fmt.Printf("[RATINGS] Error getting rateable participants of event %d: %v\n", postID, err)
+		http.Error(w, "Errore durante il recupero dei partecipanti", http.StatusInternalServerError)
+		return
+	}
+
+	if participants == nil {
+		participants = []models.RateableParticipant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"post_id":      postID,
+		"participants": participants,
+	})
+}
+
+func (h *RatingHandler) ratePlayer(w http.ResponseWriter, r *http.Request, userID int64, postID int) {
+	var req RatePlayerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+	if req.RatedUserID <= 0 {
+		http.Error(w, "rated_user_id è obbligatorio", http.StatusBadRequest)
+		return
+	}
+
+	scores := []*int{req.Sportsmanship, req.Skill, req.Punctuality}
+	for _, score := range scores {
+		switch {
+		case req.NoShow && score != nil:
+			http.Error(w, "Un giocatore segnalato come assente non può ricevere voti", http.StatusBadRequest)
+			return
+		case !req.NoShow && score == nil:
+			http.Error(w, "Sportività, abilità e puntualità sono obbligatorie", http.StatusBadRequest)
+			return
+		case score != nil && (*score < 1 || *score > 5):
+			http.Error(w, "I voti devono essere compresi tra 1 e 5", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rating := &models.PlayerRating{
+		PostID:        postID,
+		RaterID:       userID,
+		RatedID:       req.RatedUserID,
+		Sportsmanship: req.Sportsmanship,
+		Skill:         req.Skill,
+		Punctuality:   req.Punctuality,
+		NoShow:        req.NoShow,
+	}
+
+	if err := h.ratingRepo.CreateRating(rating); err != nil {
+		if h.handleRatingError(w, err) {
+			return
+		}
+		fmt.Printf("[RATINGS] Error saving rating of user %d by %d for event %d: %v\n", req.RatedUserID, userID, postID, err)
+		http.Error(w, "Errore durante il salvataggio della valutazione", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[RATINGS] User %d rated user %d for event %d (no_show=%t)\n", userID, req.RatedUserID, postID, req.NoShow)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rating)
+}
+
+// handleRatingError mappa gli errori delle valutazioni sui codici HTTP; restituisce true se ha risposto
+func (h *RatingHandler) handleRatingError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repositories.ErrEventNotFound):
+		http.Error(w, "Evento non trovato", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrNotCoParticipant):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrCannotRateSelf):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrEventNotStarted),
+		errors.Is(err, repositories.ErrRatingWindowClosed),
+		errors.Is(err, repositories.ErrAlreadyRated):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/services"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+type RecommendationHandler struct {
+	recommendationService *services.RecommendationService
+	sm                    *sessions.SessionManager
+}
+
+func NewRecommendationHandler(recommendationService *services.RecommendationService, sm *sessions.SessionManager) *RecommendationHandler {
+	return &RecommendationHandler{
+		recommendationService: recommendationService,
+		sm:                    sm,
+	}
+}
+
+// RecommendedEventsHandler restituisce le prossime partite consigliate all'utente
+// con le ragioni di ogni consiglio. Parametri opzionali: limit (default 20) e
+// refresh=true per ricalcolare i consigli ignorando la cache.
+func (h *RecommendationHandler) RecommendedEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		limit := 20
+		if value := r.URL.Query().Get("limit"); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > services.MaxRecommendations {
+				http.Error(w, fmt.Sprintf("limit deve essere compreso tra 1 e %d", services.MaxRecommendations), http.StatusBadRequest)
+				return
+			}
+		}
+		refresh := r.URL.Query().Get("refresh") == "true"
+
+		recommendations, generatedAt, err := h.recommendationService.Recommend(userID, limit, refresh)
+		if err != nil {
+			fmt.Printf("[RECOMMEND] Error computing recommendations for user %d: %v\n", userID, err)
+			http.Error(w, "Errore durante il calcolo delle partite consigliate", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":         true,
+			"recommendations": recommendations,
+			"count":           len(recommendations),
+			"generated_at":    generatedAt,
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Finestra di default e massima (in giorni) del calendario dell'utente
+const (
+	defaultScheduleDays = 30
+	maxScheduleDays     = 180
+)
+
+// scheduleConflictWarning è il messaggio restituito quando l'iscrizione si sovrappone ad altri impegni
+const scheduleConflictWarning = "Hai già altre partite in questo orario: reinvia la richiesta con \"force\": true per iscriverti comunque"
+
+// UserScheduleHandler restituisce il calendario unificato degli impegni
+// dell'utente (partite organizzate e partecipazioni), segnalando le sovrapposizioni.
+// Parametri opzionali: from=YYYY-MM-DD (default oggi) e days (default 30, max 180).
+func (h *EventHandler) UserScheduleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		days := defaultScheduleDays
+		if value := r.URL.Query().Get("days"); value != "" {
+			days, err = strconv.Atoi(value)
+			if err != nil || days < 1 || days > maxScheduleDays {
+				http.Error(w, fmt.Sprintf("days deve essere compreso tra 1 e %d", maxScheduleDays), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var from time.Time
+		if value := r.URL.Query().Get("from"); value != "" {
+			from, err = time.Parse("2006-01-02", value)
+			if err != nil {
+				http.Error(w, "from deve essere una data nel formato YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+		} else {
+			from, err = h.eventRepo.LocalToday()
+			if err != nil {
+				fmt.Printf("[SCHEDULE] Error reading database date: %v\n", err)
+				http.Error(w, "Errore durante il recupero del calendario", http.StatusInternalServerError)
+				return
+			}
+		}
+		until := from.AddDate(0, 0, days)
+
+		// Le partite iniziate poco prima di from possono ancora sovrapporsi alle prime della finestra
+		commitments, err := h.eventRepo.GetUserCommitments(userID, from.Add(-h.scheduleCfg.MaxMatchDuration()), until)
+		if err != nil {
+			fmt.Printf("[SCHEDULE] Error getting schedule of user %d: %v\n", userID, err)
+			http.Error(w, "Errore durante il recupero del calendario", http.StatusInternalServerError)
+			return
+		}
+		h.markConflicts(commitments)
+
+		timeline := []models.Commitment{}
+		conflicts := 0
+		for _, commitment := range commitments {
+			if commitment.StartsAt.Before(from) {
+				continue
+			}
+			if len(commitment.ConflictsWith) > 0 {
+				conflicts++
+			}
+			timeline = append(timeline, commitment)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"from":      from.Format("2006-01-02"),
+			"until":     until.Format("2006-01-02"),
+			"schedule":  timeline,
+			"conflicts": conflicts,
+		})
+	}
+}
+
+// markConflicts imposta la fine presunta di ogni impegno e gli ID di quelli che
+// si sovrappongono; gli impegni devono essere ordinati per orario di inizio
+func (h *EventHandler) markConflicts(commitments []models.Commitment) {
+	for i := range commitments {
+		commitments[i].EndsAt = commitments[i].StartsAt.Add(h.scheduleCfg.MatchDuration(commitments[i].Sport))
+	}
+
+	for i := range commitments {
+		for j := i + 1; j < len(commitments) && commitments[j].StartsAt.Before(commitments[i].EndsAt); j++ {
+			commitments[i].ConflictsWith = append(commitments[i].ConflictsWith, commitments[j].PostID)
+			commitments[j].ConflictsWith = append(commitments[j].ConflictsWith, commitments[i].PostID)
+		}
+	}
+
+	for i := range commitments {
+		sort.Ints(commitments[i].ConflictsWith)
+	}
+}
@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"trovagiocatoriAuth/internal/calendar"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/middleware"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/postcatalog"
+	"trovagiocatoriAuth/internal/sessions"
+)
+
+const (
+	defaultSeriesHorizonDays = 7
+	maxSeriesHorizonDays     = 60
+	// Numero di prossime date mostrate nel dettaglio della serie
+	seriesUpcomingLimit = 5
+)
+
+type SeriesHandler struct {
+	seriesRepo       *repositories.SeriesRepository
+	eventRepo        *repositories.EventRepository
+	notificationRepo *repositories.NotificationRepository
+	catalog          postcatalog.PostCatalog
+	sm               *sessions.SessionManager
+}
+
+func NewSeriesHandler(seriesRepo *repositories.SeriesRepository, eventRepo *repositories.EventRepository, notificationRepo *repositories.NotificationRepository, catalog postcatalog.PostCatalog, sm *sessions.SessionManager) *SeriesHandler {
+	return &SeriesHandler{
+		seriesRepo:       seriesRepo,
+		eventRepo:        eventRepo,
+		notificationRepo: notificationRepo,
+		catalog:          catalog,
+		sm:               sm,
+	}
+}
+
+type CreateSeriesRequest struct {
+	TemplatePostID int    `json:"template_post_id"`
+	RRule          string `json:"rrule"`
+	HorizonDays    int    `json:"horizon_days"`
+}
+
+type UpdateSeriesRequest struct {
+	RRule       *string `json:"rrule"`
+	HorizonDays *int    `json:"horizon_days"`
+	Active      *bool   `json:"active"`
+}
+
+type SeriesRosterRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+type SeriesOptOutRequest struct {
+	Date string `json:"date"`
+}
+
+// SeriesListHandler gestisce "/series": GET elenca le serie dell'utente, POST ne crea una
+func (h *SeriesHandler) SeriesListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			h.listSeries(w, userID)
+		case http.MethodPost:
+			h.createSeries(w, r, userID)
+		default:
+			http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// SeriesRoutesHandler smista le richieste "/series/{id}/...":
+//
+//	GET    /series/{id}
+//	PUT    /series/{id}
+//	DELETE /series/{id}
+//	POST   /series/{id}/roster
+//	DELETE /series/{id}/roster/{userID}
+//	POST   /series/{id}/optouts
+//	DELETE /series/{id}/optouts/{date}
+func (h *SeriesHandler) SeriesRoutesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromSession(r, h.sm)
+		if err != nil {
+			http.Error(w, "Unauthorized: sessione non valida", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/series/"), "/"), "/")
+		seriesID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			http.Error(w, "Series ID non valido", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case len(parts) == 1:
+			switch r.Method {
+			case http.MethodGet:
+				h.getSeries(w, userID, seriesID)
+			case http.MethodPut:
+				h.updateSeries(w, r, userID, seriesID)
+			case http.MethodDelete:
+				h.deleteSeries(w, userID, seriesID)
+			default:
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+			}
+
+		case parts[1] == "roster" && len(parts) == 2:
+			if r.Method != http.MethodPost {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.addRosterMember(w, r, userID, seriesID)
+
+		case parts[1] == "roster" && len(parts) == 3:
+			if r.Method != http.MethodDelete {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			memberID, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				http.Error(w, "User ID non valido", http.StatusBadRequest)
+				return
+			}
+			h.removeRosterMember(w, userID, seriesID, memberID)
+
+		case parts[1] == "optouts" && len(parts) == 2:
+			if r.Method != http.MethodPost {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			var req SeriesOptOutRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+				return
+			}
+			h.setOptOut(w, userID, seriesID, req.Date, true)
+
+		case parts[1] == "optouts" && len(parts) == 3:
+			if r.Method != http.MethodDelete {
+				http.Error(w, "Metodo non consentito", http.StatusMethodNotAllowed)
+				return
+			}
+			h.setOptOut(w, userID, seriesID, parts[2], false)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func (h *SeriesHandler) listSeries(w http.ResponseWriter, userID int64) {
+	seriesList, err := h.seriesRepo.GetUserSeries(userID)
+	if err != nil {
+		fmt.Printf("[SERIES] Error getting series of user %d: %v\n", userID, err)
+		http.Error(w, "Errore durante il recupero delle serie", http.StatusInternalServerError)
+		return
+	}
+
+	if seriesList == nil {
+		seriesList = []models.EventSeries{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"series":  seriesList,
+		"count":   len(seriesList),
+	})
+}
+
+func (h *SeriesHandler) createSeries(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req CreateSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+	if req.TemplatePostID <= 0 {
+		http.Error(w, "template_post_id è obbligatorio", http.StatusBadRequest)
+		return
+	}
+	if req.HorizonDays == 0 {
+		req.HorizonDays = defaultSeriesHorizonDays
+	}
+	if !validSeriesHorizon(req.HorizonDays) {
+		http.Error(w, fmt.Sprintf("horizon_days deve essere compreso tra 1 e %d", maxSeriesHorizonDays), http.StatusBadRequest)
+		return
+	}
+
+	rrule := strings.TrimSpace(req.RRule)
+	if _, err := calendar.ParseRRule(rrule); err != nil {
+		http.Error(w, "Regola di ricorrenza non valida: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.seriesRepo.CreateSeries(userID, req.TemplatePostID, rrule, req.HorizonDays)
+	if err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		fmt.Printf("[SERIES] Error creating series from post %d: %v\n", req.TemplatePostID, err)
+		http.Error(w, "Errore durante la creazione della serie", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[SERIES] User %d created series %d from post %d (%s)\n", userID, series.ID, req.TemplatePostID, rrule)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(series)
+}
+
+// getSeries restituisce la serie con roster e prossime date; visibile
+// all'organizzatore e ai giocatori fissi
+func (h *SeriesHandler) getSeries(w http.ResponseWriter, userID, seriesID int64) {
+	series, err := h.seriesRepo.GetSeries(seriesID)
+	if err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+
+	if series.OrganizerID != userID {
+		member, err := h.seriesRepo.IsRosterMember(seriesID, userID)
+		if err != nil {
+			http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+			return
+		}
+		if !member {
+			h.handleSeriesError(w, repositories.ErrNotInRoster)
+			return
+		}
+	}
+
+	series.Roster, err = h.seriesRepo.GetRoster(seriesID)
+	if err != nil {
+		fmt.Printf("[SERIES] Error getting roster of series %d: %v\n", seriesID, err)
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+
+	series.Upcoming, err = h.upcomingOccurrences(series, userID)
+	if err != nil {
+		fmt.Printf("[SERIES] Error computing occurrences of series %d: %v\n", seriesID, err)
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+// upcomingOccurrences unisce le occorrenze già generate e quelle previste
+// dalla regola, segnando le date a cui l'utente ha rinunciato
+func (h *SeriesHandler) upcomingOccurrences(series *models.EventSeries, userID int64) ([]models.SeriesOccurrence, error) {
+	now, err := h.seriesRepo.LocalNow()
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences, err := h.seriesRepo.GetOccurrences(series.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := make(map[int64]bool, len(occurrences))
+	for _, occurrence := range occurrences {
+		generated[occurrence.StartsAt.Unix()] = true
+	}
+
+	if rule, err := calendar.ParseRRule(series.RRule); err == nil && series.Active {
+		after := now
+		if series.LastOccurrenceAt.After(after) {
+			after = series.LastOccurrenceAt
+		}
+		for _, startsAt := range rule.Between(series.DTStart, after, now.AddDate(0, 0, maxSeriesHorizonDays)) {
+			if !generated[startsAt.Unix()] {
+				occurrences = append(occurrences, models.SeriesOccurrence{StartsAt: startsAt})
+			}
+		}
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].StartsAt.Before(occurrences[j].StartsAt) })
+	if len(occurrences) > seriesUpcomingLimit {
+		occurrences = occurrences[:seriesUpcomingLimit]
+	}
+
+	optOuts, err := h.seriesRepo.GetOptOutDates(series.ID, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range occurrences {
+		occurrences[i].OptedOut = optOuts[occurrences[i].StartsAt.Format("2006-01-02")]
+	}
+	return occurrences, nil
+}
+
+func (h *SeriesHandler) updateSeries(w http.ResponseWriter, r *http.Request, userID, seriesID int64) {
+	var req UpdateSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.seriesRepo.GetSeries(seriesID)
+	if err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+
+	rrule, horizonDays, active := series.RRule, series.HorizonDays, series.Active
+	if req.RRule != nil {
+		rrule = strings.TrimSpace(*req.RRule)
+		if _, err := calendar.ParseRRule(rrule); err != nil {
+			http.Error(w, "Regola di ricorrenza non valida: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.HorizonDays != nil {
+		horizonDays = *req.HorizonDays
+		if !validSeriesHorizon(horizonDays) {
+			http.Error(w, fmt.Sprintf("horizon_days deve essere compreso tra 1 e %d", maxSeriesHorizonDays), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	updated, err := h.seriesRepo.UpdateSeries(userID, seriesID, rrule, horizonDays, active)
+	if err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		fmt.Printf("[SERIES] Error updating series %d: %v\n", seriesID, err)
+		http.Error(w, "Errore durante l'aggiornamento della serie", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[SERIES] Series %d updated by user %d\n", seriesID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (h *SeriesHandler) deleteSeries(w http.ResponseWriter, userID, seriesID int64) {
+	if err := h.seriesRepo.DeleteSeries(userID, seriesID); err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		fmt.Printf("[SERIES] Error deleting series %d: %v\n", seriesID, err)
+		http.Error(w, "Errore durante l'eliminazione della serie", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[SERIES] Series %d deleted by user %d\n", seriesID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Serie eliminata: le partite già create restano disponibili",
+	})
+}
+
+func (h *SeriesHandler) addRosterMember(w http.ResponseWriter, r *http.Request, userID, seriesID int64) {
+	var req SeriesRosterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "Formato richiesta non valido", http.StatusBadRequest)
+		return
+	}
+
+	added, err := h.seriesRepo.AddRosterMember(userID, seriesID, req.UserID)
+	if err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		fmt.Printf("[SERIES] Error adding user %d to series %d: %v\n", req.UserID, seriesID, err)
+		http.Error(w, "Errore durante l'aggiunta del giocatore", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Giocatore già presente nella serie"
+	if added {
+		message = "Giocatore aggiunto alla serie"
+		fmt.Printf("[SERIES] User %d added to series %d\n", req.UserID, seriesID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+	})
+}
+
+func (h *SeriesHandler) removeRosterMember(w http.ResponseWriter, userID, seriesID, memberID int64) {
+	if err := h.seriesRepo.RemoveRosterMember(userID, seriesID, memberID); err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		fmt.Printf("[SERIES] Error removing user %d from series %d: %v\n", memberID, seriesID, err)
+		http.Error(w, "Errore durante la rimozione del giocatore", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("[SERIES] User %d removed from series %d by user %d\n", memberID, seriesID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Giocatore rimosso dalla serie",
+	})
+}
+
+// setOptOut registra o annulla la rinuncia a una data della serie. Se la partita
+// è già stata generata viene aggiornata anche la partecipazione al post.
+func (h *SeriesHandler) setOptOut(w http.ResponseWriter, userID, seriesID int64, value string, optOut bool) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		http.Error(w, "Data non valida: usa il formato AAAA-MM-GG", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.seriesRepo.GetSeries(seriesID)
+	if err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+
+	now, err := h.seriesRepo.LocalNow()
+	if err != nil {
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+	if date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+		http.Error(w, "Non puoi modificare una partita già passata", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := h.seriesRepo.GetOccurrencePostID(seriesID, date)
+	if err != nil {
+		http.Error(w, "Errore durante il recupero della serie", http.StatusInternalServerError)
+		return
+	}
+	if postID == nil && !seriesHasDate(series, date) {
+		http.Error(w, "La serie non prevede una partita in questa data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.seriesRepo.SetOptOut(userID, seriesID, date, optOut); err != nil {
+		if h.handleSeriesError(w, err) {
+			return
+		}
+		fmt.Printf("[SERIES] Error setting opt-out of user %d for series %d: %v\n", userID, seriesID, err)
+		http.Error(w, "Errore durante l'aggiornamento della partecipazione", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":   true,
+		"date":      value,
+		"opted_out": optOut,
+	}
+
+	if postID != nil {
+		response["post_id"] = *postID
+		status, err := h.applyOccurrenceParticipation(userID, *postID, optOut)
+		if err != nil {
+			if h.handleSeriesError(w, err) {
+				return
+			}
+			fmt.Printf("[SERIES] Error updating participation of user %d to event %d: %v\n", userID, *postID, err)
+			http.Error(w, "Errore durante l'aggiornamento della partecipazione", http.StatusInternalServerError)
+			return
+		}
+		response["status"] = status
+	}
+
+	fmt.Printf("[SERIES] User %d set opt-out=%t for %s of series %d\n", userID, optOut, value, seriesID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyOccurrenceParticipation disiscrive (o riscrive) l'utente da un'occorrenza
+// già generata, avvisando chi viene promosso dalla lista d'attesa
+func (h *SeriesHandler) applyOccurrenceParticipation(userID int64, postID int, optOut bool) (string, error) {
+	if !optOut {
+		change, err := h.eventRepo.JoinEvent(userID, postID, nil)
+		if errors.Is(err, repositories.ErrAlreadyParticipant) {
+			return models.ParticipantStatusConfirmed, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return change.Status, nil
+	}
+
+	change, err := h.eventRepo.LeaveEvent(userID, postID)
+	if err != nil {
+		return "", err
+	}
+
+	if h.notificationRepo != nil && len(change.PromotedUserIDs) > 0 {
+		title := postcatalog.Title(h.catalog, postID)
+		for _, promotedID := range change.PromotedUserIDs {
+			if err := h.notificationRepo.CreateWaitlistPromotionNotification(promotedID, int64(postID), title); err != nil {
+				fmt.Printf("[SERIES] WARNING: Error notifying waitlist promotion to user %d: %v\n", promotedID, err)
+			}
+		}
+	}
+	return change.Status, nil
+}
+
+// seriesHasDate verifica se la regola della serie prevede una partita nel giorno indicato
+func seriesHasDate(series *models.EventSeries, date time.Time) bool {
+	rule, err := calendar.ParseRRule(series.RRule)
+	if err != nil {
+		return false
+	}
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, series.DTStart.Location())
+	return len(rule.Between(series.DTStart, dayStart.Add(-time.Nanosecond), dayStart.Add(24*time.Hour-time.Nanosecond))) > 0
+}
+
+func validSeriesHorizon(days int) bool {
+	return days >= 1 && days <= maxSeriesHorizonDays
+}
+
+// handleSeriesError mappa gli errori delle serie sui codici HTTP; restituisce true se ha risposto
+func (h *SeriesHandler) handleSeriesError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repositories.ErrSeriesNotFound),
+		errors.Is(err, repositories.ErrEventNotFound),
+		errors.Is(err, repositories.ErrRosterMemberNotFound),
+		errors.Is(err, repositories.ErrRosterUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repositories.ErrNotSeriesOrganizer),
+		errors.Is(err, repositories.ErrNotEventOrganizer),
+		errors.Is(err, repositories.ErrNotInRoster),
+		errors.Is(err, repositories.ErrJoinNotAllowed),
+		errors.Is(err, repositories.ErrRemovedFromEvent):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repositories.ErrSeriesExists),
+		errors.Is(err, repositories.ErrAlreadyWaitlisted),
+		errors.Is(err, repositories.ErrEventAlreadyStarted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
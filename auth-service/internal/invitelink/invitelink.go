@@ -0,0 +1,68 @@
+// Package invitelink firma e verifica i token dei link di invito alle partite.
+// Il token contiene l'ID del link e una firma HMAC: un token alterato viene
+// scartato senza interrogare il database, mentre scadenza, utilizzi e revoca
+// restano salvati sul link.
+package invitelink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidToken indica un token malformato o con firma non valida
+	ErrInvalidToken = errors.New("link di invito non valido")
+	// ErrMissingKey indica che EVENT_LINK_SIGNING_KEY non è impostata
+	ErrMissingKey = errors.New("EVENT_LINK_SIGNING_KEY deve essere impostata (chiave condivisa tra le repliche)")
+)
+
+// Byte della firma inclusi nel token (128 bit)
+const signatureBytes = 16
+
+// Signer genera e verifica i token dei link
+type Signer struct {
+	key []byte
+}
+
+// NewSigner crea un signer con la chiave indicata, condivisa tra le repliche.
+// La chiave è obbligatoria: con una chiave diversa a ogni avvio i link già
+// condivisi smetterebbero di funzionare al riavvio o su un'altra replica.
+func NewSigner(secret string) (*Signer, error) {
+	if secret == "" {
+		return nil, ErrMissingKey
+	}
+	return &Signer{key: []byte(secret)}, nil
+}
+
+// Token restituisce il token del link: "<id in base 36>.<firma>"
+func (s *Signer) Token(linkID int64) string {
+	id := strconv.FormatInt(linkID, 36)
+	return id + "." + s.sign(id)
+}
+
+// Parse verifica la firma del token e restituisce l'ID del link
+func (s *Signer) Parse(token string) (int64, error) {
+	id, signature, found := strings.Cut(strings.TrimSpace(token), ".")
+	if !found || id == "" {
+		return 0, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(s.sign(id)), []byte(signature)) {
+		return 0, ErrInvalidToken
+	}
+
+	linkID, err := strconv.ParseInt(id, 36, 64)
+	if err != nil || linkID <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return linkID, nil
+}
+
+func (s *Signer) sign(id string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("event-link\n" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureBytes])
+}
@@ -0,0 +1,269 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Stili disponibili per gli avatar generati
+const (
+	AvatarStyleInitials  = "initials"
+	AvatarStyleIdenticon = "identicon"
+)
+
+// Gli utenti senza immagine profilo espongono un nome file "avatar_<id>.png"
+// che ServeProfilePicture genera al volo
+const (
+	avatarPrefix = "avatar_"
+	avatarExt    = ".png"
+)
+
+// Palette di sfondo: colori abbastanza scuri da garantire contrasto con il bianco
+var avatarPalette = []color.NRGBA{
+	{0xE5, 0x39, 0x35, 0xFF}, {0xD8, 0x1B, 0x60, 0xFF}, {0x8E, 0x24, 0xAA, 0xFF},
+	{0x5E, 0x35, 0xB1, 0xFF}, {0x39, 0x49, 0xAB, 0xFF}, {0x1E, 0x88, 0xE5, 0xFF},
+	{0x00, 0x89, 0x7B, 0xFF}, {0x43, 0xA0, 0x47, 0xFF}, {0x6D, 0x4C, 0x41, 0xFF},
+	{0xF4, 0x51, 0x1E, 0xFF}, {0x54, 0x6E, 0x7A, 0xFF}, {0x00, 0x83, 0x8F, 0xFF},
+}
+
+// AvatarFileName restituisce il nome file dell'avatar generato di un utente
+func AvatarFileName(userID int64) string {
+	return avatarPrefix + strconv.FormatInt(userID, 10) + avatarExt
+}
+
+// ParseAvatarFileName estrae l'ID utente da un nome file "avatar_<id>.png"
+func ParseAvatarFileName(name string) (int64, bool) {
+	if !strings.HasPrefix(name, avatarPrefix) || !strings.HasSuffix(name, avatarExt) {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, avatarPrefix), avatarExt), 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+	return userID, true
+}
+
+// AvatarSide restituisce il lato in pixel dell'avatar per la variante richiesta
+func AvatarSide(v Variant) int {
+	if v == VariantThumbnail {
+		return ThumbnailSide
+	}
+	return MediumSide
+}
+
+// Initials calcola le iniziali da nome e cognome (fallback sullo username)
+func Initials(nome, cognome, username string) string {
+	var initials []rune
+	for _, s := range []string{nome, cognome} {
+		if r, ok := firstLetter(s); ok {
+			initials = append(initials, r)
+		}
+	}
+	if len(initials) == 0 {
+		if r, ok := firstLetter(username); ok {
+			initials = append(initials, r)
+		}
+	}
+	if len(initials) == 0 {
+		return "?"
+	}
+	return string(initials)
+}
+
+// GenerateAvatar genera un avatar PNG deterministico: a parità di seed
+// e iniziali il risultato è identico byte per byte
+func GenerateAvatar(style, seed, initials string, side int) ([]byte, error) {
+	var img *image.NRGBA
+	switch style {
+	case "", AvatarStyleInitials:
+		img = initialsAvatar(seed, initials, side)
+	case AvatarStyleIdenticon:
+		img = identiconAvatar(seed, side)
+	default:
+		return nil, fmt.Errorf("stile avatar non supportato: %s", style)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func initialsAvatar(seed, initials string, side int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, side, side))
+	fill(img, img.Bounds(), avatarPalette[paletteIndex(seed)])
+
+	glyphs := []rune(initials)
+	if len(glyphs) == 0 {
+		glyphs = []rune{'?'}
+	}
+
+	// Ogni glifo è 5x7 con una colonna di spazio; il testo occupa ~55% del lato
+	textCols := len(glyphs)*(glyphWidth+1) - 1
+	scale := side * 55 / 100 / textCols
+	if maxScale := side * 45 / 100 / glyphHeight; scale > maxScale {
+		scale = maxScale
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	x0 := (side - textCols*scale) / 2
+	y0 := (side - glyphHeight*scale) / 2
+	white := color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	for i, r := range glyphs {
+		rows := glyphFor(r)
+		gx := x0 + i*(glyphWidth+1)*scale
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if rows[row][col] == '#' {
+					fill(img, image.Rect(gx+col*scale, y0+row*scale, gx+(col+1)*scale, y0+(row+1)*scale), white)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// identiconAvatar disegna una griglia 5x5 simmetrica derivata dallo SHA-256 del seed
+func identiconAvatar(seed string, side int) *image.NRGBA {
+	sum := sha256.Sum256([]byte(seed))
+	fg := avatarPalette[int(sum[0])%len(avatarPalette)]
+	bg := color.NRGBA{0xF0, 0xF0, 0xF0, 0xFF}
+
+	img := image.NewNRGBA(image.Rect(0, 0, side, side))
+	fill(img, img.Bounds(), bg)
+
+	const cells = 5
+	margin := side / 10
+	cell := (side - 2*margin) / cells
+	offset := (side - cell*cells) / 2
+
+	bit := 0
+	for col := 0; col < 3; col++ {
+		for row := 0; row < cells; row++ {
+			on := sum[1+bit/8]&(1<<(bit%8)) != 0
+			bit++
+			if !on {
+				continue
+			}
+			for _, c := range []int{col, cells - 1 - col} {
+				fill(img, image.Rect(offset+c*cell, offset+row*cell, offset+(c+1)*cell, offset+(row+1)*cell), fg)
+			}
+		}
+	}
+	return img
+}
+
+func fill(img *image.NRGBA, rect image.Rectangle, c color.NRGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+func paletteIndex(seed string) int {
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	return int(h.Sum32() % uint32(len(avatarPalette)))
+}
+
+// firstLetter restituisce la prima lettera maiuscola, senza accenti
+func firstLetter(s string) (rune, bool) {
+	for _, r := range strings.TrimSpace(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		r = unicode.ToUpper(foldAccent(r))
+		if _, ok := font5x7[r]; ok {
+			return r, true
+		}
+		return '?', true
+	}
+	return 0, false
+}
+
+// foldAccent riduce le lettere accentate più comuni alla lettera base
+func foldAccent(r rune) rune {
+	switch unicode.ToLower(r) {
+	case 'à', 'á', 'â', 'ã', 'ä', 'å':
+		return 'A'
+	case 'è', 'é', 'ê', 'ë':
+		return 'E'
+	case 'ì', 'í', 'î', 'ï':
+		return 'I'
+	case 'ò', 'ó', 'ô', 'õ', 'ö':
+		return 'O'
+	case 'ù', 'ú', 'û', 'ü':
+		return 'U'
+	case 'ç':
+		return 'C'
+	case 'ñ':
+		return 'N'
+	}
+	return r
+}
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+func glyphFor(r rune) [glyphHeight]string {
+	if g, ok := font5x7[r]; ok {
+		return g
+	}
+	return font5x7['?']
+}
+
+// Font bitmap 5x7 per le iniziali (nessun font vettoriale nella standard library)
+var font5x7 = map[rune][glyphHeight]string{
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".###."},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'?': {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+}
@@ -0,0 +1,238 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/webp"
+)
+
+// Limiti applicati alle immagini caricate dagli utenti
+const (
+	MaxUploadBytes  = 5 << 20
+	MinDimension    = 32
+	MaxDimension    = 6000
+	MaxPixels       = 24_000_000
+	MaxStoredSide   = 2048
+	MediumSide      = 512
+	ThumbnailSide   = 128
+	jpegQuality     = 85
+	contentHashSize = 32 // caratteri esadecimali usati nel nome file
+)
+
+// Variant identifica una delle dimensioni salvate per ogni immagine
+type Variant string
+
+const (
+	VariantThumbnail Variant = "thumb"
+	VariantMedium    Variant = "medium"
+	VariantOriginal  Variant = "original"
+)
+
+// Variants elenca tutte le varianti generate, dalla più piccola alla più grande
+var Variants = []Variant{VariantThumbnail, VariantMedium, VariantOriginal}
+
+// ParseVariant converte il parametro ?size= nella variante corrispondente
+func ParseVariant(size string) (Variant, bool) {
+	switch strings.ToLower(size) {
+	case "", "original", "full":
+		return VariantOriginal, true
+	case "thumb", "thumbnail", "small":
+		return VariantThumbnail, true
+	case "medium":
+		return VariantMedium, true
+	}
+	return "", false
+}
+
+// Errori di validazione dell'immagine caricata
+var (
+	ErrImageTooLarge          = errors.New("immagine troppo grande")
+	ErrImageUnsupportedFormat = errors.New("formato immagine non supportato: usa JPEG, PNG o WebP")
+	ErrImageCorrupted         = errors.New("il file non è un'immagine valida")
+	ErrImageDimensions        = errors.New("dimensioni dell'immagine non consentite")
+	ErrImageAnimated          = errors.New("le immagini animate non sono supportate")
+)
+
+// ProcessedImage contiene le varianti ri-codificate di un'immagine caricata
+type ProcessedImage struct {
+	Hash        string
+	Ext         string
+	ContentType string
+	Width       int
+	Height      int
+	Variants    map[Variant][]byte
+}
+
+// FileName restituisce il nome content-addressed della variante richiesta
+func (p *ProcessedImage) FileName(v Variant) string {
+	return VariantFileName(p.Hash+p.Ext, v)
+}
+
+// VariantFileName deriva il nome file di una variante dal nome dell'originale
+// salvato nel profilo utente (es. "ab12.jpg" -> "ab12_thumb.jpg")
+func VariantFileName(name string, v Variant) string {
+	if v == VariantOriginal {
+		return name
+	}
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return name + "_" + string(v)
+	}
+	return name[:dot] + "_" + string(v) + name[dot:]
+}
+
+// IsContentAddressed indica se il nome file deriva dall'hash del contenuto
+// (con eventuale suffisso di variante): questi file non cambiano mai
+func IsContentAddressed(name string) bool {
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return false
+	}
+	base := name[:dot]
+	for _, v := range Variants {
+		base = strings.TrimSuffix(base, "_"+string(v))
+	}
+	if len(base) != contentHashSize {
+		return false
+	}
+	_, err := hex.DecodeString(base)
+	return err == nil && strings.ToLower(base) == base
+}
+
+// ProcessProfilePicture legge un upload, verifica che sia davvero un'immagine
+// JPEG/PNG/WebP entro i limiti consentiti e genera le varianti da salvare.
+// Ogni formato viene decodificato e ri-codificato: questo elimina EXIF e ogni
+// altro metadato, dopo aver applicato l'eventuale rotazione EXIF dei JPEG.
+func ProcessProfilePicture(r io.Reader) (*ProcessedImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadBytes {
+		return nil, ErrImageTooLarge
+	}
+
+	// Il tipo viene determinato dal contenuto, mai dall'estensione del client
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return processRaster(data, "jpeg")
+	case "image/png":
+		return processRaster(data, "png")
+	case "image/webp":
+		return processWebP(data)
+	}
+	return nil, ErrImageUnsupportedFormat
+}
+
+func processRaster(data []byte, expectedFormat string) (*ProcessedImage, error) {
+	// Controllo delle dimensioni dall'header, prima di allocare l'immagine intera
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != expectedFormat {
+		return nil, ErrImageCorrupted
+	}
+	if err := checkDimensions(cfg.Width, cfg.Height); err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageCorrupted
+	}
+
+	img := toNRGBA(src)
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	return encodeVariants(img, format == "png")
+}
+
+// processWebP valida il container WebP (niente animazioni), decodifica
+// l'immagine e genera le varianti come per JPEG e PNG. Non esistendo un
+// encoder WebP le varianti vengono salvate in PNG se l'immagine ha
+// trasparenze, altrimenti in JPEG; i metadati EXIF/XMP vanno persi.
+func processWebP(data []byte) (*ProcessedImage, error) {
+	info, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+	if info.animated {
+		return nil, ErrImageAnimated
+	}
+	if err := checkDimensions(info.width, info.height); err != nil {
+		return nil, err
+	}
+
+	src, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageCorrupted
+	}
+
+	img := toNRGBA(src)
+	return encodeVariants(img, !img.Opaque())
+}
+
+// encodeVariants ridimensiona l'immagine nelle varianti da salvare e le
+// codifica in PNG o JPEG
+func encodeVariants(img *image.NRGBA, asPNG bool) (*ProcessedImage, error) {
+	processed := &ProcessedImage{Variants: make(map[Variant][]byte, len(Variants))}
+	encode := func(img image.Image) ([]byte, error) {
+		var buf bytes.Buffer
+		var err error
+		if asPNG {
+			err = png.Encode(&buf, img)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+		}
+		return buf.Bytes(), err
+	}
+
+	original := fitWithin(img, MaxStoredSide)
+	variants := map[Variant]image.Image{
+		VariantOriginal:  original,
+		VariantMedium:    fitWithin(original, MediumSide),
+		VariantThumbnail: squareThumbnail(original, ThumbnailSide),
+	}
+	for v, variantImg := range variants {
+		encoded, err := encode(variantImg)
+		if err != nil {
+			return nil, fmt.Errorf("errore nella codifica dell'immagine: %v", err)
+		}
+		processed.Variants[v] = encoded
+	}
+
+	bounds := original.Bounds()
+	processed.Width, processed.Height = bounds.Dx(), bounds.Dy()
+	if asPNG {
+		processed.Ext, processed.ContentType = ".png", "image/png"
+	} else {
+		processed.Ext, processed.ContentType = ".jpg", "image/jpeg"
+	}
+	processed.Hash = contentHash(processed.Variants[VariantOriginal])
+
+	return processed, nil
+}
+
+func checkDimensions(width, height int) error {
+	if width < MinDimension || height < MinDimension ||
+		width > MaxDimension || height > MaxDimension ||
+		width*height > MaxPixels {
+		return ErrImageDimensions
+	}
+	return nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:contentHashSize]
+}
@@ -0,0 +1,204 @@
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// toNRGBA converte qualsiasi immagine decodificata in NRGBA con origine (0,0)
+func toNRGBA(src image.Image) *image.NRGBA {
+	if img, ok := src.(*image.NRGBA); ok && img.Bounds().Min == (image.Point{}) {
+		return img
+	}
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+// fitWithin riduce l'immagine in modo che il lato maggiore non superi maxSide.
+// Le immagini già più piccole vengono restituite invariate (mai ingrandite).
+func fitWithin(src *image.NRGBA, maxSide int) *image.NRGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+	if w >= h {
+		return resizeArea(src, maxSide, scaleSide(h, maxSide, w))
+	}
+	return resizeArea(src, scaleSide(w, maxSide, h), maxSide)
+}
+
+// squareThumbnail ritaglia il quadrato centrale e lo riduce a side x side
+func squareThumbnail(src *image.NRGBA, side int) *image.NRGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	crop := w
+	if h < crop {
+		crop = h
+	}
+	x0, y0 := (w-crop)/2, (h-crop)/2
+	square := src.SubImage(image.Rect(x0, y0, x0+crop, y0+crop)).(*image.NRGBA)
+	if crop <= side {
+		return toNRGBA(square)
+	}
+	return resizeArea(toNRGBA(square), side, side)
+}
+
+func scaleSide(side, target, reference int) int {
+	scaled := side * target / reference
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// resizeArea riduce l'immagine calcolando per ogni pixel di destinazione la
+// media dei pixel sorgente che copre (box filter), pesata sull'alpha.
+func resizeArea(src *image.NRGBA, dstW, dstH int) *image.NRGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for dy := 0; dy < dstH; dy++ {
+		sy0 := dy * srcH / dstH
+		sy1 := (dy + 1) * srcH / dstH
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for dx := 0; dx < dstW; dx++ {
+			sx0 := dx * srcW / dstW
+			sx1 := (dx + 1) * srcW / dstW
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, count uint64
+			for sy := sy0; sy < sy1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					pa := uint64(p[3])
+					r += uint64(p[0]) * pa
+					g += uint64(p[1]) * pa
+					b += uint64(p[2]) * pa
+					a += pa
+					count++
+				}
+			}
+
+			o := dst.Pix[dy*dst.Stride+dx*4:]
+			if a > 0 {
+				o[0] = uint8(r / a)
+				o[1] = uint8(g / a)
+				o[2] = uint8(b / a)
+			}
+			o[3] = uint8(a / count)
+		}
+	}
+
+	return dst
+}
+
+// jpegOrientation legge il tag EXIF Orientation (0x0112) da un JPEG.
+// Restituisce 1 (nessuna trasformazione) se il tag è assente o illeggibile.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		// SOS o EOI: da qui in poi non ci sono più segmenti di metadati
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+segLen]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + segLen
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation ruota/specchia l'immagine secondo il valore EXIF Orientation
+func applyOrientation(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var nx, ny int
+			switch orientation {
+			case 2: // specchiata orizzontalmente
+				nx, ny = w-1-x, y
+			case 3: // ruotata di 180°
+				nx, ny = w-1-x, h-1-y
+			case 4: // specchiata verticalmente
+				nx, ny = x, h-1-y
+			case 5: // trasposta
+				nx, ny = y, x
+			case 6: // ruotata di 90° in senso orario
+				nx, ny = h-1-y, x
+			case 7: // trasversa
+				nx, ny = h-1-y, w-1-x
+			case 8: // ruotata di 90° in senso antiorario
+				nx, ny = y, w-1-x
+			}
+			copy(dst.Pix[ny*dst.Stride+nx*4:ny*dst.Stride+nx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+
+	return dst
+}
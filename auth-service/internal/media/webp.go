@@ -0,0 +1,94 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// Flag del chunk VP8X (https://developers.google.com/speed/webp/docs/riff_container)
+const (
+	vp8xFlagAnimation = 0x02
+)
+
+type webpInfo struct {
+	width    int
+	height   int
+	animated bool
+}
+
+type riffChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+// readWebPChunks verifica l'header RIFF/WEBP e restituisce i chunk contenuti
+func readWebPChunks(data []byte) ([]riffChunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrImageCorrupted
+	}
+	riffSize := int(binary.LittleEndian.Uint32(data[4:]))
+	if riffSize+8 > len(data) || riffSize < 4 {
+		return nil, ErrImageCorrupted
+	}
+
+	var chunks []riffChunk
+	pos := 12
+	end := riffSize + 8
+	for pos+8 <= end {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || pos+8+size > end {
+			return nil, ErrImageCorrupted
+		}
+		chunks = append(chunks, riffChunk{
+			fourCC:  string(data[pos : pos+4]),
+			payload: data[pos+8 : pos+8+size],
+		})
+		// I chunk RIFF sono allineati a 2 byte
+		pos += 8 + size + size%2
+	}
+
+	if len(chunks) == 0 {
+		return nil, ErrImageCorrupted
+	}
+	return chunks, nil
+}
+
+// parseWebP legge le dimensioni dal primo chunk (VP8, VP8L o VP8X)
+func parseWebP(data []byte) (*webpInfo, error) {
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	first := chunks[0]
+	p := first.payload
+	switch first.fourCC {
+	case "VP8 ":
+		// Frame tag (3 byte) + start code 9d 01 2a + larghezza/altezza a 14 bit
+		if len(p) < 10 || p[3] != 0x9d || p[4] != 0x01 || p[5] != 0x2a {
+			return nil, ErrImageCorrupted
+		}
+		return &webpInfo{
+			width:  int(binary.LittleEndian.Uint16(p[6:]) & 0x3fff),
+			height: int(binary.LittleEndian.Uint16(p[8:]) & 0x3fff),
+		}, nil
+	case "VP8L":
+		if len(p) < 5 || p[0] != 0x2f {
+			return nil, ErrImageCorrupted
+		}
+		bits := binary.LittleEndian.Uint32(p[1:])
+		return &webpInfo{
+			width:  int(bits&0x3fff) + 1,
+			height: int((bits>>14)&0x3fff) + 1,
+		}, nil
+	case "VP8X":
+		if len(p) < 10 {
+			return nil, ErrImageCorrupted
+		}
+		return &webpInfo{
+			width:    int(uint32(p[4])|uint32(p[5])<<8|uint32(p[6])<<16) + 1,
+			height:   int(uint32(p[7])|uint32(p[8])<<8|uint32(p[9])<<16) + 1,
+			animated: p[0]&vp8xFlagAnimation != 0,
+		}, nil
+	}
+	return nil, ErrImageCorrupted
+}
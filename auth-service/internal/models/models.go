@@ -4,17 +4,20 @@ import "time"
 
 // User rappresenta un utente nel sistema
 type User struct {
-	ID         int64  `json:"id"`
-	Nome       string `json:"nome"`
-	Cognome    string `json:"cognome"`
-	Username   string `json:"username"`
-	Email      string `json:"email"`
-	Password   string `json:"-"`
-	ProfilePic string `json:"profile_picture,omitempty"`
-	IsAdmin    bool   `json:"is_admin"`
-	IsActive   bool   `json:"is_active"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID                int64       `json:"id"`
+	Nome              string      `json:"nome"`
+	Cognome           string      `json:"cognome"`
+	Username          string      `json:"username"`
+	Email             string      `json:"email"`
+	Password          string      `json:"-"`
+	ProfilePic        string      `json:"profile_picture,omitempty"`
+	HasProfilePicture bool        `json:"has_profile_picture"`
+	IsAdmin           bool        `json:"is_admin"`
+	IsActive          bool        `json:"is_active"`
+	InvitedBy         *int64      `json:"invited_by,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+	Reputation        *Reputation `json:"reputation,omitempty"`
 }
 
 // AdminUserInfo rappresenta le informazioni di un utente per il pannello admin
@@ -67,26 +70,142 @@ type UserSearchResult struct {
 
 // EventInviteInfo rappresenta un invito a un evento
 type EventInviteInfo struct {
-	InviteID             int64  `json:"invite_id"`
-	PostID               int    `json:"post_id"`
-	Message              string `json:"message"`
-	CreatedAt            string `json:"created_at"`
-	Status               string `json:"status"`
-	SenderUsername       string `json:"sender_username"`
-	SenderNome           string `json:"sender_nome"`
-	SenderCognome        string `json:"sender_cognome"`
-	SenderEmail          string `json:"sender_email"`
-	SenderProfilePicture string `json:"sender_profile_picture"`
+	InviteID             int64      `json:"invite_id"`
+	PostID               int        `json:"post_id"`
+	Message              string     `json:"message"`
+	CreatedAt            string     `json:"created_at"`
+	Status               string     `json:"status"`
+	SenderUsername       string     `json:"sender_username"`
+	SenderNome           string     `json:"sender_nome"`
+	SenderCognome        string     `json:"sender_cognome"`
+	SenderEmail          string     `json:"sender_email"`
+	SenderProfilePicture string     `json:"sender_profile_picture"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty"`
+}
+
+// Stati di un invito a un evento
+const (
+	EventInviteStatusPending  = "pending"
+	EventInviteStatusAccepted = "accepted"
+	EventInviteStatusRejected = "rejected"
+	EventInviteStatusExpired  = "expired" // la partita è iniziata senza risposta
+	EventInviteStatusRevoked  = "revoked" // ritirato dal mittente
+)
+
+// Stati della partecipazione a un evento
+const (
+	ParticipantStatusConfirmed  = "confirmed"
+	ParticipantStatusWaitlisted = "waitlisted"
+	ParticipantStatusTentative  = "tentative"
+	ParticipantStatusDeclined   = "declined"
+	ParticipantStatusCancelled  = "cancelled"
+	ParticipantStatusNoShow     = "no_show"
+	ParticipantStatusPending    = "pending"
+	ParticipantStatusRejected   = "rejected"
+	ParticipantStatusRemoved    = "removed"
+)
+
+// Politiche di iscrizione a un evento, scelte dall'organizzatore
+const (
+	JoinPolicyOpen        = "open"
+	JoinPolicyApproval    = "approval"
+	JoinPolicyFriendsOnly = "friends_only"
+	JoinPolicyInviteOnly  = "invite_only"
+)
+
+// EventSettings contiene le impostazioni di un evento gestite dall'organizzatore
+type EventSettings struct {
+	PostID     int       `json:"post_id"`
+	JoinPolicy string    `json:"join_policy"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// EventJoinRequest rappresenta una richiesta di partecipazione in attesa di approvazione
+type EventJoinRequest struct {
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	Nome        string    `json:"nome"`
+	Cognome     string    `json:"cognome"`
+	ProfilePic  string    `json:"profile_picture"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// ParticipationChange rappresenta l'esito di un cambio di stato della partecipazione
+type ParticipationChange struct {
+	PreviousStatus   string  `json:"previous_status,omitempty"`
+	Status           string  `json:"status"`
+	WaitlistPosition int     `json:"waitlist_position,omitempty"`
+	PromotedUserIDs  []int64 `json:"promoted_user_ids,omitempty"`
+	// Impegni sovrapposti, quando l'iscrizione è stata forzata
+	Conflicts []Commitment `json:"conflicts,omitempty"`
+}
+
+// Livelli di visibilità del profilo nelle liste dei partecipanti
+const (
+	ProfileVisibilityFull   = "full"   // username, nome, cognome e immagine profilo
+	ProfileVisibilityBasic  = "basic"  // solo username e immagine profilo
+	ProfileVisibilityHidden = "hidden" // partecipante anonimo
+)
+
+// PrivacySettings indica cosa vedono dell'utente, nelle liste dei partecipanti,
+// gli sconosciuti, gli amici e chi partecipa alla stessa partita
+type PrivacySettings struct {
+	Strangers      string `json:"strangers"`
+	Friends        string `json:"friends"`
+	CoParticipants string `json:"co_participants"`
+}
+
+// DefaultPrivacySettings restituisce le impostazioni di chi non le ha mai modificate
+func DefaultPrivacySettings() PrivacySettings {
+	return PrivacySettings{
+		Strangers:      ProfileVisibilityBasic,
+		Friends:        ProfileVisibilityFull,
+		CoParticipants: ProfileVisibilityFull,
+	}
+}
+
+// EventParticipant è un partecipante letto dal database, con i dati completi e
+// le sue impostazioni di privacy: non va mai restituito così com'è ai client
+type EventParticipant struct {
+	UserID          int64
+	Username        string
+	Nome            string
+	Cognome         string
+	Email           string
+	ProfilePic      string
+	Status          string
+	RegisteredAt    time.Time
+	StatusUpdatedAt time.Time
+	Privacy         PrivacySettings
+}
+
+// ParticipantView è un partecipante come lo vede chi consulta la lista
+type ParticipantView struct {
+	UserID          int64     `json:"user_id,omitempty"`
+	Username        string    `json:"username,omitempty"`
+	Nome            string    `json:"nome,omitempty"`
+	Cognome         string    `json:"cognome,omitempty"`
+	Email           string    `json:"email,omitempty"` // solo per gli amministratori
+	ProfilePic      string    `json:"profile_picture,omitempty"`
+	Anonymous       bool      `json:"anonymous,omitempty"`
+	IsFriend        bool      `json:"is_friend,omitempty"`
+	Status          string    `json:"status"`
+	RegisteredAt    time.Time `json:"registered_at"`
+	StatusUpdatedAt time.Time `json:"status_updated_at"`
 }
 
 // NotificationType enum per i tipi di notifica
 type NotificationType string
 
 const (
-	NotificationTypeFriendRequest NotificationType = "friend_request"
-	NotificationTypeEventInvite   NotificationType = "event_invite"
-	NotificationTypePostComment   NotificationType = "post_comment"
-	NotificationTypeGeneral       NotificationType = "general"
+	NotificationTypeFriendRequest    NotificationType = "friend_request"
+	NotificationTypeEventInvite      NotificationType = "event_invite"
+	NotificationTypePostComment      NotificationType = "post_comment"
+	NotificationTypeGeneral          NotificationType = "general"
+	NotificationTypeEventUpdate      NotificationType = "event_update"
+	NotificationTypeMatchReminder    NotificationType = "match_reminder"
+	NotificationTypeSeriesOccurrence NotificationType = "series_occurrence"
+	NotificationTypeFieldAlert       NotificationType = "field_alert"
 )
 
 // NotificationStatus enum per lo stato della notifica
@@ -108,6 +227,7 @@ type Notification struct {
 	RelatedID  *int64             `json:"related_id,omitempty"`
 	SenderID   *int64             `json:"sender_id,omitempty"`
 	SenderInfo *SenderInfo        `json:"sender_info,omitempty"`
+	Post       *PostSummary       `json:"post,omitempty"` // partita a cui si riferisce la notifica
 	CreatedAt  time.Time          `json:"created_at"`
 	UpdatedAt  time.Time          `json:"updated_at"`
 	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
@@ -154,4 +274,539 @@ type BanUserRequest struct {
     UserID int64  `json:"user_id"`
     Reason string `json:"reason,omitempty"` 
     Notes  string `json:"notes"`
-}
\ No newline at end of file
+}
+
+// InviteCode rappresenta un codice invito per la registrazione in modalità closed-beta
+type InviteCode struct {
+	ID            int64      `json:"id"`
+	Code          string     `json:"code"`
+	CreatedBy     *int64     `json:"created_by,omitempty"`
+	CreatedByName string     `json:"created_by_username,omitempty"`
+	MaxUses       int        `json:"max_uses"`
+	Uses          int        `json:"uses"`
+	Role          string     `json:"role,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	IsActive      bool       `json:"is_active"`
+	Note          string     `json:"note,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// CreateInviteCodeRequest rappresenta la richiesta di generazione di un codice invito
+type CreateInviteCodeRequest struct {
+	MaxUses        int    `json:"max_uses"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"`
+	Role           string `json:"role,omitempty"`
+	Note           string `json:"note,omitempty"`
+}
+
+// InviteCodeRedemption rappresenta l'utilizzo di un codice invito da parte di un nuovo utente
+type InviteCodeRedemption struct {
+	ID         int64     `json:"id"`
+	CodeID     int64     `json:"code_id"`
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// CalendarSubscription rappresenta l'URL segreto del feed calendario di un utente
+type CalendarSubscription struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CalendarEntry contiene i dati di una partita (post, campo e partecipazione) da esportare in iCalendar
+type CalendarEntry struct {
+	PostID       int
+	Titolo       string
+	Sport        string
+	Livello      string
+	Commento     string
+	Citta        string
+	Provincia    string
+	StartsAt     time.Time // data_partita + ora_partita, ora locale senza fuso
+	Status       string    // stato della partecipazione dell'utente (vuoto per il download singolo)
+	LastModified time.Time
+	FieldName    string
+	FieldAddress string
+	Latitude     *float64
+	Longitude    *float64
+}
+
+// NotificationPreferences contiene le preferenze di notifica di un utente
+type NotificationPreferences struct {
+	MatchReminders bool `json:"match_reminders"`
+}
+
+// MatchReminder rappresenta un promemoria da inviare a un partecipante confermato
+type MatchReminder struct {
+	UserID   int64
+	PostID   int
+	Titolo   string
+	StartsAt time.Time // data_partita + ora_partita, ora locale senza fuso
+	Kind     string
+}
+
+// JobRun rappresenta un'esecuzione di un job schedulato
+type JobRun struct {
+	ID           int64      `json:"id"`
+	JobName      string     `json:"job_name"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	Instance     string     `json:"instance"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// Origine dell'annullamento di un evento
+const (
+	CancellationSourceAdmin  = "admin"
+	CancellationSourceAuthor = "author"
+)
+
+// EventCancellation descrive l'annullamento di un evento a seguito dell'eliminazione del post
+type EventCancellation struct {
+	PostID           int        `json:"post_id"`
+	Titolo           string     `json:"titolo"`
+	StartsAt         *time.Time `json:"starts_at,omitempty"`
+	Reason           string     `json:"reason,omitempty"`
+	Source           string     `json:"source"`
+	CancelledBy      *int64     `json:"cancelled_by,omitempty"`
+	CancelledAt      time.Time  `json:"cancelled_at"`
+	Participants     int        `json:"participants"`
+	Invites          int        `json:"invites"`
+	Favorites        int        `json:"favorites"`
+	NotifiedUsers    int        `json:"notified_users"`
+	AlreadyCancelled bool       `json:"already_cancelled,omitempty"`
+}
+
+// Origine del punteggio di abilità usato per bilanciare le squadre
+const (
+	SkillSourceOrganizer = "organizer"
+	SkillSourceHistory   = "history"
+	SkillSourceDefault   = "default"
+)
+
+// TeamPlayer rappresenta un giocatore assegnato a una squadra
+type TeamPlayer struct {
+	UserID      int64   `json:"user_id"`
+	Username    string  `json:"username"`
+	ProfilePic  string  `json:"profile_picture"`
+	Skill       float64 `json:"skill,omitempty"`
+	SkillSource string  `json:"skill_source,omitempty"`
+	Anonymous   bool    `json:"anonymous,omitempty"` // nascosto dalle impostazioni di privacy
+}
+
+// Team rappresenta una squadra generata per un evento
+type Team struct {
+	Number     int          `json:"number"`
+	Players    []TeamPlayer `json:"players"`
+	TotalSkill float64      `json:"total_skill,omitempty"`
+}
+
+// EventTeams rappresenta la suddivisione in squadre dei partecipanti di un evento
+type EventTeams struct {
+	PostID    int        `json:"post_id"`
+	TeamCount int        `json:"team_count"`
+	Seed      int64      `json:"seed"`
+	Pairs     [][2]int64 `json:"pairs,omitempty"`
+	Teams     []Team     `json:"teams"`
+	Locked    bool       `json:"locked"`
+	LockedAt  *time.Time `json:"locked_at,omitempty"`
+}
+
+// EventSeries rappresenta una serie di partite ricorrenti generate da un post modello
+type EventSeries struct {
+	ID               int64                `json:"id"`
+	TemplatePostID   int                  `json:"template_post_id"`
+	OrganizerID      int64                `json:"organizer_id"`
+	RRule            string               `json:"rrule"`
+	DTStart          time.Time            `json:"dtstart"`
+	HorizonDays      int                  `json:"horizon_days"`
+	Active           bool                 `json:"active"`
+	LastOccurrenceAt time.Time            `json:"last_occurrence_at"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	Roster           []SeriesRosterMember `json:"roster,omitempty"`
+	Upcoming         []SeriesOccurrence   `json:"upcoming,omitempty"`
+}
+
+// SeriesRosterMember è un giocatore iscritto automaticamente alle occorrenze
+type SeriesRosterMember struct {
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	ProfilePic string    `json:"profile_picture"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// SeriesOccurrence è una data della serie; PostID è valorizzato se il post è già stato generato
+type SeriesOccurrence struct {
+	StartsAt time.Time `json:"starts_at"`
+	PostID   *int      `json:"post_id,omitempty"`
+	OptedOut bool      `json:"opted_out"`
+}
+
+// SeriesEnrollment è l'iscrizione automatica di un giocatore del roster a una nuova occorrenza
+type SeriesEnrollment struct {
+	UserID           int64  `json:"user_id"`
+	Status           string `json:"status"`
+	WaitlistPosition int    `json:"waitlist_position,omitempty"`
+}
+
+// GeneratedOccurrence descrive un'occorrenza appena creata dal job delle serie
+type GeneratedOccurrence struct {
+	SeriesID    int64              `json:"series_id"`
+	PostID      int                `json:"post_id"`
+	Titolo      string             `json:"titolo"`
+	StartsAt    time.Time          `json:"starts_at"`
+	OrganizerID int64              `json:"organizer_id"`
+	Enrollments []SeriesEnrollment `json:"enrollments"`
+}
+
+// PlayerRating è la valutazione di un partecipante da parte di un altro dopo la partita.
+// Se NoShow è true il giocatore non si è presentato e i voti sono assenti.
+type PlayerRating struct {
+	PostID        int       `json:"post_id"`
+	RaterID       int64     `json:"rater_id"`
+	RatedID       int64     `json:"rated_id"`
+	Sportsmanship *int      `json:"sportsmanship,omitempty"`
+	Skill         *int      `json:"skill,omitempty"`
+	Punctuality   *int      `json:"punctuality,omitempty"`
+	NoShow        bool      `json:"no_show"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RateableParticipant è un compagno di partita valutabile, con l'eventuale voto già dato
+type RateableParticipant struct {
+	UserID     int64         `json:"user_id"`
+	Username   string        `json:"username"`
+	ProfilePic string        `json:"profile_picture"`
+	MyRating   *PlayerRating `json:"my_rating,omitempty"`
+}
+
+// Reputation riassume le valutazioni ricevute da un giocatore
+type Reputation struct {
+	UserID        int64   `json:"user_id"`
+	Sportsmanship float64 `json:"sportsmanship"`
+	Skill         float64 `json:"skill"`
+	Punctuality   float64 `json:"punctuality"`
+	Overall       float64 `json:"overall"`
+	Raters        int     `json:"raters"`
+	EventsPlayed  int     `json:"events_played"`
+	NoShows       int     `json:"no_shows"`
+	NoShowRate    float64 `json:"no_show_rate"`
+}
+
+// Stati del risultato di una partita
+const (
+	MatchResultStatusPending   = "pending"
+	MatchResultStatusConfirmed = "confirmed"
+	MatchResultStatusDisputed  = "disputed"
+)
+
+// MatchResultPlayer è un giocatore di una delle due squadre di un risultato
+type MatchResultPlayer struct {
+	UserID       int64    `json:"user_id"`
+	Username     string   `json:"username"`
+	ProfilePic   string   `json:"profile_picture"`
+	RatingBefore *float64 `json:"rating_before,omitempty"`
+	RatingAfter  *float64 `json:"rating_after,omitempty"`
+}
+
+// MatchResult rappresenta il risultato finale di una partita con le squadre
+type MatchResult struct {
+	PostID      int                 `json:"post_id"`
+	Sport       string              `json:"sport"`
+	Provincia   string              `json:"provincia"`
+	PlayedAt    time.Time           `json:"played_at"`
+	ScoreA      int                 `json:"score_a"`
+	ScoreB      int                 `json:"score_b"`
+	Status      string              `json:"status"`
+	RecordedBy  *int64              `json:"recorded_by,omitempty"`
+	TeamA       []MatchResultPlayer `json:"team_a"`
+	TeamB       []MatchResultPlayer `json:"team_b"`
+	Agreements  int                 `json:"agreements"`
+	Disputes    int                 `json:"disputes"`
+	Required    int                 `json:"required"`
+	MyVote      *bool               `json:"my_vote,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	ConfirmedAt *time.Time          `json:"confirmed_at,omitempty"`
+}
+
+// LeaderboardEntry è una posizione nella classifica di uno sport
+type LeaderboardEntry struct {
+	Rank        int     `json:"rank"`
+	UserID      int64   `json:"user_id"`
+	Username    string  `json:"username"`
+	ProfilePic  string  `json:"profile_picture"`
+	Rating      float64 `json:"rating"`
+	Games       int     `json:"games"`
+	Provisional bool    `json:"provisional"`
+}
+
+// RatingChange è la variazione di punteggio di un giocatore in una partita
+type RatingChange struct {
+	PostID       int       `json:"post_id"`
+	PlayedAt     time.Time `json:"played_at"`
+	RatingBefore float64   `json:"rating_before"`
+	RatingAfter  float64   `json:"rating_after"`
+}
+
+// SportRating è il punteggio Elo di un giocatore in uno sport
+type SportRating struct {
+	Sport       string         `json:"sport"`
+	Rating      float64        `json:"rating"`
+	Games       int            `json:"games"`
+	Provisional bool           `json:"provisional"`
+	History     []RatingChange `json:"history"`
+}
+
+// Ruoli di un utente in un impegno del calendario
+const (
+	CommitmentRoleOrganizer   = "organizer"
+	CommitmentRoleParticipant = "participant"
+)
+
+// Commitment è una partita nel calendario dell'utente, come organizzatore o partecipante
+type Commitment struct {
+	PostID        int       `json:"post_id"`
+	Titolo        string    `json:"titolo"`
+	Sport         string    `json:"sport"`
+	Citta         string    `json:"citta"`
+	Provincia     string    `json:"provincia"`
+	StartsAt      time.Time `json:"starts_at"`
+	EndsAt        time.Time `json:"ends_at"`
+	Role          string    `json:"role"`
+	Status        string    `json:"status,omitempty"`
+	ConflictsWith []int     `json:"conflicts_with,omitempty"`
+}
+
+// Recommendation è una partita consigliata all'utente con le ragioni del consiglio
+type Recommendation struct {
+	PostID       int       `json:"post_id"`
+	Titolo       string    `json:"titolo"`
+	Sport        string    `json:"sport"`
+	Livello      string    `json:"livello"`
+	Citta        string    `json:"citta"`
+	Provincia    string    `json:"provincia"`
+	Campo        string    `json:"campo,omitempty"`
+	StartsAt     time.Time `json:"starts_at"`
+	SpotsLeft    int       `json:"spots_left"`
+	FriendsGoing []string  `json:"friends_going,omitempty"`
+	DistanceKm   *float64  `json:"distance_km,omitempty"`
+	Score        float64   `json:"score"`
+	Reasons      []string  `json:"reasons"`
+}
+
+// FriendGroupMember è un amico salvato in un gruppo
+type FriendGroupMember struct {
+	UserID     int64  `json:"user_id"`
+	Username   string `json:"username"`
+	ProfilePic string `json:"profile_picture"`
+}
+
+// FriendGroup è un gruppo di amici salvato dall'utente per gli inviti
+type FriendGroup struct {
+	ID        int64               `json:"id"`
+	Name      string              `json:"name"`
+	Members   []FriendGroupMember `json:"members"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// Esito dell'invito di un destinatario in un invio multiplo
+const (
+	BulkInviteStatusInvited = "invited"
+	BulkInviteStatusSkipped = "skipped"
+)
+
+// Motivi per cui un destinatario di un invio multiplo viene saltato
+const (
+	BulkInviteReasonSelf               = "self"
+	BulkInviteReasonUserNotFound       = "user_not_found"
+	BulkInviteReasonNotFriend          = "not_friend"
+	BulkInviteReasonOrganizer          = "organizer"
+	BulkInviteReasonAlreadyParticipant = "already_participant"
+	BulkInviteReasonAlreadyInvited     = "already_invited"
+	BulkInviteReasonExcluded           = "excluded"
+	BulkInviteReasonCooldown           = "cooldown"
+)
+
+// BulkInviteResult è l'esito dell'invito per un singolo destinatario
+type BulkInviteResult struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SentEventInvite è un invito visto dal mittente
+type SentEventInvite struct {
+	InviteID               int64      `json:"invite_id"`
+	PostID                 int        `json:"post_id"`
+	Titolo                 string     `json:"titolo,omitempty"`
+	ReceiverID             int64      `json:"receiver_id"`
+	ReceiverUsername       string     `json:"receiver_username"`
+	ReceiverProfilePicture string     `json:"receiver_profile_picture"`
+	Message                string     `json:"message"`
+	Status                 string     `json:"status"`
+	CreatedAt              time.Time  `json:"created_at"`
+	RespondedAt            *time.Time `json:"responded_at,omitempty"`
+	ExpiresAt              *time.Time `json:"expires_at,omitempty"`
+	RemindedAt             *time.Time `json:"reminded_at,omitempty"`
+}
+
+// EventInviteReminder è un invito ancora senza risposta da ricordare al destinatario
+type EventInviteReminder struct {
+	InviteID       int64
+	ReceiverID     int64
+	SenderID       int64
+	SenderUsername string
+	PostID         int
+	Titolo         string
+	StartsAt       time.Time
+}
+
+// EventLink è un link di invito a una partita generato dall'organizzatore
+type EventLink struct {
+	ID          int64                 `json:"id"`
+	PostID      int                   `json:"post_id"`
+	Token       string                `json:"token"`
+	URL         string                `json:"url"`
+	MaxUses     *int                  `json:"max_uses,omitempty"` // nil = illimitato
+	Uses        int                   `json:"uses"`
+	ExpiresAt   time.Time             `json:"expires_at"`
+	RevokedAt   *time.Time            `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+	Active      bool                  `json:"active"`
+	Redemptions []EventLinkRedemption `json:"redemptions,omitempty"`
+}
+
+// EventLinkRedemption è l'utilizzo di un link di invito da parte di un utente
+type EventLinkRedemption struct {
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	ProfilePic string    `json:"profile_picture"`
+	Status     string    `json:"status"`     // stato della partecipazione ottenuto
+	Registered bool      `json:"registered"` // l'utente si è registrato aprendo il link
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// EventLinkPreview è l'anteprima della partita mostrata a chi apre un link di invito
+type EventLinkPreview struct {
+	PostID     int       `json:"post_id"`
+	Titolo     string    `json:"titolo"`
+	Sport      string    `json:"sport"`
+	Livello    string    `json:"livello"`
+	Citta      string    `json:"citta"`
+	Provincia  string    `json:"provincia"`
+	StartsAt   time.Time `json:"starts_at"`
+	Organizer  string    `json:"organizer"`
+	SpotsLeft  int       `json:"spots_left"`
+	JoinPolicy string    `json:"join_policy"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// PostSummary contiene i dati di un post (gestito dal backend Python) mostrati
+// accanto a notifiche, preferiti e partecipazioni
+type PostSummary struct {
+	ID              int           `json:"id"`
+	Titolo          string        `json:"titolo"`
+	Sport           string        `json:"sport"`
+	Livello         string        `json:"livello"`
+	Citta           string        `json:"citta"`
+	Provincia       string        `json:"provincia"`
+	StartsAt        time.Time     `json:"starts_at"` // data_partita + ora_partita, ora locale senza fuso
+	NumeroGiocatori int           `json:"numero_giocatori"`
+	Field           *FieldSummary `json:"campo,omitempty"`
+}
+
+// FieldSummary contiene i dati del campo sportivo di un post
+type FieldSummary struct {
+	ID        int     `json:"id"`
+	Nome      string  `json:"nome"`
+	Indirizzo string  `json:"indirizzo"`
+	Citta     string  `json:"citta"`
+	Provincia string  `json:"provincia"`
+	Lat       float64 `json:"lat"`
+	Lng       float64 `json:"lng"`
+}
+
+// PostDetails è un post con il conteggio degli iscritti, incluso nelle liste
+// di preferiti e partecipazioni con expand=post
+type PostDetails struct {
+	PostSummary
+	ConfirmedCount int `json:"confirmed_count"`
+	WaitlistCount  int `json:"waitlist_count"`
+	SpotsLeft      int `json:"spots_left"`
+}
+
+// FavoriteEntry è un post salvato tra i preferiti
+type FavoriteEntry struct {
+	PostID  int          `json:"post_id"`
+	SavedAt time.Time    `json:"saved_at"`
+	Post    *PostDetails `json:"post,omitempty"` // nil senza expand=post o se il post è stato eliminato
+}
+
+// ParticipationEntry è una partecipazione dell'utente a un evento
+type ParticipationEntry struct {
+	PostID       int          `json:"post_id"`
+	Status       string       `json:"status"`
+	RegisteredAt time.Time    `json:"registered_at"`
+	Post         *PostDetails `json:"post,omitempty"` // nil senza expand=post o se il post è stato eliminato
+}
+
+// Visibilità di una raccolta di preferiti
+const (
+	CollectionVisibilityPrivate = "private" // solo il proprietario
+	CollectionVisibilityFriends = "friends" // gli amici del proprietario
+	CollectionVisibilityLink    = "link"    // chiunque abbia il link di condivisione
+)
+
+// FavoriteCollection è una raccolta di post preferiti con un nome; la raccolta
+// predefinita contiene i post salvati con "/favorites/add"
+type FavoriteCollection struct {
+	ID            int64                    `json:"id"`
+	OwnerID       int64                    `json:"owner_id"`
+	OwnerUsername string                   `json:"owner_username"`
+	Name          string                   `json:"name"`
+	Visibility    string                   `json:"visibility"`
+	IsDefault     bool                     `json:"is_default"`
+	ShareToken    string                   `json:"share_token,omitempty"` // solo per il proprietario
+	ItemCount     int                      `json:"item_count"`
+	FollowerCount int                      `json:"follower_count"`
+	IsFollowing   bool                     `json:"is_following"`
+	Items         []FavoriteCollectionItem `json:"items,omitempty"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+}
+
+// FavoriteCollectionItem è un post di una raccolta, nella posizione scelta dal proprietario
+type FavoriteCollectionItem struct {
+	PostID   int          `json:"post_id"`
+	Position int          `json:"position"`
+	AddedAt  time.Time    `json:"added_at"`
+	Post     *PostSummary `json:"post,omitempty"` // nil se il post è stato eliminato
+}
+
+// FavoriteField è un campo sportivo salvato tra i preferiti; con Alerts l'utente
+// viene avvisato delle nuove partite organizzate nel campo
+type FavoriteField struct {
+	FieldID int           `json:"field_id"`
+	Alerts  bool          `json:"alerts"`
+	SavedAt time.Time     `json:"saved_at"`
+	Field   *FieldSummary `json:"campo,omitempty"` // nil se il campo è stato eliminato
+}
+
+// FieldAlert è una nuova partita in un campo preferito, in attesa del prossimo riepilogo
+type FieldAlert struct {
+	UserID    int64
+	PostID    int
+	FieldID   int
+	FieldName string
+	Titolo    string
+	StartsAt  time.Time // data_partita + ora_partita, ora locale senza fuso
+}
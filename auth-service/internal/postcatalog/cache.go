@@ -0,0 +1,122 @@
+package postcatalog
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// cacheEntry è un post in cache; post nil indica un post inesistente, tenuto in
+// cache per non interrogare di nuovo la sorgente a ogni notifica
+type cacheEntry struct {
+	postID    int
+	post      *models.PostSummary
+	expiresAt time.Time
+}
+
+// CachedCatalog mette una cache LRU con scadenza davanti a un altro catalogo.
+// I post sono condivisi tra i chiamanti e non vanno modificati.
+type CachedCatalog struct {
+	source   PostCatalog
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // dal più al meno recente
+	entries map[int]*list.Element
+}
+
+func NewCachedCatalog(source PostCatalog, capacity int, ttl time.Duration) *CachedCatalog {
+	return &CachedCatalog{
+		source:   source,
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+	}
+}
+
+func (c *CachedCatalog) Get(ctx context.Context, postID int) (*models.PostSummary, error) {
+	posts, err := c.GetMany(ctx, []int{postID})
+	if err != nil {
+		return nil, err
+	}
+	post, ok := posts[postID]
+	if !ok {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
+// GetMany legge dalla sorgente, con una sola chiamata, solo i post assenti o scaduti
+func (c *CachedCatalog) GetMany(ctx context.Context, postIDs []int) (map[int]*models.PostSummary, error) {
+	ids := uniqueIDs(postIDs)
+	posts := make(map[int]*models.PostSummary, len(ids))
+
+	var missing []int
+	c.mu.Lock()
+	now := c.now()
+	for _, postID := range ids {
+		element, ok := c.entries[postID]
+		if !ok {
+			missing = append(missing, postID)
+			continue
+		}
+		entry := element.Value.(*cacheEntry)
+		if now.After(entry.expiresAt) {
+			c.remove(element)
+			missing = append(missing, postID)
+			continue
+		}
+		c.order.MoveToFront(element)
+		if entry.post != nil {
+			posts[postID] = entry.post
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return posts, nil
+	}
+
+	loaded, err := c.source.GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := c.now().Add(c.ttl)
+	for _, postID := range missing {
+		post := loaded[postID]
+		c.store(&cacheEntry{postID: postID, post: post, expiresAt: expiresAt})
+		if post != nil {
+			posts[postID] = post
+		}
+	}
+	return posts, nil
+}
+
+// store inserisce o aggiorna una voce, eliminando la meno usata oltre la capacità
+func (c *CachedCatalog) store(entry *cacheEntry) {
+	if element, ok := c.entries[entry.postID]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[entry.postID] = c.order.PushFront(entry)
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *CachedCatalog) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).postID)
+}
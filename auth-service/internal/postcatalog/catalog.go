@@ -0,0 +1,66 @@
+package postcatalog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// ErrPostNotFound è restituito quando il post non esiste (o è stato eliminato)
+var ErrPostNotFound = errors.New("post non trovato")
+
+// Titolo usato quando il post non è disponibile
+const fallbackTitle = "Evento sportivo"
+
+// PostCatalog legge i dati dei post gestiti dal backend Python, così che
+// notifiche, preferiti e partecipazioni mostrino titoli, date e luoghi reali
+type PostCatalog interface {
+	// Get restituisce un post; ErrPostNotFound se non esiste
+	Get(ctx context.Context, postID int) (*models.PostSummary, error)
+	// GetMany restituisce i post trovati indicizzati per ID; quelli inesistenti sono omessi
+	GetMany(ctx context.Context, postIDs []int) (map[int]*models.PostSummary, error)
+}
+
+// New crea il catalogo configurato (db o http) con la cache LRU davanti
+func New(cfg config.PostCatalogConfig, db *sql.DB) (PostCatalog, error) {
+	var source PostCatalog
+	switch cfg.Backend {
+	case "", "db":
+		source = NewDBCatalog(db)
+	case "http":
+		source = NewHTTPCatalog(cfg.BaseURL, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	default:
+		return nil, fmt.Errorf("post catalog backend non supportato: %s", cfg.Backend)
+	}
+	return NewCachedCatalog(source, cfg.CacheSize, time.Duration(cfg.CacheTTLSeconds)*time.Second), nil
+}
+
+// Title restituisce il titolo del post, o un titolo generico se non è disponibile
+func Title(catalog PostCatalog, postID int) string {
+	if catalog == nil {
+		return fallbackTitle
+	}
+	post, err := catalog.Get(context.Background(), postID)
+	if err != nil || post.Titolo == "" {
+		return fallbackTitle
+	}
+	return post.Titolo
+}
+
+// uniqueIDs elimina gli ID duplicati o non validi mantenendo l'ordine
+func uniqueIDs(postIDs []int) []int {
+	seen := make(map[int]bool, len(postIDs))
+	ids := make([]int, 0, len(postIDs))
+	for _, postID := range postIDs {
+		if postID > 0 && !seen[postID] {
+			seen[postID] = true
+			ids = append(ids, postID)
+		}
+	}
+	return ids
+}
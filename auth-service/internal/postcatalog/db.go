@@ -0,0 +1,77 @@
+package postcatalog
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// DBCatalog legge i post direttamente dalle tabelle posts e sport_fields,
+// condivise con il backend Python
+type DBCatalog struct {
+	db *sql.DB
+}
+
+func NewDBCatalog(db *sql.DB) *DBCatalog {
+	return &DBCatalog{db: db}
+}
+
+func (c *DBCatalog) Get(ctx context.Context, postID int) (*models.PostSummary, error) {
+	posts, err := c.GetMany(ctx, []int{postID})
+	if err != nil {
+		return nil, err
+	}
+	post, ok := posts[postID]
+	if !ok {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
+func (c *DBCatalog) GetMany(ctx context.Context, postIDs []int) (map[int]*models.PostSummary, error) {
+	posts := make(map[int]*models.PostSummary, len(postIDs))
+	ids := uniqueIDs(postIDs)
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT p.id, p.titolo, p.sport, COALESCE(p.livello, ''), p.citta, p.provincia,
+			p.data_partita + p.ora_partita, COALESCE(p.numero_giocatori, 0),
+			f.id, f.nome, f.indirizzo, f.citta, f.provincia, f.lat, f.lng
+		FROM posts p
+		LEFT JOIN sport_fields f ON f.id = p.campo_id
+		WHERE p.id = ANY($1)`,
+		pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.PostSummary
+		var fieldID sql.NullInt64
+		var fieldNome, fieldIndirizzo, fieldCitta, fieldProvincia sql.NullString
+		var fieldLat, fieldLng sql.NullFloat64
+		if err := rows.Scan(&post.ID, &post.Titolo, &post.Sport, &post.Livello, &post.Citta, &post.Provincia,
+			&post.StartsAt, &post.NumeroGiocatori,
+			&fieldID, &fieldNome, &fieldIndirizzo, &fieldCitta, &fieldProvincia, &fieldLat, &fieldLng); err != nil {
+			return nil, err
+		}
+		if fieldID.Valid {
+			post.Field = &models.FieldSummary{
+				ID:        int(fieldID.Int64),
+				Nome:      fieldNome.String,
+				Indirizzo: fieldIndirizzo.String,
+				Citta:     fieldCitta.String,
+				Provincia: fieldProvincia.String,
+				Lat:       fieldLat.Float64,
+				Lng:       fieldLng.Float64,
+			}
+		}
+		posts[post.ID] = &post
+	}
+	return posts, rows.Err()
+}
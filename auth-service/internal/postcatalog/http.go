@@ -0,0 +1,136 @@
+package postcatalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Numero massimo di richieste contemporanee verso il backend Python in GetMany
+const maxConcurrentRequests = 8
+
+// HTTPCatalog legge i post dall'API del backend Python ("/posts/{id}/details"),
+// per i deployment in cui l'auth-service non accede alle sue tabelle
+type HTTPCatalog struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPCatalog(baseURL string, timeout time.Duration) *HTTPCatalog {
+	return &HTTPCatalog{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// postDetailsResponse è la risposta di "/posts/{id}/details"
+type postDetailsResponse struct {
+	Post struct {
+		ID              int    `json:"id"`
+		Titolo          string `json:"titolo"`
+		Sport           string `json:"sport"`
+		Livello         string `json:"livello"`
+		Citta           string `json:"citta"`
+		Provincia       string `json:"provincia"`
+		DataPartita     string `json:"data_partita"` // "2006-01-02"
+		OraPartita      string `json:"ora_partita"`  // "15:04:05"
+		NumeroGiocatori int    `json:"numero_giocatori"`
+		CampoID         *int   `json:"campo_id"`
+	} `json:"post"`
+}
+
+func (c *HTTPCatalog) Get(ctx context.Context, postID int) (*models.PostSummary, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/posts/%d/details", c.baseURL, postID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrPostNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backend Python: stato %d per il post %d", resp.StatusCode, postID)
+	}
+
+	var details postDetailsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return nil, err
+	}
+
+	post := &models.PostSummary{
+		ID:              postID,
+		Titolo:          details.Post.Titolo,
+		Sport:           details.Post.Sport,
+		Livello:         details.Post.Livello,
+		Citta:           details.Post.Citta,
+		Provincia:       details.Post.Provincia,
+		NumeroGiocatori: details.Post.NumeroGiocatori,
+	}
+	post.StartsAt, err = parseStartsAt(details.Post.DataPartita, details.Post.OraPartita)
+	if err != nil {
+		return nil, err
+	}
+	// L'API non include i dati del campo: ne riportiamo solo l'ID
+	if details.Post.CampoID != nil {
+		post.Field = &models.FieldSummary{ID: *details.Post.CampoID}
+	}
+	return post, nil
+}
+
+// GetMany esegue una richiesta per post (l'API non ha una lettura multipla),
+// al massimo maxConcurrentRequests alla volta
+func (c *HTTPCatalog) GetMany(ctx context.Context, postIDs []int) (map[int]*models.PostSummary, error) {
+	ids := uniqueIDs(postIDs)
+	posts := make(map[int]*models.PostSummary, len(ids))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	slots := make(chan struct{}, maxConcurrentRequests)
+
+	for _, postID := range ids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(postID int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			post, err := c.Get(ctx, postID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				posts[postID] = post
+			case err != ErrPostNotFound && firstErr == nil:
+				firstErr = err
+			}
+		}(postID)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return posts, nil
+}
+
+// parseStartsAt combina data e ora della partita (ora locale senza fuso)
+func parseStartsAt(date, clock string) (time.Time, error) {
+	if len(clock) == len("15:04") {
+		clock += ":00"
+	}
+	return time.Parse("2006-01-02 15:04:05", date+" "+clock)
+}
@@ -0,0 +1,95 @@
+package privacy
+
+import (
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Viewer descrive chi consulta la lista dei partecipanti di una partita
+type Viewer struct {
+	UserID        int64          // 0 = richiesta anonima o di un altro servizio
+	IsAdmin       bool           // vede tutto, email comprese
+	IsOrganizer   bool           // vede almeno lo username di chi gestisce
+	IsParticipant bool           // iscritto (o in forse) alla stessa partita
+	FriendIDs     map[int64]bool // amici del viewer tra i partecipanti
+}
+
+// rank ordina i livelli dal più restrittivo al più permissivo
+var rank = map[string]int{
+	models.ProfileVisibilityHidden: 0,
+	models.ProfileVisibilityBasic:  1,
+	models.ProfileVisibilityFull:   2,
+}
+
+// ValidVisibility indica se il livello di visibilità è tra quelli supportati
+func ValidVisibility(level string) bool {
+	_, ok := rank[level]
+	return ok
+}
+
+// Visibility restituisce il livello con cui il viewer vede il partecipante: tra
+// le impostazioni che si applicano (sconosciuto, amico, co-partecipante) vale la
+// più permissiva. L'utente vede sempre se stesso per intero e l'organizzatore
+// non vede mai partecipanti anonimi, altrimenti non potrebbe gestirli.
+func (v Viewer) Visibility(participant models.EventParticipant) string {
+	if v.IsAdmin || (v.UserID != 0 && v.UserID == participant.UserID) {
+		return models.ProfileVisibilityFull
+	}
+
+	level := participant.Privacy.Strangers
+	if v.FriendIDs[participant.UserID] {
+		level = morePermissive(level, participant.Privacy.Friends)
+	}
+	if v.IsParticipant {
+		level = morePermissive(level, participant.Privacy.CoParticipants)
+	}
+	if v.IsOrganizer {
+		level = morePermissive(level, models.ProfileVisibilityBasic)
+	}
+	return level
+}
+
+// BuildParticipantViews applica le impostazioni di privacy di ogni partecipante
+// e restituisce la lista come la vede il viewer. Le email sono esposte solo agli
+// amministratori.
+func BuildParticipantViews(viewer Viewer, participants []models.EventParticipant) []models.ParticipantView {
+	views := make([]models.ParticipantView, 0, len(participants))
+	for _, participant := range participants {
+		view := models.ParticipantView{
+			Status:          participant.Status,
+			RegisteredAt:    participant.RegisteredAt,
+			StatusUpdatedAt: participant.StatusUpdatedAt,
+		}
+
+		switch viewer.Visibility(participant) {
+		case models.ProfileVisibilityFull:
+			view.Nome = participant.Nome
+			view.Cognome = participant.Cognome
+			fallthrough
+		case models.ProfileVisibilityBasic:
+			view.UserID = participant.UserID
+			view.Username = participant.Username
+			view.ProfilePic = participant.ProfilePic
+			view.IsFriend = viewer.FriendIDs[participant.UserID]
+		default:
+			view.Anonymous = true
+		}
+
+		if viewer.IsAdmin {
+			view.Email = participant.Email
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// morePermissive restituisce il più permissivo tra due livelli; un livello
+// sconosciuto conta come "hidden"
+func morePermissive(a, b string) string {
+	if rank[b] > rank[a] {
+		return b
+	}
+	if !ValidVisibility(a) {
+		return models.ProfileVisibilityHidden
+	}
+	return a
+}
@@ -0,0 +1,213 @@
+package recommend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Numero massimo di ragioni mostrate per ogni partita consigliata
+const maxReasons = 3
+
+// Sotto questi posti liberi la disponibilità viene citata tra le ragioni
+const fewSpotsLeft = 3
+
+// Raggio medio della Terra in km
+const earthRadiusKm = 6371.0
+
+// Weights sono i pesi dei singoli segnali; ogni segnale vale tra 0 e 1
+type Weights struct {
+	Sport    float64
+	Level    float64
+	Favorite float64
+	Friends  float64
+	Distance float64
+	Capacity float64
+}
+
+// Point è la posizione di un campo sportivo
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Profile raccoglie le abitudini dell'utente ricavate da partite giocate e preferiti
+type Profile struct {
+	played         int
+	sports         map[string]int
+	levels         map[string]int
+	favorites      int
+	favoritePosts  map[int]bool
+	favoriteSports map[string]int
+	fields         []Point
+}
+
+// NewProfile crea un profilo vuoto (utente senza storico)
+func NewProfile() *Profile {
+	return &Profile{
+		sports:         make(map[string]int),
+		levels:         make(map[string]int),
+		favoritePosts:  make(map[int]bool),
+		favoriteSports: make(map[string]int),
+	}
+}
+
+// AddPlayed registra una partita giocata; field è nil se il post non indica il campo
+func (p *Profile) AddPlayed(sport, level string, field *Point) {
+	p.played++
+	p.sports[normalize(sport)]++
+	p.levels[normalize(level)]++
+	if field != nil {
+		p.fields = append(p.fields, *field)
+	}
+}
+
+// AddFavorite registra un post tra i preferiti dell'utente
+func (p *Profile) AddFavorite(postID int, sport string) {
+	p.favorites++
+	p.favoritePosts[postID] = true
+	p.favoriteSports[normalize(sport)]++
+}
+
+// Candidate è una partita futura da valutare
+type Candidate struct {
+	Event     models.Recommendation
+	Field     *Point
+	Capacity  int
+	Confirmed int
+}
+
+// reason è una ragione del consiglio con il contributo al punteggio
+type reason struct {
+	text   string
+	weight float64
+}
+
+// Rank assegna un punteggio a ogni candidato e restituisce i migliori limit,
+// ciascuno con le ragioni che hanno contribuito di più
+func Rank(profile *Profile, candidates []Candidate, weights Weights, maxDistanceKm float64, limit int) []models.Recommendation {
+	ranked := make([]models.Recommendation, 0, len(candidates))
+	for _, candidate := range candidates {
+		ranked = append(ranked, score(profile, candidate, weights, maxDistanceKm))
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].StartsAt.Before(ranked[j].StartsAt)
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+func score(profile *Profile, candidate Candidate, weights Weights, maxDistanceKm float64) models.Recommendation {
+	event := candidate.Event
+	sport := normalize(event.Sport)
+	var reasons []reason
+
+	add := func(weight, signal float64, text string) {
+		if signal <= 0 {
+			return
+		}
+		event.Score += weight * signal
+		if text != "" && weight > 0 {
+			reasons = append(reasons, reason{text: text, weight: weight * signal})
+		}
+	}
+
+	if profile.played > 0 {
+		played := profile.sports[sport]
+		add(weights.Sport, float64(played)/float64(profile.played),
+			pluralize(played, fmt.Sprintf("Hai già giocato una partita di %s", event.Sport),
+				fmt.Sprintf("Hai già giocato %d partite di %s", played, event.Sport)))
+
+		add(weights.Level, float64(profile.levels[normalize(event.Livello)])/float64(profile.played),
+			fmt.Sprintf("Livello %s, come le partite che giochi", event.Livello))
+	}
+
+	if profile.favoritePosts[event.PostID] {
+		add(weights.Favorite, 1, "È tra i tuoi preferiti")
+	} else if profile.favorites > 0 {
+		add(weights.Favorite, 0.5*float64(profile.favoriteSports[sport])/float64(profile.favorites),
+			fmt.Sprintf("Tra i tuoi preferiti ci sono partite di %s", event.Sport))
+	}
+
+	add(weights.Friends, math.Min(float64(len(event.FriendsGoing))/3, 1), friendsReason(event.FriendsGoing))
+
+	if candidate.Field != nil && len(profile.fields) > 0 && maxDistanceKm > 0 {
+		nearest := math.Inf(1)
+		for _, field := range profile.fields {
+			nearest = math.Min(nearest, DistanceKm(*candidate.Field, field))
+		}
+		distance := math.Round(nearest*10) / 10
+		event.DistanceKm = &distance
+		add(weights.Distance, 1-nearest/maxDistanceKm,
+			fmt.Sprintf("A %.1f km dai campi in cui giochi di solito", distance))
+	}
+
+	event.SpotsLeft = candidate.Capacity - candidate.Confirmed
+	if event.SpotsLeft < 0 {
+		event.SpotsLeft = 0
+	}
+	if event.SpotsLeft > 0 {
+		text := ""
+		if event.SpotsLeft <= fewSpotsLeft {
+			text = pluralize(event.SpotsLeft, "Ultimo posto disponibile", fmt.Sprintf("Ancora %d posti liberi", event.SpotsLeft))
+		}
+		add(weights.Capacity, 1, text)
+	}
+
+	sort.SliceStable(reasons, func(i, j int) bool { return reasons[i].weight > reasons[j].weight })
+	if len(reasons) > maxReasons {
+		reasons = reasons[:maxReasons]
+	}
+	event.Reasons = make([]string, 0, len(reasons))
+	for _, r := range reasons {
+		event.Reasons = append(event.Reasons, r.text)
+	}
+
+	event.Score = math.Round(event.Score*1000) / 1000
+	return event
+}
+
+// DistanceKm restituisce la distanza in linea d'aria tra due punti (formula dell'emisenoverso)
+func DistanceKm(a, b Point) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(b.Lat - a.Lat)
+	dLng := toRad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+func friendsReason(friends []string) string {
+	switch len(friends) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("%s partecipa", friends[0])
+	case 2:
+		return fmt.Sprintf("%s e %s partecipano", friends[0], friends[1])
+	default:
+		return fmt.Sprintf("%d amici partecipano", len(friends))
+	}
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
@@ -0,0 +1,83 @@
+package reputation
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// PriorMean è il punteggio verso cui vengono attirati i giocatori con pochi voti
+	PriorMean = 3.5
+	// PriorWeight è il numero di voti "virtuali" pari a PriorMean aggiunti a ogni media
+	PriorWeight = 5.0
+	// MaxVotesPerEvent è il peso massimo complessivo dei voti ricevuti in una singola partita
+	MaxVotesPerEvent = 3.0
+	// Sotto questo numero di voti non si cercano valori anomali
+	minVotesForClamp = 5
+	// Scostamento massimo di un voto dalla mediana dei voti
+	maxDeviation = 1.5
+)
+
+// Vote è il voto (scala 1-5) di un valutatore; EventRaters è il numero di
+// valutatori che hanno votato il giocatore nella stessa partita
+type Vote struct {
+	Value       float64
+	EventRaters int
+}
+
+// Score aggrega i voti in un punteggio resistente agli attacchi di gruppo:
+//   - i voti di una stessa partita pesano al massimo MaxVotesPerEvent in
+//     totale, così un gruppo numeroso in una partita conta come pochi voti;
+//   - con abbastanza voti ciascun voto viene limitato a ±1.5 dalla mediana,
+//     così una minoranza di voti estremi non può affossare il punteggio;
+//   - la media pesata viene smussata in modo bayesiano verso PriorMean, così
+//     pochi voti hanno un effetto limitato.
+//
+// Deduplicare i voti (uno per valutatore) è compito del chiamante.
+func Score(votes []Vote) float64 {
+	if len(votes) == 0 {
+		return PriorMean
+	}
+
+	values := make([]float64, len(votes))
+	for i, vote := range votes {
+		values[i] = vote.Value
+	}
+
+	if len(values) >= minVotesForClamp {
+		median := Median(values)
+		for i, v := range values {
+			values[i] = math.Max(median-maxDeviation, math.Min(median+maxDeviation, v))
+		}
+	}
+
+	sum, weights := 0.0, 0.0
+	for i, vote := range votes {
+		weight := 1.0
+		if float64(vote.EventRaters) > MaxVotesPerEvent {
+			weight = MaxVotesPerEvent / float64(vote.EventRaters)
+		}
+		sum += values[i] * weight
+		weights += weight
+	}
+
+	smoothed := (PriorMean*PriorWeight + sum) / (PriorWeight + weights)
+	return math.Round(smoothed*100) / 100
+}
+
+// Median restituisce la mediana dei valori (0 se vuoti)
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
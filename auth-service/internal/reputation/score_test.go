@@ -0,0 +1,72 @@
+package reputation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func votes(eventRaters int, values ...float64) []Vote {
+	result := make([]Vote, len(values))
+	for i, v := range values {
+		result[i] = Vote{Value: v, EventRaters: eventRaters}
+	}
+	return result
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		votes []Vote
+		want  float64
+	}{
+		{"nessun voto", nil, PriorMean},
+		{"un voto alto pesa poco", votes(1, 5), 3.75},
+		{"un voto basso pesa poco", votes(1, 1), 3.08},
+		{"voti concordi", votes(1, 5, 5, 5, 5, 5), 4.25},
+		// Sotto minVotesForClamp il voto estremo non viene limitato
+		{"pochi voti non limitati", votes(1, 5, 5, 5, 1), 3.72},
+		// Con la mediana a 5 il voto 1 viene portato a 3.5
+		{"voto anomalo limitato alla mediana", votes(1, 5, 5, 5, 5, 1), 4.1},
+		{"voti indipendenti", votes(1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1), 1.83},
+		// Dieci voti dalla stessa partita valgono quanto MaxVotesPerEvent voti
+		{"voti di gruppo nella stessa partita", votes(10, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1), 2.56},
+		{"fino a MaxVotesPerEvent valutatori nessuna riduzione", votes(3, 1), 3.08},
+		{
+			name:  "voti di partite diverse",
+			votes: append(votes(1, 4, 4), votes(6, 2, 2, 2, 2, 2, 2)...),
+			// Mediana 2: i due 4 scendono a 3.5; (3.5*5 + 3.5*2 + 6*2*0.5) / (5 + 2 + 3)
+			want: 3.05,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.votes); got != tt.want {
+				t.Errorf("Score = %v, atteso %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{4}, 4},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+		{[]float64{5, 5, 1, 1}, 3},
+	}
+
+	for _, tt := range tests {
+		input := append([]float64(nil), tt.values...)
+		if got := Median(tt.values); got != tt.want {
+			t.Errorf("Median(%v) = %v, atteso %v", tt.values, got, tt.want)
+		}
+		if !reflect.DeepEqual(tt.values, input) {
+			t.Errorf("Median ha modificato i valori: %v, erano %v", tt.values, input)
+		}
+	}
+}
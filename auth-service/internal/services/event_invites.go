@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+)
+
+const (
+	// Dopo questo tempo senza risposta il destinatario riceve un (solo) promemoria
+	inviteReminderAfter = 24 * time.Hour
+	// Numero massimo di promemoria inviati a ogni esecuzione
+	inviteReminderBatchSize = 500
+)
+
+// EventInviteService chiude gli inviti scaduti e ricorda quelli senza risposta
+type EventInviteService struct {
+	eventRepo        *repositories.EventRepository
+	notificationRepo *repositories.NotificationRepository
+}
+
+// NewEventInviteService crea il servizio del ciclo di vita degli inviti
+func NewEventInviteService(eventRepo *repositories.EventRepository, notificationRepo *repositories.NotificationRepository) *EventInviteService {
+	return &EventInviteService{
+		eventRepo:        eventRepo,
+		notificationRepo: notificationRepo,
+	}
+}
+
+// Run esegue scadenze e promemoria; è pensato per essere registrato nello Scheduler
+func (eis *EventInviteService) Run(ctx context.Context) error {
+	expired, err := eis.eventRepo.ExpireEventInvites()
+	if err != nil {
+		return fmt.Errorf("errore nella scadenza degli inviti: %v", err)
+	}
+	if expired > 0 {
+		log.Printf("Event invites expired: %d", expired)
+	}
+
+	due, err := eis.eventRepo.GetDueInviteReminders(inviteReminderAfter, inviteReminderBatchSize)
+	if err != nil {
+		return fmt.Errorf("errore nel recupero dei promemoria degli inviti: %v", err)
+	}
+
+	sent := 0
+	for _, invite := range due {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Come per i promemoria delle partite, si registra prima dell'invio
+		marked, err := eis.eventRepo.MarkInviteReminded(invite.InviteID)
+		if err != nil {
+			return fmt.Errorf("errore nella registrazione del promemoria dell'invito: %v", err)
+		}
+		if !marked {
+			continue
+		}
+
+		message := fmt.Sprintf("%s aspetta ancora una tua risposta per %s (%s alle %s)", invite.SenderUsername,
+			invite.Titolo, invite.StartsAt.Format("02/01/2006"), invite.StartsAt.Format("15:04"))
+		if err := eis.notificationRepo.CreateEventInviteReminderNotification(invite.ReceiverID, invite.SenderID,
+			int64(invite.PostID), "Invito in attesa di risposta", message); err != nil {
+			log.Printf("Error sending reminder for event invite %d: %v", invite.InviteID, err)
+			continue
+		}
+		sent++
+	}
+
+	if sent > 0 {
+		log.Printf("Event invite reminders sent: %d", sent)
+	}
+	return nil
+}
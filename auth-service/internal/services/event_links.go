@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/invitelink"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/postcatalog"
+)
+
+// EventLinkService firma i link di invito alle partite e li riscatta, sia per
+// utenti già registrati sia durante la registrazione
+type EventLinkService struct {
+	eventRepo        *repositories.EventRepository
+	userRepo         *repositories.UserRepository
+	notificationRepo *repositories.NotificationRepository
+	catalog          postcatalog.PostCatalog
+	signer           *invitelink.Signer
+}
+
+// NewEventLinkService crea il servizio dei link di invito
+func NewEventLinkService(eventRepo *repositories.EventRepository, userRepo *repositories.UserRepository, notificationRepo *repositories.NotificationRepository, catalog postcatalog.PostCatalog, signer *invitelink.Signer) *EventLinkService {
+	return &EventLinkService{
+		eventRepo:        eventRepo,
+		userRepo:         userRepo,
+		notificationRepo: notificationRepo,
+		catalog:          catalog,
+		signer:           signer,
+	}
+}
+
+// Token restituisce il token firmato del link
+func (s *EventLinkService) Token(linkID int64) string {
+	return s.signer.Token(linkID)
+}
+
+// Preview restituisce l'anteprima della partita di un link valido
+func (s *EventLinkService) Preview(token string) (*models.EventLinkPreview, error) {
+	linkID, err := s.signer.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.eventRepo.GetEventLinkPreview(linkID)
+}
+
+// Redeem iscrive l'utente alla partita del link (o ne accoda la richiesta se
+// l'evento richiede approvazione) e restituisce l'ID del post e il cambio di stato
+func (s *EventLinkService) Redeem(token string, userID int64, registered bool) (int, *models.ParticipationChange, error) {
+	linkID, err := s.signer.Parse(token)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	postID, change, err := s.eventRepo.RedeemEventLink(linkID, userID, registered)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	log.Printf("Event link %d redeemed by user %d for event %d: %s", linkID, userID, postID, change.Status)
+
+	if change.Status == models.ParticipantStatusPending && change.PreviousStatus != models.ParticipantStatusPending {
+		s.notifyJoinRequest(postID, userID)
+	}
+	return postID, change, nil
+}
+
+// notifyJoinRequest avvisa l'organizzatore di una richiesta arrivata tramite link
+func (s *EventLinkService) notifyJoinRequest(postID int, requesterID int64) {
+	organizerID, err := s.eventRepo.GetEventOrganizerID(postID)
+	if err != nil {
+		log.Printf("Error getting organizer of event %d: %v", postID, err)
+		return
+	}
+
+	requesterName := "Utente sconosciuto"
+	if requester, err := s.userRepo.GetUserProfile(fmt.Sprintf("%d", requesterID)); err == nil {
+		requesterName = requester.Username
+	}
+	message := fmt.Sprintf("%s ha chiesto di partecipare all'evento tramite link di invito: %s", requesterName, postcatalog.Title(s.catalog, postID))
+	if err := s.notificationRepo.CreateEventUpdateNotification(organizerID, int64(postID), &requesterID, "Nuova richiesta di partecipazione", message); err != nil {
+		log.Printf("Error notifying join request to organizer %d: %v", organizerID, err)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Numero massimo di utenti a cui inviare un riepilogo a ogni esecuzione
+const fieldAlertBatchSize = 500
+
+// FieldAlertService avvisa chi segue un campo delle nuove partite organizzate lì,
+// raggruppandole in un riepilogo al massimo ogni MinIntervalMinutes per utente
+type FieldAlertService struct {
+	eventRepo        *repositories.EventRepository
+	notificationRepo *repositories.NotificationRepository
+	cfg              config.FieldAlertConfig
+}
+
+// NewFieldAlertService crea il servizio degli avvisi dei campi preferiti
+func NewFieldAlertService(eventRepo *repositories.EventRepository, notificationRepo *repositories.NotificationRepository, cfg config.FieldAlertConfig) *FieldAlertService {
+	return &FieldAlertService{
+		eventRepo:        eventRepo,
+		notificationRepo: notificationRepo,
+		cfg:              cfg,
+	}
+}
+
+// Run mette in coda le nuove partite e invia i riepiloghi dovuti; è pensato per
+// essere registrato nello Scheduler
+func (fas *FieldAlertService) Run(ctx context.Context) error {
+	pruned, err := fas.eventRepo.PruneFieldAlerts()
+	if err != nil {
+		return fmt.Errorf("errore nella pulizia degli avvisi dei campi: %v", err)
+	}
+	if pruned > 0 {
+		log.Printf("Field alerts pruned: %d", pruned)
+	}
+
+	queued, err := fas.eventRepo.QueueFieldAlerts(time.Duration(fas.cfg.LookbackHours) * time.Hour)
+	if err != nil {
+		return fmt.Errorf("errore nella ricerca delle nuove partite nei campi preferiti: %v", err)
+	}
+	if queued > 0 {
+		log.Printf("Field alerts queued: %d", queued)
+	}
+
+	minInterval := time.Duration(fas.cfg.MinIntervalMinutes) * time.Minute
+	due, err := fas.eventRepo.GetDueFieldAlerts(minInterval, fieldAlertBatchSize)
+	if err != nil {
+		return fmt.Errorf("errore nel recupero degli avvisi dei campi: %v", err)
+	}
+
+	sent := 0
+	for start := 0; start < len(due); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Gli avvisi sono ordinati per utente: ogni gruppo diventa un riepilogo
+		end := start + 1
+		for end < len(due) && due[end].UserID == due[start].UserID {
+			end++
+		}
+		alerts := due[start:end]
+		start = end
+
+		postIDs := make([]int, len(alerts))
+		for i, alert := range alerts {
+			postIDs[i] = alert.PostID
+		}
+
+		// Come per i promemoria delle partite, si registra prima dell'invio
+		marked, err := fas.eventRepo.MarkFieldAlertsSent(alerts[0].UserID, postIDs, minInterval)
+		if err != nil {
+			return fmt.Errorf("errore nella registrazione del riepilogo dei campi: %v", err)
+		}
+		if !marked {
+			continue
+		}
+
+		title, message, postID := fas.digest(alerts)
+		if err := fas.notificationRepo.CreateFieldAlertNotification(alerts[0].UserID, postID, title, message); err != nil {
+			log.Printf("Error sending field alerts to user %d: %v", alerts[0].UserID, err)
+			continue
+		}
+		sent++
+	}
+
+	if sent > 0 {
+		log.Printf("Field alert digests sent: %d", sent)
+	}
+	return nil
+}
+
+// digest compone il testo della notifica; con una sola partita la notifica
+// rimanda al post, altrimenti elenca le prime MaxPostsPerDigest
+func (fas *FieldAlertService) digest(alerts []models.FieldAlert) (string, string, *int64) {
+	if len(alerts) == 1 {
+		alert := alerts[0]
+		postID := int64(alert.PostID)
+		return "Nuova partita in un tuo campo preferito",
+			fmt.Sprintf("%s: %s (%s alle %s)", fieldAlertName(alert), alert.Titolo,
+				alert.StartsAt.Format("02/01/2006"), alert.StartsAt.Format("15:04")),
+			&postID
+	}
+
+	listed := len(alerts)
+	if fas.cfg.MaxPostsPerDigest > 0 && listed > fas.cfg.MaxPostsPerDigest {
+		listed = fas.cfg.MaxPostsPerDigest
+	}
+
+	lines := make([]string, 0, listed+1)
+	for _, alert := range alerts[:listed] {
+		lines = append(lines, fmt.Sprintf("%s: %s (%s alle %s)", fieldAlertName(alert), alert.Titolo,
+			alert.StartsAt.Format("02/01"), alert.StartsAt.Format("15:04")))
+	}
+	if others := len(alerts) - listed; others == 1 {
+		lines = append(lines, "e un'altra partita")
+	} else if others > 1 {
+		lines = append(lines, fmt.Sprintf("e altre %d partite", others))
+	}
+
+	return fmt.Sprintf("%d nuove partite nei tuoi campi preferiti", len(alerts)), strings.Join(lines, "\n"), nil
+}
+
+func fieldAlertName(alert models.FieldAlert) string {
+	if alert.FieldName == "" {
+		return "Campo preferito"
+	}
+	return alert.FieldName
+}
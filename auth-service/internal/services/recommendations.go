@@ -0,0 +1,109 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"trovagiocatoriAuth/internal/config"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/models"
+	"trovagiocatoriAuth/internal/recommend"
+)
+
+const (
+	// Numero massimo di partite valutate per ogni utente
+	maxRecommendationCandidates = 500
+	// Numero di consigli calcolati e tenuti in cache per ogni utente
+	MaxRecommendations = 50
+	// Oltre questo numero di utenti in cache le voci scadute vengono rimosse
+	maxCachedRecommendationUsers = 1000
+)
+
+type cachedRecommendations struct {
+	items       []models.Recommendation
+	generatedAt time.Time
+	expiresAt   time.Time
+}
+
+// RecommendationService calcola le partite consigliate e le tiene in cache per utente
+type RecommendationService struct {
+	repo *repositories.RecommendationRepository
+	cfg  config.RecommendationConfig
+
+	mu    sync.Mutex
+	cache map[int64]cachedRecommendations
+}
+
+// NewRecommendationService crea il servizio dei consigli
+func NewRecommendationService(repo *repositories.RecommendationRepository, cfg config.RecommendationConfig) *RecommendationService {
+	return &RecommendationService{
+		repo:  repo,
+		cfg:   cfg,
+		cache: make(map[int64]cachedRecommendations),
+	}
+}
+
+// Recommend restituisce fino a limit partite consigliate all'utente e l'istante
+// in cui sono state calcolate; refresh ignora la cache
+func (rs *RecommendationService) Recommend(userID int64, limit int, refresh bool) ([]models.Recommendation, time.Time, error) {
+	now := time.Now()
+
+	rs.mu.Lock()
+	entry, found := rs.cache[userID]
+	rs.mu.Unlock()
+
+	if !found || refresh || now.After(entry.expiresAt) {
+		items, err := rs.compute(userID)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		entry = cachedRecommendations{
+			items:       items,
+			generatedAt: now,
+			expiresAt:   now.Add(time.Duration(rs.cfg.CacheTTLSeconds) * time.Second),
+		}
+		rs.store(userID, entry)
+	}
+
+	items := entry.items
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, entry.generatedAt, nil
+}
+
+func (rs *RecommendationService) compute(userID int64) ([]models.Recommendation, error) {
+	profile, err := rs.repo.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := rs.repo.GetCandidates(userID, rs.cfg.HorizonDays, maxRecommendationCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := recommend.Weights{
+		Sport:    rs.cfg.SportWeight,
+		Level:    rs.cfg.LevelWeight,
+		Favorite: rs.cfg.FavoriteWeight,
+		Friends:  rs.cfg.FriendsWeight,
+		Distance: rs.cfg.DistanceWeight,
+		Capacity: rs.cfg.CapacityWeight,
+	}
+	return recommend.Rank(profile, candidates, weights, rs.cfg.MaxDistanceKm, MaxRecommendations), nil
+}
+
+func (rs *RecommendationService) store(userID int64, entry cachedRecommendations) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if len(rs.cache) >= maxCachedRecommendationUsers {
+		for cachedUserID, cached := range rs.cache {
+			if entry.generatedAt.After(cached.expiresAt) {
+				delete(rs.cache, cachedUserID)
+			}
+		}
+	}
+	rs.cache[userID] = entry
+}
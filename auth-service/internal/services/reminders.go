@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+)
+
+// matchReminder definisce un promemoria: viene inviato quando mancano al più
+// lead all'inizio della partita, ma non se ne mancano già meno di minLead
+// (in quel caso è il promemoria successivo a essere inviato)
+type matchReminder struct {
+	kind    string
+	lead    time.Duration
+	minLead time.Duration
+	title   string
+}
+
+var matchReminders = []matchReminder{
+	{kind: "24h", lead: 24 * time.Hour, minLead: 2 * time.Hour, title: "La partita è domani"},
+	{kind: "2h", lead: 2 * time.Hour, minLead: 0, title: "La partita inizia tra poco"},
+}
+
+// Numero massimo di promemoria inviati per tipo a ogni esecuzione
+const matchReminderBatchSize = 500
+
+// MatchReminderService invia i promemoria delle partite ai partecipanti confermati
+type MatchReminderService struct {
+	reminderRepo     *repositories.ReminderRepository
+	notificationRepo *repositories.NotificationRepository
+}
+
+// NewMatchReminderService crea il servizio dei promemoria partite
+func NewMatchReminderService(reminderRepo *repositories.ReminderRepository, notificationRepo *repositories.NotificationRepository) *MatchReminderService {
+	return &MatchReminderService{
+		reminderRepo:     reminderRepo,
+		notificationRepo: notificationRepo,
+	}
+}
+
+// Run invia i promemoria dovuti; è pensato per essere registrato nello Scheduler
+func (mrs *MatchReminderService) Run(ctx context.Context) error {
+	sent := 0
+	for _, reminder := range matchReminders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		due, err := mrs.reminderRepo.GetDueMatchReminders(reminder.kind, reminder.lead, reminder.minLead, matchReminderBatchSize)
+		if err != nil {
+			return fmt.Errorf("errore nel recupero dei promemoria %s: %v", reminder.kind, err)
+		}
+
+		for _, match := range due {
+			// Il promemoria viene registrato prima dell'invio: meglio perderne
+			// uno in caso di errore che inviarlo due volte
+			marked, err := mrs.reminderRepo.MarkReminderSent(match.UserID, match.PostID, match.Kind)
+			if err != nil {
+				return fmt.Errorf("errore nella registrazione del promemoria: %v", err)
+			}
+			if !marked {
+				continue
+			}
+
+			message := fmt.Sprintf("%s: %s alle %s", match.Titolo,
+				match.StartsAt.Format("02/01/2006"), match.StartsAt.Format("15:04"))
+			if err := mrs.notificationRepo.CreateMatchReminderNotification(match.UserID, int64(match.PostID), reminder.title, message); err != nil {
+				log.Printf("Error sending %s reminder to user %d for event %d: %v", match.Kind, match.UserID, match.PostID, err)
+				continue
+			}
+			sent++
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("Match reminders sent: %d", sent)
+	}
+	return nil
+}
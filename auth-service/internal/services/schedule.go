@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule calcola i prossimi orari di esecuzione di un job
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// ParseSchedule interpreta un'espressione cron a 5 campi
+// (minuto ora giorno-del-mese mese giorno-della-settimana) oppure una delle
+// forme abbreviate @hourly, @daily, @weekly, @monthly e "@every <durata>"
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("intervallo non valido in %q: %v", spec, err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("intervallo troppo breve in %q: minimo 1m", spec)
+		}
+		return intervalSchedule{interval: interval}, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("espressione cron non valida %q: attesi 5 campi", spec)
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minuto non valido in %q: %v", spec, err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("ora non valida in %q: %v", spec, err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("giorno del mese non valido in %q: %v", spec, err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("mese non valido in %q: %v", spec, err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("giorno della settimana non valido in %q: %v", spec, err)
+	}
+	// 7 è un alias di domenica
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+
+	return s, nil
+}
+
+// intervalSchedule esegue il job a intervalli fissi allineati all'epoch Unix,
+// così tutte le repliche calcolano gli stessi orari
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Truncate(s.interval).Add(s.interval)
+}
+
+// cronSchedule memorizza i valori ammessi di ogni campo come bitmask
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// Il calcolo si ferma dopo qualche anno per espressioni che non corrispondono
+// mai a una data reale (es. 30 febbraio)
+const maxScheduleIterations = 5 * 366 * 24
+
+func (s cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	loc := t.Location()
+
+	for i := 0; i < maxScheduleIterations; i++ {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches segue la semantica cron: se sono indicati sia il giorno del mese
+// sia quello della settimana basta che ne corrisponda uno dei due
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// parseCronField interpreta un campo con liste (a,b), intervalli (a-b) e passi (*/n, a-b/n)
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("passo non valido %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("valore non valido %q", part)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("valore non valido %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("valore non valido %q", part)
+			}
+			start, end = value, value
+			if step > 1 {
+				// "5/15" equivale a "5-max/15"
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("valore fuori intervallo %q (%d-%d)", part, min, max)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
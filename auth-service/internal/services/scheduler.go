@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"trovagiocatoriAuth/internal/database/repositories"
+)
+
+// JobFunc è il lavoro eseguito da un job; il contesto viene annullato allo stop dello scheduler
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      JobFunc
+}
+
+// Scheduler esegue job con nome secondo schedule in stile cron. Ogni esecuzione
+// è protetta da un advisory lock Postgres e registrata in job_runs: con più
+// repliche del servizio ogni job viene eseguito una sola volta per orario.
+type Scheduler struct {
+	jobRunRepo *repositories.JobRunRepository
+	instance   string
+	jobs       []*job
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler crea uno scheduler senza job registrati
+func NewScheduler(jobRunRepo *repositories.JobRunRepository) *Scheduler {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+	instance = fmt.Sprintf("%s-%d", instance, os.Getpid())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jobRunRepo: jobRunRepo,
+		instance:   instance,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Register aggiunge un job; va chiamato prima di Start
+func (s *Scheduler) Register(name, spec string, run JobFunc) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %v", name, err)
+	}
+
+	for _, existing := range s.jobs {
+		if existing.name == name {
+			return fmt.Errorf("job %s già registrato", name)
+		}
+	}
+
+	s.jobs = append(s.jobs, &job{name: name, spec: spec, schedule: schedule, run: run})
+	return nil
+}
+
+// Start avvia un goroutine per ogni job registrato
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+		log.Printf("Scheduler: job %s registered (%s)", j.name, j.spec)
+	}
+
+	log.Printf("Scheduler started on instance %s with %d jobs", s.instance, len(s.jobs))
+}
+
+// Stop annulla le esecuzioni in corso e attende la terminazione dei job
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Println("Scheduler stopped")
+}
+
+func (s *Scheduler) loop(j *job) {
+	defer s.wg.Done()
+
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Scheduler: job %s has no future executions, stopping", j.name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			s.execute(j, next)
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// execute esegue il job per l'orario scheduledFor se nessun'altra replica
+// lo sta eseguendo o lo ha già eseguito
+func (s *Scheduler) execute(j *job, scheduledFor time.Time) {
+	unlock, acquired, err := s.jobRunRepo.TryAdvisoryLock(s.ctx, advisoryLockKey(j.name))
+	if err != nil {
+		log.Printf("Scheduler: error acquiring lock for job %s: %v", j.name, err)
+		return
+	}
+	if !acquired {
+		log.Printf("Scheduler: job %s is running on another instance, skipped", j.name)
+		return
+	}
+	defer unlock()
+
+	runID, started, err := s.jobRunRepo.StartRun(j.name, scheduledFor, s.instance)
+	if err != nil {
+		log.Printf("Scheduler: error recording run of job %s: %v", j.name, err)
+		return
+	}
+	if !started {
+		// Un'altra replica ha già completato l'esecuzione per questo orario
+		return
+	}
+
+	startTime := time.Now()
+	runErr := s.safeRun(j)
+	duration := time.Since(startTime)
+
+	if runErr != nil {
+		log.Printf("Scheduler: job %s failed after %v: %v", j.name, duration, runErr)
+	} else {
+		log.Printf("Scheduler: job %s completed in %v", j.name, duration)
+	}
+
+	if err := s.jobRunRepo.FinishRun(runID, runErr); err != nil {
+		log.Printf("Scheduler: error recording result of job %s: %v", j.name, err)
+	}
+}
+
+// safeRun esegue il job trasformando un eventuale panic in errore,
+// così un job difettoso non ferma lo scheduler
+func (s *Scheduler) safeRun(j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.run(s.ctx)
+}
+
+// Le esecuzioni più vecchie vengono eliminate da PruneHistory
+const jobRunRetention = 30 * 24 * time.Hour
+
+// PruneHistory elimina lo storico delle esecuzioni più vecchio di 30 giorni;
+// è a sua volta un JobFunc da registrare nello scheduler
+func (s *Scheduler) PruneHistory(ctx context.Context) error {
+	deleted, err := s.jobRunRepo.DeleteRunsOlderThan(jobRunRetention)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Scheduler: %d old job runs deleted", deleted)
+	}
+	return nil
+}
+
+// advisoryLockKey deriva la chiave dell'advisory lock dal nome del job
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"trovagiocatoriAuth/internal/calendar"
+	"trovagiocatoriAuth/internal/database/repositories"
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Numero massimo di occorrenze generate per serie a ogni esecuzione
+const seriesOccurrencesPerRun = 10
+
+// SeriesService genera i post delle partite ricorrenti e iscrive il roster
+type SeriesService struct {
+	seriesRepo       *repositories.SeriesRepository
+	notificationRepo *repositories.NotificationRepository
+}
+
+// NewSeriesService crea il servizio di generazione delle serie
+func NewSeriesService(seriesRepo *repositories.SeriesRepository, notificationRepo *repositories.NotificationRepository) *SeriesService {
+	return &SeriesService{
+		seriesRepo:       seriesRepo,
+		notificationRepo: notificationRepo,
+	}
+}
+
+// Run genera le occorrenze che rientrano nell'orizzonte di ogni serie attiva;
+// è pensato per essere registrato nello Scheduler
+func (ss *SeriesService) Run(ctx context.Context) error {
+	now, err := ss.seriesRepo.LocalNow()
+	if err != nil {
+		return fmt.Errorf("errore nella lettura dell'ora del database: %v", err)
+	}
+
+	seriesList, err := ss.seriesRepo.GetActiveSeries()
+	if err != nil {
+		return fmt.Errorf("errore nel recupero delle serie: %v", err)
+	}
+
+	generated := 0
+	for i := range seriesList {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		series := &seriesList[i]
+		rule, err := calendar.ParseRRule(series.RRule)
+		if err != nil {
+			log.Printf("Series %d has an invalid rule %q: %v", series.ID, series.RRule, err)
+			continue
+		}
+
+		// Le date già passate non vengono recuperate: si riparte da adesso
+		after := series.LastOccurrenceAt
+		if now.After(after) {
+			after = now
+		}
+		horizon := now.AddDate(0, 0, series.HorizonDays)
+
+		dates := rule.Between(series.DTStart, after, horizon)
+		if len(dates) > seriesOccurrencesPerRun {
+			dates = dates[:seriesOccurrencesPerRun]
+		}
+
+		for _, startsAt := range dates {
+			occurrence, err := ss.seriesRepo.GenerateOccurrence(series, startsAt)
+			if errors.Is(err, repositories.ErrSeriesSourceMissing) {
+				// Tutti i post della serie sono stati eliminati: non c'è più nulla da copiare
+				log.Printf("Series %d has no posts left, deactivating it", series.ID)
+				if _, err := ss.seriesRepo.UpdateSeries(series.OrganizerID, series.ID, series.RRule, series.HorizonDays, false); err != nil {
+					log.Printf("Error deactivating series %d: %v", series.ID, err)
+				}
+				break
+			}
+			if err != nil {
+				log.Printf("Error generating occurrence %s of series %d: %v", startsAt.Format("2006-01-02 15:04"), series.ID, err)
+				break
+			}
+			if occurrence == nil {
+				continue
+			}
+
+			generated++
+			ss.notifyRoster(occurrence)
+		}
+	}
+
+	if generated > 0 {
+		log.Printf("Series occurrences generated: %d", generated)
+	}
+	return nil
+}
+
+// notifyRoster avvisa i giocatori iscritti automaticamente alla nuova occorrenza
+func (ss *SeriesService) notifyRoster(occurrence *models.GeneratedOccurrence) {
+	when := fmt.Sprintf("%s alle %s", occurrence.StartsAt.Format("02/01/2006"), occurrence.StartsAt.Format("15:04"))
+
+	for _, enrollment := range occurrence.Enrollments {
+		if enrollment.UserID == occurrence.OrganizerID {
+			continue
+		}
+
+		message := fmt.Sprintf("%s del %s: sei già iscritto", occurrence.Titolo, when)
+		if enrollment.Status == models.ParticipantStatusWaitlisted {
+			message = fmt.Sprintf("%s del %s: sei in lista d'attesa (posizione %d)", occurrence.Titolo, when, enrollment.WaitlistPosition)
+		}
+
+		if err := ss.notificationRepo.CreateSeriesOccurrenceNotification(enrollment.UserID, int64(occurrence.PostID),
+			occurrence.OrganizerID, "Nuova partita della serie", message); err != nil {
+			log.Printf("Error notifying series occurrence %d to user %d: %v", occurrence.PostID, enrollment.UserID, err)
+		}
+	}
+}
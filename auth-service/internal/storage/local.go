@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Percorso servito dall'auth-service per gli URL firmati dello storage locale
+const SignedMediaPath = "/media/signed/"
+
+// LocalStore salva gli oggetti su filesystem locale (o su un volume condiviso)
+type LocalStore struct {
+	root          string
+	publicBaseURL string
+	signingKey    []byte
+}
+
+func NewLocalStore(root, publicBaseURL string, signingKey []byte) (*LocalStore, error) {
+	if root == "" {
+		root = "uploads"
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("errore nella creazione della directory %s: %v", root, err)
+	}
+	return &LocalStore{
+		root:          root,
+		publicBaseURL: strings.TrimSuffix(publicBaseURL, "/"),
+		signingKey:    signingKey,
+	}, nil
+}
+
+// Put scrive il file su un temporaneo e lo rinomina per non esporre mai file parziali
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, s.info(key, stat), nil
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.info(key, stat), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL genera un URL servito da SignedMediaPath, firmato con HMAC-SHA256
+func (s *LocalStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(key, expires))
+
+	return s.publicBaseURL + SignedMediaPath + key + "?" + query.Encode(), nil
+}
+
+// VerifySignedURL controlla firma e scadenza di un URL generato da SignedURL
+func (s *LocalStore) VerifySignedURL(key, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(s.sign(key, expiresAt)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *LocalStore) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s\n%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path converte una chiave nel percorso su disco, rifiutando chiavi che escono dalla root
+func (s *LocalStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if key == "" || cleaned != key || strings.HasPrefix(key, ".") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStore) info(key string, stat os.FileInfo) *BlobInfo {
+	return &BlobInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(key)),
+		ETag:         fmt.Sprintf(`"%x-%x"`, stat.Size(), stat.ModTime().UnixNano()),
+		LastModified: stat.ModTime(),
+	}
+}
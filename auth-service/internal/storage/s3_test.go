@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testBucket    = "avatars"
+	testRegion    = "eu-south-1"
+)
+
+var testNow = time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+// fakeS3 è un bucket in memoria che verifica la firma SigV4 di ogni richiesta
+type fakeS3 struct {
+	t     *testing.T
+	store *S3Store
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newTestS3(t *testing.T) (*S3Store, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{t: t, objects: make(map[string]fakeObject)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Options{
+		Endpoint:  server.URL,
+		Bucket:    testBucket,
+		Region:    testRegion,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		Client:    server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	store.now = func() time.Time { return testNow }
+	fake.store = store
+	return store, fake
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		f.t.Errorf("lettura body: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := f.checkSignature(r, body); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+testBucket+"/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", `"etag-`+key+`"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(obj.data)))
+		w.Header().Set("ETag", `"etag-`+key+`"`)
+		w.Header().Set("Last-Modified", testNow.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		if _, ok := f.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// checkSignature ricostruisce la richiesta canonica da ciò che è arrivato al
+// server: se path o header inviati non corrispondono a quelli firmati, la
+// firma non torna
+func (f *fakeS3) checkSignature(r *http.Request, body []byte) error {
+	if r.URL.Query().Get("X-Amz-Signature") != "" {
+		return f.checkPresigned(r)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate != testNow.Format(amzDateFormat) {
+		return fmt.Errorf("x-amz-date = %q, atteso %q", amzDate, testNow.Format(amzDateFormat))
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != sha256Hex(body) {
+		return fmt.Errorf("x-amz-content-sha256 = %q, atteso %q", payloadHash, sha256Hex(body))
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		signedHeaders = "content-type;" + signedHeaders
+		canonicalHeaders = "content-type:" + contentType + "\n" + canonicalHeaders
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	want := fmt.Sprintf("%s Credential=%s/%s/%s/s3/aws4_request, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, testAccessKey, testNow.Format(amzShortFormat), testRegion, signedHeaders,
+		f.store.signature(testNow, canonicalRequest))
+	if got := r.Header.Get("Authorization"); got != want {
+		return fmt.Errorf("Authorization = %q, atteso %q", got, want)
+	}
+	return nil
+}
+
+func (f *fakeS3) checkPresigned(r *http.Request) error {
+	query := r.URL.Query()
+	signature := query.Get("X-Amz-Signature")
+	query.Del("X-Amz-Signature")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + r.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+	if want := f.store.signature(testNow, canonicalRequest); signature != want {
+		return fmt.Errorf("X-Amz-Signature = %q, atteso %q", signature, want)
+	}
+	return nil
+}
+
+func TestS3StorePutGetStatDelete(t *testing.T) {
+	store, _ := newTestS3(t)
+	ctx := context.Background()
+	key := "users/42/avatar original.jpg"
+	data := []byte("contenuto dell'immagine")
+
+	if err := store.Put(ctx, key, data, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	body, info, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("lettura Get: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Get = %q, atteso %q", got, data)
+	}
+	if info.Key != key || info.ContentType != "image/jpeg" || info.Size != int64(len(data)) {
+		t.Errorf("Get info = %+v", info)
+	}
+
+	info, err = store.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(data)) || info.ETag == "" || !info.LastModified.Equal(testNow) {
+		t.Errorf("Stat info = %+v", info)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Stat dopo Delete: err = %v, atteso ErrBlobNotFound", err)
+	}
+}
+
+func TestS3StoreNotFound(t *testing.T) {
+	store, _ := newTestS3(t)
+	ctx := context.Background()
+
+	if _, _, err := store.Get(ctx, "missing.png"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get: err = %v, atteso ErrBlobNotFound", err)
+	}
+	if _, err := store.Stat(ctx, "missing.png"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Stat: err = %v, atteso ErrBlobNotFound", err)
+	}
+	// Eliminare un oggetto inesistente non è un errore
+	if err := store.Delete(ctx, "missing.png"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
+
+func TestS3StoreInvalidKey(t *testing.T) {
+	store, _ := newTestS3(t)
+
+	for _, key := range []string{"", "/assoluta.png", "../fuori.png"} {
+		if err := store.Put(context.Background(), key, []byte("x"), ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): err = %v, atteso ErrInvalidKey", key, err)
+		}
+		if _, err := store.SignedURL(key, time.Minute); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("SignedURL(%q): err = %v, atteso ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestS3StoreSignedURL(t *testing.T) {
+	store, _ := newTestS3(t)
+	key := "users/42/avatar_thumbnail.png"
+	if err := store.Put(context.Background(), key, []byte("png"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	signed, err := store.SignedURL(key, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("URL firmato non valido: %v", err)
+	}
+	query := u.Query()
+	if got := query.Get("X-Amz-Expires"); got != "900" {
+		t.Errorf("X-Amz-Expires = %q, atteso 900", got)
+	}
+	if got := query.Get("X-Amz-Date"); got != testNow.Format(amzDateFormat) {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+	wantCredential := testAccessKey + "/" + testNow.Format(amzShortFormat) + "/" + testRegion + "/s3/aws4_request"
+	if got := query.Get("X-Amz-Credential"); got != wantCredential {
+		t.Errorf("X-Amz-Credential = %q, atteso %q", got, wantCredential)
+	}
+
+	// Il server di prova verifica la firma della query string
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatalf("GET URL firmato: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET URL firmato: status %d", resp.StatusCode)
+	}
+
+	for _, ttl := range []time.Duration{0, -time.Minute, s3MaxPresignTTL + time.Second} {
+		if _, err := store.SignedURL(key, ttl); err == nil {
+			t.Errorf("SignedURL con ttl %v: atteso errore", ttl)
+		}
+	}
+	if _, err := store.SignedURL(key, s3MaxPresignTTL); err != nil {
+		t.Errorf("SignedURL con ttl massimo: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"trovagiocatoriAuth/internal/config"
+)
+
+// Errori comuni a tutte le implementazioni di BlobStore
+var (
+	ErrBlobNotFound     = errors.New("oggetto non trovato")
+	ErrInvalidKey       = errors.New("chiave oggetto non valida")
+	ErrInvalidSignature = errors.New("firma URL non valida")
+	ErrURLExpired       = errors.New("URL scaduto")
+)
+
+// BlobInfo contiene i metadati di un oggetto salvato
+type BlobInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// BlobStore astrae il salvataggio dei file media degli utenti, così che più
+// repliche dell'auth-service possano condividere lo stesso storage
+type BlobStore interface {
+	// Put salva (o sovrascrive) un oggetto
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get restituisce il contenuto di un oggetto; il chiamante deve chiudere il reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	// Stat restituisce i metadati senza scaricare il contenuto
+	Stat(ctx context.Context, key string) (*BlobInfo, error)
+	// Delete rimuove un oggetto; non restituisce errore se l'oggetto non esiste
+	Delete(ctx context.Context, key string) error
+	// SignedURL genera un URL temporaneo per accedere a un oggetto privato
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// NewBlobStore crea lo storage configurato (local o s3)
+func NewBlobStore(cfg config.StorageConfig) (BlobStore, error) {
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		// Senza chiave condivisa gli URL firmati valgono solo su questa replica
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, err
+		}
+		log.Println("MEDIA_SIGNING_KEY not set: signed media URLs will not survive restarts or work across replicas")
+	}
+
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalStore(cfg.LocalDir, cfg.PublicBaseURL, signingKey)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	}
+	return nil, fmt.Errorf("storage backend non supportato: %s", cfg.Backend)
+}
+
+// ReadAll legge un oggetto intero in memoria
+func ReadAll(ctx context.Context, store BlobStore, key string) ([]byte, *BlobInfo, error) {
+	reader, info, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, info, nil
+}
+
+// SignedURLVerifier è implementato dagli store i cui URL firmati vengono
+// serviti dall'auth-service stesso (es. LocalStore) anziché dallo storage
+type SignedURLVerifier interface {
+	VerifySignedURL(key, expires, signature string) error
+}
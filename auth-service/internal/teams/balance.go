@@ -0,0 +1,174 @@
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// Errori restituiti durante la generazione delle squadre
+var (
+	ErrNotEnoughPlayers = errors.New("partecipanti insufficienti per il numero di squadre richiesto")
+	ErrPairTooLarge     = errors.New("i gruppi da tenere insieme superano la dimensione di una squadra")
+	ErrUnknownPlayer    = errors.New("un giocatore indicato nelle coppie non è tra i partecipanti confermati")
+)
+
+// Numero massimo di passate di miglioramento tramite scambi tra squadre
+const maxSwapPasses = 50
+
+// unit è un gruppo di giocatori da tenere nella stessa squadra (un singolo
+// giocatore oppure amici uniti tramite le coppie richieste)
+type unit struct {
+	players []models.TeamPlayer
+	skill   float64
+	jitter  float64
+}
+
+// Balance divide i giocatori in count squadre di dimensione il più possibile
+// uguale, minimizzando la differenza tra le somme delle abilità. Le coppie
+// indicate finiscono sempre nella stessa squadra. A parità di input e seed il
+// risultato è identico: un seed diverso produce un'altra suddivisione equilibrata.
+func Balance(players []models.TeamPlayer, pairs [][2]int64, count int, seed int64) ([]models.Team, error) {
+	if count < 2 || len(players) < count {
+		return nil, ErrNotEnoughPlayers
+	}
+
+	units, err := buildUnits(players, pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := (len(players) + count - 1) / count
+	for _, u := range units {
+		if len(u.players) > capacity {
+			return nil, ErrPairTooLarge
+		}
+	}
+
+	// Il seed perturba leggermente l'ordine dei gruppi con abilità simili
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(units), func(i, j int) { units[i], units[j] = units[j], units[i] })
+	for i := range units {
+		units[i].jitter = rng.Float64() - 0.5
+	}
+
+	// Prima i gruppi più numerosi, poi i più forti: il greedy funziona meglio così
+	sort.SliceStable(units, func(i, j int) bool {
+		if len(units[i].players) != len(units[j].players) {
+			return len(units[i].players) > len(units[j].players)
+		}
+		return units[i].skill+units[i].jitter > units[j].skill+units[j].jitter
+	})
+
+	assigned := make([][]unit, count)
+	sizes := make([]int, count)
+	totals := make([]float64, count)
+	for _, u := range units {
+		best := -1
+		for t := 0; t < count; t++ {
+			if sizes[t]+len(u.players) > capacity {
+				continue
+			}
+			if best == -1 || totals[t] < totals[best] || (totals[t] == totals[best] && sizes[t] < sizes[best]) {
+				best = t
+			}
+		}
+		if best == -1 {
+			return nil, ErrPairTooLarge
+		}
+		assigned[best] = append(assigned[best], u)
+		sizes[best] += len(u.players)
+		totals[best] += u.skill
+	}
+
+	improveBySwaps(assigned, totals)
+
+	teams := make([]models.Team, count)
+	for t := range assigned {
+		teams[t].Number = t + 1
+		teams[t].Players = []models.TeamPlayer{}
+		for _, u := range assigned[t] {
+			teams[t].Players = append(teams[t].Players, u.players...)
+		}
+		teams[t].TotalSkill = math.Round(totals[t]*100) / 100
+	}
+	return teams, nil
+}
+
+// buildUnits unisce i giocatori collegati dalle coppie (anche a catena) in gruppi
+func buildUnits(players []models.TeamPlayer, pairs [][2]int64) ([]unit, error) {
+	index := make(map[int64]int, len(players))
+	parent := make([]int, len(players))
+	for i, p := range players {
+		index[p.UserID] = i
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for _, pair := range pairs {
+		a, okA := index[pair[0]]
+		b, okB := index[pair[1]]
+		if !okA || !okB {
+			return nil, fmt.Errorf("%w: %d-%d", ErrUnknownPlayer, pair[0], pair[1])
+		}
+		parent[find(a)] = find(b)
+	}
+
+	byRoot := make(map[int]int)
+	var units []unit
+	for i, p := range players {
+		root := find(i)
+		pos, ok := byRoot[root]
+		if !ok {
+			pos = len(units)
+			byRoot[root] = pos
+			units = append(units, unit{})
+		}
+		units[pos].players = append(units[pos].players, p)
+		units[pos].skill += p.Skill
+	}
+	return units, nil
+}
+
+// improveBySwaps scambia gruppi della stessa dimensione tra due squadre finché
+// lo scambio riduce la differenza di abilità tra di esse
+func improveBySwaps(assigned [][]unit, totals []float64) {
+	for pass := 0; pass < maxSwapPasses; pass++ {
+		improved := false
+		for a := 0; a < len(assigned); a++ {
+			for b := a + 1; b < len(assigned); b++ {
+				for i := range assigned[a] {
+					for j := range assigned[b] {
+						ua, ub := assigned[a][i], assigned[b][j]
+						if len(ua.players) != len(ub.players) {
+							continue
+						}
+						before := math.Abs(totals[a] - totals[b])
+						delta := ub.skill - ua.skill
+						after := math.Abs((totals[a] + delta) - (totals[b] - delta))
+						if after+1e-9 < before {
+							assigned[a][i], assigned[b][j] = ub, ua
+							totals[a] += delta
+							totals[b] -= delta
+							improved = true
+						}
+					}
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package teams
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+func players(skills ...float64) []models.TeamPlayer {
+	result := make([]models.TeamPlayer, len(skills))
+	for i, skill := range skills {
+		result[i] = models.TeamPlayer{UserID: int64(i + 1), Skill: skill}
+	}
+	return result
+}
+
+// teamOf restituisce il numero della squadra di ogni giocatore, verificando che
+// ciascuno compaia una sola volta
+func teamOf(t *testing.T, teams []models.Team, count int) map[int64]int {
+	t.Helper()
+	result := make(map[int64]int)
+	for _, team := range teams {
+		for _, p := range team.Players {
+			if _, dup := result[p.UserID]; dup {
+				t.Fatalf("giocatore %d in più squadre", p.UserID)
+			}
+			result[p.UserID] = team.Number
+		}
+	}
+	if len(result) != count {
+		t.Fatalf("assegnati %d giocatori, attesi %d", len(result), count)
+	}
+	return result
+}
+
+func TestBalanceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []models.TeamPlayer
+		pairs   [][2]int64
+		count   int
+		want    error
+	}{
+		{"una sola squadra", players(5, 5, 5, 5), nil, 1, ErrNotEnoughPlayers},
+		{"meno giocatori che squadre", players(5, 5), nil, 3, ErrNotEnoughPlayers},
+		{"coppia con giocatore sconosciuto", players(5, 5, 5, 5), [][2]int64{{1, 99}}, 2, ErrUnknownPlayer},
+		// Le coppie 1-2 e 2-3 formano un gruppo di 3 su squadre da 2
+		{"gruppo a catena troppo grande", players(5, 5, 5, 5), [][2]int64{{1, 2}, {2, 3}}, 2, ErrPairTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Balance(tt.players, tt.pairs, tt.count, 1); !errors.Is(err, tt.want) {
+				t.Errorf("Balance: err = %v, atteso %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []models.TeamPlayer
+		pairs   [][2]int64
+		count   int
+		sizes   []int   // dimensioni attese, in ordine crescente
+		maxDiff float64 // differenza massima tra le abilità totali
+	}{
+		{
+			name:    "numero pari di giocatori",
+			players: players(10, 9, 8, 7, 6, 5, 4, 3),
+			count:   2,
+			sizes:   []int{4, 4},
+			maxDiff: 0,
+		},
+		{
+			name:    "squadre di dimensione diversa",
+			players: players(7, 6, 5, 4, 3, 2, 1),
+			count:   3,
+			sizes:   []int{2, 2, 3},
+			maxDiff: 3,
+		},
+		{
+			name:    "coppie tenute insieme",
+			players: players(10, 2, 5, 5, 4, 6),
+			pairs:   [][2]int64{{1, 2}, {3, 4}},
+			count:   2,
+			sizes:   []int{3, 3},
+			maxDiff: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				teams, err := Balance(tt.players, tt.pairs, tt.count, seed)
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				if len(teams) != tt.count {
+					t.Fatalf("seed %d: %d squadre, attese %d", seed, len(teams), tt.count)
+				}
+
+				assigned := teamOf(t, teams, len(tt.players))
+				for _, pair := range tt.pairs {
+					if assigned[pair[0]] != assigned[pair[1]] {
+						t.Errorf("seed %d: coppia %v divisa", seed, pair)
+					}
+				}
+
+				var sizes []int
+				low, high := math.Inf(1), math.Inf(-1)
+				for i, team := range teams {
+					if team.Number != i+1 {
+						t.Errorf("seed %d: squadra %d con numero %d", seed, i, team.Number)
+					}
+					sizes = append(sizes, len(team.Players))
+					low = math.Min(low, team.TotalSkill)
+					high = math.Max(high, team.TotalSkill)
+				}
+				sort.Ints(sizes)
+				if !reflect.DeepEqual(sizes, tt.sizes) {
+					t.Errorf("seed %d: dimensioni %v, attese %v", seed, sizes, tt.sizes)
+				}
+				if high-low > tt.maxDiff {
+					t.Errorf("seed %d: differenza di abilità %.2f, massima %.2f", seed, high-low, tt.maxDiff)
+				}
+			}
+		})
+	}
+}
+
+func TestBalanceDeterministic(t *testing.T) {
+	input := players(9, 8, 8, 7, 7, 6, 5, 5, 4, 3)
+
+	first, err := Balance(input, nil, 2, 42)
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	second, err := Balance(input, nil, 2, 42)
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("stesso seed, risultati diversi:\n%v\n%v", first, second)
+	}
+}
@@ -1,6 +1,13 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // HashPassword genera un hash della password
 func HashPassword(password string) (string, error) {
@@ -12,4 +19,30 @@ func HashPassword(password string) (string, error) {
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
-}
\ No newline at end of file
+}
+
+// Alfabeto senza caratteri ambigui (0/O, 1/I/L) per codici da digitare a mano
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// GenerateCode genera un codice casuale di n caratteri leggibile da un utente
+func GenerateCode(n int) (string, error) {
+	code := make([]byte, n)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", errors.New("impossibile generare un codice sicuro")
+		}
+		code[i] = codeAlphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
+
+// GenerateToken genera un token casuale esadecimale di n byte, adatto a URL segreti
+func GenerateToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("impossibile generare un token sicuro")
+	}
+	return hex.EncodeToString(buf), nil
+}
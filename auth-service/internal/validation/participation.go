@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+// ErrInvalidTransition indica un cambio di stato della partecipazione non consentito
+var ErrInvalidTransition = errors.New("cambio di stato della partecipazione non consentito")
+
+// participationTransitions elenca, per ogni stato, gli stati raggiungibili.
+// La chiave vuota rappresenta un utente non ancora iscritto; waitlisted non è
+// mai un obiettivo esplicito ma l'esito di una conferma su un evento al completo.
+var participationTransitions = map[string][]string{
+	"": {
+		models.ParticipantStatusConfirmed,
+		models.ParticipantStatusTentative,
+		models.ParticipantStatusDeclined,
+		models.ParticipantStatusPending,
+	},
+	models.ParticipantStatusConfirmed: {
+		models.ParticipantStatusTentative,
+		models.ParticipantStatusDeclined,
+		models.ParticipantStatusCancelled,
+		models.ParticipantStatusNoShow,
+		models.ParticipantStatusRemoved,
+	},
+	models.ParticipantStatusWaitlisted: {
+		models.ParticipantStatusConfirmed,
+		models.ParticipantStatusDeclined,
+		models.ParticipantStatusCancelled,
+		models.ParticipantStatusRemoved,
+	},
+	models.ParticipantStatusTentative: {
+		models.ParticipantStatusConfirmed,
+		models.ParticipantStatusDeclined,
+		models.ParticipantStatusCancelled,
+		models.ParticipantStatusRemoved,
+	},
+	models.ParticipantStatusDeclined: {
+		models.ParticipantStatusConfirmed,
+		models.ParticipantStatusTentative,
+		models.ParticipantStatusPending,
+	},
+	models.ParticipantStatusCancelled: {
+		models.ParticipantStatusConfirmed,
+		models.ParticipantStatusTentative,
+		models.ParticipantStatusDeclined,
+		models.ParticipantStatusPending,
+	},
+	// Solo l'organizzatore può correggere un no-show, con MarkAttendance
+	models.ParticipantStatusNoShow: {},
+	// Richieste di partecipazione agli eventi con approvazione
+	models.ParticipantStatusPending: {
+		models.ParticipantStatusConfirmed,
+		models.ParticipantStatusRejected,
+		models.ParticipantStatusCancelled,
+		models.ParticipantStatusRemoved,
+	},
+	// Una richiesta rifiutata può essere approvata in seguito dall'organizzatore
+	models.ParticipantStatusRejected: {
+		models.ParticipantStatusConfirmed,
+	},
+	models.ParticipantStatusRemoved: {},
+}
+
+// IsJoinPolicy indica se la politica di iscrizione è tra quelle supportate
+func IsJoinPolicy(policy string) bool {
+	switch policy {
+	case models.JoinPolicyOpen, models.JoinPolicyApproval, models.JoinPolicyFriendsOnly, models.JoinPolicyInviteOnly:
+		return true
+	}
+	return false
+}
+
+// IsParticipationStatus indica se lo stato è uno di quelli gestiti
+func IsParticipationStatus(status string) bool {
+	_, ok := participationTransitions[status]
+	return ok && status != ""
+}
+
+// ValidateParticipationTransition verifica che il passaggio from -> to sia consentito
+func ValidateParticipationTransition(from, to string) error {
+	for _, allowed := range participationTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+
+	if from == "" {
+		return fmt.Errorf("%w: impossibile passare a %s senza essere iscritti", ErrInvalidTransition, to)
+	}
+	return fmt.Errorf("%w: da %s a %s", ErrInvalidTransition, from, to)
+}
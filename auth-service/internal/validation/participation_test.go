@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"trovagiocatoriAuth/internal/models"
+)
+
+func TestValidateParticipationTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		// Iscrizione
+		{"", models.ParticipantStatusConfirmed, true},
+		{"", models.ParticipantStatusTentative, true},
+		{"", models.ParticipantStatusDeclined, true},
+		{"", models.ParticipantStatusPending, true},
+		{"", models.ParticipantStatusWaitlisted, false},
+		{"", models.ParticipantStatusCancelled, false},
+		{"", models.ParticipantStatusNoShow, false},
+		{"", models.ParticipantStatusRemoved, false},
+
+		{models.ParticipantStatusConfirmed, models.ParticipantStatusCancelled, true},
+		{models.ParticipantStatusConfirmed, models.ParticipantStatusNoShow, true},
+		{models.ParticipantStatusConfirmed, models.ParticipantStatusRemoved, true},
+		{models.ParticipantStatusConfirmed, models.ParticipantStatusConfirmed, false},
+		{models.ParticipantStatusConfirmed, models.ParticipantStatusPending, false},
+
+		{models.ParticipantStatusWaitlisted, models.ParticipantStatusConfirmed, true},
+		{models.ParticipantStatusWaitlisted, models.ParticipantStatusTentative, false},
+		{models.ParticipantStatusTentative, models.ParticipantStatusConfirmed, true},
+		{models.ParticipantStatusTentative, models.ParticipantStatusNoShow, false},
+
+		// Chi si è disiscritto o ha declinato può tornare
+		{models.ParticipantStatusDeclined, models.ParticipantStatusConfirmed, true},
+		{models.ParticipantStatusDeclined, models.ParticipantStatusCancelled, false},
+		{models.ParticipantStatusCancelled, models.ParticipantStatusPending, true},
+		{models.ParticipantStatusCancelled, models.ParticipantStatusCancelled, false},
+
+		// Richieste di partecipazione
+		{models.ParticipantStatusPending, models.ParticipantStatusConfirmed, true},
+		{models.ParticipantStatusPending, models.ParticipantStatusRejected, true},
+		{models.ParticipantStatusPending, models.ParticipantStatusCancelled, true},
+		{models.ParticipantStatusPending, models.ParticipantStatusTentative, false},
+		{models.ParticipantStatusRejected, models.ParticipantStatusConfirmed, true},
+		{models.ParticipantStatusRejected, models.ParticipantStatusPending, false},
+
+		// Stati finali per il partecipante
+		{models.ParticipantStatusNoShow, models.ParticipantStatusConfirmed, false},
+		{models.ParticipantStatusRemoved, models.ParticipantStatusConfirmed, false},
+		{models.ParticipantStatusRemoved, models.ParticipantStatusPending, false},
+
+		{"sconosciuto", models.ParticipantStatusConfirmed, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateParticipationTransition(tt.from, tt.to)
+		if tt.allowed && err != nil {
+			t.Errorf("%q -> %q: %v, atteso consentito", tt.from, tt.to, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%q -> %q: err = %v, atteso ErrInvalidTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestIsParticipationStatus(t *testing.T) {
+	tests := map[string]bool{
+		models.ParticipantStatusConfirmed:  true,
+		models.ParticipantStatusWaitlisted: true,
+		models.ParticipantStatusTentative:  true,
+		models.ParticipantStatusDeclined:   true,
+		models.ParticipantStatusCancelled:  true,
+		models.ParticipantStatusNoShow:     true,
+		models.ParticipantStatusPending:    true,
+		models.ParticipantStatusRejected:   true,
+		models.ParticipantStatusRemoved:    true,
+		"":                                 false,
+		"all":                              false,
+		"CONFIRMED":                        false,
+	}
+
+	for status, want := range tests {
+		if got := IsParticipationStatus(status); got != want {
+			t.Errorf("IsParticipationStatus(%q) = %v, atteso %v", status, got, want)
+		}
+	}
+}
+
+func TestIsJoinPolicy(t *testing.T) {
+	tests := map[string]bool{
+		models.JoinPolicyOpen:        true,
+		models.JoinPolicyApproval:    true,
+		models.JoinPolicyFriendsOnly: true,
+		models.JoinPolicyInviteOnly:  true,
+		"":                           false,
+		"closed":                     false,
+	}
+
+	for policy, want := range tests {
+		if got := IsJoinPolicy(policy); got != want {
+			t.Errorf("IsJoinPolicy(%q) = %v, atteso %v", policy, got, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package validation
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codici di errore machine-readable restituiti al client
+const (
+	CodeRequired         = "required"
+	CodeEmailInvalid     = "email_invalid"
+	CodeEmailDisposable  = "email_disposable"
+	CodeEmailTaken       = "email_taken"
+	CodeUsernameTooShort = "username_too_short"
+	CodeUsernameTooLong  = "username_too_long"
+	CodeUsernameCharset  = "username_invalid_chars"
+	CodeUsernameReserved = "username_reserved"
+	CodeUsernameTaken    = "username_taken"
+	CodeFieldTooLong     = "field_too_long"
+)
+
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 30
+	EmailMaxLength    = 254
+	NameMaxLength     = 100
+)
+
+// FieldError descrive un errore di validazione su un singolo campo
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Lo username deve iniziare con una lettera o un numero e può contenere solo . _ -
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Nomi riservati che non possono essere usati come username (confronto case-insensitive)
+var reservedUsernames = map[string]bool{
+	"admin":          true,
+	"administrator":  true,
+	"amministratore": true,
+	"root":           true,
+	"system":         true,
+	"sistema":        true,
+	"support":        true,
+	"supporto":       true,
+	"moderator":      true,
+	"moderatore":     true,
+	"staff":          true,
+	"trovagiocatori": true,
+	"api":            true,
+	"null":           true,
+	"undefined":      true,
+	"me":             true,
+	"profile":        true,
+}
+
+// Domini di email temporanee bloccati in registrazione
+var disposableDomains = map[string]bool{
+	"mailinator.com":         true,
+	"guerrillamail.com":      true,
+	"guerrillamail.net":      true,
+	"10minutemail.com":       true,
+	"tempmail.com":           true,
+	"temp-mail.org":          true,
+	"throwawaymail.com":      true,
+	"yopmail.com":            true,
+	"trashmail.com":          true,
+	"getnada.com":            true,
+	"dispostable.com":        true,
+	"maildrop.cc":            true,
+	"sharklasers.com":        true,
+	"fakeinbox.com":          true,
+	"mintemail.com":          true,
+	"mohmal.com":             true,
+	"emailondeck.com":        true,
+	"spamgourmet.com":        true,
+	"mytemp.email":           true,
+	"burnermail.io":          true,
+	"discard.email":          true,
+	"tempr.email":            true,
+	"moakt.com":              true,
+	"inboxkitten.com":        true,
+	"mailnesia.com":          true,
+	"mailcatch.com":          true,
+	"anonaddy.me":            true,
+	"33mail.com":             true,
+	"grr.la":                 true,
+	"guerrillamailblock.com": true,
+}
+
+// ValidateEmail verifica la sintassi dell'email e blocca i domini usa-e-getta
+func ValidateEmail(email string) *FieldError {
+	if email == "" {
+		return &FieldError{Field: "email", Code: CodeRequired, Message: "L'email è obbligatoria"}
+	}
+	if len(email) > EmailMaxLength {
+		return &FieldError{Field: "email", Code: CodeEmailInvalid, Message: "L'email è troppo lunga"}
+	}
+
+	// ParseAddress accetta anche forme come "Nome <a@b.it>": richiediamo l'indirizzo nudo
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return &FieldError{Field: "email", Code: CodeEmailInvalid, Message: "Formato email non valido"}
+	}
+
+	at := strings.LastIndex(email, "@")
+	domain := strings.ToLower(email[at+1:])
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return &FieldError{Field: "email", Code: CodeEmailInvalid, Message: "Dominio email non valido"}
+	}
+
+	if isDisposableDomain(domain) {
+		return &FieldError{Field: "email", Code: CodeEmailDisposable, Message: "Gli indirizzi email temporanei non sono ammessi"}
+	}
+
+	return nil
+}
+
+// ValidateUsername verifica lunghezza, caratteri ammessi e nomi riservati
+func ValidateUsername(username string) *FieldError {
+	if username == "" {
+		return &FieldError{Field: "username", Code: CodeRequired, Message: "Lo username è obbligatorio"}
+	}
+
+	length := utf8.RuneCountInString(username)
+	if length < UsernameMinLength {
+		return &FieldError{Field: "username", Code: CodeUsernameTooShort, Message: "Lo username deve avere almeno 3 caratteri"}
+	}
+	if length > UsernameMaxLength {
+		return &FieldError{Field: "username", Code: CodeUsernameTooLong, Message: "Lo username può avere al massimo 30 caratteri"}
+	}
+
+	if !usernamePattern.MatchString(username) {
+		return &FieldError{Field: "username", Code: CodeUsernameCharset, Message: "Lo username può contenere solo lettere, numeri, punto, trattino e underscore"}
+	}
+
+	if reservedUsernames[strings.ToLower(username)] {
+		return &FieldError{Field: "username", Code: CodeUsernameReserved, Message: "Questo username è riservato"}
+	}
+
+	return nil
+}
+
+// ValidateRegistration valida tutti i campi del form di registrazione
+// e restituisce l'elenco completo degli errori trovati
+func ValidateRegistration(nome, cognome, username, email, password string) []FieldError {
+	var errs []FieldError
+
+	for _, f := range []struct{ field, value string }{
+		{"nome", nome},
+		{"cognome", cognome},
+		{"password", password},
+	} {
+		if f.value == "" {
+			errs = append(errs, FieldError{Field: f.field, Code: CodeRequired, Message: "Campo obbligatorio"})
+		} else if f.field != "password" && utf8.RuneCountInString(f.value) > NameMaxLength {
+			errs = append(errs, FieldError{Field: f.field, Code: CodeFieldTooLong, Message: "Campo troppo lungo"})
+		}
+	}
+
+	if err := ValidateUsername(username); err != nil {
+		errs = append(errs, *err)
+	}
+	if err := ValidateEmail(email); err != nil {
+		errs = append(errs, *err)
+	}
+
+	return errs
+}
+
+// isDisposableDomain controlla il dominio e i suoi domini padre (es. x.mailinator.com)
+func isDisposableDomain(domain string) bool {
+	for {
+		if disposableDomains[domain] {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
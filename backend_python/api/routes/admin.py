@@ -5,7 +5,7 @@ from datetime import datetime
 from database.connection import get_db
 from database.models import Post, Comment, SportField
 from api.dependencies import verify_admin_user
-from services.post import get_participants_count
+from services.post import get_participants_count, cancel_event, EventCancellationError
 from config.settings import settings
 import requests
 import logging
@@ -14,8 +14,8 @@ logger = logging.getLogger(__name__)
 router = APIRouter(prefix="/admin", tags=["Amministrazione"])
 
 @router.delete("/posts/{post_id}")
-def delete_post(post_id: int, request: Request, db: Session = Depends(get_db)):
-    "Elimina un post (solo amministratori)"
+def delete_post(post_id: int, request: Request, reason: str = "", db: Session = Depends(get_db)):
+    "Elimina un post (solo amministratori) e annulla il relativo evento"
     admin_email = verify_admin_user(request)
     
     # Trova il post
@@ -25,6 +25,16 @@ def delete_post(post_id: int, request: Request, db: Session = Depends(get_db)):
     
     logger.info(f"Eliminazione post {post_id}: '{post.titolo}' di {post.autore_email}")
     
+    # Prima si annulla l'evento: se non riesce il post resta, così iscritti e
+    # invitati non restano legati a un evento che non esiste più
+    try:
+        cancel_event(post, reason=reason.strip(), source="admin", cancelled_by_email=admin_email)
+    except EventCancellationError:
+        raise HTTPException(
+            status_code=502,
+            detail="Annullamento dell'evento non riuscito, il post non è stato eliminato"
+        )
+    
     # Elimina il post (i commenti vengono eliminati automaticamente per cascade)
     db.delete(post)
     db.commit()
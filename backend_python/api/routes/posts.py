@@ -105,33 +105,13 @@ def get_post(post_id: int, db: Session = Depends(get_db)):
 @router.get("/{post_id}/participants-count")
 def get_post_participants_count(post_id: int):
     """Ottiene il numero di partecipanti iscritti a un evento"""
-    try:
-        response = requests.get(f"{settings.AUTH_SERVICE_URL}/events/{post_id}/participants", timeout=5)
-        if response.status_code == 200:
-            data = response.json()
-            participants = data.get("participants", [])
-            
-            return {
-                "success": True,
-                "post_id": post_id,
-                "count": len(participants), 
-                "participants": participants
-            }
-        else:
-            return {
-                "success": False,
-                "post_id": post_id,
-                "count": 0,  
-                "participants": []
-            }
-    except Exception as e:
-        logger.error(f"Errore nel recupero partecipanti per post {post_id}: {e}")
-        return {
-            "success": False,
-            "post_id": post_id,
-            "count": 0,  
-            "participants": []
-        }
+    # La lista dei partecipanti è soggetta alla privacy di ciascuno e va
+    # richiesta all'auth-service con la sessione dell'utente
+    return {
+        "success": True,
+        "post_id": post_id,
+        "count": get_participants_count(post_id)
+    }
 
 @router.get("/{post_id}/availability")
 def get_post_availability(post_id: int, db: Session = Depends(get_db)):
@@ -159,24 +139,12 @@ def get_post_with_participants(post_id: int, db: Session = Depends(get_db)):
     if not post:
         raise HTTPException(status_code=404, detail="Post non trovato")
     
-    # Ottieni informazioni sui partecipanti
-    try:
-        response = requests.get(f"{settings.AUTH_SERVICE_URL}/events/{post_id}/participants", timeout=5)
-        if response.status_code == 200:
-            participants_data = response.json()
-            participants = participants_data.get("participants", [])
-            participants_count = participants_data.get("count", 0)
-        else:
-            participants = []
-            participants_count = 0
-    except Exception as e:
-        logger.error(f"Errore nel recupero partecipanti: {e}")
-        participants = []
-        participants_count = 0
+    # Solo il conteggio: la lista dei partecipanti rispetta la privacy di ciascuno
+    # e va richiesta all'auth-service con la sessione dell'utente
+    participants_count = get_participants_count(post_id)
     
     return {
         "post": post,
-        "participants": participants,
         "participants_count": participants_count,
         "posti_disponibili": max(0, post.numero_giocatori - participants_count),
         "is_full": participants_count >= post.numero_giocatori
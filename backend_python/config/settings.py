@@ -18,6 +18,7 @@ class Settings:
     
     # Auth Service
     AUTH_SERVICE_URL: str = "http://auth-service:8080"
+    INTERNAL_API_TOKEN: str = os.getenv("INTERNAL_API_TOKEN", "")
     
     # Socket.IO
     SOCKETIO_PATH: str = "/ws/socket.io"
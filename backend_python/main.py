@@ -1,10 +1,13 @@
 from fastapi import FastAPI, Depends
+from fastapi.responses import JSONResponse
 import socketio
 from database.connection import engine, get_db, Base
 from database.models import *
 from api.routes import posts, comments, fields, admin
 from chat.socketio_app import sio
 from services.field import load_sport_fields
+from services.post import ParticipantsCountError
+from config.settings import settings
 import logging
 
 # Configurazione logging
@@ -26,10 +29,18 @@ app.include_router(posts.router)
 app.include_router(comments.router)
 app.include_router(admin.router)
 
+@app.exception_handler(ParticipantsCountError)
+async def participants_count_error_handler(request, exc):
+    "Senza il conteggio dei partecipanti disponibilità e posti liberi non sono affidabili"
+    return JSONResponse(status_code=503, content={"detail": "Conteggio partecipanti non disponibile, riprova più tardi"})
+
 @app.on_event("startup")
 async def startup_event():
     "Inizializzazione dell'applicazione"
 
+    if not settings.INTERNAL_API_TOKEN:
+        logger.error("INTERNAL_API_TOKEN non impostato: i conteggi dei partecipanti non saranno disponibili")
+
     # Carica i campi sportivi
     db = next(get_db())
     try:
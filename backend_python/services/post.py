@@ -4,20 +4,75 @@ import logging
 
 logger = logging.getLogger(__name__)
 
+class ParticipantsCountError(Exception):
+    "Il conteggio dei partecipanti non è disponibile (auth-service non raggiungibile o token errato)"
+
 def get_participants_count(post_id: int) -> int:
-    "Ottiene il numero di partecipanti per un evento"
+    """Ottiene il numero di partecipanti per un evento.
+
+    Solleva ParticipantsCountError se l'auth-service non risponde correttamente:
+    restituire 0 farebbe sembrare ogni evento vuoto e mai al completo."""
     try:
         response = requests.get(
-            f"{settings.AUTH_SERVICE_URL}/events/{post_id}/participants", 
+            f"{settings.AUTH_SERVICE_URL}/internal/events/participants/count",
+            params={"post_id": post_id},
+            headers={"X-Internal-Token": settings.INTERNAL_API_TOKEN},
             timeout=3
         )
-        if response.status_code == 200:
-            data = response.json()
-            return data.get("count", 0)
-        return 0
-    except Exception as e:
-        logger.error(f"Errore nel recupero partecipanti per post {post_id}: {e}")
-        return 0
+    except requests.RequestException as e:
+        logger.error(f"Auth-service non raggiungibile per il conteggio del post {post_id}: {e}")
+        raise ParticipantsCountError(str(e)) from e
+
+    if response.status_code in (401, 403):
+        logger.error(
+            f"Conteggio partecipanti del post {post_id} rifiutato (stato {response.status_code}): "
+            "verificare che INTERNAL_API_TOKEN sia uguale nei due servizi"
+        )
+        raise ParticipantsCountError(f"stato {response.status_code}")
+    if response.status_code != 200:
+        logger.error(f"Errore dell'auth-service nel conteggio del post {post_id}: stato {response.status_code}")
+        raise ParticipantsCountError(f"stato {response.status_code}")
+
+    return response.json().get("count", 0)
+
+class EventCancellationError(Exception):
+    "L'auth-service non ha annullato l'evento del post"
+
+def cancel_event(post, reason: str = "", source: str = "author", cancelled_by_email: str = "") -> dict:
+    """Annulla l'evento di un post sull'auth-service: iscrizioni, inviti e preferiti
+    vengono rimossi e gli interessati ricevono una notifica.
+
+    Va chiamata prima di eliminare il post, così l'archivio conserva titolo e data.
+    L'annullamento è idempotente; solleva EventCancellationError se non riesce."""
+    try:
+        response = requests.post(
+            f"{settings.AUTH_SERVICE_URL}/internal/events/cancel",
+            json={
+                "post_id": post.id,
+                "author_email": post.autore_email,
+                "titolo": post.titolo,
+                "reason": reason,
+                "source": source,
+                "cancelled_by_email": cancelled_by_email,
+            },
+            headers={"X-Internal-Token": settings.INTERNAL_API_TOKEN},
+            timeout=10
+        )
+    except requests.RequestException as e:
+        logger.error(f"Auth-service non raggiungibile per l'annullamento del post {post.id}: {e}")
+        raise EventCancellationError(str(e)) from e
+
+    if response.status_code in (401, 403):
+        logger.error(
+            f"Annullamento dell'evento del post {post.id} rifiutato (stato {response.status_code}): "
+            "verificare che INTERNAL_API_TOKEN sia uguale nei due servizi"
+        )
+        raise EventCancellationError(f"stato {response.status_code}")
+    if response.status_code != 200:
+        logger.error(f"Errore dell'auth-service nell'annullamento del post {post.id}: stato {response.status_code}")
+        raise EventCancellationError(f"stato {response.status_code}")
+
+    return response.json().get("cancellation", {})
 
 def enrich_post_with_participants(post) -> dict:
     "Arricchisce un post con informazioni sui partecipanti"